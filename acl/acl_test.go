@@ -0,0 +1,226 @@
+package acl
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func args(s string) [][]byte {
+	var out [][]byte
+	for _, f := range strings.Fields(s) {
+		out = append(out, []byte(f))
+	}
+	return out
+}
+
+func TestDefaultUser(t *testing.T) {
+	s, err := Load("", "")
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if !s.DefaultAuthenticated() {
+		t.Error("default user should be open without a password")
+	}
+	if err := s.Check(DefaultUser, args("set k v")); err != nil {
+		t.Errorf("default user denied: %v", err)
+	}
+
+	s, _ = Load("", "secret")
+	if s.DefaultAuthenticated() {
+		t.Error("default user should require AUTH once a password is set")
+	}
+	if !s.Authenticate(DefaultUser, "secret") || s.Authenticate(DefaultUser, "wrong") {
+		t.Error("password check failed")
+	}
+}
+
+func TestSetUserRulesAndChecks(t *testing.T) {
+	s, _ := Load("", "")
+	if err := s.SetUser("alice", []string{"on", ">pw", "~app:*", "+@read", "+set", "-hgetall"}); err != nil {
+		t.Fatalf("SetUser failed: %v", err)
+	}
+	if !s.Authenticate("alice", "pw") || s.Authenticate("alice", "nope") {
+		t.Error("alice password check failed")
+	}
+
+	cases := []struct {
+		cmd     string
+		allowed bool
+	}{
+		{"get app:1", true},
+		{"set app:1 v", true},
+		{"get other", false},
+		{"hgetall app:h", false},
+		{"del app:1", false},
+		{"exists app:1 app:2", true},
+		{"exists app:1 secret", false},
+		{"acl list", false},
+		{"unknowncmd", false},
+	}
+	for _, c := range cases {
+		err := s.Check("alice", args(c.cmd))
+		if (err == nil) != c.allowed {
+			t.Errorf("%q: allowed=%v, err=%v", c.cmd, c.allowed, err)
+		}
+	}
+	if err, ok := s.Check("alice", args("get other")).(*PermissionError); !ok || err.Key != "other" {
+		t.Errorf("expected key permission error, got %v", err)
+	}
+
+	u, _ := s.GetUser("alice")
+	if got := u.Describe(); got != "user alice on #"+HashPassword("pw")+" ~app:* -@all +@read +set -hgetall" {
+		t.Errorf("describe = %q", got)
+	}
+
+	if err := s.SetUser("alice", []string{"off"}); err != nil {
+		t.Fatal(err)
+	}
+	if s.Authenticate("alice", "pw") || s.Check("alice", args("get app:1")) == nil {
+		t.Error("disabled user still usable")
+	}
+	if err := s.SetUser("alice", []string{"+@nosuch"}); err == nil {
+		t.Error("expected error for unknown category")
+	}
+}
+
+func TestDelUserAndPersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users.json")
+	s, _ := Load(path, "")
+	s.SetUser("bob", []string{"on", "nopass", "allkeys", "allcommands"})
+	if _, err := s.DelUser(DefaultUser); err == nil {
+		t.Error("default user must not be deletable")
+	}
+
+	reloaded, err := Load(path, "")
+	if err != nil {
+		t.Fatalf("reload failed: %v", err)
+	}
+	if got := strings.Join(reloaded.Users(), ","); got != "bob,default" {
+		t.Errorf("users after reload = %s", got)
+	}
+	if n, _ := reloaded.DelUser("bob", "ghost"); n != 1 {
+		t.Errorf("deleted %d users, want 1", n)
+	}
+}
+
+func TestReplicatedChangesConverge(t *testing.T) {
+	a, _ := Load("", "")
+	a.SetReplicaID("a")
+	b, _ := Load("", "")
+	b.SetReplicaID("b")
+
+	a.SetUser("carol", []string{"on", ">pw", "~*", "+@all"})
+	_, set := a.Changes()
+	b.SetUser("carol", []string{"on", ">other", "~*", "+@read"})
+	_, reset := b.Changes()
+	a.DelUser("carol")
+	_, deleted := a.Changes()
+
+	// Each store gets every change, in a different order and some twice
+	var all []UserChange
+	for _, changes := range [][]UserChange{set, reset, deleted} {
+		all = append(all, changes...)
+	}
+	for i := len(all) - 1; i >= 0; i-- {
+		if err := a.ApplyChange(all[i]); err != nil {
+			t.Fatalf("ApplyChange failed: %v", err)
+		}
+	}
+	for _, change := range append(all, all...) {
+		if err := b.ApplyChange(change); err != nil {
+			t.Fatalf("ApplyChange failed: %v", err)
+		}
+	}
+	for _, s := range []*Store{a, b} {
+		if _, ok := s.GetUser("carol"); ok {
+			t.Error("the later DELUSER should win")
+		}
+	}
+
+	// A stale SETUSER does not bring the user back, and survives a reload
+	dir := t.TempDir()
+	c, _ := Load(filepath.Join(dir, "users.json"), "")
+	for _, change := range deleted {
+		c.ApplyChange(change)
+	}
+	c, _ = Load(filepath.Join(dir, "users.json"), "")
+	for _, change := range set {
+		c.ApplyChange(change)
+	}
+	if _, ok := c.GetUser("carol"); ok {
+		t.Error("a SETUSER older than the DELUSER recreated the user")
+	}
+
+	// A local change after a replicated one wins even if the clock is behind
+	c.SetReplicaID("c")
+	c.now = func() time.Time { return time.Unix(0, 1) }
+	c.SetUser("carol", []string{"on", "nopass"})
+	_, changes := c.Changes()
+	for _, change := range changes {
+		a.ApplyChange(change)
+	}
+	if !a.Authenticate("carol", "") {
+		t.Error("the recreated user did not replicate")
+	}
+}
+
+func TestHandleChanges(t *testing.T) {
+	remote, _ := Load("", "")
+	ts := httptest.NewServer(httpHandler(remote))
+	defer ts.Close()
+
+	local, _ := Load("", "")
+	local.SetReplicaID("local")
+	before, _, _ := local.ReplicationState()
+	local.SetUser("carol", []string{"on", ">pw", "~*", "+@all"})
+	generation, data, err := local.ReplicationState()
+	if err != nil || generation <= before {
+		t.Fatalf("ReplicationState = %d, %v; want a generation after %d", generation, err, before)
+	}
+	for i := 0; i < 2; i++ {
+		resp, err := http.Post(ts.URL+"/acl", "application/json", bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("POST /acl failed: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("POST /acl returned %d", resp.StatusCode)
+		}
+	}
+	if !remote.Authenticate("carol", "pw") {
+		t.Fatal("user change was not applied")
+	}
+}
+
+func TestMatchPattern(t *testing.T) {
+	cases := []struct {
+		pattern, s string
+		want       bool
+	}{
+		{"*", "anything", true},
+		{"app:*", "app:1", true},
+		{"app:*", "web:1", false},
+		{"h?llo", "hello", true},
+		{"h[ae]llo", "hallo", true},
+		{"h[^e]llo", "hello", false},
+		{"k[a-c]", "kb", true},
+		{`a\*`, "a*", true},
+		{`a\*`, "ab", false},
+	}
+	for _, c := range cases {
+		if got := MatchPattern(c.pattern, c.s); got != c.want {
+			t.Errorf("MatchPattern(%q, %q) = %v, want %v", c.pattern, c.s, got, c.want)
+		}
+	}
+}
+
+func httpHandler(s *Store) *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/acl", s.HandleChanges)
+	return mux
+}
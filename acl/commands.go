@@ -0,0 +1,266 @@
+package acl
+
+import "strings"
+
+// CommandSpec describes a command for permission checks: its ACL categories
+// and which arguments are keys (FirstKey..LastKey by Step, LastKey -1 meaning
+// the last argument; FirstKey 0 means the command takes no keys)
+type CommandSpec struct {
+	Categories []string
+	FirstKey   int
+	LastKey    int
+	Step       int
+}
+
+// categories are the ACL categories understood by +@ and -@ rules
+var categories = []string{
+	"all", "read", "write", "keyspace", "string", "list", "set", "hash", "sortedset",
+	"fast", "slow", "admin", "dangerous", "connection", "pubsub",
+}
+
+func isCategory(name string) bool {
+	for _, c := range categories {
+		if c == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Categories returns the ACL category names
+func Categories() []string {
+	return append([]string(nil), categories...)
+}
+
+func spec(key int, cats ...string) CommandSpec {
+	if key == 0 {
+		return CommandSpec{Categories: cats}
+	}
+	return CommandSpec{Categories: cats, FirstKey: 1, LastKey: key, Step: 1}
+}
+
+// commandTable lists the commands served by the Redis listener. Commands not
+// in the table are only permitted to users holding +@all.
+var commandTable = map[string]CommandSpec{
+	"ping":      spec(0, "fast", "connection"),
+	"echo":      spec(0, "fast", "connection"),
+	"auth":      spec(0, "fast", "connection"),
+	"info":      spec(0, "slow", "dangerous"),
+	"acl":       spec(0, "admin", "slow", "dangerous"),
+	"crdt.peer": spec(0, "admin", "slow", "dangerous"),
+	"replicaof": spec(0, "admin", "slow", "dangerous"),
+	"slaveof":   spec(0, "admin", "slow", "dangerous"),
+	"backup":    spec(0, "admin", "slow", "dangerous"),
+	"save":      spec(0, "admin", "slow", "dangerous"),
+	"bgsave":    spec(0, "admin", "slow", "dangerous"),
+	"lastsave":  spec(0, "fast", "dangerous"),
+	"config":    spec(0, "admin", "slow", "dangerous"),
+
+	"get":         spec(1, "read", "string", "fast"),
+	"set":         spec(1, "write", "string", "slow"),
+	"getdel":      spec(1, "write", "string", "fast"),
+	"incr":        spec(1, "write", "string", "fast"),
+	"incrby":      spec(1, "write", "string", "fast"),
+	"decr":        spec(1, "write", "string", "fast"),
+	"decrby":      spec(1, "write", "string", "fast"),
+	"incrbyfloat": spec(1, "write", "string", "fast"),
+
+	"exists":    spec(-1, "read", "keyspace", "fast"),
+	"del":       spec(-1, "write", "keyspace", "slow"),
+	"ttl":       spec(1, "read", "keyspace", "fast"),
+	"pttl":      spec(1, "read", "keyspace", "fast"),
+	"expire":    spec(1, "write", "keyspace", "fast"),
+	"pexpire":   spec(1, "write", "keyspace", "fast"),
+	"expireat":  spec(1, "write", "keyspace", "fast"),
+	"pexpireat": spec(1, "write", "keyspace", "fast"),
+
+	"lpush":   spec(1, "write", "list", "fast"),
+	"rpush":   spec(1, "write", "list", "fast"),
+	"lpop":    spec(1, "write", "list", "fast"),
+	"rpop":    spec(1, "write", "list", "fast"),
+	"lset":    spec(1, "write", "list", "slow"),
+	"linsert": spec(1, "write", "list", "slow"),
+	"ltrim":   spec(1, "write", "list", "slow"),
+	"lrem":    spec(1, "write", "list", "slow"),
+	"lrange":  spec(1, "read", "list", "slow"),
+	"llen":    spec(1, "read", "list", "fast"),
+	"lindex":  spec(1, "read", "list", "slow"),
+
+	"sadd":      spec(1, "write", "set", "fast"),
+	"srem":      spec(1, "write", "set", "fast"),
+	"smembers":  spec(1, "read", "set", "slow"),
+	"scard":     spec(1, "read", "set", "fast"),
+	"sismember": spec(1, "read", "set", "fast"),
+
+	"hset":         spec(1, "write", "hash", "fast"),
+	"hdel":         spec(1, "write", "hash", "fast"),
+	"hincrby":      spec(1, "write", "hash", "fast"),
+	"hincrbyfloat": spec(1, "write", "hash", "fast"),
+	"hget":         spec(1, "read", "hash", "fast"),
+	"hlen":         spec(1, "read", "hash", "fast"),
+	"hgetall":      spec(1, "read", "hash", "slow"),
+
+	"zadd":          spec(1, "write", "sortedset", "fast"),
+	"zrem":          spec(1, "write", "sortedset", "fast"),
+	"zincrby":       spec(1, "write", "sortedset", "fast"),
+	"zscore":        spec(1, "read", "sortedset", "fast"),
+	"zcard":         spec(1, "read", "sortedset", "fast"),
+	"zrank":         spec(1, "read", "sortedset", "fast"),
+	"zrange":        spec(1, "read", "sortedset", "slow"),
+	"zrangebyscore": spec(1, "read", "sortedset", "slow"),
+
+	"subscribe":    spec(0, "pubsub", "slow"),
+	"unsubscribe":  spec(0, "pubsub", "slow"),
+	"psubscribe":   spec(0, "pubsub", "slow"),
+	"punsubscribe": spec(0, "pubsub", "slow"),
+	"publish":      spec(0, "pubsub", "fast"),
+	"pubsub":       spec(0, "pubsub", "slow"),
+}
+
+// allowsCommand evaluates the user's command rules in order, as Redis does
+func (u *User) allowsCommand(name string) bool {
+	cs, known := commandTable[name]
+	allowed := false
+	for _, rule := range u.Commands {
+		grant := rule[0] == '+'
+		target := rule[1:]
+		switch {
+		case target == "@all":
+			allowed = grant
+		case strings.HasPrefix(target, "@"):
+			if known && hasCategory(cs, target[1:]) {
+				allowed = grant
+			}
+		case target == name:
+			allowed = grant
+		}
+	}
+	return allowed
+}
+
+// InCategory reports whether a command in the permission table belongs to an
+// ACL category; commands not in the table belong to none
+func InCategory(command, category string) bool {
+	cs, ok := commandTable[strings.ToLower(command)]
+	return ok && hasCategory(cs, category)
+}
+
+func hasCategory(s CommandSpec, cat string) bool {
+	for _, c := range s.Categories {
+		if c == cat {
+			return true
+		}
+	}
+	return false
+}
+
+// commandKeys returns the key arguments of a command; args includes the command name
+func commandKeys(name string, args [][]byte) []string {
+	cs, ok := commandTable[name]
+	if !ok || cs.FirstKey == 0 {
+		return nil
+	}
+	last := cs.LastKey
+	if last < 0 {
+		last = len(args) + last
+	}
+	var keys []string
+	for i := cs.FirstKey; i <= last && i < len(args); i += cs.Step {
+		keys = append(keys, string(args[i]))
+	}
+	return keys
+}
+
+// allowsKey reports whether any of the user's key patterns matches key
+func (u *User) allowsKey(key string) bool {
+	for _, pattern := range u.Keys {
+		if MatchPattern(pattern, key) {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchPattern reports whether s matches a Redis glob pattern supporting
+// *, ?, [abc], [^a-z] and backslash escapes
+func MatchPattern(pattern, s string) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			for len(pattern) > 1 && pattern[1] == '*' {
+				pattern = pattern[1:]
+			}
+			if len(pattern) == 1 {
+				return true
+			}
+			for i := 0; i <= len(s); i++ {
+				if MatchPattern(pattern[1:], s[i:]) {
+					return true
+				}
+			}
+			return false
+		case '?':
+			if len(s) == 0 {
+				return false
+			}
+			s = s[1:]
+			pattern = pattern[1:]
+		case '[':
+			if len(s) == 0 {
+				return false
+			}
+			end := strings.IndexByte(pattern[1:], ']')
+			if end < 0 {
+				// Unterminated class: treat '[' literally
+				if s[0] != '[' {
+					return false
+				}
+				s = s[1:]
+				pattern = pattern[1:]
+				continue
+			}
+			class := pattern[1 : end+1]
+			negate := strings.HasPrefix(class, "^")
+			if negate {
+				class = class[1:]
+			}
+			if matchClass(class, s[0]) == negate {
+				return false
+			}
+			s = s[1:]
+			pattern = pattern[end+2:]
+		case '\\':
+			if len(pattern) > 1 {
+				pattern = pattern[1:]
+			}
+			fallthrough
+		default:
+			if len(s) == 0 || s[0] != pattern[0] {
+				return false
+			}
+			s = s[1:]
+			pattern = pattern[1:]
+		}
+	}
+	return len(s) == 0
+}
+
+func matchClass(class string, c byte) bool {
+	for i := 0; i < len(class); i++ {
+		if i+2 < len(class) && class[i+1] == '-' {
+			lo, hi := class[i], class[i+2]
+			if lo > hi {
+				lo, hi = hi, lo
+			}
+			if c >= lo && c <= hi {
+				return true
+			}
+			i += 2
+			continue
+		}
+		if class[i] == c {
+			return true
+		}
+	}
+	return false
+}
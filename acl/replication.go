@@ -0,0 +1,46 @@
+package acl
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// ReplicationState returns every user and deleted user encoded for
+// HandleChanges, and the generation it reflects. The syncer posts it to
+// peers whenever the generation moved since they last accepted it, retrying
+// with the replication link's backoff until they do.
+func (s *Store) ReplicationState() (int64, []byte, error) {
+	generation, changes := s.Changes()
+	data, err := json.Marshal(changes)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to marshal ACL users: %v", err)
+	}
+	return generation, data, nil
+}
+
+// HandleChanges serves POST /acl, applying the user changes replicated by a
+// peer. Changes older than what is already known are ignored, so a peer can
+// send its whole state again.
+func (s *Store) HandleChanges(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	var changes []UserChange
+	if err := json.NewDecoder(r.Body).Decode(&changes); err != nil {
+		http.Error(w, fmt.Sprintf("invalid user changes: %v", err), http.StatusBadRequest)
+		return
+	}
+	var firstErr error
+	for _, change := range changes {
+		if err := s.ApplyChange(change); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("user %s: %v", change.Name, err)
+		}
+	}
+	if firstErr != nil {
+		http.Error(w, firstErr.Error(), http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
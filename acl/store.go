@@ -0,0 +1,383 @@
+package acl
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// User change actions carried by a UserChange
+const (
+	UserSet    = "set"
+	UserDelete = "delete"
+)
+
+// UserChange describes a user being defined or deleted, as replicated to peers
+type UserChange struct {
+	Action  string   `json:"action"`
+	Name    string   `json:"name"`
+	Rules   []string `json:"rules,omitempty"` // full definition starting with "reset"
+	Version Version  `json:"version"`
+}
+
+// Version orders the changes to a user across regions: the later timestamp
+// wins and the replica ID breaks ties, as for LWW values
+type Version struct {
+	Timestamp int64  `json:"timestamp"`
+	ReplicaID string `json:"replica_id"`
+}
+
+// After reports whether v is later than other
+func (v Version) After(other Version) bool {
+	if v.Timestamp != other.Timestamp {
+		return v.Timestamp > other.Timestamp
+	}
+	return v.ReplicaID > other.ReplicaID
+}
+
+// PermissionError reports a command or key the user may not access
+type PermissionError struct {
+	User    string
+	Command string
+	Key     string // empty when the command itself is denied
+}
+
+func (e *PermissionError) Error() string {
+	if e.Key != "" {
+		return "NOPERM No permissions to access a key"
+	}
+	return fmt.Sprintf("NOPERM User %s has no permissions to run the '%s' command", e.User, e.Command)
+}
+
+// Store holds ACL users and persists them in the data dir. Deleted users
+// are kept as tombstones with the version of the deletion, so a change to
+// the user that a peer made before it does not bring the user back.
+type Store struct {
+	mu         sync.RWMutex
+	path       string
+	users      map[string]*User
+	tombstones map[string]Version
+	replicaID  string
+	generation int64 // incremented on every change, local or replicated
+	now        func() time.Time
+}
+
+// Load loads users from path, creating the default user if missing. A
+// non-empty defaultPassword (e.g. the configured auth token) replaces the
+// default user's passwords. An empty path keeps users in memory only.
+func Load(path string, defaultPassword string) (*Store, error) {
+	s := &Store{
+		path:       path,
+		users:      make(map[string]*User),
+		tombstones: make(map[string]Version),
+		generation: 1,
+		now:        time.Now,
+	}
+
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to read ACL file: %v", err)
+		}
+		if len(data) > 0 {
+			var users []*User
+			if err := json.Unmarshal(data, &users); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal ACL users: %v", err)
+			}
+			for _, u := range users {
+				if u.Deleted {
+					s.tombstones[u.Name] = u.Version
+				} else {
+					s.users[u.Name] = u
+				}
+			}
+		}
+	}
+
+	def, ok := s.users[DefaultUser]
+	if !ok {
+		def = &User{Name: DefaultUser, Enabled: true, NoPass: true, Keys: []string{"*"}, Commands: []string{"+@all"}}
+		s.users[DefaultUser] = def
+	}
+	if defaultPassword != "" {
+		def.NoPass = false
+		def.Passwords = []string{HashPassword(defaultPassword)}
+	}
+
+	if err := s.save(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// SetReplicaID sets the replica ID that versions local user changes
+func (s *Store) SetReplicaID(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.replicaID = id
+}
+
+// Authenticate reports whether name/password identify an enabled user
+func (s *Store) Authenticate(name, password string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	u, ok := s.users[name]
+	if !ok || !u.Enabled {
+		return false
+	}
+	if u.NoPass {
+		return true
+	}
+	hash := HashPassword(password)
+	for _, p := range u.Passwords {
+		if subtle.ConstantTimeCompare([]byte(p), []byte(hash)) == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// DefaultAuthenticated reports whether new connections are logged in as the
+// default user without sending AUTH
+func (s *Store) DefaultAuthenticated() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	u, ok := s.users[DefaultUser]
+	return ok && u.Enabled && u.NoPass
+}
+
+// Check verifies that user may run a command; args includes the command name
+func (s *Store) Check(user string, args [][]byte) error {
+	name := strings.ToLower(string(args[0]))
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	u, ok := s.users[user]
+	if !ok || !u.Enabled {
+		return &PermissionError{User: user, Command: name}
+	}
+	if !u.allowsCommand(name) {
+		return &PermissionError{User: user, Command: name}
+	}
+	for _, key := range commandKeys(name, args) {
+		if !u.allowsKey(key) {
+			return &PermissionError{User: user, Command: name, Key: key}
+		}
+	}
+	return nil
+}
+
+// SetUser creates or modifies a user by applying rules in order
+func (s *Store) SetUser(name string, rules []string) error {
+	if err := checkUserName(name); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.users[name]
+	if ok {
+		u = u.clone()
+	} else {
+		u = newUser(name)
+	}
+	// Apply to a copy so a bad rule leaves the user untouched
+	for _, rule := range rules {
+		if err := u.applyRule(rule); err != nil {
+			return fmt.Errorf("error in ACL SETUSER modifier '%s': %v", rule, err)
+		}
+	}
+	u.Version = s.nextVersion(name)
+	s.users[name] = u
+	delete(s.tombstones, name)
+	s.generation++
+	return s.save()
+}
+
+func checkUserName(name string) error {
+	if name == "" || strings.ContainsAny(name, " \t\r\n") {
+		return fmt.Errorf("invalid username '%s'", name)
+	}
+	return nil
+}
+
+// nextVersion returns the version of a local change to name. It is later
+// than every change to the user seen so far, even from a peer whose clock is
+// ahead, so the change is not lost to an older one. Callers must hold s.mu.
+func (s *Store) nextVersion(name string) Version {
+	ts := s.now().UnixNano()
+	if prev := s.versionOf(name); ts <= prev.Timestamp {
+		ts = prev.Timestamp + 1
+	}
+	return Version{Timestamp: ts, ReplicaID: s.replicaID}
+}
+
+// versionOf returns the version of the last change to name, or the zero
+// version if there was none; callers must hold s.mu
+func (s *Store) versionOf(name string) Version {
+	if u, ok := s.users[name]; ok {
+		return u.Version
+	}
+	return s.tombstones[name]
+}
+
+// DelUser deletes users and returns how many existed; the default user cannot be deleted
+func (s *Store) DelUser(names ...string) (int, error) {
+	for _, name := range names {
+		if name == DefaultUser {
+			return 0, fmt.Errorf("the 'default' user cannot be removed")
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	deleted := 0
+	for _, name := range names {
+		if _, ok := s.users[name]; ok {
+			s.tombstones[name] = s.nextVersion(name)
+			delete(s.users, name)
+			deleted++
+		}
+	}
+	if deleted == 0 {
+		return 0, nil
+	}
+	s.generation++
+	if err := s.save(); err != nil {
+		return 0, err
+	}
+	return deleted, nil
+}
+
+// ApplyChange applies a change received from a peer if it is later than the
+// last change to the user; an earlier or repeated change is ignored, so
+// changes converge in whatever order they arrive
+func (s *Store) ApplyChange(change UserChange) error {
+	if err := checkUserName(change.Name); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !change.Version.After(s.versionOf(change.Name)) {
+		return nil
+	}
+	switch change.Action {
+	case UserSet:
+		u := newUser(change.Name)
+		for _, rule := range change.Rules {
+			if err := u.applyRule(rule); err != nil {
+				return fmt.Errorf("error in ACL SETUSER modifier '%s': %v", rule, err)
+			}
+		}
+		u.Version = change.Version
+		s.users[change.Name] = u
+		delete(s.tombstones, change.Name)
+	case UserDelete:
+		if change.Name == DefaultUser {
+			return fmt.Errorf("the 'default' user cannot be removed")
+		}
+		delete(s.users, change.Name)
+		s.tombstones[change.Name] = change.Version
+	default:
+		return fmt.Errorf("unknown user change action: %s", change.Action)
+	}
+	s.generation++
+	return s.save()
+}
+
+// Changes returns every user and deleted user as the change that produced
+// it, sorted by name, and the generation they reflect, which grows whenever
+// they change
+func (s *Store) Changes() (int64, []UserChange) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	changes := make([]UserChange, 0, len(s.users)+len(s.tombstones))
+	for name, u := range s.users {
+		changes = append(changes, UserChange{Action: UserSet, Name: name, Rules: u.Rules(), Version: u.Version})
+	}
+	for name, v := range s.tombstones {
+		changes = append(changes, UserChange{Action: UserDelete, Name: name, Version: v})
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Name < changes[j].Name })
+	return s.generation, changes
+}
+
+// GetUser returns a copy of a user
+func (s *Store) GetUser(name string) (*User, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	u, ok := s.users[name]
+	if !ok {
+		return nil, false
+	}
+	return u.clone(), true
+}
+
+// Users returns the user names sorted
+func (s *Store) Users() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	names := make([]string, 0, len(s.users))
+	for name := range s.users {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// List returns an ACL LIST line per user sorted by name
+func (s *Store) List() []string {
+	var lines []string
+	for _, name := range s.Users() {
+		if u, ok := s.GetUser(name); ok {
+			lines = append(lines, u.Describe())
+		}
+	}
+	return lines
+}
+
+// save writes the users to disk; callers must hold s.mu
+func (s *Store) save() error {
+	if s.path == "" {
+		return nil
+	}
+
+	users := make([]*User, 0, len(s.users)+len(s.tombstones))
+	for _, u := range s.users {
+		users = append(users, u)
+	}
+	for name, v := range s.tombstones {
+		users = append(users, &User{Name: name, Deleted: true, Version: v})
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].Name < users[j].Name })
+
+	data, err := json.MarshalIndent(users, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal ACL users: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return fmt.Errorf("failed to create ACL directory: %v", err)
+	}
+
+	// Password hashes live here, so keep the file private to the owner
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write ACL file: %v", err)
+	}
+	return os.Rename(tmp, s.path)
+}
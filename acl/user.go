@@ -0,0 +1,192 @@
+package acl
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// DefaultUser is the user new connections are authenticated as
+const DefaultUser = "default"
+
+// User is an ACL user with passwords, command rules and key patterns
+type User struct {
+	Name      string   `json:"name"`
+	Enabled   bool     `json:"enabled"`
+	NoPass    bool     `json:"nopass"`
+	Passwords []string `json:"passwords"`         // hex SHA-256 of each password
+	Commands  []string `json:"commands"`          // ordered rules such as "+@read" or "-del"
+	Keys      []string `json:"keys"`              // glob patterns of accessible keys
+	Version   Version  `json:"version"`           // last change to the user
+	Deleted   bool     `json:"deleted,omitempty"` // a tombstone kept in the ACL file
+}
+
+// newUser returns a user in the Redis default state for ACL SETUSER: disabled,
+// without passwords, commands or keys
+func newUser(name string) *User {
+	return &User{Name: name, Commands: []string{"-@all"}}
+}
+
+// HashPassword returns the hex SHA-256 digest stored for a password
+func HashPassword(password string) string {
+	sum := sha256.Sum256([]byte(password))
+	return hex.EncodeToString(sum[:])
+}
+
+// clone returns a deep copy so callers never share slices with the store
+func (u *User) clone() *User {
+	c := *u
+	c.Passwords = append([]string(nil), u.Passwords...)
+	c.Commands = append([]string(nil), u.Commands...)
+	c.Keys = append([]string(nil), u.Keys...)
+	return &c
+}
+
+// applyRule applies a single ACL SETUSER rule
+func (u *User) applyRule(rule string) error {
+	lower := strings.ToLower(rule)
+	switch {
+	case lower == "on":
+		u.Enabled = true
+	case lower == "off":
+		u.Enabled = false
+	case lower == "nopass":
+		u.NoPass = true
+		u.Passwords = nil
+	case lower == "resetpass":
+		u.NoPass = false
+		u.Passwords = nil
+	case lower == "allkeys":
+		u.Keys = []string{"*"}
+	case lower == "resetkeys":
+		u.Keys = nil
+	case lower == "allcommands":
+		u.Commands = []string{"+@all"}
+	case lower == "nocommands":
+		u.Commands = []string{"-@all"}
+	case lower == "reset":
+		*u = *newUser(u.Name)
+	case strings.HasPrefix(rule, ">"):
+		u.addPassword(HashPassword(rule[1:]))
+	case strings.HasPrefix(rule, "<"):
+		u.removePassword(HashPassword(rule[1:]))
+	case strings.HasPrefix(rule, "#"):
+		hash := strings.ToLower(rule[1:])
+		if _, err := hex.DecodeString(hash); err != nil || len(hash) != sha256.Size*2 {
+			return fmt.Errorf("the password hash must be exactly 64 characters and contain only lowercase hexadecimal characters")
+		}
+		u.addPassword(hash)
+	case strings.HasPrefix(rule, "!"):
+		u.removePassword(strings.ToLower(rule[1:]))
+	case strings.HasPrefix(rule, "~"):
+		if rule == "~*" {
+			u.Keys = []string{"*"}
+		} else {
+			u.Keys = append(u.Keys, rule[1:])
+		}
+	case strings.HasPrefix(rule, "+") || strings.HasPrefix(rule, "-"):
+		name := strings.ToLower(rule[1:])
+		if strings.HasPrefix(name, "@") {
+			if !isCategory(name[1:]) {
+				return fmt.Errorf("unknown command category '%s'", name[1:])
+			}
+			if name == "@all" {
+				u.Commands = nil
+			}
+		} else if name == "" {
+			return fmt.Errorf("syntax error in ACL rule '%s'", rule)
+		}
+		u.Commands = append(u.Commands, rule[:1]+name)
+	default:
+		return fmt.Errorf("syntax error in ACL rule '%s'", rule)
+	}
+	return nil
+}
+
+func (u *User) addPassword(hash string) {
+	u.NoPass = false
+	for _, p := range u.Passwords {
+		if p == hash {
+			return
+		}
+	}
+	u.Passwords = append(u.Passwords, hash)
+}
+
+func (u *User) removePassword(hash string) {
+	kept := u.Passwords[:0]
+	for _, p := range u.Passwords {
+		if p != hash {
+			kept = append(kept, p)
+		}
+	}
+	u.Passwords = kept
+}
+
+// Flags returns the user's flags as reported by ACL GETUSER
+func (u *User) Flags() []string {
+	flags := []string{"off"}
+	if u.Enabled {
+		flags[0] = "on"
+	}
+	if u.NoPass {
+		flags = append(flags, "nopass")
+	}
+	if u.allKeys() {
+		flags = append(flags, "allkeys")
+	}
+	if len(u.Commands) == 1 && u.Commands[0] == "+@all" {
+		flags = append(flags, "allcommands")
+	}
+	return flags
+}
+
+// CommandRules returns the command rules as a single string, e.g. "+@all -del"
+func (u *User) CommandRules() string {
+	return strings.Join(u.Commands, " ")
+}
+
+// KeyRules returns the key patterns as a single string, e.g. "~app:* ~tmp:*"
+func (u *User) KeyRules() string {
+	rules := make([]string, len(u.Keys))
+	for i, k := range u.Keys {
+		rules[i] = "~" + k
+	}
+	return strings.Join(rules, " ")
+}
+
+// Rules returns the rules that recreate the user from scratch
+func (u *User) Rules() []string {
+	rules := []string{"reset", "off"}
+	if u.Enabled {
+		rules[1] = "on"
+	}
+	if u.NoPass {
+		rules = append(rules, "nopass")
+	}
+	passwords := append([]string(nil), u.Passwords...)
+	sort.Strings(passwords)
+	for _, p := range passwords {
+		rules = append(rules, "#"+p)
+	}
+	for _, k := range u.Keys {
+		rules = append(rules, "~"+k)
+	}
+	return append(rules, u.Commands...)
+}
+
+// Describe renders the user as an ACL LIST line
+func (u *User) Describe() string {
+	return "user " + u.Name + " " + strings.Join(u.Rules()[1:], " ")
+}
+
+func (u *User) allKeys() bool {
+	for _, k := range u.Keys {
+		if k == "*" {
+			return true
+		}
+	}
+	return false
+}
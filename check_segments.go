@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"path/filepath"
+
+	"github.com/luoyjx/crdt-redis/storage"
+)
+
+// checkSegments implements "crdt-redis check-segments": it validates the
+// segment files in a directory and can repair them or dump their entries.
+// It also lists the segments truncate recovery took out of the log.
+// It returns the exit status: 0 if the segments are clean or were repaired,
+// 1 if corruption remains, 2 on usage or read errors.
+func checkSegments(args []string, out io.Writer) int {
+	fs := flag.NewFlagSet("check-segments", flag.ContinueOnError)
+	fs.SetOutput(out)
+	repair := fs.String("repair", "", "rewrite corrupt segments: skip drops corrupt entries, truncate cuts the log at its first corrupt entry and drops the segments after it")
+	dump := fs.Bool("dump", false, "print every valid entry")
+	fs.Usage = func() {
+		fmt.Fprintln(out, "usage: crdt-redis check-segments [-repair skip|truncate] [-dump] <segments dir>")
+		fmt.Fprintln(out, "The segments dir is <data>/store/segments; stop the server before repairing.")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 1 || (*repair != "" && *repair != storage.RecoverySkip && *repair != storage.RecoveryTruncate) {
+		fs.Usage()
+		return 2
+	}
+
+	paths, err := storage.SegmentFiles(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(out, "check-segments: %v\n", err)
+		return 2
+	}
+	dropped, err := storage.DroppedSegments(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(out, "check-segments: %v\n", err)
+		return 2
+	}
+	for _, path := range dropped {
+		fmt.Fprintf(out, "%s: dropped by truncate recovery, not replayed\n", filepath.Base(path))
+	}
+
+	status := 0
+	corruptIn := "" // first segment with corruption; truncate recovery drops the ones after it
+	for _, path := range paths {
+		name := filepath.Base(path)
+		if corruptIn != "" && *repair == storage.RecoveryTruncate {
+			if _, err := storage.DropSegment(path); err != nil {
+				fmt.Fprintf(out, "%s: %v\n", name, err)
+				status = 1
+				continue
+			}
+			fmt.Fprintf(out, "%s: dropped, follows the truncated %s\n", name, corruptIn)
+			continue
+		}
+		report, err := storage.ReadSegmentFile(path, func(offset int64, entry *storage.LogEntry) {
+			if *dump {
+				data, _ := json.Marshal(entry)
+				fmt.Fprintf(out, "%s@%d %s\n", name, offset, data)
+			}
+		})
+		if err != nil {
+			fmt.Fprintf(out, "%s: %v\n", name, err)
+			return 2
+		}
+		fmt.Fprintf(out, "%s: v%d, %d entries, %d bytes, %d corrupt\n", name, report.Version, report.Entries, report.Size, len(report.Corrupt))
+		for _, c := range report.Corrupt {
+			fmt.Fprintf(out, "  offset %d (%d bytes): %s\n", c.Offset, c.Length, c.Reason)
+		}
+		if corruptIn != "" && *repair == "" {
+			fmt.Fprintf(out, "  follows corruption in %s; truncate recovery drops it\n", corruptIn)
+		}
+		if report.Clean() {
+			continue
+		}
+		if corruptIn == "" {
+			corruptIn = name
+		}
+		if *repair == "" {
+			status = 1
+			continue
+		}
+		if _, err := storage.RepairSegment(path, *repair); err != nil {
+			fmt.Fprintf(out, "  repair failed: %v\n", err)
+			status = 1
+			continue
+		}
+		fmt.Fprintf(out, "  repaired (%s)\n", *repair)
+	}
+	return status
+}
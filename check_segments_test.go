@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/luoyjx/crdt-redis/storage"
+)
+
+func TestCheckSegments(t *testing.T) {
+	dir := t.TempDir()
+	store, err := storage.NewStore(dir, "", 0)
+	if err != nil {
+		t.Fatalf("NewStore failed: %v", err)
+	}
+	store.Set("a", storage.NewStringValue("1", 1, "r1"), nil)
+	store.Set("b", storage.NewStringValue("2", 1, "r1"), nil)
+	store.Close()
+
+	segDir := filepath.Join(dir, "segments")
+	path := filepath.Join(segDir, "segment-0.log")
+	var offsets []int64
+	storage.ReadSegmentFile(path, func(offset int64, _ *storage.LogEntry) {
+		offsets = append(offsets, offset)
+	})
+	// Damage the entry for a and leave half a record at the end
+	data, _ := os.ReadFile(path)
+	data[offsets[0]+10] ^= 0x01
+	os.WriteFile(path, append(data, data[offsets[1]:offsets[1]+4]...), 0644)
+
+	var out bytes.Buffer
+	if status := checkSegments([]string{"-dump", segDir}, &out); status != 1 {
+		t.Errorf("check of a corrupt segment exited %d, want 1", status)
+	}
+	for _, want := range []string{"segment-0.log: v2, 1 entries", "checksum mismatch", "torn write", `"key":"b"`} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output missing %q:\n%s", want, out.String())
+		}
+	}
+
+	out.Reset()
+	if status := checkSegments([]string{"-repair", "skip", segDir}, &out); status != 0 {
+		t.Errorf("repair exited %d, want 0:\n%s", status, out.String())
+	}
+	out.Reset()
+	if status := checkSegments([]string{segDir}, &out); status != 0 {
+		t.Errorf("check after repair exited %d, want 0:\n%s", status, out.String())
+	}
+
+	if status := checkSegments([]string{"-repair", "bogus", segDir}, &out); status != 2 {
+		t.Errorf("invalid -repair exited %d, want 2", status)
+	}
+}
+
+func TestCheckSegmentsDropsLaterSegments(t *testing.T) {
+	dir := t.TempDir()
+	// Each open starts a new segment
+	for _, key := range []string{"a", "b", "c"} {
+		store, err := storage.NewStore(dir, "", 0)
+		if err != nil {
+			t.Fatalf("NewStore failed: %v", err)
+		}
+		store.Set(key, storage.NewStringValue("1", 1, "r1"), nil)
+		store.Close()
+	}
+
+	segDir := filepath.Join(dir, "segments")
+	path := filepath.Join(segDir, "segment-0.log")
+	var offset int64
+	storage.ReadSegmentFile(path, func(o int64, _ *storage.LogEntry) { offset = o })
+	data, _ := os.ReadFile(path)
+	data[offset+10] ^= 0x01
+	os.WriteFile(path, data, 0644)
+
+	var out bytes.Buffer
+	if status := checkSegments([]string{segDir}, &out); status != 1 {
+		t.Errorf("check of a corrupt log exited %d, want 1", status)
+	}
+	if !strings.Contains(out.String(), "follows corruption in segment-0.log") {
+		t.Errorf("check does not say later segments are dropped:\n%s", out.String())
+	}
+
+	out.Reset()
+	if status := checkSegments([]string{"-repair", "truncate", segDir}, &out); status != 0 {
+		t.Errorf("repair exited %d, want 0:\n%s", status, out.String())
+	}
+	if !strings.Contains(out.String(), "segment-1.log: dropped, follows the truncated segment-0.log") {
+		t.Errorf("repair does not report the dropped segments:\n%s", out.String())
+	}
+
+	out.Reset()
+	if status := checkSegments([]string{segDir}, &out); status != 0 {
+		t.Errorf("check after repair exited %d, want 0:\n%s", status, out.String())
+	}
+	if !strings.Contains(out.String(), "segment-1.log.dropped: dropped by truncate recovery") {
+		t.Errorf("check does not list the dropped segments:\n%s", out.String())
+	}
+}
@@ -26,6 +26,24 @@ type Config struct {
 	RedisAddr string `json:"redis_addr" yaml:"redis_addr"`
 	RedisDB   int    `json:"redis_db" yaml:"redis_db"`
 
+	// StorageBackend mirrors the CRDT state: "none", "write-through" or "read-through"
+	StorageBackend string `json:"storage_backend" yaml:"storage_backend"`
+
+	// StorageEngine holds the keyspace: "memory", or "disk" for datasets
+	// larger than RAM with EngineCacheSize bytes of hot values cached
+	StorageEngine   string `json:"storage_engine" yaml:"storage_engine"`
+	EngineCacheSize int64  `json:"engine_cache_size" yaml:"engine_cache_size"`
+
+	// AppendFsync is when the segment log is synced: "always", "everysec" or "no"
+	AppendFsync string `json:"appendfsync" yaml:"appendfsync"`
+
+	// Save schedules snapshots as "<seconds> <changes>" pairs; empty disables them
+	Save string `json:"save" yaml:"save"`
+
+	// SegmentRecovery handles corrupt segment entries on startup: "strict",
+	// "skip" or "truncate"
+	SegmentRecovery string `json:"segment_recovery" yaml:"segment_recovery"`
+
 	// Replication settings
 	Peers         []string      `json:"peers" yaml:"peers"`
 	SyncInterval  time.Duration `json:"sync_interval" yaml:"sync_interval"`
@@ -33,8 +51,11 @@ type Config struct {
 	MaxRetries    int           `json:"max_retries" yaml:"max_retries"`
 	RetryInterval time.Duration `json:"retry_interval" yaml:"retry_interval"`
 
+	// AdvertiseAddr is the host or host:port peers use to reach the sync endpoint
+	AdvertiseAddr string `json:"advertise_addr" yaml:"advertise_addr"`
+
 	// Cluster discovery settings
-	DiscoveryMode     string        `json:"discovery_mode" yaml:"discovery_mode"` // "static", "consul", "etcd"
+	DiscoveryMode     string        `json:"discovery_mode" yaml:"discovery_mode"` // "static", "file", "dns", "consul", "etcd"
 	DiscoveryAddr     string        `json:"discovery_addr" yaml:"discovery_addr"`
 	DiscoveryInterval time.Duration `json:"discovery_interval" yaml:"discovery_interval"`
 	ClusterName       string        `json:"cluster_name" yaml:"cluster_name"`
@@ -45,7 +66,14 @@ type Config struct {
 	WriteTimeout     time.Duration `json:"write_timeout" yaml:"write_timeout"`
 	KeepAliveTimeout time.Duration `json:"keepalive_timeout" yaml:"keepalive_timeout"`
 	MaxMemory        int64         `json:"max_memory" yaml:"max_memory"` // in bytes
+	MaxMemoryPolicy  string        `json:"max_memory_policy" yaml:"max_memory_policy"`
 	GCInterval       time.Duration `json:"gc_interval" yaml:"gc_interval"`
+	TombstoneTTL     time.Duration `json:"tombstone_ttl" yaml:"tombstone_ttl"`
+	OpLogRetention   time.Duration `json:"oplog_retention" yaml:"oplog_retention"`           // minimum age before acknowledged operations are dropped
+	OpLogMaxOps      int           `json:"oplog_max_operations" yaml:"oplog_max_operations"` // 0 for no cap
+
+	// Keyspace notification classes, e.g. "KEA"
+	NotifyKeyspaceEvents string `json:"notify_keyspace_events" yaml:"notify_keyspace_events"`
 
 	// Logging settings
 	LogLevel  string `json:"log_level" yaml:"log_level"`
@@ -57,6 +85,12 @@ type Config struct {
 	TLSCertFile string `json:"tls_cert_file" yaml:"tls_cert_file"`
 	TLSKeyFile  string `json:"tls_key_file" yaml:"tls_key_file"`
 	AuthToken   string `json:"auth_token" yaml:"auth_token"`
+
+	// TLSCAFile verifies peer and client certificates; setting it turns on
+	// mutual TLS for the replication endpoints
+	TLSCAFile       string   `json:"tls_ca_file" yaml:"tls_ca_file"`
+	TLSAuthClients  bool     `json:"tls_auth_clients" yaml:"tls_auth_clients"`   // require client certificates on the Redis listener
+	TLSAllowedPeers []string `json:"tls_allowed_peers" yaml:"tls_allowed_peers"` // accepted peer certificate identities
 }
 
 // DefaultConfig returns a configuration with default values
@@ -80,6 +114,14 @@ func DefaultConfig() *Config {
 		RedisAddr: "localhost:6379",
 		RedisDB:   0,
 
+		StorageBackend: "write-through",
+
+		StorageEngine:   "memory",
+		EngineCacheSize: 64 * 1024 * 1024, // 64MB
+		AppendFsync:     "everysec",
+		Save:            "3600 1 300 100 60 10000",
+		SegmentRecovery: "truncate",
+
 		// Replication settings
 		Peers:         []string{},
 		SyncInterval:  5 * time.Second,
@@ -99,7 +141,11 @@ func DefaultConfig() *Config {
 		WriteTimeout:     30 * time.Second,
 		KeepAliveTimeout: 300 * time.Second,
 		MaxMemory:        1024 * 1024 * 1024, // 1GB
+		MaxMemoryPolicy:  "noeviction",
 		GCInterval:       60 * time.Second,
+		TombstoneTTL:     1 * time.Hour,
+		OpLogRetention:   1 * time.Hour,
+		OpLogMaxOps:      0,
 
 		// Logging settings
 		LogLevel:  "info",
@@ -270,8 +316,44 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("max connections must be positive")
 	}
 
+	if c.GCInterval <= 0 {
+		return fmt.Errorf("GC interval must be positive")
+	}
+
+	if c.TombstoneTTL < 0 {
+		return fmt.Errorf("tombstone TTL cannot be negative")
+	}
+
+	if c.OpLogRetention < 0 {
+		return fmt.Errorf("oplog retention cannot be negative")
+	}
+
+	if c.OpLogMaxOps < 0 {
+		return fmt.Errorf("oplog max operations cannot be negative")
+	}
+
+	if c.MaxMemory < 0 {
+		return fmt.Errorf("max memory cannot be negative")
+	}
+
+	if c.StorageEngine != "memory" && c.StorageEngine != "disk" {
+		return fmt.Errorf("invalid storage engine: %s (valid: memory, disk)", c.StorageEngine)
+	}
+
+	if c.EngineCacheSize < 0 {
+		return fmt.Errorf("engine cache size cannot be negative")
+	}
+
+	if c.AppendFsync != "always" && c.AppendFsync != "everysec" && c.AppendFsync != "no" {
+		return fmt.Errorf("invalid appendfsync policy: %s (valid: always, everysec, no)", c.AppendFsync)
+	}
+
+	if c.SegmentRecovery != "strict" && c.SegmentRecovery != "skip" && c.SegmentRecovery != "truncate" {
+		return fmt.Errorf("invalid segment recovery mode: %s (valid: strict, skip, truncate)", c.SegmentRecovery)
+	}
+
 	// Validate discovery mode
-	validModes := []string{"static", "consul", "etcd"}
+	validModes := []string{"static", "file", "dns", "consul", "etcd"}
 	validMode := false
 	for _, mode := range validModes {
 		if c.DiscoveryMode == mode {
@@ -283,6 +365,13 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("invalid discovery mode: %s (valid: %v)", c.DiscoveryMode, validModes)
 	}
 
+	if c.EnableTLS && (c.TLSCertFile == "" || c.TLSKeyFile == "") {
+		return fmt.Errorf("TLS requires both tls_cert_file and tls_key_file")
+	}
+	if c.TLSAuthClients && c.TLSCAFile == "" {
+		return fmt.Errorf("tls_auth_clients requires tls_ca_file")
+	}
+
 	// Validate log level
 	validLevels := []string{"debug", "info", "warn", "error"}
 	validLevel := false
@@ -0,0 +1,181 @@
+package config
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Manager holds the running configuration and applies changes made through
+// CONFIG SET or a reload of the config file. Components register a hook per
+// hot parameter; parameters without hot support are rejected at runtime and
+// only take effect after a restart.
+type Manager struct {
+	mu    sync.Mutex
+	path  string
+	cfg   *Config
+	file  *Config // contents of path as last loaded, to detect what a reload changed
+	hooks map[string][]func(*Config) error
+}
+
+// NewManager manages cfg; filename is the file Reload reads and Rewrite
+// writes, and may be empty
+func NewManager(cfg *Config, filename string) *Manager {
+	m := &Manager{
+		path:  filename,
+		cfg:   cfg,
+		hooks: make(map[string][]func(*Config) error),
+	}
+	if filename != "" {
+		if file, err := LoadFromFile(filename); err == nil {
+			m.file = file
+		}
+	}
+	return m
+}
+
+// Path returns the config file path, or "" if there is none
+func (m *Manager) Path() string {
+	return m.path
+}
+
+// Config returns a copy of the running configuration
+func (m *Manager) Config() Config {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return *m.cfg
+}
+
+// OnChange registers fn to apply a new value of the named parameter. An
+// error from fn rejects the change.
+func (m *Manager) OnChange(name string, fn func(*Config) error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.hooks[name] = append(m.hooks[name], fn)
+}
+
+// Apply runs every registered hook with the current configuration, e.g. to
+// push file and flag values into components at startup
+func (m *Manager) Apply() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, p := range params {
+		if err := m.runHooks(p.Name, m.cfg); err != nil {
+			return fmt.Errorf("%s: %v", p.Name, err)
+		}
+	}
+	return nil
+}
+
+// Get returns the name and value of every parameter matching the glob
+// pattern, sorted by name
+func (m *Manager) Get(pattern string) [][2]string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out [][2]string
+	for _, p := range params {
+		if ok, _ := path.Match(strings.ToLower(pattern), p.Name); ok {
+			out = append(out, [2]string{p.Name, p.get(m.cfg)})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i][0] < out[j][0] })
+	return out
+}
+
+// Set changes a hot parameter at runtime
+func (m *Manager) Set(name, value string) error {
+	p, ok := LookupParam(name)
+	if !ok {
+		return fmt.Errorf("unknown parameter: %s", name)
+	}
+	if !p.Hot {
+		return fmt.Errorf("%s cannot be changed at runtime, a restart is required", name)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	next := *m.cfg
+	if err := p.set(&next, value); err != nil {
+		return err
+	}
+	if err := next.Validate(); err != nil {
+		return err
+	}
+	if err := m.runHooks(name, &next); err != nil {
+		return err
+	}
+	*m.cfg = next
+	return nil
+}
+
+// Reload re-reads the config file and applies the hot parameters that
+// changed in it. It returns the parameters applied and those that changed
+// but need a restart; the latter keep their running values.
+func (m *Manager) Reload() (applied, restart []string, err error) {
+	if m.path == "" {
+		return nil, nil, fmt.Errorf("no config file")
+	}
+	file, err := LoadFromFile(m.path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	base := m.file
+	if base == nil {
+		base = m.cfg
+	}
+	next := *m.cfg
+	for _, p := range params {
+		value := p.get(file)
+		if value == p.get(base) {
+			continue
+		}
+		if !p.Hot {
+			restart = append(restart, p.Name)
+			continue
+		}
+		if err := p.set(&next, value); err != nil {
+			return nil, nil, fmt.Errorf("%s: %v", p.Name, err)
+		}
+		applied = append(applied, p.Name)
+	}
+	if err := next.Validate(); err != nil {
+		return nil, nil, err
+	}
+	for _, name := range applied {
+		if err := m.runHooks(name, &next); err != nil {
+			return nil, nil, fmt.Errorf("%s: %v", name, err)
+		}
+	}
+	*m.cfg = next
+	m.file = file
+	return applied, restart, nil
+}
+
+// Rewrite writes the running configuration back to the config file
+func (m *Manager) Rewrite() error {
+	if m.path == "" {
+		return fmt.Errorf("the server is running without a config file")
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.cfg.SaveToFile(m.path); err != nil {
+		return err
+	}
+	file := *m.cfg
+	m.file = &file
+	return nil
+}
+
+func (m *Manager) runHooks(name string, cfg *Config) error {
+	for _, fn := range m.hooks[name] {
+		if err := fn(cfg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
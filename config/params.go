@@ -0,0 +1,213 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Param is a configuration setting exposed through CONFIG GET/SET under its
+// Redis-style name. Hot parameters take effect at runtime; the rest are only
+// read at startup.
+type Param struct {
+	Name string
+	Hot  bool
+	get  func(c *Config) string
+	set  func(c *Config, value string) error
+}
+
+var params = []Param{
+	intParam("port", false, func(c *Config) *int { return &c.ServerPort }),
+	intParam("sync-port", false, func(c *Config) *int { return &c.HTTPPort }),
+	stringParam("replica-id", false, func(c *Config) *string { return &c.ReplicaID }),
+	stringParam("dir", false, func(c *Config) *string { return &c.DataDir }),
+	stringParam("oplog-path", false, func(c *Config) *string { return &c.OpLogPath }),
+	stringParam("redis-addr", false, func(c *Config) *string { return &c.RedisAddr }),
+	intParam("redis-db", false, func(c *Config) *int { return &c.RedisDB }),
+	stringParam("storage-backend", false, func(c *Config) *string { return &c.StorageBackend }),
+	stringParam("storage-engine", false, func(c *Config) *string { return &c.StorageEngine }),
+	memoryParam("engine-cache-size", false, func(c *Config) *int64 { return &c.EngineCacheSize }),
+	stringParam("appendfsync", true, func(c *Config) *string { return &c.AppendFsync }),
+	stringParam("save", true, func(c *Config) *string { return &c.Save }),
+	stringParam("segment-recovery", false, func(c *Config) *string { return &c.SegmentRecovery }),
+	listParam("peers", false, func(c *Config) *[]string { return &c.Peers }),
+	durationParam("sync-interval", true, func(c *Config) *time.Duration { return &c.SyncInterval }),
+	durationParam("sync-timeout", false, func(c *Config) *time.Duration { return &c.SyncTimeout }),
+	intParam("sync-max-retries", false, func(c *Config) *int { return &c.MaxRetries }),
+	durationParam("sync-retry-interval", false, func(c *Config) *time.Duration { return &c.RetryInterval }),
+	stringParam("advertise-addr", false, func(c *Config) *string { return &c.AdvertiseAddr }),
+	stringParam("discovery-mode", false, func(c *Config) *string { return &c.DiscoveryMode }),
+	stringParam("discovery-addr", false, func(c *Config) *string { return &c.DiscoveryAddr }),
+	durationParam("discovery-interval", false, func(c *Config) *time.Duration { return &c.DiscoveryInterval }),
+	stringParam("cluster-name", false, func(c *Config) *string { return &c.ClusterName }),
+	intParam("maxclients", false, func(c *Config) *int { return &c.MaxConnections }),
+	memoryParam("maxmemory", true, func(c *Config) *int64 { return &c.MaxMemory }),
+	stringParam("maxmemory-policy", true, func(c *Config) *string { return &c.MaxMemoryPolicy }),
+	durationParam("gc-interval", true, func(c *Config) *time.Duration { return &c.GCInterval }),
+	durationParam("tombstone-ttl", true, func(c *Config) *time.Duration { return &c.TombstoneTTL }),
+	durationParam("oplog-retention", true, func(c *Config) *time.Duration { return &c.OpLogRetention }),
+	intParam("oplog-max-operations", true, func(c *Config) *int { return &c.OpLogMaxOps }),
+	stringParam("notify-keyspace-events", true, func(c *Config) *string { return &c.NotifyKeyspaceEvents }),
+	stringParam("loglevel", true, func(c *Config) *string { return &c.LogLevel }),
+	stringParam("logfile", false, func(c *Config) *string { return &c.LogFile }),
+	stringParam("requirepass", false, func(c *Config) *string { return &c.AuthToken }),
+	stringParam("tls-cert-file", false, func(c *Config) *string { return &c.TLSCertFile }),
+	stringParam("tls-key-file", false, func(c *Config) *string { return &c.TLSKeyFile }),
+	stringParam("tls-ca-file", false, func(c *Config) *string { return &c.TLSCAFile }),
+	boolParam("tls-auth-clients", false, func(c *Config) *bool { return &c.TLSAuthClients }),
+	listParam("tls-allowed-peers", false, func(c *Config) *[]string { return &c.TLSAllowedPeers }),
+}
+
+// LookupParam returns the parameter with the given name
+func LookupParam(name string) (Param, bool) {
+	for _, p := range params {
+		if p.Name == name {
+			return p, true
+		}
+	}
+	return Param{}, false
+}
+
+// GetParam returns the value of the named parameter in c
+func (c *Config) GetParam(name string) (string, error) {
+	p, ok := LookupParam(name)
+	if !ok {
+		return "", fmt.Errorf("unknown parameter: %s", name)
+	}
+	return p.get(c), nil
+}
+
+// SetParam parses value into the named parameter of c; it does not apply
+// the change to a running server
+func (c *Config) SetParam(name, value string) error {
+	p, ok := LookupParam(name)
+	if !ok {
+		return fmt.Errorf("unknown parameter: %s", name)
+	}
+	return p.set(c, value)
+}
+
+// ParseMemory parses a byte count with an optional kb, mb or gb suffix, as
+// accepted by Redis for maxmemory
+func ParseMemory(value string) (int64, error) {
+	s := strings.ToLower(strings.TrimSpace(value))
+	mult := int64(1)
+	for _, u := range []struct {
+		suffix string
+		mult   int64
+	}{{"kb", 1 << 10}, {"mb", 1 << 20}, {"gb", 1 << 30}, {"k", 1000}, {"m", 1000 * 1000}, {"g", 1000 * 1000 * 1000}, {"b", 1}} {
+		if strings.HasSuffix(s, u.suffix) {
+			s = strings.TrimSuffix(s, u.suffix)
+			mult = u.mult
+			break
+		}
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid memory value: %s", value)
+	}
+	return n * mult, nil
+}
+
+func intParam(name string, hot bool, field func(c *Config) *int) Param {
+	return Param{
+		Name: name,
+		Hot:  hot,
+		get:  func(c *Config) string { return strconv.Itoa(*field(c)) },
+		set: func(c *Config, value string) error {
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return fmt.Errorf("invalid integer: %s", value)
+			}
+			*field(c) = n
+			return nil
+		},
+	}
+}
+
+func memoryParam(name string, hot bool, field func(c *Config) *int64) Param {
+	return Param{
+		Name: name,
+		Hot:  hot,
+		get:  func(c *Config) string { return strconv.FormatInt(*field(c), 10) },
+		set: func(c *Config, value string) error {
+			n, err := ParseMemory(value)
+			if err != nil {
+				return err
+			}
+			*field(c) = n
+			return nil
+		},
+	}
+}
+
+func stringParam(name string, hot bool, field func(c *Config) *string) Param {
+	return Param{
+		Name: name,
+		Hot:  hot,
+		get:  func(c *Config) string { return *field(c) },
+		set: func(c *Config, value string) error {
+			*field(c) = value
+			return nil
+		},
+	}
+}
+
+func boolParam(name string, hot bool, field func(c *Config) *bool) Param {
+	return Param{
+		Name: name,
+		Hot:  hot,
+		get: func(c *Config) string {
+			if *field(c) {
+				return "yes"
+			}
+			return "no"
+		},
+		set: func(c *Config, value string) error {
+			switch strings.ToLower(value) {
+			case "yes", "true":
+				*field(c) = true
+			case "no", "false":
+				*field(c) = false
+			default:
+				return fmt.Errorf("argument must be 'yes' or 'no'")
+			}
+			return nil
+		},
+	}
+}
+
+func durationParam(name string, hot bool, field func(c *Config) *time.Duration) Param {
+	return Param{
+		Name: name,
+		Hot:  hot,
+		get:  func(c *Config) string { return field(c).String() },
+		set: func(c *Config, value string) error {
+			d, err := time.ParseDuration(value)
+			if err != nil {
+				return fmt.Errorf("invalid duration: %s", value)
+			}
+			*field(c) = d
+			return nil
+		},
+	}
+}
+
+func listParam(name string, hot bool, field func(c *Config) *[]string) Param {
+	return Param{
+		Name: name,
+		Hot:  hot,
+		get:  func(c *Config) string { return strings.Join(*field(c), ",") },
+		set: func(c *Config, value string) error {
+			var list []string
+			for _, v := range strings.Split(value, ",") {
+				if v = strings.TrimSpace(v); v != "" {
+					list = append(list, v)
+				}
+			}
+			*field(c) = list
+			return nil
+		},
+	}
+}
@@ -250,3 +250,93 @@ func contains(s, substr string) bool {
 		(len(s) > len(substr) && contains(s[1:], substr))
 }
 
+
+func TestConfigManagerSet(t *testing.T) {
+	cfg := config.DefaultConfig()
+	m := config.NewManager(cfg, "")
+
+	var applied time.Duration
+	m.OnChange("sync-interval", func(c *config.Config) error {
+		applied = c.SyncInterval
+		return nil
+	})
+	if err := m.Set("sync-interval", "250ms"); err != nil {
+		t.Fatalf("Set sync-interval failed: %v", err)
+	}
+	if applied != 250*time.Millisecond || cfg.SyncInterval != 250*time.Millisecond {
+		t.Errorf("sync-interval not applied: hook %s, config %s", applied, cfg.SyncInterval)
+	}
+
+	if err := m.Set("maxmemory", "2mb"); err != nil {
+		t.Fatalf("Set maxmemory failed: %v", err)
+	}
+	if got := m.Get("maxmem*"); len(got) != 2 || got[0][1] != "2097152" || got[1][1] != "noeviction" {
+		t.Errorf("Get maxmem* = %v", got)
+	}
+
+	// Settings without hot support need a restart
+	if err := m.Set("port", "7000"); err == nil || !contains(err.Error(), "restart") {
+		t.Errorf("Set port should require a restart, got %v", err)
+	}
+	// Invalid values and hook errors leave the config unchanged
+	if err := m.Set("loglevel", "verbose"); err == nil {
+		t.Error("invalid log level should be rejected")
+	}
+	m.OnChange("gc-interval", func(c *config.Config) error { return os.ErrInvalid })
+	if err := m.Set("gc-interval", "1s"); err == nil || cfg.GCInterval != 60*time.Second {
+		t.Errorf("failed hook should reject the change, err %v, gc-interval %s", err, cfg.GCInterval)
+	}
+}
+
+func TestConfigManagerReloadRewrite(t *testing.T) {
+	path := t.TempDir() + "/crdt.json"
+	file := config.DefaultConfig()
+	file.ReplicaID = "node-1"
+	if err := file.SaveToFile(path); err != nil {
+		t.Fatalf("SaveToFile failed: %v", err)
+	}
+	cfg, err := config.LoadFromFile(path)
+	if err != nil {
+		t.Fatalf("LoadFromFile failed: %v", err)
+	}
+	m := config.NewManager(cfg, path)
+	var level string
+	m.OnChange("loglevel", func(c *config.Config) error {
+		level = c.LogLevel
+		return nil
+	})
+
+	file.LogLevel = "debug"
+	file.ServerPort = 7000
+	if err := file.SaveToFile(path); err != nil {
+		t.Fatalf("SaveToFile failed: %v", err)
+	}
+	applied, restart, err := m.Reload()
+	if err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+	if len(applied) != 1 || applied[0] != "loglevel" || level != "debug" {
+		t.Errorf("Reload applied %v, hook saw %q", applied, level)
+	}
+	if len(restart) != 1 || restart[0] != "port" || cfg.ServerPort != 6379 {
+		t.Errorf("Reload restart %v, running port %d", restart, cfg.ServerPort)
+	}
+
+	if err := m.Set("tombstone-ttl", "10m"); err != nil {
+		t.Fatalf("Set tombstone-ttl failed: %v", err)
+	}
+	if err := m.Rewrite(); err != nil {
+		t.Fatalf("Rewrite failed: %v", err)
+	}
+	saved, err := config.LoadFromFile(path)
+	if err != nil {
+		t.Fatalf("LoadFromFile failed: %v", err)
+	}
+	if saved.TombstoneTTL != 10*time.Minute || saved.LogLevel != "debug" || saved.ServerPort != 6379 {
+		t.Errorf("rewritten file has tombstone-ttl %s, loglevel %s, port %d", saved.TombstoneTTL, saved.LogLevel, saved.ServerPort)
+	}
+
+	if err := config.NewManager(config.DefaultConfig(), "").Rewrite(); err == nil {
+		t.Error("Rewrite without a config file should fail")
+	}
+}
@@ -1,12 +1,15 @@
 package main
 
 import (
+	"context"
 	"math"
 	"os"
 	"testing"
 	"time"
 
+	"github.com/luoyjx/crdt-redis/proto"
 	"github.com/luoyjx/crdt-redis/server"
+	"github.com/luoyjx/crdt-redis/storage"
 )
 
 // Helper function to create two test servers
@@ -72,6 +75,87 @@ func syncServers(srv1, srv2 *server.Server) {
 	}
 }
 
+// createCounterReplicas creates a server without a Redis backend for each
+// replica ID, for counter reset tests that need more than two replicas or a
+// restart. It returns the servers and their data directories.
+func createCounterReplicas(t *testing.T, ids ...string) ([]*server.Server, []string, func()) {
+	var replicas []*server.Server
+	var dirs []string
+	cleanup := func() {
+		for _, srv := range replicas {
+			srv.Close()
+		}
+		for _, dir := range dirs {
+			os.RemoveAll(dir)
+		}
+	}
+	for _, id := range ids {
+		dir, err := os.MkdirTemp("", "counter-reset-*")
+		if err != nil {
+			cleanup()
+			t.Fatalf("Failed to create temp dir: %v", err)
+		}
+		dirs = append(dirs, dir)
+		replicas = append(replicas, openCounterReplica(t, dir, id))
+	}
+	return replicas, dirs, cleanup
+}
+
+// openCounterReplica opens the server for replica id in dir
+func openCounterReplica(t *testing.T, dir, id string) *server.Server {
+	t.Helper()
+	srv, err := server.NewServerWithConfig(server.Config{
+		DataDir:   dir,
+		OpLogPath: dir + "/oplog.json",
+		ReplicaID: id,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create server %s: %v", id, err)
+	}
+	return srv
+}
+
+// deliver applies every operation logged by from to each of the replicas
+func deliver(t *testing.T, from *server.Server, to ...*server.Server) {
+	t.Helper()
+	apply(t, logged(t, from), to...)
+}
+
+// logged returns the operations srv has logged so far
+func logged(t *testing.T, srv *server.Server) []*proto.Operation {
+	t.Helper()
+	ops, err := srv.OpLog().GetOperations(0)
+	if err != nil {
+		t.Fatalf("GetOperations failed: %v", err)
+	}
+	return ops
+}
+
+// apply applies ops, in order, to each of the replicas
+func apply(t *testing.T, ops []*proto.Operation, to ...*server.Server) {
+	t.Helper()
+	for _, srv := range to {
+		for _, op := range ops {
+			if err := srv.HandleOperation(context.Background(), op); err != nil {
+				t.Fatalf("%s failed to apply %s %v: %v", srv.ReplicaID(), op.Command, op.Args, err)
+			}
+		}
+	}
+}
+
+// expectValue checks key on every replica; want "" means the key is absent
+func expectValue(t *testing.T, key, want string, replicas ...*server.Server) {
+	t.Helper()
+	for _, srv := range replicas {
+		v, ok := srv.Get(key)
+		if want == "" && ok {
+			t.Errorf("%s: %s = %q, want no key", srv.ReplicaID(), key, v)
+		} else if want != "" && v != want {
+			t.Errorf("%s: %s = %q, want %s", srv.ReplicaID(), key, v, want)
+		}
+	}
+}
+
 // TestZIncrByReplicationBasic tests basic ZINCRBY replication
 func TestZIncrByReplicationBasic(t *testing.T) {
 	srv1, srv2, cleanup := createTestServers(t)
@@ -457,6 +541,189 @@ func TestMultipleFieldsHIncrBy(t *testing.T) {
 	}
 }
 
+func TestCounterSetKeepsConcurrentIncr(t *testing.T) {
+	replicas, _, cleanup := createCounterReplicas(t, "a", "b", "c")
+	defer cleanup()
+	a, b, c := replicas[0], replicas[1], replicas[2]
+	a.IncrBy("n", 10)
+	deliver(t, a, b, c)
+
+	// b resets the 10 it saw while a adds 5 it has not seen
+	if err := b.Set("n", "0", nil); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	a.IncrBy("n", 5)
+
+	// c gets the SET after the INCR, a and b the other's write
+	deliver(t, a, b, c)
+	deliver(t, b, a, c)
+	expectValue(t, "n", "5", a, b, c)
+
+	// A later INCR counts from there everywhere
+	c.Incr("n")
+	deliver(t, c, a, b)
+	expectValue(t, "n", "6", a, b, c)
+}
+
+func TestCounterSetResetsObservedIncr(t *testing.T) {
+	replicas, _, cleanup := createCounterReplicas(t, "a", "b", "c")
+	defer cleanup()
+	a, b, c := replicas[0], replicas[1], replicas[2]
+	a.IncrBy("n", 10)
+	deliver(t, a, b)
+	if err := b.Set("n", "100", nil); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+
+	// c sees the SET before the INCR it reset; redelivery changes nothing
+	deliver(t, b, a, c)
+	deliver(t, a, b, c)
+	deliver(t, b, c)
+	expectValue(t, "n", "100", a, b, c)
+
+	// Increments after the SET add to its value
+	a.IncrBy("n", 2)
+	deliver(t, a, b, c)
+	expectValue(t, "n", "102", a, b, c)
+
+	// A string that is not a number stays as set
+	if err := c.Set("n", "hello", nil); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	deliver(t, c, a, b)
+	deliver(t, a, b, c)
+	expectValue(t, "n", "hello", a, b, c)
+}
+
+func TestCounterDelKeepsConcurrentIncr(t *testing.T) {
+	replicas, _, cleanup := createCounterReplicas(t, "a", "b", "c")
+	defer cleanup()
+	a, b, c := replicas[0], replicas[1], replicas[2]
+	a.IncrBy("n", 10)
+	deliver(t, a, b)
+
+	// b deletes the 10 it saw while a adds 3; c has seen nothing yet
+	if _, err := b.Del("n"); err != nil {
+		t.Fatalf("Del failed: %v", err)
+	}
+	a.IncrBy("n", 3)
+	deleted := logged(t, b)
+	incremented := logged(t, a)
+
+	apply(t, deleted, a, c)
+	apply(t, incremented, b, c)
+	// Redelivering the DEL does not remove the later increments
+	apply(t, deleted, a, b, c)
+	expectValue(t, "n", "3", a, b, c)
+}
+
+func TestCounterDelResetsObservedIncr(t *testing.T) {
+	replicas, _, cleanup := createCounterReplicas(t, "a", "b", "c")
+	defer cleanup()
+	a, b, c := replicas[0], replicas[1], replicas[2]
+	a.IncrBy("n", 10)
+	a.Decr("n")
+	deliver(t, a, b)
+	if _, err := b.Del("n"); err != nil {
+		t.Fatalf("Del failed: %v", err)
+	}
+
+	// c gets the DEL first, then the increments it observed
+	deliver(t, b, a, c)
+	deliver(t, a, b, c)
+	expectValue(t, "n", "", a, b, c)
+
+	// The key counts from zero again after the DEL
+	c.IncrBy("n", 4)
+	deliver(t, c, a, b)
+	deliver(t, a, b, c)
+	expectValue(t, "n", "4", a, b, c)
+}
+
+func TestCounterSetAndDelInterleaved(t *testing.T) {
+	replicas, _, cleanup := createCounterReplicas(t, "a", "b", "c")
+	defer cleanup()
+	a, b, c := replicas[0], replicas[1], replicas[2]
+	a.IncrBy("n", 1)
+	deliver(t, a, b, c)
+
+	// A SET, a later DEL and increments on all three, delivered in
+	// different orders to each replica
+	if err := a.Set("n", "50", nil); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	b.IncrBy("n", 7)
+	if _, err := c.Del("n"); err != nil {
+		t.Fatalf("Del failed: %v", err)
+	}
+	b.IncrBy("n", 1)
+	fromA, fromB, fromC := logged(t, a), logged(t, b), logged(t, c)
+
+	apply(t, fromB, a)
+	apply(t, fromC, a)
+	apply(t, fromC, b)
+	apply(t, fromA, b)
+	apply(t, fromA, c)
+	apply(t, fromB, c)
+	// The DEL is newest and observed only the first INCR, so b's 8 remain
+	expectValue(t, "n", "8", a, b, c)
+}
+
+func TestCounterDelSurvivesRestart(t *testing.T) {
+	replicas, dirs, cleanup := createCounterReplicas(t, "a", "b")
+	defer cleanup()
+	a, b := replicas[0], replicas[1]
+	a.IncrBy("n", 10)
+	deliver(t, a, b)
+	if _, err := b.Del("n"); err != nil {
+		t.Fatalf("Del failed: %v", err)
+	}
+	a.IncrBy("n", 3)
+
+	// b still knows which increments its DEL reset after a restart, from
+	// the segment log and then from a snapshot
+	for _, save := range []bool{false, true} {
+		if save {
+			if err := b.Save(); err != nil {
+				t.Fatalf("Save failed: %v", err)
+			}
+		}
+		b.Close()
+		b = openCounterReplica(t, dirs[1], "b")
+		replicas[1] = b
+
+		deliver(t, a, b)
+		deliver(t, b, a)
+		expectValue(t, "n", "3", a, b)
+	}
+}
+
+func TestCounterNotEvicted(t *testing.T) {
+	replicas, _, cleanup := createCounterReplicas(t, "a", "b")
+	defer cleanup()
+	a, b := replicas[0], replicas[1]
+	for i := 0; i < 100; i++ {
+		a.Incr("n")
+	}
+	a.Set("s", "v", nil)
+	a.SAdd("set", "x")
+	deliver(t, a, b)
+
+	// Only the plain string can go; the counter and the set stay
+	a.SetEvictionPolicy(storage.PolicyAllKeysLRU)
+	a.SetMaxMemory(1)
+	if err := a.FreeMemory(); err != storage.ErrOOM {
+		t.Errorf("FreeMemory = %v, want ErrOOM", err)
+	}
+	expectValue(t, "s", "", a)
+	a.SetMaxMemory(0)
+
+	a.Incr("n")
+	deliver(t, a, b)
+	deliver(t, b, a)
+	expectValue(t, "n", "101", a, b)
+}
+
 // BenchmarkZIncrByReplication benchmarks ZINCRBY replication performance
 func BenchmarkZIncrByReplication(b *testing.B) {
 	srv1, srv2, cleanup := createTestServersBench(b)
@@ -0,0 +1,244 @@
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/luoyjx/crdt-redis/config"
+)
+
+// Provider returns the current set of peer addresses (http base URLs)
+type Provider interface {
+	Name() string
+	Discover(ctx context.Context) ([]string, error)
+}
+
+// PeerSet is the replication peer set fed by discovery, implemented by
+// syncer.Syncer. Every node runs discovery, so changes stay local.
+type PeerSet interface {
+	AddLocalPeer(address string) error
+	RemoveLocalPeer(address string) error
+}
+
+// HealthChecker reports whether a peer is reachable
+type HealthChecker func(ctx context.Context, address string) error
+
+// Config for Discoverer
+type Config struct {
+	SelfAddress      string
+	Interval         time.Duration
+	FailureThreshold int           // consecutive failed health checks before a peer is removed; 0 disables health checks
+	HealthTimeout    time.Duration // per-check timeout
+	HealthCheck      HealthChecker // defaults to HTTPHealthCheck
+}
+
+// Discoverer periodically reconciles the peer set with a Provider
+type Discoverer struct {
+	cfg      Config
+	provider Provider
+	peers    PeerSet
+	mu       sync.Mutex
+	managed  map[string]struct{} // peers this discoverer has added
+	failures map[string]int      // consecutive health-check failures per peer
+}
+
+// NewDiscoverer creates a discoverer feeding peers into the given peer set
+func NewDiscoverer(cfg Config, provider Provider, peers PeerSet) *Discoverer {
+	if cfg.Interval <= 0 {
+		cfg.Interval = 30 * time.Second
+	}
+	if cfg.HealthTimeout <= 0 {
+		cfg.HealthTimeout = 2 * time.Second
+	}
+	if cfg.HealthCheck == nil {
+		cfg.HealthCheck = HTTPHealthCheck
+	}
+	return &Discoverer{
+		cfg:      cfg,
+		provider: provider,
+		peers:    peers,
+		managed:  make(map[string]struct{}),
+		failures: make(map[string]int),
+	}
+}
+
+// Start runs discovery immediately and then every interval until stop is closed.
+// Providers implementing Watcher also trigger a round whenever they change.
+func (d *Discoverer) Start(stop <-chan struct{}) {
+	var changes <-chan struct{}
+	if w, ok := d.provider.(Watcher); ok {
+		changes = w.Watch(stop)
+	}
+	go func() {
+		ticker := time.NewTicker(d.cfg.Interval)
+		defer ticker.Stop()
+
+		d.refresh()
+		for {
+			select {
+			case <-ticker.C:
+				d.refresh()
+			case <-changes:
+				d.refresh()
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
+
+func (d *Discoverer) refresh() {
+	ctx, cancel := context.WithTimeout(context.Background(), d.cfg.Interval)
+	defer cancel()
+	if err := d.Reconcile(ctx); err != nil {
+		log.Printf("Discovery via %s failed: %v", d.provider.Name(), err)
+	}
+}
+
+// Reconcile runs one discovery round: discovered and healthy peers are added,
+// managed peers that disappeared or failed health checks are removed.
+// Peers added by other means (flags, CRDT.PEER) are never removed here.
+func (d *Discoverer) Reconcile(ctx context.Context) error {
+	addrs, err := d.provider.Discover(ctx)
+	if err != nil {
+		// Keep the current peer set when the provider is unavailable
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	desired := make(map[string]struct{})
+	for _, addr := range addrs {
+		if addr == "" || addr == d.cfg.SelfAddress {
+			continue
+		}
+		if d.cfg.FailureThreshold > 0 && !d.healthy(ctx, addr) {
+			continue
+		}
+		desired[addr] = struct{}{}
+	}
+
+	for addr := range desired {
+		if _, ok := d.managed[addr]; ok {
+			continue
+		}
+		if err := d.peers.AddLocalPeer(addr); err != nil {
+			log.Printf("Discovery failed to add peer %s: %v", addr, err)
+			continue
+		}
+		d.managed[addr] = struct{}{}
+	}
+
+	for addr := range d.managed {
+		if _, ok := desired[addr]; ok {
+			continue
+		}
+		if err := d.peers.RemoveLocalPeer(addr); err != nil {
+			log.Printf("Discovery failed to remove peer %s: %v", addr, err)
+			continue
+		}
+		delete(d.managed, addr)
+	}
+
+	for addr := range d.failures {
+		if !contains(addrs, addr) {
+			delete(d.failures, addr)
+		}
+	}
+	return nil
+}
+
+// healthy checks a peer and reports false once it has failed FailureThreshold
+// consecutive checks; callers must hold d.mu
+func (d *Discoverer) healthy(ctx context.Context, addr string) bool {
+	checkCtx, cancel := context.WithTimeout(ctx, d.cfg.HealthTimeout)
+	defer cancel()
+
+	if err := d.cfg.HealthCheck(checkCtx, addr); err != nil {
+		d.failures[addr]++
+		if d.failures[addr] >= d.cfg.FailureThreshold {
+			return false
+		}
+		// Not yet over the threshold: keep a peer we already track, but don't add a new one
+		_, managed := d.managed[addr]
+		return managed
+	}
+	delete(d.failures, addr)
+	return true
+}
+
+// Managed returns the peers currently added by this discoverer
+func (d *Discoverer) Managed() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	addrs := make([]string, 0, len(d.managed))
+	for addr := range d.managed {
+		addrs = append(addrs, addr)
+	}
+	sort.Strings(addrs)
+	return addrs
+}
+
+// HTTPHealthCheck probes a peer's /health endpoint
+func HTTPHealthCheck(ctx context.Context, address string) error {
+	return NewHTTPHealthCheck(http.DefaultClient)(ctx, address)
+}
+
+// NewHTTPHealthCheck returns a HealthChecker probing /health with client,
+// e.g. one configured for mutual TLS
+func NewHTTPHealthCheck(client *http.Client) HealthChecker {
+	return func(ctx context.Context, address string) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, address+"/health", nil)
+		if err != nil {
+			return err
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("health check returned status %d", resp.StatusCode)
+		}
+		return nil
+	}
+}
+
+// NewProvider builds the provider selected by cfg.DiscoveryMode. DNS SRV
+// records carry no scheme, so it follows the replication TLS setting.
+func NewProvider(cfg *config.Config) (Provider, error) {
+	scheme := "http"
+	if cfg.TLSCAFile != "" {
+		scheme = "https"
+	}
+	switch cfg.DiscoveryMode {
+	case "", "static":
+		return NewStaticProvider(cfg.Peers), nil
+	case "file":
+		return NewFileProvider(cfg.DiscoveryAddr), nil
+	case "dns":
+		return NewDNSSRVProvider(cfg.DiscoveryAddr, scheme), nil
+	case "consul":
+		return NewConsulProvider(cfg.DiscoveryAddr, cfg.ClusterName), nil
+	case "etcd":
+		return NewEtcdProvider(cfg.DiscoveryAddr, "/"+cfg.ClusterName+"/peers/"), nil
+	default:
+		return nil, fmt.Errorf("unknown discovery mode: %s", cfg.DiscoveryMode)
+	}
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
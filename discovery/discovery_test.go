@@ -0,0 +1,274 @@
+package discovery
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/luoyjx/crdt-redis/config"
+)
+
+// fakePeerSet records the peers discovery has added
+type fakePeerSet struct {
+	mu    sync.Mutex
+	peers map[string]struct{}
+}
+
+func newFakePeerSet() *fakePeerSet {
+	return &fakePeerSet{peers: make(map[string]struct{})}
+}
+
+func (f *fakePeerSet) AddLocalPeer(address string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.peers[address] = struct{}{}
+	return nil
+}
+
+func (f *fakePeerSet) RemoveLocalPeer(address string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.peers, address)
+	return nil
+}
+
+func (f *fakePeerSet) list() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var out []string
+	for p := range f.peers {
+		out = append(out, p)
+	}
+	sort.Strings(out)
+	return out
+}
+
+func TestFileProviderFormats(t *testing.T) {
+	dir := t.TempDir()
+	cases := map[string]string{
+		"list.json":  `["http://a:8083", "http://b:8083/"]`,
+		"obj.json":   `{"peers": ["http://a:8083", "http://b:8083"]}`,
+		"peers.yaml": "# cluster peers\npeers:\n  - http://a:8083\n  - \"http://b:8083\"\n",
+		"list.yml":   "- http://a:8083\n- http://b:8083\n",
+	}
+	want := []string{"http://a:8083", "http://b:8083"}
+	for name, content := range cases {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		got, err := NewFileProvider(path).Discover(context.Background())
+		if err != nil {
+			t.Fatalf("%s: Discover failed: %v", name, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: got %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestFileProviderWatch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "peers.json")
+	if err := os.WriteFile(path, []byte(`["http://a:8083"]`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	p := NewFileProvider(path)
+	p.PollInterval = 10 * time.Millisecond
+	peers := newFakePeerSet()
+	d := NewDiscoverer(Config{Interval: time.Hour}, p, peers)
+
+	stop := make(chan struct{})
+	defer close(stop)
+	d.Start(stop)
+
+	waitFor(t, func() bool { return reflect.DeepEqual(peers.list(), []string{"http://a:8083"}) })
+
+	// Ensure the modification time moves even on coarse-grained filesystems
+	later := time.Now().Add(2 * time.Second)
+	if err := os.WriteFile(path, []byte(`["http://b:8083", "http://c:8083"]`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	os.Chtimes(path, later, later)
+
+	waitFor(t, func() bool { return reflect.DeepEqual(peers.list(), []string{"http://b:8083", "http://c:8083"}) })
+}
+
+func TestDNSSRVProvider(t *testing.T) {
+	p := NewDNSSRVProvider("_crdt._tcp.example.com", "http")
+	p.Lookup = func(ctx context.Context, name string) ([]*net.SRV, error) {
+		if name != "_crdt._tcp.example.com" {
+			t.Errorf("unexpected SRV name %s", name)
+		}
+		return []*net.SRV{
+			{Target: "node1.example.com.", Port: 8083},
+			{Target: "node2.example.com.", Port: 8084},
+		}, nil
+	}
+	got, err := p.Discover(context.Background())
+	if err != nil {
+		t.Fatalf("Discover failed: %v", err)
+	}
+	want := []string{"http://node1.example.com:8083", "http://node2.example.com:8084"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestNewProviderDNSSchemeFollowsTLS(t *testing.T) {
+	for caFile, want := range map[string]string{"": "http", "ca.pem": "https"} {
+		p, err := NewProvider(&config.Config{DiscoveryMode: "dns", DiscoveryAddr: "_crdt._tcp.example.com", TLSCAFile: caFile})
+		if err != nil {
+			t.Fatalf("NewProvider failed: %v", err)
+		}
+		if got := p.(*DNSSRVProvider).scheme; got != want {
+			t.Errorf("TLSCAFile %q: scheme = %s, want %s", caFile, got, want)
+		}
+	}
+}
+
+func TestConsulProvider(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/health/service/crdt" || r.URL.Query().Get("passing") != "true" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`[
+			{"Node": {"Address": "10.0.0.1"}, "Service": {"Address": "", "Port": 8083}},
+			{"Node": {"Address": "10.0.0.9"}, "Service": {"Address": "10.0.0.2", "Port": 8083, "Meta": {"scheme": "https"}}}
+		]`))
+	}))
+	defer ts.Close()
+
+	got, err := NewConsulProvider(ts.URL, "crdt").Discover(context.Background())
+	if err != nil {
+		t.Fatalf("Discover failed: %v", err)
+	}
+	want := []string{"http://10.0.0.1:8083", "https://10.0.0.2:8083"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestEtcdProvider(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req map[string]string
+		json.NewDecoder(r.Body).Decode(&req)
+		key, _ := base64.StdEncoding.DecodeString(req["key"])
+		end, _ := base64.StdEncoding.DecodeString(req["range_end"])
+		if r.URL.Path != "/v3/kv/range" || string(key) != "/crdt/peers/" || string(end) != "/crdt/peers0" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		enc := func(s string) string { return base64.StdEncoding.EncodeToString([]byte(s)) }
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"kvs": []map[string]string{
+				{"key": enc("/crdt/peers/a"), "value": enc("http://a:8083")},
+				{"key": enc("/crdt/peers/b"), "value": enc("http://b:8083")},
+			},
+		})
+	}))
+	defer ts.Close()
+
+	got, err := NewEtcdProvider(ts.URL, "/crdt/peers/").Discover(context.Background())
+	if err != nil {
+		t.Fatalf("Discover failed: %v", err)
+	}
+	want := []string{"http://a:8083", "http://b:8083"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestReconcileHealthBasedRemoval(t *testing.T) {
+	var mu sync.Mutex
+	down := map[string]bool{}
+	check := func(ctx context.Context, addr string) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if down[addr] {
+			return errors.New("unreachable")
+		}
+		return nil
+	}
+
+	peers := newFakePeerSet()
+	provider := NewStaticProvider([]string{"http://self:8083", "http://a:8083", "http://b:8083"})
+	d := NewDiscoverer(Config{
+		SelfAddress:      "http://self:8083",
+		FailureThreshold: 2,
+		HealthCheck:      check,
+	}, provider, peers)
+
+	ctx := context.Background()
+	if err := d.Reconcile(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := peers.list(); !reflect.DeepEqual(got, []string{"http://a:8083", "http://b:8083"}) {
+		t.Fatalf("peers = %v", got)
+	}
+
+	mu.Lock()
+	down["http://b:8083"] = true
+	mu.Unlock()
+
+	// One failure is tolerated, the second crosses the threshold
+	d.Reconcile(ctx)
+	if got := peers.list(); len(got) != 2 {
+		t.Fatalf("peer removed before threshold: %v", got)
+	}
+	d.Reconcile(ctx)
+	if got := peers.list(); !reflect.DeepEqual(got, []string{"http://a:8083"}) {
+		t.Fatalf("unhealthy peer not removed: %v", got)
+	}
+
+	// Recovery re-adds the peer
+	mu.Lock()
+	down["http://b:8083"] = false
+	mu.Unlock()
+	d.Reconcile(ctx)
+	if got := peers.list(); len(got) != 2 {
+		t.Fatalf("recovered peer not re-added: %v", got)
+	}
+}
+
+func TestReconcileKeepsPeersOnProviderError(t *testing.T) {
+	peers := newFakePeerSet()
+	peers.AddLocalPeer("http://manual:8083")
+	path := filepath.Join(t.TempDir(), "peers.json")
+	os.WriteFile(path, []byte(`["http://a:8083"]`), 0644)
+	d := NewDiscoverer(Config{}, NewFileProvider(path), peers)
+
+	if err := d.Reconcile(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	os.Remove(path)
+	if err := d.Reconcile(context.Background()); err == nil {
+		t.Fatal("expected error for missing file")
+	}
+	if got := peers.list(); !reflect.DeepEqual(got, []string{"http://a:8083", "http://manual:8083"}) {
+		t.Errorf("peers = %v", got)
+	}
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(3 * time.Second)
+	for time.Now().Before(deadline) {
+		if cond() {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("condition not met before timeout")
+}
@@ -0,0 +1,50 @@
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// SRVLookup resolves an SRV name; it matches net.Resolver.LookupSRV with empty service and proto
+type SRVLookup func(ctx context.Context, name string) ([]*net.SRV, error)
+
+// DNSSRVProvider discovers peers from DNS SRV records, e.g. _crdt-sync._tcp.example.com
+type DNSSRVProvider struct {
+	name   string
+	scheme string
+	Lookup SRVLookup
+}
+
+// NewDNSSRVProvider creates a provider resolving the given SRV name; peers are
+// addressed as scheme://target:port
+func NewDNSSRVProvider(name string, scheme string) *DNSSRVProvider {
+	return &DNSSRVProvider{
+		name:   name,
+		scheme: scheme,
+		Lookup: func(ctx context.Context, name string) ([]*net.SRV, error) {
+			_, addrs, err := net.DefaultResolver.LookupSRV(ctx, "", "", name)
+			return addrs, err
+		},
+	}
+}
+
+// Name implements Provider
+func (p *DNSSRVProvider) Name() string {
+	return "dns"
+}
+
+// Discover implements Provider
+func (p *DNSSRVProvider) Discover(ctx context.Context) ([]string, error) {
+	records, err := p.Lookup(ctx, p.name)
+	if err != nil {
+		return nil, fmt.Errorf("SRV lookup for %s failed: %v", p.name, err)
+	}
+	addrs := make([]string, 0, len(records))
+	for _, rec := range records {
+		host := strings.TrimSuffix(rec.Target, ".")
+		addrs = append(addrs, fmt.Sprintf("%s://%s", p.scheme, net.JoinHostPort(host, fmt.Sprint(rec.Port))))
+	}
+	return normalize(addrs), nil
+}
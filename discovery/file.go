@@ -0,0 +1,154 @@
+package discovery
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Watcher is implemented by providers that can signal changes between discovery rounds
+type Watcher interface {
+	Watch(stop <-chan struct{}) <-chan struct{}
+}
+
+// FileProvider reads the peer list from a JSON or YAML file.
+//
+// Accepted formats are a JSON array of addresses, a JSON object with a "peers"
+// array, or a YAML document with either a top-level list or a "peers:" list.
+type FileProvider struct {
+	path         string
+	PollInterval time.Duration
+
+	mu      sync.Mutex
+	modTime time.Time
+	size    int64
+	peers   []string
+}
+
+// NewFileProvider creates a provider backed by the file at path
+func NewFileProvider(path string) *FileProvider {
+	return &FileProvider{path: path, PollInterval: time.Second}
+}
+
+// Name implements Provider
+func (p *FileProvider) Name() string {
+	return "file"
+}
+
+// Discover implements Provider, re-reading the file only when it has changed
+func (p *FileProvider) Discover(ctx context.Context) ([]string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	stat, err := os.Stat(p.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat peer file: %v", err)
+	}
+	if p.peers != nil && stat.ModTime().Equal(p.modTime) && stat.Size() == p.size {
+		return append([]string(nil), p.peers...), nil
+	}
+
+	data, err := os.ReadFile(p.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read peer file: %v", err)
+	}
+	peers, err := parsePeerFile(p.path, data)
+	if err != nil {
+		return nil, err
+	}
+
+	p.modTime = stat.ModTime()
+	p.size = stat.Size()
+	p.peers = peers
+	return append([]string(nil), peers...), nil
+}
+
+// Watch implements Watcher by polling the file's modification time
+func (p *FileProvider) Watch(stop <-chan struct{}) <-chan struct{} {
+	changes := make(chan struct{}, 1)
+	go func() {
+		ticker := time.NewTicker(p.PollInterval)
+		defer ticker.Stop()
+
+		var lastMod time.Time
+		var lastSize int64
+		if stat, err := os.Stat(p.path); err == nil {
+			lastMod, lastSize = stat.ModTime(), stat.Size()
+		}
+		for {
+			select {
+			case <-ticker.C:
+				stat, err := os.Stat(p.path)
+				if err != nil {
+					continue
+				}
+				if stat.ModTime().Equal(lastMod) && stat.Size() == lastSize {
+					continue
+				}
+				lastMod, lastSize = stat.ModTime(), stat.Size()
+				select {
+				case changes <- struct{}{}:
+				default:
+				}
+			case <-stop:
+				return
+			}
+		}
+	}()
+	return changes
+}
+
+// parsePeerFile parses a JSON or YAML peer list
+func parsePeerFile(path string, data []byte) ([]string, error) {
+	ext := strings.ToLower(filepath.Ext(path))
+	trimmed := strings.TrimSpace(string(data))
+	if ext == ".json" || strings.HasPrefix(trimmed, "[") || strings.HasPrefix(trimmed, "{") {
+		var list []string
+		if err := json.Unmarshal(data, &list); err == nil {
+			return normalize(list), nil
+		}
+		var doc struct {
+			Peers []string `json:"peers"`
+		}
+		if err := json.Unmarshal(data, &doc); err != nil {
+			return nil, fmt.Errorf("failed to parse JSON peer file: %v", err)
+		}
+		return normalize(doc.Peers), nil
+	}
+	return parseYAMLPeers(trimmed)
+}
+
+// parseYAMLPeers parses the subset of YAML used for peer lists:
+// a top-level sequence, or a sequence under a "peers:" key
+func parseYAMLPeers(doc string) ([]string, error) {
+	var peers []string
+	inPeers := true
+	for i, line := range strings.Split(doc, "\n") {
+		if idx := strings.Index(line, "#"); idx >= 0 {
+			line = line[:idx]
+		}
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || trimmed == "---" {
+			continue
+		}
+		if strings.HasSuffix(trimmed, ":") && !strings.HasPrefix(trimmed, "-") {
+			inPeers = trimmed == "peers:"
+			continue
+		}
+		if !strings.HasPrefix(trimmed, "-") {
+			return nil, fmt.Errorf("failed to parse YAML peer file: unexpected line %d: %q", i+1, trimmed)
+		}
+		if !inPeers {
+			continue
+		}
+		item := strings.TrimSpace(strings.TrimPrefix(trimmed, "-"))
+		item = strings.Trim(item, `"'`)
+		peers = append(peers, item)
+	}
+	return normalize(peers), nil
+}
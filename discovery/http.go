@@ -0,0 +1,155 @@
+package discovery
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ConsulProvider discovers healthy instances of a service from the Consul HTTP API
+type ConsulProvider struct {
+	addr       string
+	service    string
+	httpClient *http.Client
+}
+
+// NewConsulProvider creates a provider querying the Consul agent at addr (http base URL)
+func NewConsulProvider(addr string, service string) *ConsulProvider {
+	return &ConsulProvider{
+		addr:       strings.TrimRight(addr, "/"),
+		service:    service,
+		httpClient: &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+// Name implements Provider
+func (p *ConsulProvider) Name() string {
+	return "consul"
+}
+
+// Discover implements Provider using /v1/health/service/<service>?passing=true,
+// so instances failing their Consul checks are dropped
+func (p *ConsulProvider) Discover(ctx context.Context) ([]string, error) {
+	url := fmt.Sprintf("%s/v1/health/service/%s?passing=true", p.addr, p.service)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("consul request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("consul returned status %d", resp.StatusCode)
+	}
+
+	var entries []struct {
+		Node struct {
+			Address string `json:"Address"`
+		} `json:"Node"`
+		Service struct {
+			Address string            `json:"Address"`
+			Port    int               `json:"Port"`
+			Meta    map[string]string `json:"Meta"`
+		} `json:"Service"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&entries); err != nil {
+		return nil, fmt.Errorf("failed to decode consul response: %v", err)
+	}
+
+	addrs := make([]string, 0, len(entries))
+	for _, e := range entries {
+		host := e.Service.Address
+		if host == "" {
+			host = e.Node.Address
+		}
+		scheme := e.Service.Meta["scheme"]
+		if scheme == "" {
+			scheme = "http"
+		}
+		addrs = append(addrs, fmt.Sprintf("%s://%s", scheme, net.JoinHostPort(host, strconv.Itoa(e.Service.Port))))
+	}
+	return normalize(addrs), nil
+}
+
+// EtcdProvider discovers peers stored as values under a key prefix, via the etcd v3 JSON gateway
+type EtcdProvider struct {
+	addr       string
+	prefix     string
+	httpClient *http.Client
+}
+
+// NewEtcdProvider creates a provider reading keys under prefix from the etcd endpoint at addr
+func NewEtcdProvider(addr string, prefix string) *EtcdProvider {
+	return &EtcdProvider{
+		addr:       strings.TrimRight(addr, "/"),
+		prefix:     prefix,
+		httpClient: &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+// Name implements Provider
+func (p *EtcdProvider) Name() string {
+	return "etcd"
+}
+
+// Discover implements Provider with a range request over the prefix
+func (p *EtcdProvider) Discover(ctx context.Context) ([]string, error) {
+	body, _ := json.Marshal(map[string]string{
+		"key":       base64.StdEncoding.EncodeToString([]byte(p.prefix)),
+		"range_end": base64.StdEncoding.EncodeToString(prefixRangeEnd([]byte(p.prefix))),
+	})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.addr+"/v3/kv/range", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("etcd request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("etcd returned status %d", resp.StatusCode)
+	}
+
+	var result struct {
+		Kvs []struct {
+			Value string `json:"value"`
+		} `json:"kvs"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode etcd response: %v", err)
+	}
+
+	addrs := make([]string, 0, len(result.Kvs))
+	for _, kv := range result.Kvs {
+		value, err := base64.StdEncoding.DecodeString(kv.Value)
+		if err != nil {
+			continue
+		}
+		addrs = append(addrs, string(value))
+	}
+	return normalize(addrs), nil
+}
+
+// prefixRangeEnd returns the smallest key greater than every key with the given prefix
+func prefixRangeEnd(prefix []byte) []byte {
+	end := append([]byte(nil), prefix...)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return end[:i+1]
+		}
+	}
+	// All 0xff: range to the end of the keyspace
+	return []byte{0}
+}
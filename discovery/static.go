@@ -0,0 +1,44 @@
+package discovery
+
+import (
+	"context"
+	"strings"
+)
+
+// StaticProvider returns a fixed list of peers
+type StaticProvider struct {
+	peers []string
+}
+
+// NewStaticProvider creates a provider for a fixed peer list
+func NewStaticProvider(peers []string) *StaticProvider {
+	return &StaticProvider{peers: normalize(peers)}
+}
+
+// Name implements Provider
+func (p *StaticProvider) Name() string {
+	return "static"
+}
+
+// Discover implements Provider
+func (p *StaticProvider) Discover(ctx context.Context) ([]string, error) {
+	return append([]string(nil), p.peers...), nil
+}
+
+// normalize trims whitespace and trailing slashes and drops empty and duplicate entries
+func normalize(addrs []string) []string {
+	seen := make(map[string]struct{})
+	var out []string
+	for _, addr := range addrs {
+		addr = strings.TrimRight(strings.TrimSpace(addr), "/")
+		if addr == "" {
+			continue
+		}
+		if _, ok := seen[addr]; ok {
+			continue
+		}
+		seen[addr] = struct{}{}
+		out = append(out, addr)
+	}
+	return out
+}
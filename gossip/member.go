@@ -0,0 +1,69 @@
+package gossip
+
+import "fmt"
+
+// State is the liveness state of a member as seen by the local node
+type State int
+
+const (
+	StateAlive State = iota
+	StateSuspect
+	StateDead
+	StateLeft
+)
+
+func (s State) String() string {
+	switch s {
+	case StateAlive:
+		return "alive"
+	case StateSuspect:
+		return "suspect"
+	case StateDead:
+		return "dead"
+	case StateLeft:
+		return "left"
+	default:
+		return fmt.Sprintf("state(%d)", int(s))
+	}
+}
+
+// Member is a cluster node known to the gossip layer
+type Member struct {
+	Name        string `json:"name"`      // unique node name
+	Addr        string `json:"addr"`      // gossip (UDP) address
+	SyncAddr    string `json:"sync_addr"` // http base used by the syncer
+	State       State  `json:"state"`
+	Incarnation uint64 `json:"incarnation"` // bumped by the member itself to refute suspicion
+}
+
+// Delegate receives membership change notifications
+type Delegate interface {
+	NotifyJoin(m Member)
+	NotifyLeave(m Member)
+}
+
+// PeerSet is the replication peer set fed by gossip, implemented by
+// syncer.Syncer. Every member sees the change by gossip, so it stays local.
+type PeerSet interface {
+	AddLocalPeer(address string) error
+	RemoveLocalPeer(address string) error
+}
+
+// PeerSetDelegate adds alive members to a PeerSet and removes dead or departed ones
+type PeerSetDelegate struct {
+	Peers PeerSet
+}
+
+// NotifyJoin implements Delegate
+func (d PeerSetDelegate) NotifyJoin(m Member) {
+	if m.SyncAddr != "" {
+		_ = d.Peers.AddLocalPeer(m.SyncAddr)
+	}
+}
+
+// NotifyLeave implements Delegate
+func (d PeerSetDelegate) NotifyLeave(m Member) {
+	if m.SyncAddr != "" {
+		_ = d.Peers.RemoveLocalPeer(m.SyncAddr)
+	}
+}
@@ -0,0 +1,34 @@
+package gossip
+
+import "encoding/json"
+
+type messageType string
+
+const (
+	msgPing    messageType = "ping"
+	msgPingReq messageType = "ping-req"
+	msgAck     messageType = "ack"
+	msgJoin    messageType = "join"
+	msgJoinAck messageType = "join-ack"
+)
+
+// message is the gossip wire format; membership updates are piggybacked on every message
+type message struct {
+	Type    messageType `json:"type"`
+	Seq     uint64      `json:"seq,omitempty"`
+	From    string      `json:"from"`
+	Target  string      `json:"target,omitempty"` // member name for ping-req
+	Updates []Member    `json:"updates,omitempty"`
+}
+
+func encodeMessage(m *message) ([]byte, error) {
+	return json.Marshal(m)
+}
+
+func decodeMessage(data []byte) (*message, error) {
+	var m message
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
@@ -0,0 +1,513 @@
+package gossip
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Transport sends gossip packets to other nodes by gossip address
+type Transport interface {
+	Send(to string, data []byte) error
+}
+
+// Config for a gossip Node
+type Config struct {
+	Name             string
+	Addr             string // gossip address other nodes send to
+	SyncAddr         string // http base advertised to peers for replication
+	Incarnation      uint64 // starting incarnation; use a wall-clock value so restarts supersede old state
+	ProbeInterval    time.Duration
+	ProbeTimeout     time.Duration // time to wait for a direct ack before asking for indirect pings
+	IndirectChecks   int           // members asked to ping-req a target
+	SuspicionTimeout time.Duration // time a member stays suspect before it is declared dead
+	RetransmitMult   int           // piggyback retransmits = RetransmitMult * ceil(log10(n+1))
+	MaxPiggyback     int           // updates piggybacked per message
+	Seed             int64         // seed for probe order and indirect member selection
+}
+
+// DefaultConfig returns a configuration with SWIM defaults
+func DefaultConfig() Config {
+	return Config{
+		ProbeInterval:    time.Second,
+		ProbeTimeout:     500 * time.Millisecond,
+		IndirectChecks:   3,
+		SuspicionTimeout: 5 * time.Second,
+		RetransmitMult:   4,
+		MaxPiggyback:     8,
+		Seed:             time.Now().UnixNano(),
+	}
+}
+
+type broadcast struct {
+	member    Member
+	transmits int
+}
+
+type probe struct {
+	target       string
+	seq          uint64
+	start        time.Time
+	indirectSent bool
+	acked        bool
+}
+
+// relay is an indirect ping sent on behalf of another member
+type relay struct {
+	requester string // gossip address to forward the ack to
+	seq       uint64 // requester's sequence number
+	sent      time.Time
+}
+
+type notification struct {
+	member Member
+	join   bool
+}
+
+// Node runs the SWIM failure detector and disseminates membership by gossip
+type Node struct {
+	mu         sync.Mutex
+	cfg        Config
+	transport  Transport
+	delegate   Delegate
+	rng        *rand.Rand
+	members    map[string]*Member // by name, including self
+	suspected  map[string]time.Time
+	broadcasts []*broadcast
+	probeOrder []string
+	probeIdx   int
+	probe      *probe
+	lastProbe  time.Time
+	relays     map[uint64]relay
+	seq        uint64
+	pending    []notification
+}
+
+// NewNode creates a gossip node; delegate may be nil
+func NewNode(cfg Config, transport Transport, delegate Delegate) *Node {
+	def := DefaultConfig()
+	if cfg.ProbeInterval <= 0 {
+		cfg.ProbeInterval = def.ProbeInterval
+	}
+	if cfg.ProbeTimeout <= 0 {
+		cfg.ProbeTimeout = cfg.ProbeInterval / 2
+	}
+	if cfg.IndirectChecks <= 0 {
+		cfg.IndirectChecks = def.IndirectChecks
+	}
+	if cfg.SuspicionTimeout <= 0 {
+		cfg.SuspicionTimeout = 5 * cfg.ProbeInterval
+	}
+	if cfg.RetransmitMult <= 0 {
+		cfg.RetransmitMult = def.RetransmitMult
+	}
+	if cfg.MaxPiggyback <= 0 {
+		cfg.MaxPiggyback = def.MaxPiggyback
+	}
+	if cfg.Name == "" {
+		cfg.Name = cfg.Addr
+	}
+
+	n := &Node{
+		cfg:       cfg,
+		transport: transport,
+		delegate:  delegate,
+		rng:       rand.New(rand.NewSource(cfg.Seed)),
+		members:   make(map[string]*Member),
+		suspected: make(map[string]time.Time),
+		relays:    make(map[uint64]relay),
+	}
+	n.members[cfg.Name] = &Member{
+		Name:        cfg.Name,
+		Addr:        cfg.Addr,
+		SyncAddr:    cfg.SyncAddr,
+		State:       StateAlive,
+		Incarnation: cfg.Incarnation,
+	}
+	return n
+}
+
+// Name returns the local member name
+func (n *Node) Name() string {
+	return n.cfg.Name
+}
+
+// Join contacts seed nodes by gossip address; membership arrives in their join-ack
+func (n *Node) Join(seeds []string) {
+	n.mu.Lock()
+	self := *n.members[n.cfg.Name]
+	for _, seed := range seeds {
+		if seed == "" || seed == n.cfg.Addr {
+			continue
+		}
+		n.send(seed, &message{Type: msgJoin, Updates: []Member{self}})
+	}
+	n.mu.Unlock()
+}
+
+// Leave announces a graceful departure to the cluster
+func (n *Node) Leave() {
+	n.mu.Lock()
+	self := n.members[n.cfg.Name]
+	self.State = StateLeft
+	n.queueBroadcast(*self)
+	// Push the departure directly rather than waiting for the next probe
+	for _, m := range n.sortedMembers() {
+		if m.Name != n.cfg.Name && m.State != StateDead && m.State != StateLeft {
+			n.send(m.Addr, &message{Type: msgPing, Seq: n.nextSeq()})
+		}
+	}
+	n.mu.Unlock()
+}
+
+// Members returns a snapshot of all known members sorted by name
+func (n *Node) Members() []Member {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	out := make([]Member, 0, len(n.members))
+	for _, m := range n.sortedMembers() {
+		out = append(out, *m)
+	}
+	return out
+}
+
+// Run drives the protocol from the wall clock until stop is closed
+func (n *Node) Run(stop <-chan struct{}) {
+	ticker := time.NewTicker(n.cfg.ProbeInterval / 10)
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			n.Tick(now)
+		case <-stop:
+			return
+		}
+	}
+}
+
+// Tick advances probe and suspicion timers to now
+func (n *Node) Tick(now time.Time) {
+	n.mu.Lock()
+	n.tick(now)
+	pending := n.takePending()
+	n.mu.Unlock()
+	n.dispatch(pending)
+}
+
+// HandlePacket processes a packet received from the given gossip address
+func (n *Node) HandlePacket(from string, data []byte, now time.Time) {
+	msg, err := decodeMessage(data)
+	if err != nil {
+		log.Printf("Dropping malformed gossip packet from %s: %v", from, err)
+		return
+	}
+
+	n.mu.Lock()
+	n.handle(from, msg, now)
+	pending := n.takePending()
+	n.mu.Unlock()
+	n.dispatch(pending)
+}
+
+func (n *Node) tick(now time.Time) {
+	if p := n.probe; p != nil {
+		target, known := n.members[p.target]
+		switch {
+		case p.acked || !known:
+			n.probe = nil
+		case now.Sub(p.start) >= n.cfg.ProbeInterval:
+			// No direct or indirect ack within the protocol period
+			n.suspect(target.Name, target.Incarnation, now)
+			n.probe = nil
+		case !p.indirectSent && now.Sub(p.start) >= n.cfg.ProbeTimeout:
+			p.indirectSent = true
+			for _, helper := range n.pickIndirect(p.target) {
+				n.send(helper.Addr, &message{Type: msgPingReq, Seq: p.seq, Target: p.target})
+			}
+		}
+	}
+
+	// The requester gives up on its probe after one protocol period
+	for seq, r := range n.relays {
+		if now.Sub(r.sent) >= n.cfg.ProbeInterval {
+			delete(n.relays, seq)
+		}
+	}
+
+	if n.probe == nil && now.Sub(n.lastProbe) >= n.cfg.ProbeInterval {
+		n.startProbe(now)
+	}
+
+	for _, name := range n.sortedSuspects() {
+		if now.Sub(n.suspected[name]) < n.cfg.SuspicionTimeout {
+			continue
+		}
+		m := n.members[name]
+		delete(n.suspected, name)
+		if m.State != StateSuspect {
+			continue
+		}
+		m.State = StateDead
+		n.queueBroadcast(*m)
+		n.notify(*m, false)
+	}
+}
+
+func (n *Node) startProbe(now time.Time) {
+	n.lastProbe = now
+	target := n.nextProbeTarget()
+	if target == nil {
+		return
+	}
+	n.probe = &probe{target: target.Name, seq: n.nextSeq(), start: now}
+	n.send(target.Addr, &message{Type: msgPing, Seq: n.probe.seq})
+}
+
+// nextProbeTarget walks a shuffled member list round-robin, reshuffling after each pass
+func (n *Node) nextProbeTarget() *Member {
+	for attempts := 0; attempts < 2; attempts++ {
+		for n.probeIdx < len(n.probeOrder) {
+			name := n.probeOrder[n.probeIdx]
+			n.probeIdx++
+			if m, ok := n.members[name]; ok && probeable(m) && name != n.cfg.Name {
+				return m
+			}
+		}
+		n.probeOrder = n.probeOrder[:0]
+		for _, m := range n.sortedMembers() {
+			if m.Name != n.cfg.Name && probeable(m) {
+				n.probeOrder = append(n.probeOrder, m.Name)
+			}
+		}
+		n.rng.Shuffle(len(n.probeOrder), func(i, j int) {
+			n.probeOrder[i], n.probeOrder[j] = n.probeOrder[j], n.probeOrder[i]
+		})
+		n.probeIdx = 0
+	}
+	return nil
+}
+
+func (n *Node) pickIndirect(target string) []*Member {
+	var candidates []*Member
+	for _, m := range n.sortedMembers() {
+		if m.Name != n.cfg.Name && m.Name != target && m.State == StateAlive {
+			candidates = append(candidates, m)
+		}
+	}
+	n.rng.Shuffle(len(candidates), func(i, j int) { candidates[i], candidates[j] = candidates[j], candidates[i] })
+	if len(candidates) > n.cfg.IndirectChecks {
+		candidates = candidates[:n.cfg.IndirectChecks]
+	}
+	return candidates
+}
+
+func (n *Node) handle(from string, msg *message, now time.Time) {
+	for _, u := range msg.Updates {
+		n.applyUpdate(u, now)
+	}
+
+	switch msg.Type {
+	case msgPing:
+		n.send(from, &message{Type: msgAck, Seq: msg.Seq})
+	case msgPingReq:
+		target, ok := n.members[msg.Target]
+		if !ok {
+			return
+		}
+		seq := n.nextSeq()
+		n.relays[seq] = relay{requester: from, seq: msg.Seq, sent: now}
+		n.send(target.Addr, &message{Type: msgPing, Seq: seq})
+	case msgAck:
+		if p := n.probe; p != nil && p.seq == msg.Seq {
+			p.acked = true
+			return
+		}
+		if r, ok := n.relays[msg.Seq]; ok {
+			delete(n.relays, msg.Seq)
+			n.send(r.requester, &message{Type: msgAck, Seq: r.seq})
+		}
+	case msgJoin:
+		var all []Member
+		for _, m := range n.sortedMembers() {
+			all = append(all, *m)
+		}
+		n.send(from, &message{Type: msgJoinAck, Updates: all})
+	case msgJoinAck:
+		// Updates already applied above
+	}
+}
+
+// applyUpdate merges a piggybacked membership update using SWIM precedence rules
+func (n *Node) applyUpdate(u Member, now time.Time) {
+	if u.Name == n.cfg.Name {
+		self := n.members[n.cfg.Name]
+		if self.State == StateLeft {
+			return
+		}
+		if u.State != StateAlive && u.Incarnation >= self.Incarnation {
+			// Refute: only we may bump our own incarnation
+			self.Incarnation = u.Incarnation + 1
+			n.queueBroadcast(*self)
+		}
+		return
+	}
+
+	cur, known := n.members[u.Name]
+	if !known {
+		if u.State != StateAlive && u.State != StateSuspect {
+			return
+		}
+		m := u
+		n.members[u.Name] = &m
+		if m.State == StateSuspect {
+			n.suspected[m.Name] = now
+		}
+		n.queueBroadcast(m)
+		n.notify(m, true)
+		return
+	}
+
+	switch u.State {
+	case StateAlive:
+		if u.Incarnation <= cur.Incarnation {
+			return
+		}
+		wasDown := cur.State == StateDead || cur.State == StateLeft
+		cur.State = StateAlive
+		cur.Incarnation = u.Incarnation
+		cur.Addr, cur.SyncAddr = u.Addr, u.SyncAddr
+		delete(n.suspected, cur.Name)
+		n.queueBroadcast(*cur)
+		if wasDown {
+			n.notify(*cur, true)
+		}
+	case StateSuspect:
+		if u.Incarnation < cur.Incarnation || cur.State != StateAlive {
+			return
+		}
+		n.suspect(cur.Name, u.Incarnation, now)
+	case StateDead, StateLeft:
+		if u.Incarnation < cur.Incarnation || cur.State == StateDead || cur.State == StateLeft {
+			return
+		}
+		cur.State = u.State
+		cur.Incarnation = u.Incarnation
+		delete(n.suspected, cur.Name)
+		n.queueBroadcast(*cur)
+		n.notify(*cur, false)
+	}
+}
+
+func (n *Node) suspect(name string, incarnation uint64, now time.Time) {
+	m, ok := n.members[name]
+	if !ok || m.State != StateAlive {
+		return
+	}
+	m.State = StateSuspect
+	m.Incarnation = incarnation
+	n.suspected[name] = now
+	n.queueBroadcast(*m)
+}
+
+// queueBroadcast replaces any queued update about the same member
+func (n *Node) queueBroadcast(m Member) {
+	for i, b := range n.broadcasts {
+		if b.member.Name == m.Name {
+			n.broadcasts = append(n.broadcasts[:i], n.broadcasts[i+1:]...)
+			break
+		}
+	}
+	n.broadcasts = append(n.broadcasts, &broadcast{member: m})
+}
+
+// send attaches piggybacked updates and transmits the message
+func (n *Node) send(to string, msg *message) {
+	msg.From = n.cfg.Name
+	limit := n.cfg.RetransmitMult * int(math.Ceil(math.Log10(float64(len(n.members)+1))))
+	kept := n.broadcasts[:0]
+	for _, b := range n.broadcasts {
+		if len(msg.Updates) < n.cfg.MaxPiggyback {
+			msg.Updates = append(msg.Updates, b.member)
+			b.transmits++
+		}
+		if b.transmits < limit {
+			kept = append(kept, b)
+		}
+	}
+	n.broadcasts = kept
+
+	data, err := encodeMessage(msg)
+	if err != nil {
+		return
+	}
+	if err := n.transport.Send(to, data); err != nil {
+		log.Printf("Failed to send gossip %s to %s: %v", msg.Type, to, err)
+	}
+}
+
+func (n *Node) nextSeq() uint64 {
+	n.seq++
+	return n.seq
+}
+
+func (n *Node) notify(m Member, join bool) {
+	n.pending = append(n.pending, notification{member: m, join: join})
+}
+
+func (n *Node) takePending() []notification {
+	pending := n.pending
+	n.pending = nil
+	return pending
+}
+
+// dispatch delivers notifications outside the node lock
+func (n *Node) dispatch(pending []notification) {
+	if n.delegate == nil {
+		return
+	}
+	for _, p := range pending {
+		if p.join {
+			n.delegate.NotifyJoin(p.member)
+		} else {
+			n.delegate.NotifyLeave(p.member)
+		}
+	}
+}
+
+func (n *Node) sortedMembers() []*Member {
+	out := make([]*Member, 0, len(n.members))
+	for _, m := range n.members {
+		out = append(out, m)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+func (n *Node) sortedSuspects() []string {
+	out := make([]string, 0, len(n.suspected))
+	for name := range n.suspected {
+		out = append(out, name)
+	}
+	sort.Strings(out)
+	return out
+}
+
+func probeable(m *Member) bool {
+	return m.State == StateAlive || m.State == StateSuspect
+}
+
+// InfoLines renders membership for the INFO replication section
+func (n *Node) InfoLines() []string {
+	members := n.Members()
+	lines := []string{fmt.Sprintf("gossip_members:%d", len(members))}
+	for i, m := range members {
+		lines = append(lines, fmt.Sprintf("gossip_member%d:name=%s,addr=%s,sync_addr=%s,state=%s,incarnation=%d",
+			i, m.Name, m.Addr, m.SyncAddr, m.State, m.Incarnation))
+	}
+	return lines
+}
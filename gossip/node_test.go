@@ -0,0 +1,228 @@
+package gossip
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+type recordingDelegate struct {
+	mu     sync.Mutex
+	peers  map[string]bool
+	leaves []string
+}
+
+func newRecordingDelegate() *recordingDelegate {
+	return &recordingDelegate{peers: make(map[string]bool)}
+}
+
+func (d *recordingDelegate) NotifyJoin(m Member) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.peers[m.SyncAddr] = true
+}
+
+func (d *recordingDelegate) NotifyLeave(m Member) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.peers, m.SyncAddr)
+	d.leaves = append(d.leaves, m.Name)
+}
+
+func (d *recordingDelegate) list() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var out []string
+	for p := range d.peers {
+		out = append(out, p)
+	}
+	sort.Strings(out)
+	return out
+}
+
+type simCluster struct {
+	net       *SimNetwork
+	nodes     []*Node
+	delegates []*recordingDelegate
+}
+
+func newSimCluster(t *testing.T, seed int64, size int) *simCluster {
+	t.Helper()
+	c := &simCluster{net: NewSimNetwork(seed)}
+	for i := 0; i < size; i++ {
+		addr := fmt.Sprintf("node%d:7946", i)
+		d := newRecordingDelegate()
+		n := NewNode(Config{
+			Name:             fmt.Sprintf("node%d", i),
+			Addr:             addr,
+			SyncAddr:         fmt.Sprintf("http://node%d:8083", i),
+			ProbeInterval:    100 * time.Millisecond,
+			ProbeTimeout:     40 * time.Millisecond,
+			SuspicionTimeout: 500 * time.Millisecond,
+			Seed:             seed + int64(i),
+		}, c.net.Transport(addr), d)
+		c.net.Register(addr, n)
+		c.nodes = append(c.nodes, n)
+		c.delegates = append(c.delegates, d)
+	}
+	for _, n := range c.nodes[1:] {
+		n.Join([]string{"node0:7946"})
+	}
+	return c
+}
+
+func stateOf(n *Node, name string) (State, bool) {
+	for _, m := range n.Members() {
+		if m.Name == name {
+			return m.State, true
+		}
+	}
+	return 0, false
+}
+
+func TestGossipJoinConverges(t *testing.T) {
+	c := newSimCluster(t, 1, 5)
+	c.net.Run(2*time.Second, 10*time.Millisecond)
+
+	for i, n := range c.nodes {
+		members := n.Members()
+		if len(members) != 5 {
+			t.Fatalf("node%d knows %d members, want 5", i, len(members))
+		}
+		for _, m := range members {
+			if m.State != StateAlive {
+				t.Errorf("node%d sees %s as %s", i, m.Name, m.State)
+			}
+		}
+		if got := c.delegates[i].list(); len(got) != 4 {
+			t.Errorf("node%d delegate peers = %v, want 4 peers", i, got)
+		}
+	}
+}
+
+func TestGossipDetectsCrashedNode(t *testing.T) {
+	c := newSimCluster(t, 2, 5)
+	c.net.Run(2*time.Second, 10*time.Millisecond)
+
+	c.net.Crash("node3:7946")
+	c.net.Run(3*time.Second, 10*time.Millisecond)
+
+	for i, n := range c.nodes {
+		if i == 3 {
+			continue
+		}
+		if st, _ := stateOf(n, "node3"); st != StateDead {
+			t.Errorf("node%d sees node3 as %s, want dead", i, st)
+		}
+		for _, p := range c.delegates[i].list() {
+			if p == "http://node3:8083" {
+				t.Errorf("node%d still has node3 as a replication peer", i)
+			}
+		}
+	}
+}
+
+func TestGossipIndirectProbeAvoidsFalsePositive(t *testing.T) {
+	c := newSimCluster(t, 3, 4)
+	c.net.Run(2*time.Second, 10*time.Millisecond)
+
+	// node0 cannot reach node1 directly, but others can vouch for it
+	c.net.Partition("node0:7946", "node1:7946")
+	c.net.Run(3*time.Second, 10*time.Millisecond)
+
+	for i, n := range c.nodes {
+		for _, m := range n.Members() {
+			if m.State == StateDead {
+				t.Errorf("node%d declared %s dead despite indirect reachability", i, m.Name)
+			}
+		}
+	}
+}
+
+func TestGossipRefutesSuspicion(t *testing.T) {
+	c := newSimCluster(t, 4, 3)
+	c.net.Run(time.Second, 10*time.Millisecond)
+
+	before := c.nodes[2].Members()
+	var inc uint64
+	for _, m := range before {
+		if m.Name == "node2" {
+			inc = m.Incarnation
+		}
+	}
+
+	// Briefly isolate node2 so it gets suspected, then heal before the suspicion times out
+	c.net.Partition("node0:7946", "node2:7946")
+	c.net.Partition("node1:7946", "node2:7946")
+	c.net.Run(250*time.Millisecond, 10*time.Millisecond)
+	c.net.Heal("node0:7946", "node2:7946")
+	c.net.Heal("node1:7946", "node2:7946")
+	c.net.Run(2*time.Second, 10*time.Millisecond)
+
+	for i, n := range c.nodes {
+		st, _ := stateOf(n, "node2")
+		if st != StateAlive {
+			t.Errorf("node%d sees node2 as %s after refutation", i, st)
+		}
+	}
+	for _, m := range c.nodes[0].Members() {
+		if m.Name == "node2" && m.Incarnation <= inc {
+			t.Errorf("node2 incarnation %d not bumped above %d", m.Incarnation, inc)
+		}
+	}
+}
+
+func TestGossipLeave(t *testing.T) {
+	c := newSimCluster(t, 5, 3)
+	c.net.Run(time.Second, 10*time.Millisecond)
+
+	c.nodes[1].Leave()
+	c.net.Run(200*time.Millisecond, 10*time.Millisecond)
+	c.net.Crash("node1:7946")
+	c.net.Run(time.Second, 10*time.Millisecond)
+
+	for _, i := range []int{0, 2} {
+		if st, _ := stateOf(c.nodes[i], "node1"); st != StateLeft {
+			t.Errorf("node%d sees node1 as %s, want left", i, st)
+		}
+	}
+}
+
+func TestGossipExpiresRelays(t *testing.T) {
+	c := newSimCluster(t, 11, 5)
+	c.net.Run(time.Second, 10*time.Millisecond)
+
+	// Indirect pings to a crashed node are never acked
+	c.net.Crash("node3:7946")
+	c.net.Run(2*time.Second, 10*time.Millisecond)
+
+	for i, n := range c.nodes {
+		n.mu.Lock()
+		relays := len(n.relays)
+		n.mu.Unlock()
+		if relays != 0 {
+			t.Errorf("node%d kept %d relays for unanswered indirect pings", i, relays)
+		}
+	}
+}
+
+func TestSimNetworkDeterministic(t *testing.T) {
+	run := func() (int, int, []Member) {
+		c := newSimCluster(t, 42, 6)
+		c.net.LossRate = 0.1
+		c.net.Run(3*time.Second, 10*time.Millisecond)
+		return c.net.Delivered, c.net.Dropped, c.nodes[0].Members()
+	}
+	d1, x1, m1 := run()
+	d2, x2, m2 := run()
+	if d1 != d2 || x1 != x2 {
+		t.Fatalf("runs diverged: delivered %d/%d dropped %d/%d", d1, d2, x1, x2)
+	}
+	for i := range m1 {
+		if m1[i] != m2[i] {
+			t.Fatalf("membership diverged: %+v vs %+v", m1[i], m2[i])
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package gossip
+
+import (
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+)
+
+// SimNetwork is a deterministic in-process network for exercising gossip nodes.
+// Time is virtual and only advances through Run, so a given seed always
+// produces the same sequence of deliveries, losses and probe choices.
+type SimNetwork struct {
+	mu        sync.Mutex
+	now       time.Time
+	rng       *rand.Rand
+	nodes     map[string]*Node
+	queue     []simPacket
+	seq       uint64
+	crashed   map[string]bool
+	blocked   map[[2]string]bool
+	Latency   time.Duration
+	LossRate  float64 // probability a packet is dropped
+	Delivered int
+	Dropped   int
+}
+
+type simPacket struct {
+	from, to  string
+	data      []byte
+	deliverAt time.Time
+	seq       uint64
+}
+
+// NewSimNetwork creates a simulated network with the given random seed
+func NewSimNetwork(seed int64) *SimNetwork {
+	return &SimNetwork{
+		now:     time.Unix(0, 0),
+		rng:     rand.New(rand.NewSource(seed)),
+		nodes:   make(map[string]*Node),
+		crashed: make(map[string]bool),
+		blocked: make(map[[2]string]bool),
+		Latency: time.Millisecond,
+	}
+}
+
+// Now returns the current virtual time
+func (s *SimNetwork) Now() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.now
+}
+
+// Transport returns a transport sending from addr through the simulated network
+func (s *SimNetwork) Transport(addr string) Transport {
+	return &simTransport{net: s, addr: addr}
+}
+
+// Register attaches a node so it receives packets addressed to addr
+func (s *SimNetwork) Register(addr string, n *Node) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nodes[addr] = n
+}
+
+// Crash stops a node from sending, receiving and ticking
+func (s *SimNetwork) Crash(addr string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.crashed[addr] = true
+}
+
+// Partition drops all traffic between a and b in both directions
+func (s *SimNetwork) Partition(a, b string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.blocked[[2]string{a, b}] = true
+	s.blocked[[2]string{b, a}] = true
+}
+
+// Heal removes a partition between a and b
+func (s *SimNetwork) Heal(a, b string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.blocked, [2]string{a, b})
+	delete(s.blocked, [2]string{b, a})
+}
+
+// Run advances virtual time by d in increments of step, delivering due packets
+// and ticking every live node at each step
+func (s *SimNetwork) Run(d, step time.Duration) {
+	end := s.Now().Add(d)
+	for s.Now().Before(end) {
+		s.mu.Lock()
+		s.now = s.now.Add(step)
+		now := s.now
+		s.mu.Unlock()
+
+		// Deliveries may enqueue replies due within this same step
+		for {
+			p, ok := s.nextDue(now)
+			if !ok {
+				break
+			}
+			p.node.HandlePacket(p.from, p.data, now)
+		}
+		for _, addr := range s.liveAddrs() {
+			s.nodes[addr].Tick(now)
+		}
+	}
+}
+
+type dueDelivery struct {
+	node *Node
+	from string
+	data []byte
+}
+
+func (s *SimNetwork) nextDue(now time.Time) (dueDelivery, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sort.Slice(s.queue, func(i, j int) bool {
+		if !s.queue[i].deliverAt.Equal(s.queue[j].deliverAt) {
+			return s.queue[i].deliverAt.Before(s.queue[j].deliverAt)
+		}
+		return s.queue[i].seq < s.queue[j].seq
+	})
+	for len(s.queue) > 0 && !s.queue[0].deliverAt.After(now) {
+		p := s.queue[0]
+		s.queue = s.queue[1:]
+		n, ok := s.nodes[p.to]
+		if !ok || s.crashed[p.to] {
+			s.Dropped++
+			continue
+		}
+		s.Delivered++
+		return dueDelivery{node: n, from: p.from, data: p.data}, true
+	}
+	return dueDelivery{}, false
+}
+
+func (s *SimNetwork) liveAddrs() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []string
+	for addr := range s.nodes {
+		if !s.crashed[addr] {
+			out = append(out, addr)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+func (s *SimNetwork) enqueue(from, to string, data []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.crashed[from] || s.blocked[[2]string{from, to}] {
+		s.Dropped++
+		return
+	}
+	if s.LossRate > 0 && s.rng.Float64() < s.LossRate {
+		s.Dropped++
+		return
+	}
+	s.seq++
+	s.queue = append(s.queue, simPacket{
+		from:      from,
+		to:        to,
+		data:      append([]byte(nil), data...),
+		deliverAt: s.now.Add(s.Latency),
+		seq:       s.seq,
+	})
+}
+
+type simTransport struct {
+	net  *SimNetwork
+	addr string
+}
+
+// Send implements Transport
+func (t *simTransport) Send(to string, data []byte) error {
+	t.net.enqueue(t.addr, to, data)
+	return nil
+}
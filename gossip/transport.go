@@ -0,0 +1,61 @@
+package gossip
+
+import (
+	"net"
+	"time"
+)
+
+// maxPacketSize bounds a single gossip datagram
+const maxPacketSize = 64 * 1024
+
+// UDPTransport sends and receives gossip packets over UDP
+type UDPTransport struct {
+	conn *net.UDPConn
+}
+
+// ListenUDP binds a UDP socket for gossip traffic
+func ListenUDP(addr string) (*UDPTransport, error) {
+	udpAddr, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.ListenUDP("udp", udpAddr)
+	if err != nil {
+		return nil, err
+	}
+	return &UDPTransport{conn: conn}, nil
+}
+
+// Addr returns the bound local address
+func (t *UDPTransport) Addr() string {
+	return t.conn.LocalAddr().String()
+}
+
+// Send implements Transport
+func (t *UDPTransport) Send(to string, data []byte) error {
+	addr, err := net.ResolveUDPAddr("udp", to)
+	if err != nil {
+		return err
+	}
+	_, err = t.conn.WriteToUDP(data, addr)
+	return err
+}
+
+// Serve delivers received packets to the node until the transport is closed
+func (t *UDPTransport) Serve(n *Node) {
+	buf := make([]byte, maxPacketSize)
+	for {
+		size, from, err := t.conn.ReadFromUDP(buf)
+		if err != nil {
+			return
+		}
+		packet := make([]byte, size)
+		copy(packet, buf[:size])
+		n.HandlePacket(from.String(), packet, time.Now())
+	}
+}
+
+// Close closes the UDP socket
+func (t *UDPTransport) Close() error {
+	return t.conn.Close()
+}
@@ -0,0 +1,76 @@
+package logging
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync/atomic"
+)
+
+// Log levels in increasing severity
+const (
+	LevelDebug int32 = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+var levelNames = []string{"debug", "info", "warn", "error"}
+
+var (
+	level  = LevelInfo // updated atomically
+	direct = log.New(os.Stderr, "", log.LstdFlags)
+)
+
+func init() {
+	// Plain log.Printf calls are informational, so they are silenced above info
+	log.SetOutput(filterWriter{w: os.Stderr})
+}
+
+type filterWriter struct {
+	w io.Writer
+}
+
+func (f filterWriter) Write(p []byte) (int, error) {
+	if atomic.LoadInt32(&level) > LevelInfo {
+		return len(p), nil
+	}
+	return f.w.Write(p)
+}
+
+// SetLevel changes the log level at runtime; valid levels are debug, info,
+// warn and error
+func SetLevel(name string) error {
+	for i, n := range levelNames {
+		if n == name {
+			atomic.StoreInt32(&level, int32(i))
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid log level: %s (valid: %v)", name, levelNames)
+}
+
+// Level returns the current log level name
+func Level() string {
+	return levelNames[atomic.LoadInt32(&level)]
+}
+
+// Debugf logs only at debug level
+func Debugf(format string, args ...interface{}) {
+	if atomic.LoadInt32(&level) <= LevelDebug {
+		log.Printf(format, args...)
+	}
+}
+
+// Warnf logs at warn level and below
+func Warnf(format string, args ...interface{}) {
+	if atomic.LoadInt32(&level) <= LevelWarn {
+		direct.Printf(format, args...)
+	}
+}
+
+// Errorf logs at every level
+func Errorf(format string, args ...interface{}) {
+	direct.Printf(format, args...)
+}
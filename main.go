@@ -5,6 +5,7 @@ import (
 	"flag"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
@@ -13,82 +14,385 @@ import (
 	"syscall"
 	"time"
 
-	"github.com/luoyjx/crdt-redis/proto"
+	"github.com/luoyjx/crdt-redis/acl"
+	"github.com/luoyjx/crdt-redis/config"
+	"github.com/luoyjx/crdt-redis/discovery"
+	"github.com/luoyjx/crdt-redis/gossip"
+	"github.com/luoyjx/crdt-redis/logging"
+	"github.com/luoyjx/crdt-redis/metrics"
 	"github.com/luoyjx/crdt-redis/redisprotocol"
 	"github.com/luoyjx/crdt-redis/server"
 	"github.com/luoyjx/crdt-redis/syncer"
+	"github.com/luoyjx/crdt-redis/tlsutil"
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "check-segments" {
+		os.Exit(checkSegments(os.Args[2:], os.Stdout))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "restore" {
+		os.Exit(restore(os.Args[2:], os.Stdout))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "import-rdb" {
+		os.Exit(importRDB(os.Args[2:], os.Stdout))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "export-rdb" {
+		os.Exit(exportRDB(os.Args[2:], os.Stdout))
+	}
+
 	// Parse command line flags
 	dataDir := flag.String("data", "./crdt-redis-data", "directory for persistent storage")
 	port := flag.Int("port", 6380, "port to listen on")
 	raftPort := flag.Int("raft-port", 6381, "port for Raft consensus")
 	httpSyncPort := flag.Int("sync-port", 8083, "http sync port")
+	advertiseAddr := flag.String("advertise-addr", "", "host or host:port peers use to reach the sync endpoint (defaults to 127.0.0.1 on -sync-port; required for multi-host clusters)")
 	peerAddrs := flag.String("peers", "", "comma-separated http peer addresses, e.g. http://127.0.0.1:8084")
 	redisAddr := flag.String("redis", "localhost:6379", "address of local Redis server")
+	storageBackend := flag.String("storage-backend", "write-through", "how the CRDT state is mirrored to -redis: none, write-through or read-through")
+	storageEngine := flag.String("storage-engine", "memory", "where the keyspace is held: memory, or disk for datasets larger than RAM")
+	engineCacheSize := flag.String("engine-cache-size", "64mb", "memory for hot values with -storage-engine disk")
+	flag.String("appendfsync", "everysec", "when the segment log is synced to disk: always, everysec or no")
+	flag.String("save", "3600 1 300 100 60 10000", "snapshot schedule as <seconds> <changes> pairs (empty disables scheduled snapshots)")
+	segmentRecovery := flag.String("segment-recovery", "truncate", "what startup does with corrupt segment entries: strict (refuse to start), skip or truncate")
+	discoveryMode := flag.String("discovery", "static", "peer discovery mode: static, file, dns, consul or etcd")
+	discoveryAddr := flag.String("discovery-addr", "", "peer file path, SRV name, or consul/etcd http address")
+	discoveryInterval := flag.Duration("discovery-interval", 30*time.Second, "interval between discovery rounds")
+	clusterName := flag.String("cluster", "crdt-redis-cluster", "cluster name used as the consul service and etcd prefix")
+	gossipAddr := flag.String("gossip-addr", "", "UDP address for gossip membership, e.g. :7946 (disabled if empty)")
+	gossipAdvertise := flag.String("gossip-advertise", "", "gossip address advertised to other nodes (defaults to the bound address)")
+	gossipSeeds := flag.String("gossip-seeds", "", "comma-separated gossip addresses of nodes to join")
+	nodeName := flag.String("node-name", "", "unique gossip node name (defaults to the gossip address)")
+	flag.String("notify-keyspace-events", "", "keyspace notification classes, e.g. KEA (r publishes remote-origin events on __remote_key*@0__ channels)")
+	requirePass := flag.String("requirepass", "", "password for the default user, defaulting to $CRDT_AUTH_TOKEN (AUTH is not required if empty)")
+	aclReplicate := flag.Bool("acl-replicate", false, "replicate ACL user changes to peers; requires -cluster-secret, -peer-keys or -tls-ca")
+	replicaID := flag.String("replica-id", "", "stable replica ID (generated on each start if empty, unless the data dir was restored from a backup; required with -peer-keys)")
+	clusterSecret := flag.String("cluster-secret", "", "shared secret peers use to sign replication requests")
+	peerKeysFile := flag.String("peer-keys", "", "JSON file mapping replica IDs to per-peer signing keys")
+	syncRateLimit := flag.Float64("sync-rate-limit", 100, "replication requests per second allowed per remote host when authentication is enabled")
+	tlsCert := flag.String("tls-cert", "", "certificate file; enables TLS on the Redis listener")
+	tlsKey := flag.String("tls-key", "", "private key file for -tls-cert")
+	tlsCA := flag.String("tls-ca", "", "CA bundle; enables HTTPS with mutual TLS for replication")
+	tlsAuthClients := flag.Bool("tls-auth-clients", false, "require Redis clients to present a certificate signed by -tls-ca")
+	tlsAllowedPeers := flag.String("tls-allowed-peers", "", "comma-separated peer certificate identities (CN or SAN) accepted for replication")
+	replicaOf := flag.String("replicaof", "", "host:port of a Redis master to replicate from with PSYNC, applying its writes locally (see REPLICAOF)")
+	masterUser := flag.String("masteruser", "", "user for AUTH to the -replicaof master")
+	masterAuth := flag.String("masterauth", "", "password for AUTH to the -replicaof master")
+	configFile := flag.String("config", "", "JSON config file supplying settings not given as flags; SIGHUP reloads it")
+	flag.Duration("sync-interval", time.Second, "interval between replication rounds")
+	flag.Duration("gc-interval", 5*time.Minute, "interval between tombstone garbage collection passes")
+	flag.Duration("tombstone-ttl", time.Hour, "how long tombstones are kept before garbage collection")
+	flag.Duration("oplog-retention", time.Hour, "minimum time operations every peer acknowledged stay in the operation log")
+	flag.Int("oplog-max-operations", 0, "cap on operations in the operation log; peers that fall further behind bootstrap from a snapshot (0 for no cap)")
+	flag.String("maxmemory", "0", "memory limit, e.g. 512mb (0 for no limit)")
+	flag.String("maxmemory-policy", "noeviction", "eviction policy over maxmemory: noeviction, allkeys-lru, allkeys-lfu, volatile-ttl or volatile-lru")
+	flag.String("loglevel", "info", "log level: debug, info, warn or error")
 	flag.Parse()
 
+	cfg, err := loadConfig(flag.CommandLine, *configFile)
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+
 	// Create data directory if it doesn't exist
 	if err := os.MkdirAll(*dataDir, 0755); err != nil {
 		log.Fatalf("Failed to create data directory: %v", err)
 	}
 
+	cacheSize, err := config.ParseMemory(*engineCacheSize)
+	if err != nil {
+		log.Fatalf("Invalid -engine-cache-size: %v", err)
+	}
+
 	// Initialize CRDT Redis Server
-	srv, err := server.NewServer(*dataDir+"/store", *redisAddr, *dataDir+"/oplog")
+	srv, err := server.NewServerWithConfig(server.Config{
+		DataDir:         *dataDir + "/store",
+		RedisAddr:       *redisAddr,
+		Backend:         *storageBackend,
+		Engine:          *storageEngine,
+		EngineCacheSize: cacheSize,
+		SegmentRecovery: *segmentRecovery,
+		OpLogPath:       *dataDir + "/oplog",
+		ReplicaID:       *replicaID,
+	})
 	if err != nil {
 		log.Fatalf("Failed to create server: %v", err)
 	}
 	defer srv.Close()
 
-	// Initialize Redis protocol server
+	cfg.ReplicaID = srv.ReplicaID()
+	if err := cfg.Validate(); err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+
+	// Initialize Redis protocol server; CONFIG reads and changes cfg through the manager
 	redisServer := redisprotocol.NewRedisServer(srv)
+	manager := config.NewManager(cfg, *configFile)
+	redisServer.SetConfigManager(manager)
 	listenAddr := fmt.Sprintf(":%d", *port)
 
+	// TLS for the Redis listener and mutual TLS for replication, reloaded on certificate rotation
+	var certs *tlsutil.Reloader
+	var peerClient *http.Client
+	stopTLS := make(chan struct{})
+	defer close(stopTLS)
+	if *tlsCert != "" {
+		var allowedPeers []string
+		if *tlsAllowedPeers != "" {
+			allowedPeers = strings.Split(*tlsAllowedPeers, ",")
+		}
+		certs, err = tlsutil.NewReloader(tlsutil.Options{
+			CertFile:     *tlsCert,
+			KeyFile:      *tlsKey,
+			CAFile:       *tlsCA,
+			AllowedPeers: allowedPeers,
+		})
+		if err != nil {
+			log.Fatalf("Failed to load TLS certificates: %v", err)
+		}
+		certs.Watch(10*time.Second, stopTLS)
+		redisServer.SetTLSConfig(certs.ServerConfig(*tlsAuthClients))
+		if *tlsCA != "" {
+			peerClient = &http.Client{
+				Timeout:   5 * time.Second,
+				Transport: &http.Transport{TLSClientConfig: certs.ClientConfig()},
+			}
+		}
+	} else if *tlsCA != "" {
+		log.Fatalf("-tls-ca requires -tls-cert and -tls-key")
+	}
+	if *tlsAuthClients && *tlsCA == "" {
+		log.Fatalf("-tls-auth-clients requires -tls-ca")
+	}
+	syncScheme := "http"
+	if peerClient != nil {
+		syncScheme = "https"
+	}
+	selfAddress := syncAddress(syncScheme, *advertiseAddr, *httpSyncPort)
+
+	// Signed replication requests, bound to each peer's replica ID
+	var syncAuth *syncer.Authenticator
+	if *clusterSecret != "" || *peerKeysFile != "" {
+		authCfg := syncer.AuthConfig{
+			SelfID:        srv.ReplicaID(),
+			ClusterSecret: *clusterSecret,
+			RateLimit:     *syncRateLimit,
+		}
+		if *peerKeysFile != "" {
+			if *replicaID == "" {
+				log.Fatalf("-peer-keys requires -replica-id")
+			}
+			data, err := os.ReadFile(*peerKeysFile)
+			if err != nil {
+				log.Fatalf("Failed to read peer keys: %v", err)
+			}
+			if err := json.Unmarshal(data, &authCfg.PeerKeys); err != nil {
+				log.Fatalf("Failed to parse peer keys: %v", err)
+			}
+		}
+		syncAuth, err = syncer.NewAuthenticator(authCfg)
+		if err != nil {
+			log.Fatalf("Failed to configure replication authentication: %v", err)
+		}
+	}
+	if err := checkACLReplication(*aclReplicate, syncAuth != nil, peerClient != nil); err != nil {
+		log.Fatalf("%v", err)
+	}
+	protect := func(h http.Handler) http.Handler {
+		if syncAuth == nil {
+			return h
+		}
+		return syncAuth.Middleware(h)
+	}
+
+	// Background syncer pushing/pulling to peers
+	var peers []syncer.Peer
+	if *peerAddrs != "" {
+		for _, addr := range strings.Split(*peerAddrs, ",") {
+			peers = append(peers, syncer.Peer{Address: strings.TrimSpace(addr)})
+		}
+	}
+	defaults := config.DefaultConfig()
+	syncComponent := syncer.New(syncer.Config{
+		SelfAddress:    selfAddress,
+		Peers:          peers,
+		Interval:       cfg.SyncInterval,
+		MembershipPath: *dataDir + "/peers.json",
+		MaxRetries:     defaults.MaxRetries,
+		RetryInterval:  defaults.RetryInterval,
+		HTTPClient:     peerClient,
+		Auth:           syncAuth,
+		OpLogRetention: cfg.OpLogRetention,
+		OpLogMaxOps:    cfg.OpLogMaxOps,
+	}, srv)
+	redisServer.SetPeerManager(syncComponent)
+	redisServer.SetPublishForwarder(syncComponent.Broadcast)
+	syncComponent.SetMessageHandler(redisServer.DeliverMessage)
+
+	// Hot settings apply at startup, on CONFIG SET and on SIGHUP; the Redis server registered the store ones
+	manager.OnChange("sync-interval", func(c *config.Config) error { return syncComponent.SetInterval(c.SyncInterval) })
+	manager.OnChange("oplog-retention", func(c *config.Config) error { return syncComponent.SetRetention(c.OpLogRetention, c.OpLogMaxOps) })
+	manager.OnChange("oplog-max-operations", func(c *config.Config) error { return syncComponent.SetRetention(c.OpLogRetention, c.OpLogMaxOps) })
+	manager.OnChange("loglevel", func(c *config.Config) error { return logging.SetLevel(c.LogLevel) })
+	if err := manager.Apply(); err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+
+	// ACL users live in the data dir; the default user stays open unless -requirepass is set
+	users, err := acl.Load(*dataDir+"/users.json", *requirePass)
+	if err != nil {
+		log.Fatalf("Failed to load ACL users: %v", err)
+	}
+	users.SetReplicaID(srv.ReplicaID())
+	redisServer.SetACL(users)
+	if *aclReplicate {
+		syncComponent.ReplicateState("/acl", users.ReplicationState)
+	}
+	redisServer.AddInfoSource("replication", syncComponent.InfoLines)
+	redisServer.SetMasterAuth(*masterUser, *masterAuth)
+	if *replicaOf != "" {
+		if err := redisServer.ReplicaOf(*replicaOf); err != nil {
+			log.Fatalf("Invalid -replicaof: %v", err)
+		}
+	}
+	redisServer.AddInfoSource("crdt", syncComponent.CRDTInfoLines)
+
+	// Prometheus metrics for commands, storage, oplog and replication
+	registry := metrics.NewRegistry()
+	redisServer.RegisterMetrics(registry)
+	registry.AddCollector(srv.CollectMetrics)
+	registry.AddCollector(syncComponent.CollectMetrics)
+
 	// Start HTTP sync endpoints and background syncer (MVP)
 	stopSync := make(chan struct{})
 	go func() {
 		// very simple HTTP mux for ops
 		httpAddr := fmt.Sprintf(":%d", *httpSyncPort)
-		http.HandleFunc("/ops", func(w http.ResponseWriter, r *http.Request) {
-			sinceStr := r.URL.Query().Get("since")
-			var since int64
-			if sinceStr != "" {
-				if v, err := strconv.ParseInt(sinceStr, 10, 64); err == nil {
-					since = v
-				}
-			}
-			ops, _ := srv.OpLog().GetOperations(since)
-			batch := proto.OperationBatch{Operations: ops}
-			_ = json.NewEncoder(w).Encode(batch)
-		})
-		http.HandleFunc("/apply", func(w http.ResponseWriter, r *http.Request) {
-			var batch proto.OperationBatch
-			if err := json.NewDecoder(r.Body).Decode(&batch); err == nil {
-				for _, op := range batch.Operations {
-					_ = srv.HandleOperation(r.Context(), op)
-				}
-			}
+		http.Handle("/ops", protect(syncer.HandleOps(srv)))
+		http.Handle("/apply", protect(syncer.HandleApply(srv)))
+		http.Handle("/snapshot", protect(syncer.HandleSnapshot(srv)))
+		http.Handle("/peers", protect(http.HandlerFunc(syncComponent.HandlePeers)))
+		http.Handle("/publish", protect(http.HandlerFunc(syncComponent.HandlePublish)))
+		http.HandleFunc("/replication", syncComponent.HandleReplication)
+		http.Handle("/metrics", registry.Handler())
+		if *aclReplicate {
+			http.Handle("/acl", protect(http.HandlerFunc(users.HandleChanges)))
+		}
+		http.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
 			w.WriteHeader(http.StatusOK)
 		})
+		if peerClient != nil {
+			// Peers must present a certificate signed by -tls-ca
+			httpServer := &http.Server{Addr: httpAddr, TLSConfig: certs.ServerConfig(true)}
+			log.Printf("Starting HTTPS sync endpoint with mutual TLS on %s", httpAddr)
+			_ = httpServer.ListenAndServeTLS("", "")
+			return
+		}
 		log.Printf("Starting HTTP sync endpoint on %s", httpAddr)
 		_ = http.ListenAndServe(httpAddr, nil)
 	}()
 
-	// Background syncer pushing/pulling to peers
-	var peers []syncer.Peer
-	if *peerAddrs != "" {
-		for _, addr := range strings.Split(*peerAddrs, ",") {
-			peers = append(peers, syncer.Peer{Address: strings.TrimSpace(addr)})
+	syncComponent.Start(stopSync)
+
+	// Feed discovered peers into the syncer; static peers come from -peers above
+	if *discoveryMode != "static" {
+		provider, err := discovery.NewProvider(&config.Config{
+			DiscoveryMode: *discoveryMode,
+			DiscoveryAddr: *discoveryAddr,
+			ClusterName:   *clusterName,
+			TLSCAFile:     *tlsCA,
+		})
+		if err != nil {
+			log.Fatalf("Failed to create discovery provider: %v", err)
 		}
+		var healthCheck discovery.HealthChecker
+		if peerClient != nil {
+			healthCheck = discovery.NewHTTPHealthCheck(peerClient)
+		}
+		discoverer := discovery.NewDiscoverer(discovery.Config{
+			SelfAddress:      selfAddress,
+			Interval:         *discoveryInterval,
+			FailureThreshold: 3,
+			HealthCheck:      healthCheck,
+		}, provider, syncComponent)
+		discoverer.Start(stopSync)
 	}
-	syncComponent := syncer.New(syncer.Config{SelfAddress: fmt.Sprintf("http://127.0.0.1:%d", *httpSyncPort), Peers: peers, Interval: time.Second}, srv)
-	syncComponent.Start(stopSync)
+
+	// Gossip membership adds and removes replication peers as nodes come and go
+	var gossipNode *gossip.Node
+	if *gossipAddr != "" {
+		transport, err := gossip.ListenUDP(*gossipAddr)
+		if err != nil {
+			log.Fatalf("Failed to start gossip listener: %v", err)
+		}
+		defer transport.Close()
+
+		gossipCfg := gossip.DefaultConfig()
+		gossipCfg.Name = *nodeName
+		gossipCfg.Addr = transport.Addr()
+		if *gossipAdvertise != "" {
+			gossipCfg.Addr = *gossipAdvertise
+		}
+		gossipCfg.SyncAddr = selfAddress
+		gossipCfg.Incarnation = uint64(time.Now().Unix())
+		gossipNode = gossip.NewNode(gossipCfg, transport, gossip.PeerSetDelegate{Peers: syncComponent})
+		go transport.Serve(gossipNode)
+		go gossipNode.Run(stopSync)
+		if *gossipSeeds != "" {
+			gossipNode.Join(strings.Split(*gossipSeeds, ","))
+		}
+		redisServer.AddInfoSource("replication", gossipNode.InfoLines)
+		log.Printf("Starting gossip membership on %s", transport.Addr())
+	}
+
+	// SIGHUP reloads the config file
+	hupChan := make(chan os.Signal, 1)
+	signal.Notify(hupChan, syscall.SIGHUP)
+	go func() {
+		for range hupChan {
+			if manager.Path() == "" {
+				logging.Warnf("Ignoring SIGHUP: no -config file")
+				continue
+			}
+			applied, restart, err := manager.Reload()
+			if err != nil {
+				logging.Errorf("Failed to reload config: %v", err)
+				continue
+			}
+			log.Printf("Reloaded config from %s, applied: %v", manager.Path(), applied)
+			if len(restart) > 0 {
+				logging.Warnf("Changed settings %v require a restart to take effect", restart)
+			}
+		}
+	}()
 
 	// Handle graceful shutdown
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 
+	// A node restored as the same replica takes back from a peer's snapshot
+	// what it wrote after the backup before accepting writes; only a node
+	// that can never have peers skips this
+	if srv.NeedsBootstrap() {
+		standalone := *discoveryMode == "static" && *gossipAddr == ""
+		for {
+			err := syncComponent.Bootstrap()
+			if err == nil || (err == syncer.ErrNoPeers && standalone) {
+				break
+			}
+			logging.Warnf("Restored replica %s waits to bootstrap from a peer before accepting writes: %v", srv.ReplicaID(), err)
+			select {
+			case <-time.After(syncComponent.Interval()):
+			case <-sigChan:
+				log.Println("Shutting down gracefully...")
+				close(stopSync)
+				return
+			}
+		}
+		if err := srv.BootstrapDone(); err != nil {
+			log.Fatalf("%v", err)
+		}
+	}
+
 	// Start Redis server in a goroutine
 	errChan := make(chan error, 1)
 	go func() {
@@ -109,6 +413,105 @@ func main() {
 		close(stopSync) // Stop syncer
 	}
 
+	// Announce the departure so peers drop us now rather than after suspicion
+	if gossipNode != nil {
+		gossipNode.Leave()
+	}
+
 	// Give a moment for syncer to stop
 	time.Sleep(100 * time.Millisecond)
 }
+
+// checkACLReplication refuses to replicate ACL users over an unauthenticated
+// sync endpoint, where anyone who can reach it could post a user with every
+// permission
+func checkACLReplication(replicate, signed, mutualTLS bool) error {
+	if replicate && !signed && !mutualTLS {
+		return fmt.Errorf("-acl-replicate requires -cluster-secret, -peer-keys or mutual TLS with -tls-ca")
+	}
+	return nil
+}
+
+// syncAddress is the sync endpoint announced to peers: advertise as host or
+// host:port, with the sync port filled in when it has none
+func syncAddress(scheme, advertise string, port int) string {
+	host := advertise
+	if host == "" {
+		host = "127.0.0.1"
+	}
+	if _, _, err := net.SplitHostPort(host); err != nil {
+		host = net.JoinHostPort(strings.Trim(host, "[]"), strconv.Itoa(port))
+	}
+	return fmt.Sprintf("%s://%s", scheme, host)
+}
+
+// flagParams maps command line flags to the config parameters they set
+var flagParams = map[string]string{
+	"port":                   "port",
+	"sync-port":              "sync-port",
+	"advertise-addr":         "advertise-addr",
+	"data":                   "dir",
+	"redis":                  "redis-addr",
+	"storage-backend":        "storage-backend",
+	"storage-engine":         "storage-engine",
+	"engine-cache-size":      "engine-cache-size",
+	"appendfsync":            "appendfsync",
+	"save":                   "save",
+	"segment-recovery":       "segment-recovery",
+	"peers":                  "peers",
+	"discovery":              "discovery-mode",
+	"discovery-addr":         "discovery-addr",
+	"discovery-interval":     "discovery-interval",
+	"cluster":                "cluster-name",
+	"replica-id":             "replica-id",
+	"notify-keyspace-events": "notify-keyspace-events",
+	"requirepass":            "requirepass",
+	"tls-cert":               "tls-cert-file",
+	"tls-key":                "tls-key-file",
+	"tls-ca":                 "tls-ca-file",
+	"tls-auth-clients":       "tls-auth-clients",
+	"tls-allowed-peers":      "tls-allowed-peers",
+	"sync-interval":          "sync-interval",
+	"gc-interval":            "gc-interval",
+	"tombstone-ttl":          "tombstone-ttl",
+	"oplog-retention":        "oplog-retention",
+	"oplog-max-operations":   "oplog-max-operations",
+	"maxmemory":              "maxmemory",
+	"maxmemory-policy":       "maxmemory-policy",
+	"loglevel":               "loglevel",
+}
+
+// loadConfig merges the command line with the environment (CRDT_*) and the
+// config file at path. Flags given explicitly win; the others take their value
+// from the environment or the file, so the rest of main can keep reading flags.
+func loadConfig(flags *flag.FlagSet, path string) (*config.Config, error) {
+	cfg, err := config.LoadFromFile(path)
+	if err != nil {
+		return nil, err
+	}
+	config.LoadFromEnv(cfg)
+	defaults := config.DefaultConfig()
+	explicit := make(map[string]bool)
+	flags.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
+	for name, param := range flagParams {
+		f := flags.Lookup(name)
+		if f == nil {
+			continue
+		}
+		value, _ := cfg.GetParam(param)
+		def, _ := defaults.GetParam(param)
+		if !explicit[name] && (path != "" || value != def) {
+			if _, ok := f.Value.(interface{ IsBoolFlag() bool }); ok {
+				value = strconv.FormatBool(value == "yes")
+			}
+			if err := f.Value.Set(value); err != nil {
+				return nil, fmt.Errorf("-%s: %v", name, err)
+			}
+			continue
+		}
+		if err := cfg.SetParam(param, f.Value.String()); err != nil {
+			return nil, fmt.Errorf("-%s: %v", name, err)
+		}
+	}
+	return cfg, nil
+}
@@ -2,13 +2,16 @@ package main
 
 import (
 	"encoding/json"
+	"flag"
 	"fmt"
 	"net/http"
 	"os"
+	"path/filepath"
 	"strconv"
 	"testing"
 	"time"
 
+	"github.com/luoyjx/crdt-redis/acl"
 	"github.com/luoyjx/crdt-redis/proto"
 	"github.com/luoyjx/crdt-redis/server"
 	"github.com/luoyjx/crdt-redis/syncer"
@@ -249,3 +252,80 @@ func TestIntegrationMultiServer(t *testing.T) {
 			val1, exists1, val2, exists2)
 	}
 }
+
+func TestSyncAddress(t *testing.T) {
+	tests := []struct {
+		scheme, advertise, want string
+	}{
+		{"http", "", "http://127.0.0.1:8083"},
+		{"https", "10.0.0.5", "https://10.0.0.5:8083"},
+		{"http", "node1.example.com:9000", "http://node1.example.com:9000"},
+		{"http", "::1", "http://[::1]:8083"},
+		{"http", "[fd00::1]:9000", "http://[fd00::1]:9000"},
+	}
+	for _, tt := range tests {
+		if got := syncAddress(tt.scheme, tt.advertise, 8083); got != tt.want {
+			t.Errorf("syncAddress(%q, %q) = %q, want %q", tt.scheme, tt.advertise, got, tt.want)
+		}
+	}
+}
+
+func TestACLReplicationRequiresPeerAuth(t *testing.T) {
+	if err := checkACLReplication(true, false, false); err == nil {
+		t.Error("ACL replication over an unauthenticated sync endpoint was allowed")
+	}
+	for _, tt := range []struct{ replicate, signed, mutualTLS bool }{
+		{false, false, false},
+		{true, true, false},
+		{true, false, true},
+	} {
+		if err := checkACLReplication(tt.replicate, tt.signed, tt.mutualTLS); err != nil {
+			t.Errorf("checkACLReplication(%v, %v, %v) = %v", tt.replicate, tt.signed, tt.mutualTLS, err)
+		}
+	}
+}
+
+func TestAuthTokenRequiresAuth(t *testing.T) {
+	t.Setenv("CRDT_AUTH_TOKEN", "s3cret")
+
+	flags := flag.NewFlagSet("crdt-redis", flag.ContinueOnError)
+	requirePass := flags.String("requirepass", "", "")
+	port := flags.Int("port", 6380, "")
+	if err := flags.Parse(nil); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := loadConfig(flags, "")
+	if err != nil {
+		t.Fatalf("loadConfig failed: %v", err)
+	}
+	if *requirePass != "s3cret" || cfg.AuthToken != "s3cret" {
+		t.Fatalf("requirepass = %q, AuthToken = %q, want the CRDT_AUTH_TOKEN value", *requirePass, cfg.AuthToken)
+	}
+	if *port != 6380 {
+		t.Errorf("port = %d, want the flag default", *port)
+	}
+
+	users, err := acl.Load(filepath.Join(t.TempDir(), "users.json"), *requirePass)
+	if err != nil {
+		t.Fatalf("acl.Load failed: %v", err)
+	}
+	if users.DefaultAuthenticated() {
+		t.Error("AUTH should be required when CRDT_AUTH_TOKEN is set")
+	}
+	if users.Authenticate("default", "wrong") || !users.Authenticate("default", "s3cret") {
+		t.Error("default user should authenticate with CRDT_AUTH_TOKEN only")
+	}
+
+	// An explicit flag still wins over the environment
+	explicit := flag.NewFlagSet("crdt-redis", flag.ContinueOnError)
+	requirePass = explicit.String("requirepass", "", "")
+	if err := explicit.Parse([]string{"-requirepass", "override"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadConfig(explicit, ""); err != nil {
+		t.Fatalf("loadConfig failed: %v", err)
+	}
+	if *requirePass != "override" {
+		t.Errorf("requirepass = %q, want the explicit flag", *requirePass)
+	}
+}
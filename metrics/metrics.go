@@ -0,0 +1,331 @@
+package metrics
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefBuckets are the default latency buckets in seconds
+var DefBuckets = []float64{.0001, .00025, .0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1}
+
+// Collector emits metrics computed at scrape time
+type Collector func(w *Writer)
+
+// Registry holds instrumented metrics and scrape-time collectors and renders
+// them in the Prometheus text exposition format
+type Registry struct {
+	mu         sync.Mutex
+	counters   []*CounterVec
+	histograms []*HistogramVec
+	collectors []Collector
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// NewCounterVec registers a counter partitioned by the given label names
+func (r *Registry) NewCounterVec(name, help string, labels ...string) *CounterVec {
+	c := &CounterVec{name: name, help: help, labels: labels, values: make(map[string]*counterValue)}
+	r.mu.Lock()
+	r.counters = append(r.counters, c)
+	r.mu.Unlock()
+	return c
+}
+
+// NewHistogramVec registers a histogram partitioned by the given label names;
+// nil buckets use DefBuckets
+func (r *Registry) NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	if buckets == nil {
+		buckets = DefBuckets
+	}
+	h := &HistogramVec{name: name, help: help, labels: labels, buckets: buckets, values: make(map[string]*histogramValue)}
+	r.mu.Lock()
+	r.histograms = append(r.histograms, h)
+	r.mu.Unlock()
+	return h
+}
+
+// AddCollector registers a function called on every scrape
+func (r *Registry) AddCollector(c Collector) {
+	r.mu.Lock()
+	r.collectors = append(r.collectors, c)
+	r.mu.Unlock()
+}
+
+// Render returns all metrics in the Prometheus text format
+func (r *Registry) Render() string {
+	r.mu.Lock()
+	counters := append([]*CounterVec(nil), r.counters...)
+	histograms := append([]*HistogramVec(nil), r.histograms...)
+	collectors := append([]Collector(nil), r.collectors...)
+	r.mu.Unlock()
+
+	w := newWriter()
+	for _, c := range counters {
+		c.write(w)
+	}
+	for _, h := range histograms {
+		h.write(w)
+	}
+	for _, c := range collectors {
+		c(w)
+	}
+	return w.String()
+}
+
+// Handler serves the registry for Prometheus scrapes
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		_, _ = w.Write([]byte(r.Render()))
+	})
+}
+
+// CounterVec is a monotonically increasing counter with labels
+type CounterVec struct {
+	name   string
+	help   string
+	labels []string
+	mu     sync.Mutex
+	values map[string]*counterValue
+}
+
+type counterValue struct {
+	labelValues []string
+	value       float64
+}
+
+// Inc increments the counter for the given label values by one
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add increments the counter for the given label values by v
+func (c *CounterVec) Add(v float64, labelValues ...string) {
+	key := strings.Join(labelValues, "\xff")
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	cv, ok := c.values[key]
+	if !ok {
+		cv = &counterValue{labelValues: append([]string(nil), labelValues...)}
+		c.values[key] = cv
+	}
+	cv.value += v
+}
+
+// Value returns the current counter value for the given label values
+func (c *CounterVec) Value(labelValues ...string) float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if cv, ok := c.values[strings.Join(labelValues, "\xff")]; ok {
+		return cv.value
+	}
+	return 0
+}
+
+func (c *CounterVec) write(w *Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	w.declare(c.name, c.help, "counter")
+	for _, key := range sortedKeys(c.values) {
+		cv := c.values[key]
+		w.sample(c.name, zipLabels(c.labels, cv.labelValues), cv.value)
+	}
+}
+
+// HistogramVec tracks the distribution of observations with labels
+type HistogramVec struct {
+	name    string
+	help    string
+	labels  []string
+	buckets []float64
+	mu      sync.Mutex
+	values  map[string]*histogramValue
+}
+
+type histogramValue struct {
+	labelValues []string
+	counts      []uint64 // per bucket, non-cumulative
+	count       uint64
+	sum         float64
+}
+
+// Observe records a single observation for the given label values
+func (h *HistogramVec) Observe(v float64, labelValues ...string) {
+	key := strings.Join(labelValues, "\xff")
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	hv, ok := h.values[key]
+	if !ok {
+		hv = &histogramValue{labelValues: append([]string(nil), labelValues...), counts: make([]uint64, len(h.buckets))}
+		h.values[key] = hv
+	}
+	if i := sort.SearchFloat64s(h.buckets, v); i < len(h.buckets) {
+		hv.counts[i]++
+	}
+	hv.count++
+	hv.sum += v
+}
+
+// Count returns how many observations were recorded for the given label values
+func (h *HistogramVec) Count(labelValues ...string) uint64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if hv, ok := h.values[strings.Join(labelValues, "\xff")]; ok {
+		return hv.count
+	}
+	return 0
+}
+
+func (h *HistogramVec) write(w *Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	w.declare(h.name, h.help, "histogram")
+	for _, key := range sortedKeys(h.values) {
+		hv := h.values[key]
+		labels := zipLabels(h.labels, hv.labelValues)
+		var cumulative uint64
+		for i, le := range h.buckets {
+			cumulative += hv.counts[i]
+			w.sample(h.name+"_bucket", append(labels, "le", formatFloat(le)), float64(cumulative))
+		}
+		w.sample(h.name+"_bucket", append(labels, "le", "+Inf"), float64(hv.count))
+		w.sample(h.name+"_sum", labels, hv.sum)
+		w.sample(h.name+"_count", labels, float64(hv.count))
+	}
+}
+
+// Writer accumulates metric families; samples of the same family are grouped
+// together regardless of the order in which they are written
+type Writer struct {
+	order    []string
+	families map[string]*family
+}
+
+type family struct {
+	help    string
+	typ     string
+	samples []string
+}
+
+func newWriter() *Writer {
+	return &Writer{families: make(map[string]*family)}
+}
+
+// Gauge writes a gauge sample; labels are alternating name/value pairs
+func (w *Writer) Gauge(name, help string, value float64, labels ...string) {
+	w.declare(name, help, "gauge")
+	w.sample(name, labels, value)
+}
+
+// Counter writes a counter sample; labels are alternating name/value pairs
+func (w *Writer) Counter(name, help string, value float64, labels ...string) {
+	w.declare(name, help, "counter")
+	w.sample(name, labels, value)
+}
+
+// Summary writes the _sum and _count series of a summary without quantiles
+func (w *Writer) Summary(name, help string, count uint64, sum float64, labels ...string) {
+	w.declare(name, help, "summary")
+	w.sample(name+"_sum", labels, sum)
+	w.sample(name+"_count", labels, float64(count))
+}
+
+func (w *Writer) declare(name, help, typ string) {
+	if _, ok := w.families[name]; ok {
+		return
+	}
+	w.families[name] = &family{help: help, typ: typ}
+	w.order = append(w.order, name)
+}
+
+// sample appends a series line to the family it belongs to
+func (w *Writer) sample(series string, labels []string, value float64) {
+	name := series
+	if _, ok := w.families[name]; !ok {
+		for _, suffix := range []string{"_bucket", "_sum", "_count"} {
+			name = strings.TrimSuffix(name, suffix)
+		}
+	}
+	f, ok := w.families[name]
+	if !ok {
+		w.declare(name, "", "untyped")
+		f = w.families[name]
+	}
+	var b strings.Builder
+	b.WriteString(series)
+	if len(labels) > 0 {
+		b.WriteByte('{')
+		for i := 0; i+1 < len(labels); i += 2 {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			fmt.Fprintf(&b, "%s=\"%s\"", labels[i], escapeLabel(labels[i+1]))
+		}
+		b.WriteByte('}')
+	}
+	b.WriteByte(' ')
+	b.WriteString(formatFloat(value))
+	f.samples = append(f.samples, b.String())
+}
+
+// String renders the accumulated families
+func (w *Writer) String() string {
+	var b strings.Builder
+	for _, name := range w.order {
+		f := w.families[name]
+		if f.help != "" {
+			fmt.Fprintf(&b, "# HELP %s %s\n", name, f.help)
+		}
+		fmt.Fprintf(&b, "# TYPE %s %s\n", name, f.typ)
+		for _, s := range f.samples {
+			b.WriteString(s)
+			b.WriteByte('\n')
+		}
+	}
+	return b.String()
+}
+
+func zipLabels(names, values []string) []string {
+	out := make([]string, 0, 2*len(names))
+	for i, n := range names {
+		v := ""
+		if i < len(values) {
+			v = values[i]
+		}
+		out = append(out, n, v)
+	}
+	return out
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func escapeLabel(v string) string {
+	v = strings.ReplaceAll(v, `\`, `\\`)
+	v = strings.ReplaceAll(v, "\n", `\n`)
+	return strings.ReplaceAll(v, `"`, `\"`)
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -0,0 +1,57 @@
+package metrics
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRegistryRendersTextFormat(t *testing.T) {
+	reg := NewRegistry()
+	calls := reg.NewCounterVec("cmds_total", "Commands.", "command")
+	latency := reg.NewHistogramVec("cmd_seconds", "Latency.", []float64{0.1, 1}, "command")
+	reg.AddCollector(func(w *Writer) {
+		w.Gauge("keys", "Keys per type.", 3, "type", "string")
+		w.Counter("other_total", "", 1)
+		w.Gauge("keys", "Keys per type.", 1, "type", `li"st`)
+	})
+
+	calls.Inc("get")
+	calls.Add(2, "set")
+	latency.Observe(0.05, "get")
+	latency.Observe(0.5, "get")
+	latency.Observe(5, "get")
+
+	out := reg.Render()
+	for _, want := range []string{
+		"# HELP cmds_total Commands.\n# TYPE cmds_total counter\ncmds_total{command=\"get\"} 1\ncmds_total{command=\"set\"} 2\n",
+		"# TYPE cmd_seconds histogram\n",
+		`cmd_seconds_bucket{command="get",le="0.1"} 1`,
+		`cmd_seconds_bucket{command="get",le="1"} 2`,
+		`cmd_seconds_bucket{command="get",le="+Inf"} 3`,
+		`cmd_seconds_sum{command="get"} 5.55`,
+		`cmd_seconds_count{command="get"} 3`,
+		"# TYPE keys gauge\nkeys{type=\"string\"} 3\nkeys{type=\"li\\\"st\"} 1\n# TYPE other_total counter\nother_total 1\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+	if strings.Count(out, "# TYPE keys") != 1 {
+		t.Errorf("family declared more than once:\n%s", out)
+	}
+}
+
+func TestHandlerContentType(t *testing.T) {
+	reg := NewRegistry()
+	reg.NewCounterVec("x_total", "X.").Inc()
+
+	rec := httptest.NewRecorder()
+	reg.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("content type = %q", ct)
+	}
+	if !strings.Contains(rec.Body.String(), "x_total 1") {
+		t.Errorf("unexpected body:\n%s", rec.Body.String())
+	}
+}
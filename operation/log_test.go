@@ -1,6 +1,7 @@
 package operation
 
 import (
+	"errors"
 	"os"
 	"testing"
 	"time"
@@ -63,3 +64,39 @@ func TestOperationLog(t *testing.T) {
 		t.Errorf("Expected operation type %v, got %v", op.Type, ops[0].Type)
 	}
 }
+
+func TestOperationLogTruncate(t *testing.T) {
+	path := t.TempDir() + "/oplog.json"
+	opLog, err := NewOperationLog(path)
+	if err != nil {
+		t.Fatalf("Failed to create operation log: %v", err)
+	}
+	for ts := int64(1); ts <= 5; ts++ {
+		opLog.AddOperation(&proto.Operation{Type: proto.OperationType_SET, Args: []string{"k", "v"}, Timestamp: ts})
+	}
+
+	if n, err := opLog.Truncate(2, 0); err != nil || n != 2 {
+		t.Fatalf("Truncate(2, 0) = %d, %v", n, err)
+	}
+	// The cap drops the oldest operations beyond it
+	if n, err := opLog.Truncate(0, 2); err != nil || n != 1 {
+		t.Fatalf("Truncate(0, 2) = %d, %v", n, err)
+	}
+	if _, err := opLog.OperationsSince(2); !errors.Is(err, ErrTruncated) {
+		t.Errorf("OperationsSince(2) error = %v, want ErrTruncated", err)
+	}
+	if ops, err := opLog.OperationsSince(3); err != nil || len(ops) != 2 {
+		t.Errorf("OperationsSince(3) = %d operations, %v", len(ops), err)
+	}
+	opLog.Close()
+
+	// The truncation point survives a restart
+	opLog, err = NewOperationLog(path)
+	if err != nil {
+		t.Fatalf("Failed to reopen operation log: %v", err)
+	}
+	defer opLog.Close()
+	if st := opLog.Stats(); st.Operations != 2 || st.Oldest != 4 || st.Newest != 5 || st.Truncated != 3 {
+		t.Errorf("stats after reopening = %+v", st)
+	}
+}
@@ -1,10 +1,13 @@
 package operation
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"os"
+	"sort"
 	"sync"
 	"time"
 
@@ -13,10 +16,33 @@ import (
 
 // OperationLog represents a log of operations
 type OperationLog struct {
-	mu       sync.RWMutex
-	path     string
-	ops      []*proto.Operation
-	lastSync int64
+	mu           sync.RWMutex
+	path         string
+	ops          []*proto.Operation
+	lastSync     int64
+	truncated    int64 // operations with timestamps up to this one were dropped
+	truncatedOps int64 // operations dropped since the log was opened
+}
+
+// ErrTruncated is returned for reads from before the truncation point: the
+// log no longer holds every operation the reader is missing
+var ErrTruncated = errors.New("operation log truncated")
+
+// LogStats describes the operations held in the log
+type LogStats struct {
+	Operations   int
+	Oldest       int64 // timestamp of the oldest operation, 0 if none
+	Newest       int64 // timestamp of the newest operation, 0 if none
+	Truncated    int64 // truncation point, 0 if nothing was ever dropped
+	TruncatedOps int64 // operations dropped since the log was opened
+}
+
+// logFile is the on-disk form of a truncated log; a log that was never
+// truncated is written as a plain array of operations, as before truncation
+// existed
+type logFile struct {
+	Truncated  int64              `json:"truncated"`
+	Operations []*proto.Operation `json:"operations"`
 }
 
 // NewOperationLog creates a new operation log
@@ -62,6 +88,117 @@ func (o *OperationLog) GetOperations(since int64) ([]*proto.Operation, error) {
 	return ops, nil
 }
 
+// OperationsSince is GetOperations for a replica catching up from since: it
+// fails with ErrTruncated if operations newer than since were dropped
+func (o *OperationLog) OperationsSince(since int64) ([]*proto.Operation, error) {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+
+	if since < o.truncated {
+		return nil, fmt.Errorf("%w through %d, reading since %d", ErrTruncated, o.truncated, since)
+	}
+	var ops []*proto.Operation
+	for _, op := range o.ops {
+		if op.Timestamp > since {
+			ops = append(ops, op)
+		}
+	}
+	return ops, nil
+}
+
+// Truncate drops the operations with timestamps up to through, and the
+// oldest ones beyond maxOps if maxOps is positive, and returns how many it
+// dropped. Readers from before the newest dropped operation get ErrTruncated.
+func (o *OperationLog) Truncate(through int64, maxOps int) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if maxOps > 0 && len(o.ops) > maxOps {
+		timestamps := make([]int64, len(o.ops))
+		for i, op := range o.ops {
+			timestamps[i] = op.Timestamp
+		}
+		sort.Slice(timestamps, func(i, j int) bool { return timestamps[i] < timestamps[j] })
+		if ts := timestamps[len(timestamps)-maxOps-1]; ts > through {
+			through = ts
+		}
+	}
+
+	// The truncation point only moves to the newest dropped operation, so
+	// readers that have it lose nothing and an idle log is not rewritten
+	kept := o.ops[:0]
+	newest := o.truncated
+	for _, op := range o.ops {
+		if op.Timestamp > through {
+			kept = append(kept, op)
+		} else if op.Timestamp > newest {
+			newest = op.Timestamp
+		}
+	}
+	dropped := len(o.ops) - len(kept)
+	if dropped == 0 {
+		return 0, nil
+	}
+	for i := len(kept); i < len(o.ops); i++ {
+		o.ops[i] = nil
+	}
+	o.ops = kept
+	o.truncated = newest
+	o.truncatedOps += int64(dropped)
+	return dropped, o.save()
+}
+
+// Truncated returns the truncation point: operations with timestamps up to
+// it may have been dropped
+func (o *OperationLog) Truncated() int64 {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	return o.truncated
+}
+
+// Stats returns the size and truncation state of the log
+func (o *OperationLog) Stats() LogStats {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+
+	st := LogStats{Operations: len(o.ops), Truncated: o.truncated, TruncatedOps: o.truncatedOps}
+	for _, op := range o.ops {
+		if st.Oldest == 0 || op.Timestamp < st.Oldest {
+			st.Oldest = op.Timestamp
+		}
+		if op.Timestamp > st.Newest {
+			st.Newest = op.Timestamp
+		}
+	}
+	return st
+}
+
+// Len returns the number of operations held in the log
+func (o *OperationLog) Len() int {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	return len(o.ops)
+}
+
+// PendingSince returns how many operations are newer than since and the
+// timestamp of the oldest of them (0 when none are pending)
+func (o *OperationLog) PendingSince(since int64) (int, int64) {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+
+	count := 0
+	var oldest int64
+	for _, op := range o.ops {
+		if op.Timestamp > since {
+			count++
+			if oldest == 0 || op.Timestamp < oldest {
+				oldest = op.Timestamp
+			}
+		}
+	}
+	return count, oldest
+}
+
 // load reads operations from disk
 func (o *OperationLog) load() error {
 	data, err := ioutil.ReadFile(o.path)
@@ -81,6 +218,14 @@ func (o *OperationLog) load() error {
 		return nil
 	}
 
+	if data = bytes.TrimSpace(data); len(data) > 0 && data[0] == '{' {
+		var file logFile
+		if err := json.Unmarshal(data, &file); err != nil {
+			return fmt.Errorf("failed to unmarshal operations: %v", err)
+		}
+		o.ops, o.truncated = file.Operations, file.Truncated
+		return nil
+	}
 	if err := json.Unmarshal(data, &o.ops); err != nil {
 		return fmt.Errorf("failed to unmarshal operations: %v", err)
 	}
@@ -88,9 +233,21 @@ func (o *OperationLog) load() error {
 	return nil
 }
 
+// MarshalLog encodes operations in the log file format, recording the
+// truncation point if there is one
+func MarshalLog(ops []*proto.Operation, truncated int64) ([]byte, error) {
+	if ops == nil {
+		ops = []*proto.Operation{}
+	}
+	if truncated == 0 {
+		return json.Marshal(ops)
+	}
+	return json.Marshal(logFile{Truncated: truncated, Operations: ops})
+}
+
 // save writes operations to disk
 func (o *OperationLog) save() error {
-	data, err := json.Marshal(o.ops)
+	data, err := MarshalLog(o.ops, o.truncated)
 	if err != nil {
 		return fmt.Errorf("failed to marshal operations: %v", err)
 	}
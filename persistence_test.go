@@ -65,7 +65,8 @@ func TestPersistenceOptimization(t *testing.T) {
 		if err != nil {
 			t.Fatalf("Failed to create second server: %v", err)
 		}
-		defer srv2.Close()
+		// The first server is closed; the remaining tests use the reopened one
+		srv = srv2
 
 		// Verify data was persisted
 		value, exists := srv2.Get("key1")
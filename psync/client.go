@@ -0,0 +1,444 @@
+package psync
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/luoyjx/crdt-redis/rdb"
+)
+
+// Handler applies what the master sends. Its methods are called from a
+// single goroutine and return ErrSkip for data they do not apply.
+type Handler interface {
+	// ApplyEntry applies a key from the full sync RDB
+	ApplyEntry(e *rdb.Entry) error
+	// ApplyCommand applies a command from the replication stream
+	ApplyCommand(args [][]byte) error
+}
+
+// ErrSkip is returned by a Handler for entries and commands it ignores
+var ErrSkip = errors.New("not applied")
+
+// Config configures a replica of a Redis master
+type Config struct {
+	Addr        string        // master host:port
+	Username    string        // AUTH user; empty for the default user
+	Password    string        // AUTH password; AUTH is not sent if empty
+	ListenPort  int           // reported with REPLCONF listening-port if set
+	DB          int           // database whose keys and commands are applied
+	DialTimeout time.Duration // defaults to 5s
+	AckInterval time.Duration // defaults to 1s
+	MaxBackoff  time.Duration // cap on the reconnect delay, defaults to 30s
+}
+
+// State is the state of the link to the master
+type State string
+
+const (
+	StateConnecting State = "connecting"
+	StateSyncing    State = "sync" // loading the full sync RDB
+	StateConnected  State = "connected"
+)
+
+// Status is a snapshot of the link to the master
+type Status struct {
+	Master    string
+	State     State
+	ReplID    string
+	Offset    int64 // replication offset processed so far
+	Keys      int64 // keys applied from full syncs
+	Commands  int64 // commands applied from the stream
+	Skipped   int64 // keys and commands the handler or DB filter ignored
+	Errors    int64 // keys and commands that failed to apply
+	FullSyncs int64
+	LastError string
+	LastIO    time.Time
+}
+
+// Client replicates from a Redis master with PSYNC: it loads the full sync
+// RDB, then applies the command stream, and resumes with a partial sync
+// after reconnecting when the master still has the backlog
+type Client struct {
+	cfg     Config
+	handler Handler
+
+	offset int64 // updated atomically
+
+	mu        sync.Mutex
+	conn      net.Conn
+	state     State
+	replID    string
+	keys      int64
+	commands  int64
+	skipped   int64
+	errors    int64
+	fullSyncs int64
+	lastError string
+	lastIO    time.Time
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// New creates a client; Start connects it
+func New(cfg Config, h Handler) *Client {
+	if cfg.DialTimeout <= 0 {
+		cfg.DialTimeout = 5 * time.Second
+	}
+	if cfg.AckInterval <= 0 {
+		cfg.AckInterval = time.Second
+	}
+	if cfg.MaxBackoff <= 0 {
+		cfg.MaxBackoff = 30 * time.Second
+	}
+	return &Client{
+		cfg:     cfg,
+		handler: h,
+		offset:  -1,
+		state:   StateConnecting,
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+}
+
+// Start replicates in the background until Stop, reconnecting on errors
+func (c *Client) Start() {
+	go c.run()
+}
+
+// Stop closes the link and waits for the client to finish applying
+func (c *Client) Stop() {
+	close(c.stop)
+	c.mu.Lock()
+	if c.conn != nil {
+		c.conn.Close()
+	}
+	c.mu.Unlock()
+	<-c.done
+}
+
+// Status returns the state of the link
+func (c *Client) Status() Status {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return Status{
+		Master:    c.cfg.Addr,
+		State:     c.state,
+		ReplID:    c.replID,
+		Offset:    atomic.LoadInt64(&c.offset),
+		Keys:      c.keys,
+		Commands:  c.commands,
+		Skipped:   c.skipped,
+		Errors:    c.errors,
+		FullSyncs: c.fullSyncs,
+		LastError: c.lastError,
+		LastIO:    c.lastIO,
+	}
+}
+
+func (c *Client) run() {
+	defer close(c.done)
+	failures := 0
+	for {
+		synced, err := c.session()
+		select {
+		case <-c.stop:
+			return
+		default:
+		}
+		if synced {
+			failures = 0
+		}
+		failures++
+		c.mu.Lock()
+		c.state = StateConnecting
+		c.lastError = err.Error()
+		c.mu.Unlock()
+		log.Printf("Replication from master %s failed: %v", c.cfg.Addr, err)
+
+		delay := time.Second << uint(min(failures-1, 5))
+		if delay > c.cfg.MaxBackoff {
+			delay = c.cfg.MaxBackoff
+		}
+		select {
+		case <-c.stop:
+			return
+		case <-time.After(delay):
+		}
+	}
+}
+
+// session runs one connection to the master; synced reports whether it got
+// as far as the command stream
+func (c *Client) session() (synced bool, err error) {
+	conn, err := net.DialTimeout("tcp", c.cfg.Addr, c.cfg.DialTimeout)
+	if err != nil {
+		return false, err
+	}
+	c.mu.Lock()
+	select {
+	case <-c.stop:
+		c.mu.Unlock()
+		conn.Close()
+		return false, fmt.Errorf("stopped")
+	default:
+	}
+	c.conn = conn
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		c.conn = nil
+		c.mu.Unlock()
+		conn.Close()
+	}()
+
+	br := bufio.NewReader(conn)
+	l := &link{conn: conn, br: br}
+	conn.SetDeadline(time.Now().Add(c.cfg.DialTimeout))
+	if err := c.handshake(l); err != nil {
+		return false, err
+	}
+
+	// +FULLRESYNC is followed by the RDB; +CONTINUE by the stream
+	c.mu.Lock()
+	replID := c.replID
+	c.mu.Unlock()
+	psync := []string{"PSYNC", "?", "-1"}
+	if off := atomic.LoadInt64(&c.offset); replID != "" && off >= 0 {
+		psync = []string{"PSYNC", replID, strconv.FormatInt(off+1, 10)}
+	}
+	reply, err := l.call(psync...)
+	if err != nil {
+		return false, err
+	}
+	conn.SetDeadline(time.Time{})
+	fields := strings.Fields(reply)
+	switch {
+	case len(fields) == 3 && fields[0] == "FULLRESYNC":
+		offset, err := strconv.ParseInt(fields[2], 10, 64)
+		if err != nil {
+			return false, fmt.Errorf("invalid FULLRESYNC reply %q", reply)
+		}
+		if err := c.fullSync(l, fields[1], offset); err != nil {
+			return false, err
+		}
+	case len(fields) >= 1 && fields[0] == "CONTINUE":
+		if len(fields) == 2 {
+			c.mu.Lock()
+			c.replID = fields[1]
+			c.mu.Unlock()
+		}
+		log.Printf("Partial resync from master %s at offset %d", c.cfg.Addr, atomic.LoadInt64(&c.offset))
+	default:
+		return false, fmt.Errorf("unexpected PSYNC reply %q", reply)
+	}
+
+	c.mu.Lock()
+	c.state = StateConnected
+	c.lastError = ""
+	c.mu.Unlock()
+	return true, c.stream(l)
+}
+
+// handshake authenticates and announces the replica
+func (c *Client) handshake(l *link) error {
+	if c.cfg.Password != "" {
+		args := []string{"AUTH", c.cfg.Password}
+		if c.cfg.Username != "" {
+			args = []string{"AUTH", c.cfg.Username, c.cfg.Password}
+		}
+		if _, err := l.call(args...); err != nil {
+			return fmt.Errorf("AUTH failed: %v", err)
+		}
+	}
+	if _, err := l.call("PING"); err != nil {
+		return fmt.Errorf("PING failed: %v", err)
+	}
+	if c.cfg.ListenPort > 0 {
+		if _, err := l.call("REPLCONF", "listening-port", strconv.Itoa(c.cfg.ListenPort)); err != nil {
+			return fmt.Errorf("REPLCONF listening-port failed: %v", err)
+		}
+	}
+	// Without capa eof the master sends the RDB with its length up front
+	if _, err := l.call("REPLCONF", "capa", "psync2"); err != nil {
+		return fmt.Errorf("REPLCONF capa failed: %v", err)
+	}
+	return nil
+}
+
+// fullSync loads the RDB that follows +FULLRESYNC. Keys the master does not
+// have are left alone: the keyspace is shared with the other replicas.
+func (c *Client) fullSync(l *link, replID string, offset int64) error {
+	c.mu.Lock()
+	c.state = StateSyncing
+	c.replID = replID
+	c.fullSyncs++
+	c.mu.Unlock()
+	atomic.StoreInt64(&c.offset, -1)
+
+	// The master sends newlines as keepalives while it produces the RDB
+	var header string
+	for header == "" {
+		line, _, err := l.readLine()
+		if err != nil {
+			return fmt.Errorf("reading RDB length: %v", err)
+		}
+		header = string(line)
+	}
+	if header[0] != '$' || strings.HasPrefix(header, "$EOF:") {
+		return fmt.Errorf("unexpected RDB header %q", header)
+	}
+	size, err := strconv.ParseInt(header[1:], 10, 64)
+	if err != nil || size < 0 {
+		return fmt.Errorf("invalid RDB length %q", header)
+	}
+
+	log.Printf("Full resync from master %s: loading %d byte RDB, replid %s offset %d", c.cfg.Addr, size, replID, offset)
+	body := io.LimitReader(l.br, size)
+	r, err := rdb.NewReader(body)
+	if err != nil {
+		return err
+	}
+	for {
+		e, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("loading RDB: %v", err)
+		}
+		c.touch()
+		if e.DB != c.cfg.DB {
+			c.count(&c.skipped)
+			continue
+		}
+		c.result(&c.keys, "key "+e.Key, c.handler.ApplyEntry(e))
+	}
+	if _, err := io.Copy(io.Discard, body); err != nil {
+		return err
+	}
+	atomic.StoreInt64(&c.offset, offset)
+	return nil
+}
+
+// stream applies commands until the connection fails, acknowledging the
+// processed offset every AckInterval and on REPLCONF GETACK
+func (c *Client) stream(l *link) error {
+	stopAck := make(chan struct{})
+	defer close(stopAck)
+	go func() {
+		t := time.NewTicker(c.cfg.AckInterval)
+		defer t.Stop()
+		for {
+			select {
+			case <-stopAck:
+				return
+			case <-t.C:
+				l.ack(atomic.LoadInt64(&c.offset))
+			}
+		}
+	}()
+
+	db := 0
+	for {
+		args, n, err := l.readCommand()
+		if err != nil {
+			return err
+		}
+		c.touch()
+		name := strings.ToLower(string(args[0]))
+		switch {
+		case name == "replconf" && len(args) > 1 && strings.EqualFold(string(args[1]), "getack"):
+			// The reply covers the stream before the GETACK itself
+			if err := l.ack(atomic.LoadInt64(&c.offset)); err != nil {
+				return err
+			}
+		case name == "select" && len(args) == 2:
+			if db, err = strconv.Atoi(string(args[1])); err != nil {
+				return fmt.Errorf("invalid SELECT %q", args[1])
+			}
+		case name == "ping" || name == "replconf":
+		case name == "multi" || name == "exec":
+			// Transactions are applied command by command
+		case db != c.cfg.DB:
+			c.count(&c.skipped)
+		default:
+			c.result(&c.commands, "command "+name, c.handler.ApplyCommand(args))
+		}
+		atomic.AddInt64(&c.offset, int64(n))
+	}
+}
+
+// result counts the outcome of applying a key or command into applied
+func (c *Client) result(applied *int64, what string, err error) {
+	switch {
+	case err == nil:
+		c.count(applied)
+	case errors.Is(err, ErrSkip):
+		c.count(&c.skipped)
+	default:
+		c.count(&c.errors)
+		log.Printf("Replication from master %s: %s failed: %v", c.cfg.Addr, what, err)
+	}
+}
+
+func (c *Client) count(n *int64) {
+	c.mu.Lock()
+	*n++
+	c.mu.Unlock()
+}
+
+func (c *Client) touch() {
+	c.mu.Lock()
+	c.lastIO = time.Now()
+	c.mu.Unlock()
+}
+
+// InfoLines renders the link for the INFO replication section, in the
+// fields Redis uses for a replica's master link
+func (c *Client) InfoLines() []string {
+	st := c.Status()
+	host, port, _ := net.SplitHostPort(st.Master)
+	linkStatus := "down"
+	if st.State == StateConnected {
+		linkStatus = "up"
+	}
+	sync := 0
+	if st.State == StateSyncing {
+		sync = 1
+	}
+	ioAgo := -1
+	if !st.LastIO.IsZero() {
+		ioAgo = int(time.Since(st.LastIO).Seconds())
+	}
+	return []string{
+		"master_host:" + host,
+		"master_port:" + port,
+		"master_link_status:" + linkStatus,
+		fmt.Sprintf("master_last_io_seconds_ago:%d", ioAgo),
+		fmt.Sprintf("master_sync_in_progress:%d", sync),
+		"master_replid:" + st.ReplID,
+		fmt.Sprintf("slave_repl_offset:%d", st.Offset),
+		fmt.Sprintf("master_full_syncs:%d", st.FullSyncs),
+		fmt.Sprintf("master_keys_loaded:%d", st.Keys),
+		fmt.Sprintf("master_commands_applied:%d", st.Commands),
+		fmt.Sprintf("master_skipped:%d", st.Skipped),
+		fmt.Sprintf("master_errors:%d", st.Errors),
+	}
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,229 @@
+package psync
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/luoyjx/crdt-redis/rdb"
+	"github.com/luoyjx/crdt-redis/storage"
+)
+
+// recorder is a Handler that records what it applies and skips DEL
+type recorder struct {
+	mu       sync.Mutex
+	entries  []string
+	commands []string
+}
+
+func (r *recorder) ApplyEntry(e *rdb.Entry) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.entries = append(r.entries, e.Key+"="+e.String)
+	return nil
+}
+
+func (r *recorder) ApplyCommand(args [][]byte) error {
+	if strings.EqualFold(string(args[0]), "del") {
+		return ErrSkip
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.commands = append(r.commands, string(bytes.Join(args, []byte(" "))))
+	return nil
+}
+
+func (r *recorder) snapshot() ([]string, []string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.entries...), append([]string(nil), r.commands...)
+}
+
+func resp(args ...string) string {
+	s := fmt.Sprintf("*%d\r\n", len(args))
+	for _, a := range args {
+		s += fmt.Sprintf("$%d\r\n%s\r\n", len(a), a)
+	}
+	return s
+}
+
+// fakeMaster speaks the master side of the replication protocol: it answers
+// the handshake, sends an RDB on the first PSYNC and resumes later ones
+type fakeMaster struct {
+	ln       net.Listener
+	password string
+	rdb      []byte
+	streams  chan string // stream sent after each sync
+	psyncs   chan []string
+	acks     chan string
+}
+
+func newFakeMaster(t *testing.T, password string, entries ...*rdb.Entry) *fakeMaster {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	var buf bytes.Buffer
+	w, _ := rdb.NewWriter(&buf, time.Unix(1700000000, 0))
+	for _, e := range entries {
+		if err := w.Write(e); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+	w.Close()
+	m := &fakeMaster{
+		ln:       ln,
+		password: password,
+		rdb:      buf.Bytes(),
+		streams:  make(chan string, 4),
+		psyncs:   make(chan []string, 4),
+		acks:     make(chan string, 64),
+	}
+	t.Cleanup(func() { ln.Close() })
+	go m.serve()
+	return m
+}
+
+func (m *fakeMaster) serve() {
+	for {
+		conn, err := m.ln.Accept()
+		if err != nil {
+			return
+		}
+		go m.handle(conn)
+	}
+}
+
+func (m *fakeMaster) handle(conn net.Conn) {
+	defer conn.Close()
+	l := &link{conn: conn, br: bufio.NewReader(conn)}
+	authed := m.password == ""
+	for {
+		args, _, err := l.readCommand()
+		if err != nil {
+			return
+		}
+		var cmd []string
+		for _, a := range args {
+			cmd = append(cmd, string(a))
+		}
+		switch strings.ToUpper(cmd[0]) {
+		case "AUTH":
+			if cmd[len(cmd)-1] != m.password {
+				fmt.Fprint(conn, "-WRONGPASS invalid password\r\n")
+				continue
+			}
+			authed = true
+			fmt.Fprint(conn, "+OK\r\n")
+		case "PING":
+			if !authed {
+				fmt.Fprint(conn, "-NOAUTH Authentication required.\r\n")
+				continue
+			}
+			fmt.Fprint(conn, "+PONG\r\n")
+		case "REPLCONF":
+			if strings.EqualFold(cmd[1], "ack") {
+				m.acks <- cmd[2]
+				continue
+			}
+			fmt.Fprint(conn, "+OK\r\n")
+		case "PSYNC":
+			m.psyncs <- cmd
+			if cmd[1] == "?" {
+				fmt.Fprintf(conn, "+FULLRESYNC 8de1787ba490483314a4d30f1c628bc5025eb761 100\r\n\n\n$%d\r\n%s", len(m.rdb), m.rdb)
+			} else {
+				fmt.Fprint(conn, "+CONTINUE\r\n")
+			}
+			stream, ok := <-m.streams
+			if !ok {
+				return
+			}
+			fmt.Fprint(conn, stream)
+		}
+	}
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestClientSync(t *testing.T) {
+	m := newFakeMaster(t, "secret",
+		&rdb.Entry{Key: "a", Type: storage.TypeString, String: "1"},
+		&rdb.Entry{DB: 1, Key: "other", Type: storage.TypeString, String: "x"},
+		&rdb.Entry{Key: "b", Type: storage.TypeString, String: "2"},
+	)
+	stream := resp("SELECT", "0") + resp("SET", "c", "3") + resp("MULTI") + resp("SADD", "s", "m") +
+		resp("DEL", "a") + resp("EXEC") + resp("SELECT", "1") + resp("SET", "other", "y") +
+		resp("SELECT", "0") + resp("PING")
+	getack := resp("REPLCONF", "GETACK", "*")
+	m.streams <- stream + getack
+
+	h := &recorder{}
+	c := New(Config{Addr: m.ln.Addr().String(), Password: "secret", ListenPort: 6380, AckInterval: time.Hour}, h)
+	c.Start()
+	defer c.Stop()
+
+	if got := <-m.psyncs; strings.Join(got, " ") != "PSYNC ? -1" {
+		t.Errorf("first PSYNC = %v", got)
+	}
+	want := fmt.Sprint(100 + len(stream))
+	if ack := <-m.acks; ack != want {
+		t.Errorf("GETACK reply offset = %s, want %s", ack, want)
+	}
+	entries, commands := h.snapshot()
+	if strings.Join(entries, ",") != "a=1,b=2" {
+		t.Errorf("entries = %v", entries)
+	}
+	if strings.Join(commands, ",") != "SET c 3,SADD s m" {
+		t.Errorf("commands = %v", commands)
+	}
+	st := c.Status()
+	if st.State != StateConnected || st.Keys != 2 || st.Commands != 2 || st.Skipped != 3 || st.Errors != 0 {
+		t.Errorf("status = %+v", st)
+	}
+
+	// After a dropped link the client asks for the rest of the stream
+	m.streams <- resp("SET", "d", "4") + getack
+	c.mu.Lock()
+	c.conn.Close()
+	c.mu.Unlock()
+	offset := 100 + len(stream) + len(getack)
+	if got := <-m.psyncs; strings.Join(got, " ") != fmt.Sprintf("PSYNC 8de1787ba490483314a4d30f1c628bc5025eb761 %d", offset+1) {
+		t.Errorf("PSYNC after reconnect = %v", got)
+	}
+	want = fmt.Sprint(offset + len(resp("SET", "d", "4")))
+	if ack := <-m.acks; ack != want {
+		t.Errorf("GETACK reply offset after resync = %s, want %s", ack, want)
+	}
+	if _, commands := h.snapshot(); len(commands) != 3 || commands[2] != "SET d 4" {
+		t.Errorf("commands after resync = %v", commands)
+	}
+	if st := c.Status(); st.FullSyncs != 1 {
+		t.Errorf("full syncs = %d, want 1", st.FullSyncs)
+	}
+	close(m.streams)
+}
+
+func TestClientAuthFailure(t *testing.T) {
+	m := newFakeMaster(t, "secret")
+	c := New(Config{Addr: m.ln.Addr().String(), Password: "wrong"}, &recorder{})
+	c.Start()
+	defer c.Stop()
+	waitFor(t, "the link to fail", func() bool { return c.Status().LastError != "" })
+	if st := c.Status(); !strings.Contains(st.LastError, "WRONGPASS") || st.State != StateConnecting {
+		t.Errorf("status = %+v", st)
+	}
+}
@@ -0,0 +1,115 @@
+package psync
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+)
+
+// link speaks RESP to the master; writes are serialized because
+// acknowledgements are sent while the stream is being read
+type link struct {
+	conn net.Conn
+	br   *bufio.Reader
+	wmu  sync.Mutex
+}
+
+// send writes a command as a RESP array
+func (l *link) send(args ...string) error {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, a := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(a), a)
+	}
+	l.wmu.Lock()
+	defer l.wmu.Unlock()
+	_, err := l.conn.Write(b.Bytes())
+	return err
+}
+
+// call sends a command and returns its status reply
+func (l *link) call(args ...string) (string, error) {
+	if err := l.send(args...); err != nil {
+		return "", err
+	}
+	line, _, err := l.readLine()
+	if err != nil {
+		return "", err
+	}
+	switch {
+	case len(line) > 0 && line[0] == '+':
+		return string(line[1:]), nil
+	case len(line) > 0 && line[0] == '-':
+		return "", fmt.Errorf("%s", line[1:])
+	}
+	return "", fmt.Errorf("unexpected reply %q to %s", line, args[0])
+}
+
+// ack reports the processed offset
+func (l *link) ack(offset int64) error {
+	return l.send("REPLCONF", "ACK", strconv.FormatInt(offset, 10))
+}
+
+// readLine reads a line without its CRLF and returns the bytes consumed
+func (l *link) readLine() ([]byte, int, error) {
+	line, err := l.br.ReadSlice('\n')
+	if err != nil {
+		return nil, 0, err
+	}
+	n := len(line)
+	line = bytes.TrimSuffix(line[:n-1], []byte("\r"))
+	return append([]byte(nil), line...), n, nil
+}
+
+// readCommand reads a command from the stream, either a RESP array of bulk
+// strings or an inline command, and returns the bytes consumed
+func (l *link) readCommand() ([][]byte, int, error) {
+	total := 0
+	for {
+		line, n, err := l.readLine()
+		if err != nil {
+			return nil, 0, err
+		}
+		total += n
+		if len(line) == 0 {
+			continue
+		}
+		if line[0] != '*' {
+			args := bytes.Fields(line)
+			if len(args) == 0 {
+				continue
+			}
+			return args, total, nil
+		}
+
+		count, err := strconv.Atoi(string(line[1:]))
+		if err != nil || count < 1 {
+			return nil, 0, fmt.Errorf("invalid array header %q", line)
+		}
+		args := make([][]byte, count)
+		for i := range args {
+			header, hn, err := l.readLine()
+			if err != nil {
+				return nil, 0, err
+			}
+			if len(header) == 0 || header[0] != '$' {
+				return nil, 0, fmt.Errorf("invalid bulk header %q", header)
+			}
+			size, err := strconv.Atoi(string(header[1:]))
+			if err != nil || size < 0 {
+				return nil, 0, fmt.Errorf("invalid bulk header %q", header)
+			}
+			arg := make([]byte, size+2)
+			if _, err := io.ReadFull(l.br, arg); err != nil {
+				return nil, 0, err
+			}
+			args[i] = arg[:size]
+			total += hn + size + 2
+		}
+		return args, total, nil
+	}
+}
@@ -0,0 +1,109 @@
+package rdb
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/luoyjx/crdt-redis/storage"
+)
+
+// ToValue converts an entry into a CRDT value whose elements are all
+// stamped with replicaID and timestamp. The conversion is deterministic, so
+// loading the same file with the same replica ID and timestamp on every
+// replica produces identical values.
+func ToValue(e *Entry, replicaID string, timestamp int64) (*storage.Value, error) {
+	var v *storage.Value
+	switch e.Type {
+	case storage.TypeString:
+		v = storage.NewStringValue(e.String, timestamp, replicaID)
+	case storage.TypeList:
+		v = storage.NewListValue(timestamp, replicaID)
+		list := v.List()
+		list.RPushAll(e.Elements, timestamp, replicaID)
+		v.SetList(list, timestamp)
+	case storage.TypeSet:
+		v = storage.NewSetValue(timestamp, replicaID)
+		set := v.Set()
+		for _, member := range e.Elements {
+			set.Add(member, timestamp, replicaID)
+		}
+		v.SetSet(set, timestamp)
+	case storage.TypeHash:
+		v = storage.NewHashValue(timestamp, replicaID)
+		hash := v.Hash()
+		for _, f := range e.Fields {
+			hash.Set(f.Name, f.Value, timestamp, replicaID)
+		}
+		v.SetHash(hash, timestamp)
+	case storage.TypeZSet:
+		v = storage.NewZSetValue(replicaID, nil)
+		zset, _ := v.GetZSet()
+		scores := make(map[string]float64, len(e.Members))
+		for _, m := range e.Members {
+			scores[m.Name] = m.Score
+		}
+		zset.ZAddAt(scores, timestamp, storage.NewVectorClock())
+		if err := v.SetZSet(zset); err != nil {
+			return nil, err
+		}
+		v.Timestamp = timestamp
+	default:
+		return nil, fmt.Errorf("key %q has unsupported type %d", e.Key, e.Type)
+	}
+	if !e.ExpireAt.IsZero() {
+		v.SetExpireAt(&e.ExpireAt)
+	}
+	return v, nil
+}
+
+// FromValue converts the visible state of a value into an entry in db 0.
+// It returns false for empty collections, which Redis does not store.
+// Counters become strings; set members and hash fields are sorted.
+func FromValue(key string, v *storage.Value) (*Entry, bool, error) {
+	e := &Entry{Key: key, Type: v.Type}
+	if v.TTL != nil {
+		e.ExpireAt = v.ExpireAt
+	}
+	switch v.Type {
+	case storage.TypeString, storage.TypeCounter, storage.TypeFloatCounter:
+		e.Type = storage.TypeString
+		e.String = v.String()
+		return e, true, nil
+	case storage.TypeList:
+		list := v.List()
+		if list == nil {
+			return nil, false, fmt.Errorf("key %q: invalid list", key)
+		}
+		e.Elements = list.Range(0, -1)
+		return e, len(e.Elements) > 0, nil
+	case storage.TypeSet:
+		set := v.Set()
+		if set == nil {
+			return nil, false, fmt.Errorf("key %q: invalid set", key)
+		}
+		e.Elements = set.Members()
+		sort.Strings(e.Elements)
+		return e, len(e.Elements) > 0, nil
+	case storage.TypeHash:
+		hash := v.Hash()
+		if hash == nil {
+			return nil, false, fmt.Errorf("key %q: invalid hash", key)
+		}
+		for name, value := range hash.GetAll() {
+			e.Fields = append(e.Fields, Field{Name: name, Value: value})
+		}
+		sort.Slice(e.Fields, func(i, j int) bool { return e.Fields[i].Name < e.Fields[j].Name })
+		return e, len(e.Fields) > 0, nil
+	case storage.TypeZSet:
+		zset, err := v.GetZSet()
+		if err != nil {
+			return nil, false, fmt.Errorf("key %q: %v", key, err)
+		}
+		members, scores := zset.ZRange(0, -1, true)
+		for i, name := range members {
+			e.Members = append(e.Members, Member{Name: name, Score: scores[i]})
+		}
+		return e, len(e.Members) > 0, nil
+	}
+	return nil, false, fmt.Errorf("key %q has unsupported type %d", key, v.Type)
+}
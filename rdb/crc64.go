@@ -0,0 +1,11 @@
+package rdb
+
+import "hash/crc64"
+
+// RDB checksums are CRC-64/Jones, reflected, with no initial or final
+// inversion; hash/crc64 inverts both, which crc64Update undoes
+var crc64Table = crc64.MakeTable(0x95ac9329ac4bc9b5)
+
+func crc64Update(crc uint64, p []byte) uint64 {
+	return ^crc64.Update(^crc, crc64Table, p)
+}
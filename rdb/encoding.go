@@ -0,0 +1,344 @@
+package rdb
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strconv"
+
+	"github.com/luoyjx/crdt-redis/storage"
+)
+
+// decodeBlob decodes a collection stored in one of the compact encodings
+func decodeBlob(entry *Entry, typ byte, b []byte) error {
+	var items []string
+	var err error
+	switch typ {
+	case typeListZiplist, typeHashZiplist, typeZSetZiplist:
+		items, err = decodeZiplist(b)
+	case typeSetListpack, typeHashListpack, typeZSetListpack:
+		items, err = decodeListpack(b)
+	case typeSetIntset:
+		items, err = decodeIntset(b)
+	case typeHashZipmap:
+		items, err = decodeZipmap(b)
+	}
+	if err != nil {
+		return err
+	}
+
+	switch typ {
+	case typeListZiplist:
+		entry.Type, entry.Elements = storage.TypeList, items
+	case typeSetIntset, typeSetListpack:
+		entry.Type, entry.Elements = storage.TypeSet, items
+	case typeHashZipmap, typeHashZiplist, typeHashListpack:
+		if len(items)%2 != 0 {
+			return fmt.Errorf("hash has an odd number of entries")
+		}
+		entry.Type, entry.Fields = storage.TypeHash, fieldsFromPairs(items)
+	case typeZSetZiplist, typeZSetListpack:
+		if len(items)%2 != 0 {
+			return fmt.Errorf("sorted set has an odd number of entries")
+		}
+		entry.Type = storage.TypeZSet
+		for i := 0; i < len(items); i += 2 {
+			score, err := strconv.ParseFloat(items[i+1], 64)
+			if err != nil {
+				return fmt.Errorf("invalid score %q", items[i+1])
+			}
+			entry.Members = append(entry.Members, Member{Name: items[i], Score: score})
+		}
+	}
+	return nil
+}
+
+// blob reads a compact encoding, failing instead of reading past its end
+type blob struct {
+	b   []byte
+	pos int
+}
+
+func (bl *blob) take(n int) ([]byte, error) {
+	if n < 0 || bl.pos+n > len(bl.b) {
+		return nil, fmt.Errorf("encoded collection is truncated")
+	}
+	b := bl.b[bl.pos : bl.pos+n]
+	bl.pos += n
+	return b, nil
+}
+
+func (bl *blob) end() (bool, error) {
+	if bl.pos >= len(bl.b) {
+		return false, fmt.Errorf("encoded collection is truncated")
+	}
+	return bl.b[bl.pos] == 0xff, nil
+}
+
+// decodeZiplist decodes a ziplist: a header, then entries of a previous
+// entry length, an encoding and the data, then 0xff
+func decodeZiplist(b []byte) ([]string, error) {
+	bl := &blob{b: b}
+	if _, err := bl.take(10); err != nil {
+		return nil, err
+	}
+	var items []string
+	for {
+		if end, err := bl.end(); err != nil || end {
+			return items, err
+		}
+		prev, _ := bl.take(1)
+		if prev[0] == 0xfe {
+			if _, err := bl.take(4); err != nil {
+				return nil, err
+			}
+		}
+		enc, err := bl.take(1)
+		if err != nil {
+			return nil, err
+		}
+		var n int
+		switch e := enc[0]; {
+		case e>>6 == 0:
+			n = int(e & 0x3f)
+		case e>>6 == 1:
+			next, err := bl.take(1)
+			if err != nil {
+				return nil, err
+			}
+			n = int(e&0x3f)<<8 | int(next[0])
+		case e>>6 == 2:
+			l, err := bl.take(4)
+			if err != nil {
+				return nil, err
+			}
+			n = int(binary.BigEndian.Uint32(l))
+		default:
+			v, err := ziplistInt(bl, e)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, strconv.FormatInt(v, 10))
+			continue
+		}
+		data, err := bl.take(n)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, string(data))
+	}
+}
+
+// ziplistInt reads the integer for ziplist encoding e
+func ziplistInt(bl *blob, e byte) (int64, error) {
+	size := map[byte]int{0xc0: 2, 0xd0: 4, 0xe0: 8, 0xf0: 3, 0xfe: 1}[e]
+	if size == 0 {
+		if e < 0xf1 || e > 0xfd {
+			return 0, fmt.Errorf("invalid ziplist encoding 0x%02x", e)
+		}
+		return int64(e&0x0f) - 1, nil
+	}
+	b, err := bl.take(size)
+	if err != nil {
+		return 0, err
+	}
+	return littleEndianInt(b), nil
+}
+
+// decodeListpack decodes a listpack: a header, then entries of an encoding,
+// the data and a back length, then 0xff
+func decodeListpack(b []byte) ([]string, error) {
+	bl := &blob{b: b}
+	if _, err := bl.take(6); err != nil {
+		return nil, err
+	}
+	var items []string
+	for {
+		if end, err := bl.end(); err != nil || end {
+			return items, err
+		}
+		start := bl.pos
+		enc, _ := bl.take(1)
+		e := enc[0]
+		var item string
+		var err error
+		switch {
+		case e&0x80 == 0:
+			item = strconv.Itoa(int(e))
+		case e&0xc0 == 0x80:
+			item, err = bl.str(int(e & 0x3f))
+		case e&0xe0 == 0xc0:
+			var next []byte
+			if next, err = bl.take(1); err == nil {
+				v := int(e&0x1f)<<8 | int(next[0])
+				if v >= 1<<12 {
+					v -= 1 << 13
+				}
+				item = strconv.Itoa(v)
+			}
+		case e&0xf0 == 0xe0:
+			var next []byte
+			if next, err = bl.take(1); err == nil {
+				item, err = bl.str(int(e&0x0f)<<8 | int(next[0]))
+			}
+		case e == 0xf0:
+			var l []byte
+			if l, err = bl.take(4); err == nil {
+				item, err = bl.str(int(binary.LittleEndian.Uint32(l)))
+			}
+		case e >= 0xf1 && e <= 0xf4:
+			var v []byte
+			if v, err = bl.take([]int{2, 3, 4, 8}[e-0xf1]); err == nil {
+				item = strconv.FormatInt(littleEndianInt(v), 10)
+			}
+		default:
+			err = fmt.Errorf("invalid listpack encoding 0x%02x", e)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if _, err := bl.take(listpackBacklenSize(bl.pos - start)); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+}
+
+func (bl *blob) str(n int) (string, error) {
+	b, err := bl.take(n)
+	return string(b), err
+}
+
+// listpackBacklenSize returns how many bytes the back length of an entry of
+// n bytes takes
+func listpackBacklenSize(n int) int {
+	switch {
+	case n <= 127:
+		return 1
+	case n < 16383:
+		return 2
+	case n < 2097151:
+		return 3
+	case n < 268435455:
+		return 4
+	}
+	return 5
+}
+
+// decodeIntset decodes an intset: the integer size, the count and the
+// integers, all little endian
+func decodeIntset(b []byte) ([]string, error) {
+	bl := &blob{b: b}
+	header, err := bl.take(8)
+	if err != nil {
+		return nil, err
+	}
+	size := int(binary.LittleEndian.Uint32(header))
+	if size != 2 && size != 4 && size != 8 {
+		return nil, fmt.Errorf("invalid intset encoding %d", size)
+	}
+	n := int(binary.LittleEndian.Uint32(header[4:]))
+	data, err := bl.take(n * size)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]string, n)
+	for i := range items {
+		items[i] = strconv.FormatInt(littleEndianInt(data[i*size:(i+1)*size]), 10)
+	}
+	return items, nil
+}
+
+// decodeZipmap decodes a zipmap: a count, then lengths and strings of keys
+// and values, each value followed by unused bytes, then 0xff
+func decodeZipmap(b []byte) ([]string, error) {
+	bl := &blob{b: b}
+	if _, err := bl.take(1); err != nil {
+		return nil, err
+	}
+	var items []string
+	for {
+		if end, err := bl.end(); err != nil || end {
+			if len(items)%2 != 0 {
+				return nil, fmt.Errorf("zipmap key without a value")
+			}
+			return items, err
+		}
+		l, _ := bl.take(1)
+		n := int(l[0])
+		if n == 254 {
+			ext, err := bl.take(4)
+			if err != nil {
+				return nil, err
+			}
+			n = int(binary.LittleEndian.Uint32(ext))
+		}
+		free := 0
+		if len(items)%2 == 1 {
+			f, err := bl.take(1)
+			if err != nil {
+				return nil, err
+			}
+			free = int(f[0])
+		}
+		s, err := bl.str(n)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := bl.take(free); err != nil {
+			return nil, err
+		}
+		items = append(items, s)
+	}
+}
+
+// littleEndianInt decodes a signed little endian integer of 1 to 8 bytes
+func littleEndianInt(b []byte) int64 {
+	var v uint64
+	for i := len(b) - 1; i >= 0; i-- {
+		v = v<<8 | uint64(b[i])
+	}
+	shift := 64 - 8*uint(len(b))
+	return int64(v<<shift) >> shift
+}
+
+// lzfDecompress expands LZF data: runs of literal bytes and back
+// references into the output
+func lzfDecompress(in []byte, length int) ([]byte, error) {
+	out := make([]byte, 0, length)
+	for i := 0; i < len(in); {
+		ctrl := int(in[i])
+		i++
+		if ctrl < 32 {
+			n := ctrl + 1
+			if i+n > len(in) {
+				return nil, fmt.Errorf("invalid LZF data")
+			}
+			out = append(out, in[i:i+n]...)
+			i += n
+			continue
+		}
+		n := ctrl >> 5
+		if n == 7 {
+			if i >= len(in) {
+				return nil, fmt.Errorf("invalid LZF data")
+			}
+			n += int(in[i])
+			i++
+		}
+		if i >= len(in) {
+			return nil, fmt.Errorf("invalid LZF data")
+		}
+		ref := len(out) - (ctrl&0x1f)<<8 - int(in[i]) - 1
+		i++
+		if ref < 0 {
+			return nil, fmt.Errorf("invalid LZF data")
+		}
+		for j := 0; j < n+2; j++ {
+			out = append(out, out[ref+j])
+		}
+	}
+	if len(out) != length {
+		return nil, fmt.Errorf("LZF data expands to %d bytes, want %d", len(out), length)
+	}
+	return out, nil
+}
@@ -0,0 +1,95 @@
+package rdb
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/luoyjx/crdt-redis/storage"
+)
+
+// An RDB file is "REDIS" and a four digit version, then opcodes and
+// key-value records, an EOF opcode and a CRC64 of everything before it
+// (from version 5). Lengths use a prefix encoding; strings may be stored as
+// integers or LZF compressed. Collections have a plain encoding and several
+// compact ones (ziplist, listpack, intset, zipmap) that Redis picks by size
+// and version; the Reader decodes them all and the Writer only uses the
+// plain ones, which every Redis since 4.0 loads.
+
+// Version is the RDB version written by Writer
+const Version = 9
+
+// maxVersion is the newest RDB version Reader accepts
+const maxVersion = 12
+
+// Opcodes
+const (
+	opFunction2    = 0xf5
+	opModuleAux    = 0xf7
+	opIdle         = 0xf8
+	opFreq         = 0xf9
+	opAux          = 0xfa
+	opResizeDB     = 0xfb
+	opExpireTimeMS = 0xfc
+	opExpireTime   = 0xfd
+	opSelectDB     = 0xfe
+	opEOF          = 0xff
+)
+
+// Value types
+const (
+	typeString          = 0
+	typeList            = 1
+	typeSet             = 2
+	typeZSet            = 3
+	typeHash            = 4
+	typeZSet2           = 5
+	typeHashZipmap      = 9
+	typeListZiplist     = 10
+	typeSetIntset       = 11
+	typeZSetZiplist     = 12
+	typeHashZiplist     = 13
+	typeListQuicklist   = 14
+	typeHashListpack    = 16
+	typeZSetListpack    = 17
+	typeListQuicklist2  = 18
+	typeSetListpack     = 20
+	quicklistNodePlain  = 1
+	quicklistNodePacked = 2
+)
+
+// Field is a hash field
+type Field struct {
+	Name  string
+	Value string
+}
+
+// Member is a sorted set member
+type Member struct {
+	Name  string
+	Score float64
+}
+
+// Entry is a key read from or written to an RDB file. Type says which of
+// String, Elements (list elements in order, or set members), Fields and
+// Members holds the value.
+type Entry struct {
+	DB       int
+	Key      string
+	Type     storage.ValueType // TypeString, TypeList, TypeSet, TypeHash or TypeZSet
+	ExpireAt time.Time         // zero for no expiration
+	String   string
+	Elements []string
+	Fields   []Field
+	Members  []Member
+}
+
+// UnsupportedTypeError is returned for keys of types the CRDT store has no
+// equivalent for, such as streams and module types
+type UnsupportedTypeError struct {
+	Key  string
+	Type byte
+}
+
+func (e *UnsupportedTypeError) Error() string {
+	return fmt.Sprintf("key %q has unsupported RDB type %d", e.Key, e.Type)
+}
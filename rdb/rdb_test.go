@@ -0,0 +1,298 @@
+package rdb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/luoyjx/crdt-redis/storage"
+)
+
+// export writes the live keys of store as an RDB file
+func export(t *testing.T, store *storage.Store) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, time.Unix(1700000000, 0))
+	if err != nil {
+		t.Fatalf("NewWriter failed: %v", err)
+	}
+	err = store.Range(func(key string, value *storage.Value) bool {
+		e, ok, err := FromValue(key, value)
+		if err != nil {
+			t.Fatalf("FromValue failed: %v", err)
+		}
+		if ok {
+			err = w.Write(e)
+		}
+		return err == nil
+	})
+	if err != nil {
+		t.Fatalf("export failed: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// readAll reads every entry of an RDB file
+func readAll(t *testing.T, data []byte) []*Entry {
+	t.Helper()
+	r, err := NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("NewReader failed: %v", err)
+	}
+	var entries []*Entry
+	for {
+		e, err := r.Next()
+		if err == io.EOF {
+			return entries
+		}
+		if err != nil {
+			t.Fatalf("Next failed: %v", err)
+		}
+		entries = append(entries, e)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	src, err := storage.NewStore(t.TempDir(), "", 0)
+	if err != nil {
+		t.Fatalf("NewStore failed: %v", err)
+	}
+	defer src.Close()
+	ttl := int64(3600)
+	src.Set("str", storage.NewStringValue("hello", 1, "r1"), nil)
+	src.Set("ttl", storage.NewStringValue("soon", 1, "r1"), &ttl)
+	src.IncrBy("counter", -7)
+	src.RPush("list", []string{"a", "b", "c"})
+	src.LPush("list", []string{"z"})
+	src.SAdd("set", []string{"x", "y", "z"})
+	src.SRem("set", []string{"y"})
+	src.HSet("hash", "f1", "v1")
+	src.HIncrBy("hash", "n", 3)
+	src.ZAdd("zset", map[string]float64{"m1": 1.5, "m2": -2, "m3": math.Inf(1)})
+	src.SAdd("empty", []string{"x"})
+	src.SRem("empty", []string{"x"})
+
+	data := export(t, src)
+	entries := readAll(t, data)
+	if len(entries) != 7 {
+		t.Fatalf("read %d keys, want 7 (the empty set is not exported)", len(entries))
+	}
+
+	dst, err := storage.NewStore(t.TempDir(), "", 0)
+	if err != nil {
+		t.Fatalf("NewStore failed: %v", err)
+	}
+	defer dst.Close()
+	for _, e := range entries {
+		v, err := ToValue(e, "rdb", 42)
+		if err != nil {
+			t.Fatalf("ToValue failed: %v", err)
+		}
+		if err := dst.Import(e.Key, v); err != nil {
+			t.Fatalf("Import failed: %v", err)
+		}
+	}
+	if got := export(t, dst); !bytes.Equal(got, data) {
+		t.Errorf("export after import differs:\n%q\n%q", got, data)
+	}
+	if v, _ := dst.Get("counter"); v == nil || v.String() != "-7" {
+		t.Errorf("counter = %v", v)
+	}
+	if n, err := dst.IncrBy("counter", 10); err != nil || n != 3 {
+		t.Errorf("INCRBY on an imported counter = %d, %v", n, err)
+	}
+	if ttl, ok := dst.GetTTL("ttl"); !ok || ttl <= 0 || ttl > 3600 {
+		t.Errorf("ttl of imported key = %d %v", ttl, ok)
+	}
+
+	// The same file gives the same values every time
+	for _, e := range entries {
+		a, _ := ToValue(e, "rdb", 42)
+		b, _ := ToValue(e, "rdb", 42)
+		a.TTL, b.TTL = nil, nil
+		if !reflect.DeepEqual(a, b) {
+			t.Errorf("%s converts differently each time", e.Key)
+		}
+	}
+}
+
+// rdbFile builds an RDB file of the given version from records, with a checksum
+func rdbFile(version string, records ...[]byte) []byte {
+	data := []byte("REDIS" + version)
+	for _, r := range records {
+		data = append(data, r...)
+	}
+	data = append(data, opEOF)
+	var sum [8]byte
+	binary.LittleEndian.PutUint64(sum[:], crc64Update(0, data))
+	return append(data, sum[:]...)
+}
+
+func str(s string) []byte {
+	return append([]byte{byte(len(s))}, s...)
+}
+
+func record(typ byte, key string, value ...[]byte) []byte {
+	b := append([]byte{typ}, str(key)...)
+	for _, v := range value {
+		b = append(b, v...)
+	}
+	return b
+}
+
+// ziplist encodes short strings and integers 0 to 12
+func ziplist(items ...interface{}) []byte {
+	b := make([]byte, 10)
+	for _, item := range items {
+		b = append(b, 0) // previous entry length, not used by the reader
+		switch v := item.(type) {
+		case string:
+			b = append(append(b, byte(len(v))), v...)
+		case int:
+			b = append(b, 0xf1+byte(v))
+		}
+	}
+	binary.LittleEndian.PutUint32(b, uint32(len(b)+1))
+	binary.LittleEndian.PutUint16(b[8:], uint16(len(items)))
+	return append(b, 0xff)
+}
+
+// listpack encodes short strings and integers from -4096 to 4095
+func listpack(items ...interface{}) []byte {
+	b := make([]byte, 6)
+	for _, item := range items {
+		var entry []byte
+		switch v := item.(type) {
+		case string:
+			entry = append([]byte{0x80 | byte(len(v))}, v...)
+		case int:
+			if v >= 0 && v < 128 {
+				entry = []byte{byte(v)}
+			} else {
+				u := uint16(v) & 0x1fff
+				entry = []byte{0xc0 | byte(u>>8), byte(u)}
+			}
+		}
+		b = append(append(b, entry...), byte(len(entry)))
+	}
+	binary.LittleEndian.PutUint32(b, uint32(len(b)+1))
+	binary.LittleEndian.PutUint16(b[4:], uint16(len(items)))
+	return append(b, 0xff)
+}
+
+func blobString(b []byte) []byte {
+	return append([]byte{0x40 | byte(len(b)>>8), byte(len(b))}, b...)
+}
+
+func TestReadEncodings(t *testing.T) {
+	intset := []byte{2, 0, 0, 0, 3, 0, 0, 0, 0xfe, 0xff, 5, 0, 0, 1}
+	zipmap := []byte{1, 1, 'f', 2, 0, 'v', '1', 0xff}
+	expire := []byte{opExpireTime, 0, 0, 0, 0}
+	binary.LittleEndian.PutUint32(expire[1:], 2000000000)
+	data := rdbFile("0011",
+		[]byte{opAux}, str("ctime"), str("1700000000"),
+		[]byte{opSelectDB, 0, opResizeDB, 9, 1},
+		record(typeString, "int8", []byte{0xc0, 0xf6}),
+		record(typeString, "int32", []byte{0xc2, 0x40, 0xe2, 0x01, 0x00}),
+		// "a" then a back reference copying it nine times
+		record(typeString, "lzf", []byte{0xc3, 5, 10, 0x00, 'a', 0xe0, 0x00, 0x00}),
+		expire,
+		record(typeListZiplist, "ziplist", blobString(ziplist("a", 7, "bc"))),
+		record(typeListQuicklist2, "quicklist", []byte{2, quicklistNodePacked}, blobString(listpack("x", -5)), []byte{quicklistNodePlain}, str("big")),
+		record(typeSetIntset, "intset", blobString(intset)),
+		record(typeSetListpack, "setlp", blobString(listpack("m", 100))),
+		record(typeHashZipmap, "zipmap", blobString(zipmap)),
+		record(typeHashListpack, "hashlp", blobString(listpack("f", "v", "n", 1))),
+		record(typeZSetZiplist, "zsetzl", blobString(ziplist("a", "1.5", "b", 2))),
+		[]byte{opSelectDB, 1},
+		record(typeZSet, "zset1", []byte{2}, str("inf"), []byte{254}, str("neg"), str("-3")),
+	)
+
+	r, err := NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("NewReader failed: %v", err)
+	}
+	got := map[string]*Entry{}
+	for {
+		e, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Next failed: %v", err)
+		}
+		got[e.Key] = e
+	}
+	if r.Version() != 11 || r.Created().Unix() != 1700000000 {
+		t.Errorf("version %d created %v", r.Version(), r.Created())
+	}
+
+	check := func(key string, want Entry) {
+		t.Helper()
+		e := got[key]
+		if e == nil {
+			t.Errorf("%s missing", key)
+			return
+		}
+		want.Key = key
+		if !reflect.DeepEqual(*e, want) {
+			t.Errorf("%s = %+v, want %+v", key, *e, want)
+		}
+	}
+	check("int8", Entry{Type: storage.TypeString, String: "-10"})
+	check("int32", Entry{Type: storage.TypeString, String: "123456"})
+	check("lzf", Entry{Type: storage.TypeString, String: "aaaaaaaaaa"})
+	check("ziplist", Entry{Type: storage.TypeList, Elements: []string{"a", "7", "bc"}, ExpireAt: time.Unix(2000000000, 0)})
+	check("quicklist", Entry{Type: storage.TypeList, Elements: []string{"x", "-5", "big"}})
+	check("intset", Entry{Type: storage.TypeSet, Elements: []string{"-2", "5", "256"}})
+	check("setlp", Entry{Type: storage.TypeSet, Elements: []string{"m", "100"}})
+	check("zipmap", Entry{Type: storage.TypeHash, Fields: []Field{{"f", "v1"}}})
+	check("hashlp", Entry{Type: storage.TypeHash, Fields: []Field{{"f", "v"}, {"n", "1"}}})
+	check("zsetzl", Entry{Type: storage.TypeZSet, Members: []Member{{"a", 1.5}, {"b", 2}}})
+	check("zset1", Entry{DB: 1, Type: storage.TypeZSet, Members: []Member{{"inf", math.Inf(1)}, {"neg", -3}}})
+}
+
+func TestReadErrors(t *testing.T) {
+	good := rdbFile("0009", record(typeString, "k", str("v")))
+	corrupt := append([]byte(nil), good...)
+	corrupt[13] ^= 0xff
+	unchecked := append(append([]byte(nil), good[:len(good)-8]...), make([]byte, 8)...)
+	for _, tc := range []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{"not rdb", []byte("REDXS0009"), "not an RDB file"},
+		{"newer version", rdbFile("0099"), "unsupported RDB version"},
+		{"truncated", good[:len(good)-12], "truncated"},
+		{"checksum", corrupt, "checksum mismatch"},
+		{"stream", rdbFile("0010", record(15, "s")), "unsupported RDB type 15"},
+		{"bad ziplist", rdbFile("0009", record(typeListZiplist, "l", str("\x00\x00\x00"))), "truncated"},
+		{"no checksum", unchecked, ""},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var err error
+			r, err := NewReader(bytes.NewReader(tc.data))
+			for err == nil {
+				_, err = r.Next()
+			}
+			if tc.want == "" {
+				if err != io.EOF {
+					t.Errorf("error = %v, want none", err)
+				}
+				return
+			}
+			if err == io.EOF || !strings.Contains(err.Error(), tc.want) {
+				t.Errorf("error = %v, want %q", err, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,445 @@
+package rdb
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/luoyjx/crdt-redis/storage"
+)
+
+// Reader reads the keys of an RDB file in order
+type Reader struct {
+	r        *bufio.Reader
+	version  int
+	crc      uint64
+	db       int
+	aux      map[string]string
+	done     bool
+	expireAt time.Time
+}
+
+// NewReader reads the header of an RDB file
+func NewReader(r io.Reader) (*Reader, error) {
+	rd := &Reader{r: bufio.NewReader(r), aux: make(map[string]string)}
+	header, err := rd.read(9)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read RDB header: %v", err)
+	}
+	if string(header[:5]) != "REDIS" {
+		return nil, fmt.Errorf("not an RDB file")
+	}
+	version, err := strconv.Atoi(string(header[5:]))
+	if err != nil || version < 1 || version > maxVersion {
+		return nil, fmt.Errorf("unsupported RDB version %q", header[5:])
+	}
+	rd.version = version
+	return rd, nil
+}
+
+// Version returns the RDB version of the file
+func (rd *Reader) Version() int {
+	return rd.version
+}
+
+// Aux returns the auxiliary fields read so far, such as redis-ver and ctime.
+// Redis writes them before the first key.
+func (rd *Reader) Aux() map[string]string {
+	return rd.aux
+}
+
+// Created returns when the file was written, from its ctime auxiliary field,
+// or the zero time if it has none
+func (rd *Reader) Created() time.Time {
+	ctime, err := strconv.ParseInt(rd.aux["ctime"], 10, 64)
+	if err != nil {
+		return time.Time{}
+	}
+	return time.Unix(ctime, 0)
+}
+
+// Next returns the next key, or io.EOF after the last one once the checksum
+// has been verified
+func (rd *Reader) Next() (*Entry, error) {
+	if rd.done {
+		return nil, io.EOF
+	}
+	for {
+		op, err := rd.readByte()
+		if err != nil {
+			return nil, rd.truncated(err)
+		}
+		switch op {
+		case opEOF:
+			rd.done = true
+			return nil, rd.verifyChecksum()
+		case opSelectDB:
+			db, err := rd.readLength()
+			if err != nil {
+				return nil, rd.truncated(err)
+			}
+			rd.db = int(db)
+		case opResizeDB:
+			if _, err := rd.readLength(); err != nil {
+				return nil, rd.truncated(err)
+			}
+			if _, err := rd.readLength(); err != nil {
+				return nil, rd.truncated(err)
+			}
+		case opAux:
+			key, err := rd.readString()
+			if err != nil {
+				return nil, rd.truncated(err)
+			}
+			value, err := rd.readString()
+			if err != nil {
+				return nil, rd.truncated(err)
+			}
+			rd.aux[key] = value
+		case opExpireTimeMS:
+			b, err := rd.read(8)
+			if err != nil {
+				return nil, rd.truncated(err)
+			}
+			rd.expireAt = time.UnixMilli(int64(binary.LittleEndian.Uint64(b)))
+		case opExpireTime:
+			b, err := rd.read(4)
+			if err != nil {
+				return nil, rd.truncated(err)
+			}
+			rd.expireAt = time.Unix(int64(binary.LittleEndian.Uint32(b)), 0)
+		case opIdle:
+			if _, err := rd.readLength(); err != nil {
+				return nil, rd.truncated(err)
+			}
+		case opFreq:
+			if _, err := rd.readByte(); err != nil {
+				return nil, rd.truncated(err)
+			}
+		case opModuleAux, opFunction2:
+			return nil, fmt.Errorf("RDB files with modules or functions are not supported")
+		default:
+			return rd.readEntry(op)
+		}
+	}
+}
+
+// readEntry reads the key and value of a record of type typ
+func (rd *Reader) readEntry(typ byte) (*Entry, error) {
+	key, err := rd.readString()
+	if err != nil {
+		return nil, rd.truncated(err)
+	}
+	entry := &Entry{DB: rd.db, Key: key, ExpireAt: rd.expireAt}
+	rd.expireAt = time.Time{}
+
+	switch typ {
+	case typeString:
+		entry.Type = storage.TypeString
+		entry.String, err = rd.readString()
+	case typeList, typeSet:
+		entry.Type = storage.TypeList
+		if typ == typeSet {
+			entry.Type = storage.TypeSet
+		}
+		entry.Elements, err = rd.readStrings(1)
+	case typeHash:
+		entry.Type = storage.TypeHash
+		var pairs []string
+		if pairs, err = rd.readStrings(2); err == nil {
+			entry.Fields = fieldsFromPairs(pairs)
+		}
+	case typeZSet, typeZSet2:
+		entry.Type = storage.TypeZSet
+		entry.Members, err = rd.readZSet(typ == typeZSet2)
+	case typeListZiplist, typeSetIntset, typeSetListpack, typeHashZipmap, typeHashZiplist, typeHashListpack, typeZSetZiplist, typeZSetListpack:
+		var data string
+		if data, err = rd.readString(); err == nil {
+			err = decodeBlob(entry, typ, []byte(data))
+		}
+	case typeListQuicklist, typeListQuicklist2:
+		entry.Type = storage.TypeList
+		entry.Elements, err = rd.readQuicklist(typ == typeListQuicklist2)
+	default:
+		return nil, &UnsupportedTypeError{Key: key, Type: typ}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("key %q: %v", key, rd.truncated(err))
+	}
+	return entry, nil
+}
+
+// readStrings reads a length and then length*per strings
+func (rd *Reader) readStrings(per uint64) ([]string, error) {
+	n, err := rd.readLength()
+	if err != nil {
+		return nil, err
+	}
+	items := make([]string, 0, min(n*per, 1024))
+	for i := uint64(0); i < n*per; i++ {
+		s, err := rd.readString()
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, s)
+	}
+	return items, nil
+}
+
+// readZSet reads a plain sorted set, with binary scores if binary is set
+// and scores as length-prefixed strings otherwise
+func (rd *Reader) readZSet(binaryScores bool) ([]Member, error) {
+	n, err := rd.readLength()
+	if err != nil {
+		return nil, err
+	}
+	members := make([]Member, 0, min(n, 1024))
+	for i := uint64(0); i < n; i++ {
+		name, err := rd.readString()
+		if err != nil {
+			return nil, err
+		}
+		var score float64
+		if binaryScores {
+			b, err := rd.read(8)
+			if err != nil {
+				return nil, err
+			}
+			score = math.Float64frombits(binary.LittleEndian.Uint64(b))
+		} else if score, err = rd.readFloatString(); err != nil {
+			return nil, err
+		}
+		members = append(members, Member{Name: name, Score: score})
+	}
+	return members, nil
+}
+
+// readFloatString reads a score written as a string with a one byte length,
+// where lengths 253, 254 and 255 stand for NaN, +inf and -inf
+func (rd *Reader) readFloatString() (float64, error) {
+	n, err := rd.readByte()
+	if err != nil {
+		return 0, err
+	}
+	switch n {
+	case 253:
+		return math.NaN(), nil
+	case 254:
+		return math.Inf(1), nil
+	case 255:
+		return math.Inf(-1), nil
+	}
+	b, err := rd.read(int(n))
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseFloat(string(b), 64)
+}
+
+// readQuicklist reads a list stored as a sequence of ziplists or, from
+// version 2, of listpacks and plain elements
+func (rd *Reader) readQuicklist(v2 bool) ([]string, error) {
+	n, err := rd.readLength()
+	if err != nil {
+		return nil, err
+	}
+	var elements []string
+	for i := uint64(0); i < n; i++ {
+		container := uint64(quicklistNodePacked)
+		if v2 {
+			if container, err = rd.readLength(); err != nil {
+				return nil, err
+			}
+		}
+		data, err := rd.readString()
+		if err != nil {
+			return nil, err
+		}
+		if container == quicklistNodePlain {
+			elements = append(elements, data)
+			continue
+		}
+		var items []string
+		if v2 {
+			items, err = decodeListpack([]byte(data))
+		} else {
+			items, err = decodeZiplist([]byte(data))
+		}
+		if err != nil {
+			return nil, err
+		}
+		elements = append(elements, items...)
+	}
+	return elements, nil
+}
+
+// readLength reads a length; encoded strings use readString
+func (rd *Reader) readLength() (uint64, error) {
+	n, encoded, err := rd.readLengthOrEncoding()
+	if err != nil {
+		return 0, err
+	}
+	if encoded {
+		return 0, fmt.Errorf("unexpected string encoding %d", n)
+	}
+	return n, nil
+}
+
+// readLengthOrEncoding reads a length, or the special encoding of a string
+// if encoded is set
+func (rd *Reader) readLengthOrEncoding() (n uint64, encoded bool, err error) {
+	first, err := rd.readByte()
+	if err != nil {
+		return 0, false, err
+	}
+	switch first >> 6 {
+	case 0:
+		return uint64(first & 0x3f), false, nil
+	case 1:
+		next, err := rd.readByte()
+		if err != nil {
+			return 0, false, err
+		}
+		return uint64(first&0x3f)<<8 | uint64(next), false, nil
+	case 3:
+		return uint64(first & 0x3f), true, nil
+	}
+	switch first {
+	case 0x80:
+		b, err := rd.read(4)
+		if err != nil {
+			return 0, false, err
+		}
+		return uint64(binary.BigEndian.Uint32(b)), false, nil
+	case 0x81:
+		b, err := rd.read(8)
+		if err != nil {
+			return 0, false, err
+		}
+		return binary.BigEndian.Uint64(b), false, nil
+	}
+	return 0, false, fmt.Errorf("invalid length encoding 0x%02x", first)
+}
+
+// readString reads a string, which may be stored as an integer or LZF
+// compressed
+func (rd *Reader) readString() (string, error) {
+	n, encoded, err := rd.readLengthOrEncoding()
+	if err != nil {
+		return "", err
+	}
+	if !encoded {
+		b, err := rd.read(int(n))
+		return string(b), err
+	}
+	switch n {
+	case 0:
+		b, err := rd.read(1)
+		if err != nil {
+			return "", err
+		}
+		return strconv.Itoa(int(int8(b[0]))), nil
+	case 1:
+		b, err := rd.read(2)
+		if err != nil {
+			return "", err
+		}
+		return strconv.Itoa(int(int16(binary.LittleEndian.Uint16(b)))), nil
+	case 2:
+		b, err := rd.read(4)
+		if err != nil {
+			return "", err
+		}
+		return strconv.Itoa(int(int32(binary.LittleEndian.Uint32(b)))), nil
+	case 3:
+		compressed, err := rd.readLength()
+		if err != nil {
+			return "", err
+		}
+		length, err := rd.readLength()
+		if err != nil {
+			return "", err
+		}
+		b, err := rd.read(int(compressed))
+		if err != nil {
+			return "", err
+		}
+		out, err := lzfDecompress(b, int(length))
+		return string(out), err
+	}
+	return "", fmt.Errorf("invalid string encoding %d", n)
+}
+
+// verifyChecksum reads the checksum after the EOF opcode; a zero checksum
+// means the file was written with rdbchecksum off
+func (rd *Reader) verifyChecksum() error {
+	if rd.version < 5 {
+		return io.EOF
+	}
+	want := rd.crc
+	b, err := rd.read(8)
+	if err != nil {
+		return rd.truncated(err)
+	}
+	if got := binary.LittleEndian.Uint64(b); got != 0 && got != want {
+		return fmt.Errorf("RDB checksum mismatch")
+	}
+	return io.EOF
+}
+
+// read reads n bytes, adding them to the checksum
+func (rd *Reader) read(n int) ([]byte, error) {
+	if n < 0 {
+		return nil, fmt.Errorf("invalid length %d", n)
+	}
+	// Grow the buffer as data arrives, so a corrupt length fails on EOF
+	// rather than allocating it up front
+	b := make([]byte, 0, min(uint64(n), 1<<16))
+	for len(b) < n {
+		chunk := min(uint64(n-len(b)), 1<<16)
+		start := len(b)
+		b = append(b, make([]byte, chunk)...)
+		if _, err := io.ReadFull(rd.r, b[start:]); err != nil {
+			return nil, err
+		}
+	}
+	rd.crc = crc64Update(rd.crc, b)
+	return b, nil
+}
+
+func (rd *Reader) readByte() (byte, error) {
+	c, err := rd.r.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	rd.crc = crc64Update(rd.crc, []byte{c})
+	return c, nil
+}
+
+// truncated reports the end of the input inside a record as an error
+func (rd *Reader) truncated(err error) error {
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return fmt.Errorf("RDB file is truncated")
+	}
+	return err
+}
+
+func fieldsFromPairs(pairs []string) []Field {
+	fields := make([]Field, 0, len(pairs)/2)
+	for i := 0; i+1 < len(pairs); i += 2 {
+		fields = append(fields, Field{Name: pairs[i], Value: pairs[i+1]})
+	}
+	return fields
+}
+
+func min(a, b uint64) uint64 {
+	if a < b {
+		return a
+	}
+	return b
+}
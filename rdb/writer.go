@@ -0,0 +1,137 @@
+package rdb
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/luoyjx/crdt-redis/storage"
+)
+
+// Writer writes keys to an RDB file in the plain encodings
+type Writer struct {
+	w   *bufio.Writer
+	crc uint64
+	db  int
+	err error
+}
+
+// NewWriter writes the header of an RDB file created at created
+func NewWriter(w io.Writer, created time.Time) (*Writer, error) {
+	wr := &Writer{w: bufio.NewWriter(w), db: -1}
+	wr.write([]byte(fmt.Sprintf("REDIS%04d", Version)))
+	wr.aux("redis-bits", "64")
+	wr.aux("ctime", strconv.FormatInt(created.Unix(), 10))
+	return wr, wr.err
+}
+
+// Write writes a key
+func (wr *Writer) Write(e *Entry) error {
+	if wr.db != e.DB {
+		wr.write([]byte{opSelectDB})
+		wr.writeLength(uint64(e.DB))
+		wr.db = e.DB
+	}
+	if !e.ExpireAt.IsZero() {
+		var b [8]byte
+		binary.LittleEndian.PutUint64(b[:], uint64(e.ExpireAt.UnixMilli()))
+		wr.write([]byte{opExpireTimeMS})
+		wr.write(b[:])
+	}
+
+	switch e.Type {
+	case storage.TypeString:
+		wr.write([]byte{typeString})
+		wr.writeString(e.Key)
+		wr.writeString(e.String)
+	case storage.TypeList, storage.TypeSet:
+		typ := byte(typeList)
+		if e.Type == storage.TypeSet {
+			typ = typeSet
+		}
+		wr.write([]byte{typ})
+		wr.writeString(e.Key)
+		wr.writeLength(uint64(len(e.Elements)))
+		for _, s := range e.Elements {
+			wr.writeString(s)
+		}
+	case storage.TypeHash:
+		wr.write([]byte{typeHash})
+		wr.writeString(e.Key)
+		wr.writeLength(uint64(len(e.Fields)))
+		for _, f := range e.Fields {
+			wr.writeString(f.Name)
+			wr.writeString(f.Value)
+		}
+	case storage.TypeZSet:
+		wr.write([]byte{typeZSet2})
+		wr.writeString(e.Key)
+		wr.writeLength(uint64(len(e.Members)))
+		for _, m := range e.Members {
+			var b [8]byte
+			binary.LittleEndian.PutUint64(b[:], math.Float64bits(m.Score))
+			wr.writeString(m.Name)
+			wr.write(b[:])
+		}
+	default:
+		return fmt.Errorf("key %q has unsupported type %d", e.Key, e.Type)
+	}
+	return wr.err
+}
+
+// Close writes the end of the file and its checksum and flushes it; the
+// underlying writer is left open
+func (wr *Writer) Close() error {
+	wr.write([]byte{opEOF})
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], wr.crc)
+	wr.write(b[:])
+	if wr.err != nil {
+		return wr.err
+	}
+	return wr.w.Flush()
+}
+
+func (wr *Writer) aux(key, value string) {
+	wr.write([]byte{opAux})
+	wr.writeString(key)
+	wr.writeString(value)
+}
+
+// writeLength writes n in the length encoding
+func (wr *Writer) writeLength(n uint64) {
+	switch {
+	case n < 1<<6:
+		wr.write([]byte{byte(n)})
+	case n < 1<<14:
+		wr.write([]byte{0x40 | byte(n>>8), byte(n)})
+	case n <= math.MaxUint32:
+		b := []byte{0x80, 0, 0, 0, 0}
+		binary.BigEndian.PutUint32(b[1:], uint32(n))
+		wr.write(b)
+	default:
+		b := make([]byte, 9)
+		b[0] = 0x81
+		binary.BigEndian.PutUint64(b[1:], n)
+		wr.write(b)
+	}
+}
+
+func (wr *Writer) writeString(s string) {
+	wr.writeLength(uint64(len(s)))
+	wr.write([]byte(s))
+}
+
+// write writes b and adds it to the checksum; after an error it does
+// nothing, and the error is returned by Write or Close
+func (wr *Writer) write(b []byte) {
+	if wr.err != nil {
+		return
+	}
+	wr.crc = crc64Update(wr.crc, b)
+	_, wr.err = wr.w.Write(b)
+}
@@ -0,0 +1,187 @@
+package redisprotocol
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/luoyjx/crdt-redis/acl"
+	"github.com/tidwall/redcon"
+)
+
+// clientState is the per-connection state kept in the redcon context
+type clientState struct {
+	user          string
+	authenticated bool
+	sub           *subscriber // set once the connection subscribes and is detached
+	master        bool        // commands replayed from a REPLICAOF master, not checked against ACLs
+}
+
+// SetACL enables AUTH and per-command permission checks against users in store
+func (rs *RedisServer) SetACL(store *acl.Store) {
+	rs.acl = store
+}
+
+// client returns the connection's state, creating it for connections that
+// were not set up by handleConnect
+func (rs *RedisServer) client(conn redcon.Conn) *clientState {
+	if st, ok := conn.Context().(*clientState); ok {
+		return st
+	}
+	st := rs.newClientState()
+	conn.SetContext(st)
+	return st
+}
+
+func (rs *RedisServer) newClientState() *clientState {
+	st := &clientState{user: acl.DefaultUser}
+	st.authenticated = rs.acl == nil || rs.acl.DefaultAuthenticated()
+	return st
+}
+
+// authorize checks authentication and ACL permissions before a command runs,
+// writing the error reply and returning false when the command is refused
+func (rs *RedisServer) authorize(conn redcon.Conn, name string, args [][]byte) bool {
+	if rs.acl == nil || name == "auth" {
+		return true
+	}
+	st := rs.client(conn)
+	if st.master {
+		return true
+	}
+	if !st.authenticated {
+		conn.WriteError("NOAUTH Authentication required.")
+		return false
+	}
+	// Every user may ask who they are
+	if name == "acl" && len(args) == 2 && strings.EqualFold(string(args[1]), "whoami") {
+		return true
+	}
+	if err := rs.acl.Check(st.user, args); err != nil {
+		if pe, ok := err.(*acl.PermissionError); ok && pe.Key != "" {
+			log.Printf("ACL denied: user=%s command=%s key=%s client=%s", st.user, name, pe.Key, conn.RemoteAddr())
+		} else {
+			log.Printf("ACL denied: user=%s command=%s client=%s", st.user, name, conn.RemoteAddr())
+		}
+		conn.WriteError(err.Error())
+		return false
+	}
+	return true
+}
+
+// handleAuth implements AUTH [username] password
+func (rs *RedisServer) handleAuth(conn redcon.Conn, cmd redcon.Command) {
+	if len(cmd.Args) != 2 && len(cmd.Args) != 3 {
+		conn.WriteError("ERR wrong number of arguments for 'auth' command")
+		return
+	}
+	if rs.acl == nil {
+		conn.WriteError("ERR AUTH <password> called without any password configured for the default user. Are you sure your configuration is correct?")
+		return
+	}
+	user, password := acl.DefaultUser, string(cmd.Args[1])
+	if len(cmd.Args) == 3 {
+		user, password = string(cmd.Args[1]), string(cmd.Args[2])
+	}
+	if !rs.acl.Authenticate(user, password) {
+		log.Printf("ACL denied: failed AUTH for user=%s client=%s", user, conn.RemoteAddr())
+		conn.WriteError("WRONGPASS invalid username-password pair or user is disabled.")
+		return
+	}
+	st := rs.client(conn)
+	st.user = user
+	st.authenticated = true
+	conn.WriteString("OK")
+}
+
+// handleACLCommand implements ACL SETUSER|GETUSER|DELUSER|LIST|USERS|WHOAMI|CAT
+func (rs *RedisServer) handleACLCommand(conn redcon.Conn, cmd redcon.Command) {
+	if len(cmd.Args) < 2 {
+		conn.WriteError("ERR wrong number of arguments for 'acl' command")
+		return
+	}
+	sub := strings.ToLower(string(cmd.Args[1]))
+	if sub == "whoami" {
+		conn.WriteBulkString(rs.client(conn).user)
+		return
+	}
+	if rs.acl == nil {
+		conn.WriteError("ERR ACL is not enabled")
+		return
+	}
+
+	switch sub {
+	case "setuser":
+		if len(cmd.Args) < 3 {
+			conn.WriteError("ERR wrong number of arguments for 'acl|setuser' command")
+			return
+		}
+		var rules []string
+		for _, arg := range cmd.Args[3:] {
+			rules = append(rules, string(arg))
+		}
+		if err := rs.acl.SetUser(string(cmd.Args[2]), rules); err != nil {
+			conn.WriteError(fmt.Sprintf("ERR %v", err))
+			return
+		}
+		conn.WriteString("OK")
+	case "getuser":
+		if len(cmd.Args) != 3 {
+			conn.WriteError("ERR wrong number of arguments for 'acl|getuser' command")
+			return
+		}
+		u, ok := rs.acl.GetUser(string(cmd.Args[2]))
+		if !ok {
+			conn.WriteNull()
+			return
+		}
+		conn.WriteArray(8)
+		conn.WriteBulkString("flags")
+		flags := u.Flags()
+		conn.WriteArray(len(flags))
+		for _, f := range flags {
+			conn.WriteBulkString(f)
+		}
+		conn.WriteBulkString("passwords")
+		conn.WriteArray(len(u.Passwords))
+		for _, p := range u.Passwords {
+			conn.WriteBulkString(p)
+		}
+		conn.WriteBulkString("commands")
+		conn.WriteBulkString(u.CommandRules())
+		conn.WriteBulkString("keys")
+		conn.WriteBulkString(u.KeyRules())
+	case "deluser":
+		if len(cmd.Args) < 3 {
+			conn.WriteError("ERR wrong number of arguments for 'acl|deluser' command")
+			return
+		}
+		var names []string
+		for _, arg := range cmd.Args[2:] {
+			names = append(names, string(arg))
+		}
+		n, err := rs.acl.DelUser(names...)
+		if err != nil {
+			conn.WriteError(fmt.Sprintf("ERR %v", err))
+			return
+		}
+		conn.WriteInt(n)
+	case "list", "users":
+		lines := rs.acl.List()
+		if sub == "users" {
+			lines = rs.acl.Users()
+		}
+		conn.WriteArray(len(lines))
+		for _, line := range lines {
+			conn.WriteBulkString(line)
+		}
+	case "cat":
+		cats := acl.Categories()
+		conn.WriteArray(len(cats))
+		for _, c := range cats {
+			conn.WriteBulkString(c)
+		}
+	default:
+		conn.WriteError(fmt.Sprintf("ERR unknown subcommand '%s' for 'acl'", string(cmd.Args[1])))
+	}
+}
@@ -0,0 +1,151 @@
+package redisprotocol
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/luoyjx/crdt-redis/acl"
+	"github.com/tidwall/redcon"
+)
+
+// respClient is a minimal RESP client for exercising the listener end to end
+type respClient struct {
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+// serveTest starts rs on a loopback listener and returns its address
+func serveTest(t *testing.T, rs *RedisServer) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	go redcon.Serve(ln, rs.handleCommand, rs.handleConnect, rs.handleDisconnect)
+	t.Cleanup(func() { ln.Close() })
+	return ln.Addr().String()
+}
+
+func dial(t *testing.T, addr string) *respClient {
+	t.Helper()
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return &respClient{conn: conn, r: bufio.NewReader(conn)}
+}
+
+// do sends a command and returns the reply rendered as a string; errors are
+// returned with their RESP prefix stripped, arrays as space-joined elements
+func (c *respClient) do(t *testing.T, args ...string) string {
+	t.Helper()
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, a := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(a), a)
+	}
+	if _, err := c.conn.Write([]byte(b.String())); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	reply, err := c.read()
+	if err != nil {
+		t.Fatalf("read failed: %v", err)
+	}
+	return reply
+}
+
+func (c *respClient) read() (string, error) {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	switch line[0] {
+	case '+', '-', ':':
+		return line[1:], nil
+	case '$':
+		n, _ := strconv.Atoi(line[1:])
+		if n < 0 {
+			return "(nil)", nil
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(c.r, buf); err != nil {
+			return "", err
+		}
+		return string(buf[:n]), nil
+	case '*':
+		n, _ := strconv.Atoi(line[1:])
+		if n < 0 {
+			return "(nil)", nil
+		}
+		parts := make([]string, n)
+		for i := range parts {
+			if parts[i], err = c.read(); err != nil {
+				return "", err
+			}
+		}
+		return strings.Join(parts, " "), nil
+	}
+	return "", fmt.Errorf("unexpected reply %q", line)
+}
+
+func TestAuthAndACL(t *testing.T) {
+	rs := newTestRedisServer(t)
+	users, err := acl.Load("", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rs.SetACL(users)
+	addr := serveTest(t, rs)
+
+	c := dial(t, addr)
+	if got := c.do(t, "GET", "k"); !strings.HasPrefix(got, "NOAUTH") {
+		t.Errorf("unauthenticated GET = %q", got)
+	}
+	if got := c.do(t, "AUTH", "wrong"); !strings.HasPrefix(got, "WRONGPASS") {
+		t.Errorf("bad AUTH = %q", got)
+	}
+	if got := c.do(t, "AUTH", "secret"); got != "OK" {
+		t.Fatalf("AUTH = %q", got)
+	}
+	if got := c.do(t, "ACL", "SETUSER", "reader", "on", ">pw", "~app:*", "+@read"); got != "OK" {
+		t.Fatalf("ACL SETUSER = %q", got)
+	}
+	if got := c.do(t, "ACL", "USERS"); got != "default reader" {
+		t.Errorf("ACL USERS = %q", got)
+	}
+	if got := c.do(t, "ACL", "GETUSER", "reader"); !strings.HasPrefix(got, "flags on passwords ") || !strings.HasSuffix(got, "commands -@all +@read keys ~app:*") {
+		t.Errorf("ACL GETUSER = %q", got)
+	}
+	c.do(t, "SET", "app:1", "v")
+
+	r := dial(t, addr)
+	if got := r.do(t, "AUTH", "reader", "pw"); got != "OK" {
+		t.Fatalf("AUTH reader = %q", got)
+	}
+	if got := r.do(t, "ACL", "WHOAMI"); got != "reader" {
+		t.Errorf("WHOAMI = %q", got)
+	}
+	if got := r.do(t, "GET", "app:1"); got != "v" {
+		t.Errorf("GET app:1 = %q", got)
+	}
+	if got := r.do(t, "SET", "app:1", "x"); !strings.HasPrefix(got, "NOPERM") {
+		t.Errorf("SET by reader = %q", got)
+	}
+	if got := r.do(t, "GET", "other"); !strings.HasPrefix(got, "NOPERM") {
+		t.Errorf("GET outside key pattern = %q", got)
+	}
+
+	if got := c.do(t, "ACL", "DELUSER", "reader"); got != "1" {
+		t.Errorf("ACL DELUSER = %q", got)
+	}
+	if got := r.do(t, "GET", "app:1"); !strings.HasPrefix(got, "NOPERM") {
+		t.Errorf("GET by deleted user = %q", got)
+	}
+}
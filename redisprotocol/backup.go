@@ -0,0 +1,34 @@
+package redisprotocol
+
+import (
+	"fmt"
+
+	"github.com/tidwall/redcon"
+)
+
+// handleBackupCommand implements BACKUP <path>, which writes a backup
+// archive on the server's filesystem and replies with its manifest as
+// field/value pairs
+func (rs *RedisServer) handleBackupCommand(conn redcon.Conn, cmd redcon.Command) {
+	if len(cmd.Args) != 2 {
+		conn.WriteError("ERR wrong number of arguments for 'backup' command")
+		return
+	}
+	path := string(cmd.Args[1])
+	manifest, err := rs.server.Backup(path)
+	if err != nil {
+		conn.WriteError(fmt.Sprintf("ERR %v", err))
+		return
+	}
+	conn.WriteArray(10)
+	conn.WriteBulkString("path")
+	conn.WriteBulkString(path)
+	conn.WriteBulkString("replica_id")
+	conn.WriteBulkString(manifest.ReplicaID)
+	conn.WriteBulkString("keys")
+	conn.WriteInt64(manifest.Keys)
+	conn.WriteBulkString("operations")
+	conn.WriteInt(manifest.Operations)
+	conn.WriteBulkString("watermark")
+	conn.WriteInt64(manifest.Watermark)
+}
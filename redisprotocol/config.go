@@ -0,0 +1,111 @@
+package redisprotocol
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/luoyjx/crdt-redis/config"
+	"github.com/luoyjx/crdt-redis/server"
+	"github.com/tidwall/redcon"
+)
+
+// defaultConfig describes a server started without a config file, matching
+// the store defaults
+func defaultConfig(srv *server.Server) *config.Config {
+	cfg := config.DefaultConfig()
+	cfg.ReplicaID = srv.ReplicaID()
+	cfg.GCInterval = 5 * time.Minute
+	cfg.MaxMemory = 0
+	cfg.Save = ""
+	return cfg
+}
+
+// SetConfigManager backs CONFIG with m and registers the parameters applied
+// to the protocol server and the store
+func (rs *RedisServer) SetConfigManager(m *config.Manager) {
+	m.OnChange("gc-interval", func(c *config.Config) error { return rs.server.SetGCInterval(c.GCInterval) })
+	m.OnChange("tombstone-ttl", func(c *config.Config) error { return rs.server.SetTombstoneTTL(c.TombstoneTTL) })
+	m.OnChange("maxmemory", func(c *config.Config) error { return rs.server.SetMaxMemory(c.MaxMemory) })
+	m.OnChange("maxmemory-policy", func(c *config.Config) error { return rs.server.SetEvictionPolicy(c.MaxMemoryPolicy) })
+	m.OnChange("appendfsync", func(c *config.Config) error { return rs.server.SetSyncPolicy(c.AppendFsync) })
+	m.OnChange("save", func(c *config.Config) error { return rs.server.SetSaveSchedule(c.Save) })
+	m.OnChange("notify-keyspace-events", func(c *config.Config) error {
+		if err := rs.SetNotifyKeyspaceEvents(c.NotifyKeyspaceEvents); err != nil {
+			return err
+		}
+		// Report the value normalized, e.g. "KEA" as "AKE"
+		c.NotifyKeyspaceEvents = rs.NotifyKeyspaceEvents()
+		return nil
+	})
+	rs.config = m
+}
+
+// ResetStats zeroes the counters reported by INFO stats, as CONFIG RESETSTAT does
+func (rs *RedisServer) ResetStats() {
+	atomic.StoreInt64(&rs.totalConnections, 0)
+	atomic.StoreInt64(&rs.commandsProcessed, 0)
+	atomic.StoreInt64(&rs.messagesDelivered, 0)
+	atomic.StoreInt64(&rs.keyEventsDropped, 0)
+	rs.server.ResetStats()
+}
+
+// handleConfigCommand implements CONFIG GET|SET|REWRITE|RESETSTAT
+func (rs *RedisServer) handleConfigCommand(conn redcon.Conn, cmd redcon.Command) {
+	if len(cmd.Args) < 2 {
+		conn.WriteError("ERR wrong number of arguments for 'config' command")
+		return
+	}
+
+	switch strings.ToLower(string(cmd.Args[1])) {
+	case "get":
+		if len(cmd.Args) < 3 {
+			conn.WriteError("ERR wrong number of arguments for 'config|get' command")
+			return
+		}
+		matched := make(map[string]string)
+		var names []string
+		for _, arg := range cmd.Args[2:] {
+			for _, kv := range rs.config.Get(string(arg)) {
+				if _, ok := matched[kv[0]]; !ok {
+					names = append(names, kv[0])
+				}
+				matched[kv[0]] = kv[1]
+			}
+		}
+		conn.WriteArray(2 * len(names))
+		for _, name := range names {
+			conn.WriteBulkString(name)
+			conn.WriteBulkString(matched[name])
+		}
+	case "set":
+		if len(cmd.Args) < 4 || len(cmd.Args)%2 != 0 {
+			conn.WriteError("ERR wrong number of arguments for 'config|set' command")
+			return
+		}
+		for i := 2; i < len(cmd.Args); i += 2 {
+			name := strings.ToLower(string(cmd.Args[i]))
+			if _, ok := config.LookupParam(name); !ok {
+				conn.WriteError(fmt.Sprintf("ERR Unknown option or number of arguments for CONFIG SET - '%s'", name))
+				return
+			}
+			if err := rs.config.Set(name, string(cmd.Args[i+1])); err != nil {
+				conn.WriteError(fmt.Sprintf("ERR CONFIG SET failed (possibly related to argument '%s') - %v", name, err))
+				return
+			}
+		}
+		conn.WriteString("OK")
+	case "rewrite":
+		if err := rs.config.Rewrite(); err != nil {
+			conn.WriteError(fmt.Sprintf("ERR Rewriting config file: %v", err))
+			return
+		}
+		conn.WriteString("OK")
+	case "resetstat":
+		rs.ResetStats()
+		conn.WriteString("OK")
+	default:
+		conn.WriteError(fmt.Sprintf("ERR unknown subcommand '%s' for 'config'", string(cmd.Args[1])))
+	}
+}
@@ -0,0 +1,36 @@
+package redisprotocol
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestConfigCommand(t *testing.T) {
+	rs := newTestRedisServer(t)
+	addr := serveTest(t, rs)
+	c := dial(t, addr)
+
+	if got := c.do(t, "CONFIG", "SET", "maxmemory", "1mb", "tombstone-ttl", "30m"); got != "OK" {
+		t.Fatalf("CONFIG SET = %q", got)
+	}
+	if got := c.do(t, "CONFIG", "GET", "maxmemory", "tombstone-*"); got != "maxmemory 1048576 tombstone-ttl 30m0s" {
+		t.Errorf("CONFIG GET = %q", got)
+	}
+	if got := c.do(t, "CONFIG", "SET", "port", "7000"); !strings.Contains(got, "restart") {
+		t.Errorf("CONFIG SET port = %q", got)
+	}
+	if got := c.do(t, "CONFIG", "SET", "nosuch", "1"); !strings.HasPrefix(got, "ERR Unknown option") {
+		t.Errorf("CONFIG SET unknown = %q", got)
+	}
+	if got := c.do(t, "CONFIG", "REWRITE"); !strings.HasPrefix(got, "ERR Rewriting config file") {
+		t.Errorf("CONFIG REWRITE without a file = %q", got)
+	}
+
+	c.do(t, "SET", "k", "v")
+	if got := c.do(t, "CONFIG", "RESETSTAT"); got != "OK" {
+		t.Fatalf("CONFIG RESETSTAT = %q", got)
+	}
+	if got := c.do(t, "INFO", "stats"); !strings.Contains(got, "total_commands_processed:1\r\n") {
+		t.Errorf("INFO stats after RESETSTAT = %q", got)
+	}
+}
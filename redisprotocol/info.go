@@ -0,0 +1,292 @@
+package redisprotocol
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/luoyjx/crdt-redis/storage"
+)
+
+// InfoSource contributes "field:value" lines to a section of the INFO reply
+type InfoSource func() []string
+
+// infoSections are the built-in INFO sections in reply order
+var infoSections = []string{"server", "clients", "memory", "persistence", "stats", "replication", "keyspace", "crdt"}
+
+// AddInfoSource registers extra lines for an INFO section, e.g. "replication";
+// sources for sections that are not built in are rendered after the built-in ones
+func (rs *RedisServer) AddInfoSource(section string, src InfoSource) {
+	section = strings.ToLower(section)
+	rs.infoSources[section] = append(rs.infoSources[section], src)
+}
+
+// handleInfo serves INFO [section [section ...]]
+func (rs *RedisServer) handleInfo(args [][]byte) string {
+	var sections []string
+	for _, arg := range args {
+		sections = append(sections, strings.ToLower(string(arg)))
+	}
+	return rs.buildInfo(sections...)
+}
+
+// buildInfo renders the requested INFO sections; no sections, "all",
+// "default" or "everything" render every section
+func (rs *RedisServer) buildInfo(sections ...string) string {
+	all := rs.allInfoSections()
+	want := make(map[string]bool)
+	for _, s := range sections {
+		switch s {
+		case "all", "default", "everything":
+			for _, name := range all {
+				want[name] = true
+			}
+		default:
+			want[s] = true
+		}
+	}
+	if len(sections) == 0 {
+		for _, name := range all {
+			want[name] = true
+		}
+	}
+
+	// Store stats decode every collection, so compute them once per reply
+	var stats *storage.StoreStats
+	storeStats := func() storage.StoreStats {
+		if stats == nil {
+			st := rs.server.StoreStats()
+			stats = &st
+		}
+		return *stats
+	}
+
+	var blocks []string
+	for _, name := range all {
+		if !want[name] {
+			continue
+		}
+		lines := rs.infoSection(name, storeStats)
+		for _, src := range rs.infoSources[name] {
+			lines = append(lines, src()...)
+		}
+		var b strings.Builder
+		fmt.Fprintf(&b, "# %s\r\n", sectionTitle(name))
+		for _, line := range lines {
+			b.WriteString(line)
+			b.WriteString("\r\n")
+		}
+		blocks = append(blocks, b.String())
+	}
+	return strings.Join(blocks, "\r\n")
+}
+
+// allInfoSections returns the built-in sections followed by any extra
+// sections registered through AddInfoSource
+func (rs *RedisServer) allInfoSections() []string {
+	names := append([]string(nil), infoSections...)
+	var extra []string
+	for name := range rs.infoSources {
+		if !isBuiltinSection(name) {
+			extra = append(extra, name)
+		}
+	}
+	sort.Strings(extra)
+	return append(names, extra...)
+}
+
+// sectionTitle returns the header used for a section, e.g. "Replication"
+func sectionTitle(name string) string {
+	if name == "crdt" {
+		return "CRDT"
+	}
+	return strings.ToUpper(name[:1]) + name[1:]
+}
+
+func isBuiltinSection(name string) bool {
+	for _, s := range infoSections {
+		if s == name {
+			return true
+		}
+	}
+	return false
+}
+
+// infoSection returns the built-in lines of a section
+func (rs *RedisServer) infoSection(name string, storeStats func() storage.StoreStats) []string {
+	switch name {
+	case "server":
+		uptime := time.Since(rs.startTime)
+		return []string{
+			"redis_version:7.0.0-crdt",
+			"redis_mode:active-active",
+			"os:" + runtime.GOOS + " " + runtime.GOARCH,
+			"arch_bits:" + strconv.Itoa(strconv.IntSize),
+			"go_version:" + runtime.Version(),
+			"process_id:" + strconv.Itoa(os.Getpid()),
+			"tcp_port:" + listenPort(rs.listenAddr),
+			fmt.Sprintf("uptime_in_seconds:%d", int64(uptime.Seconds())),
+			fmt.Sprintf("uptime_in_days:%d", int64(uptime.Hours()/24)),
+		}
+	case "clients":
+		return []string{
+			fmt.Sprintf("connected_clients:%d", atomic.LoadInt64(&rs.connectedClients)),
+		}
+	case "memory":
+		var ms runtime.MemStats
+		runtime.ReadMemStats(&ms)
+		var dataset int64
+		for _, n := range storeStats().MemoryBytes {
+			dataset += n
+		}
+		used, maxMemory, policy := rs.server.MemoryStats()
+		return []string{
+			// used_memory is the accounted dataset size that maxmemory is enforced against
+			fmt.Sprintf("used_memory:%d", used),
+			"used_memory_human:" + humanBytes(used),
+			fmt.Sprintf("used_memory_heap:%d", ms.HeapAlloc),
+			fmt.Sprintf("used_memory_rss:%d", ms.Sys),
+			fmt.Sprintf("used_memory_dataset:%d", dataset),
+			"used_memory_dataset_human:" + humanBytes(dataset),
+			fmt.Sprintf("maxmemory:%d", maxMemory),
+			"maxmemory_human:" + humanBytes(maxMemory),
+			"maxmemory_policy:" + policy,
+		}
+	case "persistence":
+		ps := rs.server.GetPersistenceStats()
+		save := rs.server.SaveStatus()
+		status, inProgress := "ok", 0
+		if !save.LastSaveOK {
+			status = "err"
+		}
+		if save.InProgress {
+			inProgress = 1
+		}
+		lines := []string{"loading:0",
+			fmt.Sprintf("rdb_changes_since_last_save:%d", save.Changes),
+			fmt.Sprintf("rdb_bgsave_in_progress:%d", inProgress),
+			fmt.Sprintf("rdb_last_save_time:%d", save.LastSave.Unix()),
+			"rdb_last_bgsave_status:" + status,
+			fmt.Sprintf("rdb_saves:%d", save.Saves),
+		}
+		for _, f := range [][2]string{
+			{"segments", "total_segments"},
+			{"current_segment_id", "current_segment_id"},
+			{"segments_size_bytes", "total_size_bytes"},
+			{"segment_compactions", "compactions"},
+			{"appendfsync", "sync_policy"},
+			{"segment_fsyncs", "fsyncs"},
+			{"snapshots", "snapshots"},
+			{"snapshot_segment", "snapshot_segment"},
+		} {
+			if v, ok := ps[f[1]]; ok {
+				lines = append(lines, fmt.Sprintf("%s:%v", f[0], v))
+			}
+		}
+		if t, ok := ps["last_compaction"].(time.Time); ok {
+			lines = append(lines, fmt.Sprintf("segment_last_compaction_time:%d", t.Unix()))
+		}
+		st := storeStats()
+		lines = append(lines,
+			fmt.Sprintf("backend_dirty_keys:%d", st.BackendDirty),
+			fmt.Sprintf("backend_errors:%d", st.BackendErrors))
+		return lines
+	case "stats":
+		applied, rejected := rs.server.RemoteOpStats()
+		rs.pubsub.mu.RLock()
+		channels, patterns := len(rs.pubsub.channels), len(rs.pubsub.patterns)
+		rs.pubsub.mu.RUnlock()
+		return []string{
+			fmt.Sprintf("total_connections_received:%d", atomic.LoadInt64(&rs.totalConnections)),
+			fmt.Sprintf("total_commands_processed:%d", atomic.LoadInt64(&rs.commandsProcessed)),
+			fmt.Sprintf("expired_keys:%d", storeStats().Expired),
+			fmt.Sprintf("evicted_keys:%d", storeStats().Evicted),
+			fmt.Sprintf("remote_ops_applied:%d", applied),
+			fmt.Sprintf("remote_ops_rejected:%d", rejected),
+			fmt.Sprintf("pubsub_channels:%d", channels),
+			fmt.Sprintf("pubsub_patterns:%d", patterns),
+			fmt.Sprintf("pubsub_messages_delivered:%d", atomic.LoadInt64(&rs.messagesDelivered)),
+			fmt.Sprintf("keyspace_notifications_dropped:%d", atomic.LoadInt64(&rs.keyEventsDropped)),
+		}
+	case "replication":
+		// Every replica accepts writes, even while REPLICAOF is attached to a
+		// master; peers are listed by the syncer source
+		return append([]string{"role:master", "connected_slaves:0"}, rs.masterInfoLines()...)
+	case "keyspace":
+		st := storeStats()
+		keys := 0
+		for _, n := range st.Keys {
+			keys += n
+		}
+		if keys == 0 {
+			return nil
+		}
+		return []string{fmt.Sprintf("db0:keys=%d,expires=%d,avg_ttl=%d", keys, st.Expires, st.AvgTTL.Milliseconds())}
+	case "crdt":
+		st := storeStats()
+		oplog := rs.server.OpLog().Stats()
+		lines := []string{
+			"replica_id:" + rs.server.ReplicaID(),
+			"vector_clock:" + formatClock(st.Clock),
+			fmt.Sprintf("oplog_operations:%d", oplog.Operations),
+			fmt.Sprintf("oplog_oldest_timestamp:%d", oplog.Oldest),
+			fmt.Sprintf("oplog_truncated_through:%d", oplog.Truncated),
+			fmt.Sprintf("oplog_truncated_operations:%d", oplog.TruncatedOps),
+			fmt.Sprintf("conflicts_resolved:%d", st.Conflicts),
+			fmt.Sprintf("gc_runs:%d", st.GCRuns),
+			fmt.Sprintf("gc_tombstones_cleaned:%d", st.GCCleaned),
+		}
+		total := 0
+		for _, n := range st.Tombstones {
+			total += n
+		}
+		lines = append(lines, fmt.Sprintf("tombstones:%d", total))
+		for _, t := range []storage.ValueType{storage.TypeList, storage.TypeSet, storage.TypeHash, storage.TypeZSet} {
+			lines = append(lines, fmt.Sprintf("tombstones_%s:%d", t, st.Tombstones[t]))
+		}
+		return lines
+	}
+	return nil
+}
+
+// formatClock renders a vector clock as replica=tick pairs sorted by replica
+func formatClock(clock map[string]int64) string {
+	replicas := make([]string, 0, len(clock))
+	for r := range clock {
+		replicas = append(replicas, r)
+	}
+	sort.Strings(replicas)
+	parts := make([]string, 0, len(replicas))
+	for _, r := range replicas {
+		parts = append(parts, fmt.Sprintf("%s=%d", r, clock[r]))
+	}
+	return strings.Join(parts, ",")
+}
+
+// humanBytes formats a byte count the way Redis does, e.g. 1.50M
+func humanBytes(n int64) string {
+	units := []string{"B", "K", "M", "G", "T"}
+	v := float64(n)
+	i := 0
+	for v >= 1024 && i < len(units)-1 {
+		v /= 1024
+		i++
+	}
+	if i == 0 {
+		return fmt.Sprintf("%dB", n)
+	}
+	return fmt.Sprintf("%.2f%s", v, units[i])
+}
+
+// listenPort extracts the port from a listen address such as ":6380"
+func listenPort(addr string) string {
+	if i := strings.LastIndex(addr, ":"); i >= 0 {
+		return addr[i+1:]
+	}
+	return "0"
+}
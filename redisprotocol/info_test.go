@@ -0,0 +1,68 @@
+package redisprotocol
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/luoyjx/crdt-redis/server"
+)
+
+func newTestRedisServer(t *testing.T) *RedisServer {
+	t.Helper()
+	dir := t.TempDir()
+	srv, err := server.NewServerWithConfig(server.Config{
+		DataDir:   filepath.Join(dir, "store"),
+		OpLogPath: filepath.Join(dir, "oplog.json"),
+		ReplicaID: "r1",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	t.Cleanup(func() { srv.Close() })
+	return NewRedisServer(srv)
+}
+
+func TestInfoAllSections(t *testing.T) {
+	rs := newTestRedisServer(t)
+	rs.server.Set("a", "1", nil)
+	rs.server.SAdd("s", "x", "y")
+	rs.server.SRem("s", "x")
+	rs.AddInfoSource("crdt", func() []string { return []string{"pending_ops:0"} })
+
+	info := rs.buildInfo()
+	for _, want := range []string{
+		"# Server\r\n", "# Clients\r\n", "# Memory\r\n", "# Persistence\r\n",
+		"# Stats\r\n", "# Replication\r\n", "# Keyspace\r\n", "# CRDT\r\n",
+		"db0:keys=2,expires=0,avg_ttl=0\r\n",
+		"replica_id:r1\r\n",
+		"vector_clock:r1=",
+		"tombstones_set:1\r\n",
+		"pending_ops:0\r\n",
+	} {
+		if !strings.Contains(info, want) {
+			t.Errorf("INFO missing %q:\n%s", want, info)
+		}
+	}
+}
+
+func TestInfoSectionFilter(t *testing.T) {
+	rs := newTestRedisServer(t)
+	rs.AddInfoSource("replication", func() []string { return []string{"connected_peers:0"} })
+
+	info := rs.handleInfo([][]byte{[]byte("Replication")})
+	if !strings.HasPrefix(info, "# Replication\r\n") || !strings.Contains(info, "connected_peers:0") {
+		t.Errorf("unexpected replication section:\n%s", info)
+	}
+	if strings.Contains(info, "# Server") || strings.Contains(info, "# CRDT") {
+		t.Errorf("filter returned other sections:\n%s", info)
+	}
+
+	info = rs.handleInfo([][]byte{[]byte("server"), []byte("crdt")})
+	if !strings.Contains(info, "# Server") || !strings.Contains(info, "# CRDT") || strings.Contains(info, "# Memory") {
+		t.Errorf("unexpected multi-section reply:\n%s", info)
+	}
+	if info := rs.handleInfo([][]byte{[]byte("nosuchsection")}); info != "" {
+		t.Errorf("unknown section returned %q", info)
+	}
+}
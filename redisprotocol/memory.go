@@ -0,0 +1,36 @@
+package redisprotocol
+
+import (
+	"fmt"
+
+	"github.com/luoyjx/crdt-redis/storage"
+	"github.com/tidwall/redcon"
+)
+
+// denyOOM lists the commands refused while over maxmemory, as Redis flags
+// them; deletes and other commands that do not grow the dataset still run
+var denyOOM = map[string]bool{
+	"set": true, "incr": true, "incrby": true, "decr": true, "decrby": true, "incrbyfloat": true,
+	"lpush": true, "rpush": true, "lset": true, "linsert": true,
+	"sadd": true,
+	"hset": true, "hincrby": true, "hincrbyfloat": true,
+	"zadd": true, "zincrby": true,
+}
+
+// checkMemory evicts keys as the policy allows before a command that may
+// grow the dataset, and refuses the command with an OOM error if that is
+// not enough
+func (rs *RedisServer) checkMemory(conn redcon.Conn, name string) bool {
+	if !denyOOM[name] {
+		return true
+	}
+	if err := rs.server.FreeMemory(); err != nil {
+		if err == storage.ErrOOM {
+			conn.WriteError("OOM " + err.Error() + ".")
+		} else {
+			conn.WriteError(fmt.Sprintf("ERR %v", err))
+		}
+		return false
+	}
+	return true
+}
@@ -0,0 +1,50 @@
+package redisprotocol
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMaxMemory(t *testing.T) {
+	rs := newTestRedisServer(t)
+	addr := serveTest(t, rs)
+	c := dial(t, addr)
+
+	c.do(t, "SET", "a", "1")
+	c.do(t, "SET", "b", "2")
+	if got := c.do(t, "CONFIG", "SET", "maxmemory", "1"); got != "OK" {
+		t.Fatalf("CONFIG SET maxmemory = %q", got)
+	}
+
+	// noeviction refuses writes that grow the dataset but still allows deletes
+	if got := c.do(t, "SET", "c", "3"); !strings.HasPrefix(got, "OOM command not allowed") {
+		t.Errorf("SET over maxmemory = %q", got)
+	}
+	if got := c.do(t, "GET", "a"); got != "1" {
+		t.Errorf("GET over maxmemory = %q", got)
+	}
+	if got := c.do(t, "DEL", "b"); got != "1" {
+		t.Errorf("DEL over maxmemory = %q", got)
+	}
+
+	c.do(t, "CONFIG", "SET", "notify-keyspace-events", "Ee")
+	sub := dial(t, addr)
+	sub.do(t, "SUBSCRIBE", "__keyevent@0__:evicted")
+	c.do(t, "CONFIG", "SET", "maxmemory-policy", "allkeys-lru")
+	// Evicting a makes room; the write itself is allowed to exceed the limit
+	if got := c.do(t, "SET", "c", "3"); got != "OK" {
+		t.Fatalf("SET with allkeys-lru = %q", got)
+	}
+	if got, _ := sub.read(); got != "message __keyevent@0__:evicted a" {
+		t.Errorf("evicted event = %q", got)
+	}
+	info := c.do(t, "INFO", "memory", "stats")
+	for _, want := range []string{"maxmemory:1\r\n", "maxmemory_policy:allkeys-lru\r\n", "evicted_keys:1\r\n"} {
+		if !strings.Contains(info, want) {
+			t.Errorf("INFO missing %q", want)
+		}
+	}
+	if got := c.do(t, "CONFIG", "SET", "maxmemory-policy", "random"); !strings.HasPrefix(got, "ERR CONFIG SET failed") {
+		t.Errorf("CONFIG SET invalid policy = %q", got)
+	}
+}
@@ -0,0 +1,25 @@
+package redisprotocol
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/luoyjx/crdt-redis/metrics"
+)
+
+// RegisterMetrics records per-command call counts and latencies in reg
+func (rs *RedisServer) RegisterMetrics(reg *metrics.Registry) {
+	rs.cmdCalls = reg.NewCounterVec("crdt_commands_total", "Redis protocol commands processed.", "command")
+	rs.cmdLatency = reg.NewHistogramVec("crdt_command_duration_seconds", "Redis protocol command latency.", nil, "command")
+}
+
+// observeCommand records one command; name is read after the handler ran so
+// unknown commands are folded into a single label value
+func (rs *RedisServer) observeCommand(name *string, start time.Time) {
+	atomic.AddInt64(&rs.commandsProcessed, 1)
+	if rs.cmdCalls == nil {
+		return
+	}
+	rs.cmdCalls.Inc(*name)
+	rs.cmdLatency.Observe(time.Since(start).Seconds(), *name)
+}
@@ -0,0 +1,145 @@
+package redisprotocol
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+
+	"github.com/luoyjx/crdt-redis/storage"
+)
+
+// Keyspace notification classes, as configured by notify-keyspace-events
+const (
+	notifyKeyspace = 1 << iota // K: __keyspace@0__:<key> channels
+	notifyKeyevent             // E: __keyevent@0__:<event> channels
+	notifyGeneric              // g: del, expire
+	notifyString               // $
+	notifyList                 // l
+	notifySet                  // s
+	notifyHash                 // h
+	notifyZset                 // z
+	notifyExpired              // x
+	notifyEvicted              // e
+	notifyRemote               // r: publish remote-origin events on __remote_keyspace@0__ / __remote_keyevent@0__
+
+	notifyAll = notifyGeneric | notifyString | notifyList | notifySet | notifyHash | notifyZset | notifyExpired | notifyEvicted
+)
+
+const keyEventQueueSize = 4096
+
+var notifyFlagChars = []struct {
+	c    byte
+	flag int
+}{
+	{'g', notifyGeneric}, {'$', notifyString}, {'l', notifyList}, {'s', notifySet},
+	{'h', notifyHash}, {'z', notifyZset}, {'x', notifyExpired}, {'e', notifyEvicted},
+	{'K', notifyKeyspace}, {'E', notifyKeyevent}, {'r', notifyRemote},
+}
+
+// eventClasses maps store event names to their notification class
+var eventClasses = map[string]int{
+	"del": notifyGeneric, "expire": notifyGeneric, "restore": notifyGeneric,
+	"set": notifyString, "incrby": notifyString, "incrbyfloat": notifyString,
+	"lpush": notifyList, "rpush": notifyList, "lpop": notifyList, "rpop": notifyList,
+	"lset": notifyList, "linsert": notifyList, "ltrim": notifyList, "lrem": notifyList,
+	"sadd": notifySet, "srem": notifySet,
+	"hset": notifyHash, "hdel": notifyHash, "hincrby": notifyHash, "hincrbyfloat": notifyHash,
+	"zadd": notifyZset, "zrem": notifyZset, "zincr": notifyZset,
+	"expired": notifyExpired,
+	"evicted": notifyEvicted,
+}
+
+// parseNotifyFlags parses a notify-keyspace-events value such as "KEA" or "Kx"
+func parseNotifyFlags(s string) (int, error) {
+	flags := 0
+	for i := 0; i < len(s); i++ {
+		if s[i] == 'A' {
+			flags |= notifyAll
+			continue
+		}
+		found := false
+		for _, fc := range notifyFlagChars {
+			if fc.c == s[i] {
+				flags |= fc.flag
+				found = true
+				break
+			}
+		}
+		if !found {
+			return 0, fmt.Errorf("invalid event class character '%c' in notify-keyspace-events", s[i])
+		}
+	}
+	return flags, nil
+}
+
+// formatNotifyFlags renders flags the way CONFIG GET reports them
+func formatNotifyFlags(flags int) string {
+	var b strings.Builder
+	if flags&notifyAll == notifyAll {
+		b.WriteByte('A')
+	}
+	for _, fc := range notifyFlagChars {
+		if fc.flag&notifyAll != 0 && flags&notifyAll == notifyAll {
+			continue
+		}
+		if flags&fc.flag != 0 {
+			b.WriteByte(fc.c)
+		}
+	}
+	return b.String()
+}
+
+// SetNotifyKeyspaceEvents configures keyspace notifications; an empty value
+// disables them. Events are queued by the store and published from a
+// background goroutine so slow subscribers never hold up writes.
+func (rs *RedisServer) SetNotifyKeyspaceEvents(value string) error {
+	flags, err := parseNotifyFlags(value)
+	if err != nil {
+		return err
+	}
+	atomic.StoreInt64(&rs.notifyFlags, int64(flags))
+	if flags == 0 {
+		return nil
+	}
+	rs.notifyOnce.Do(func() {
+		rs.keyEvents = make(chan storage.KeyEvent, keyEventQueueSize)
+		go rs.publishKeyEvents()
+		rs.server.SetKeyspaceNotifier(rs.queueKeyEvent)
+	})
+	return nil
+}
+
+// NotifyKeyspaceEvents returns the current notify-keyspace-events value
+func (rs *RedisServer) NotifyKeyspaceEvents() string {
+	return formatNotifyFlags(int(atomic.LoadInt64(&rs.notifyFlags)))
+}
+
+// queueKeyEvent runs under the store lock, so it never blocks; events are
+// dropped when the queue is full
+func (rs *RedisServer) queueKeyEvent(ev storage.KeyEvent) {
+	flags := int(atomic.LoadInt64(&rs.notifyFlags))
+	if flags&(notifyKeyspace|notifyKeyevent) == 0 || flags&eventClasses[ev.Event] == 0 {
+		return
+	}
+	select {
+	case rs.keyEvents <- ev:
+	default:
+		atomic.AddInt64(&rs.keyEventsDropped, 1)
+	}
+}
+
+func (rs *RedisServer) publishKeyEvents() {
+	for ev := range rs.keyEvents {
+		flags := int(atomic.LoadInt64(&rs.notifyFlags))
+		prefix := "__"
+		if ev.Remote && flags&notifyRemote != 0 {
+			prefix = "__remote_"
+		}
+		if flags&notifyKeyspace != 0 {
+			rs.DeliverMessage(prefix+"keyspace@0__:"+ev.Key, ev.Event)
+		}
+		if flags&notifyKeyevent != 0 {
+			rs.DeliverMessage(prefix+"keyevent@0__:"+ev.Event, ev.Key)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package redisprotocol
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/luoyjx/crdt-redis/proto"
+)
+
+func TestNotifyFlags(t *testing.T) {
+	for in, want := range map[string]string{
+		"":     "",
+		"KEA":  "AKE",
+		"Ex":   "xE",
+		"Kg$r": "g$Kr",
+	} {
+		flags, err := parseNotifyFlags(in)
+		if err != nil {
+			t.Fatalf("parseNotifyFlags(%q) failed: %v", in, err)
+		}
+		if got := formatNotifyFlags(flags); got != want {
+			t.Errorf("formatNotifyFlags(%q) = %q, want %q", in, got, want)
+		}
+	}
+	if _, err := parseNotifyFlags("KQ"); err == nil {
+		t.Error("unknown class character should be rejected")
+	}
+}
+
+func TestKeyspaceNotifications(t *testing.T) {
+	rs := newTestRedisServer(t)
+	addr := serveTest(t, rs)
+	c := dial(t, addr)
+
+	if got := c.do(t, "CONFIG", "SET", "notify-keyspace-events", "KEA"); got != "OK" {
+		t.Fatalf("CONFIG SET = %q", got)
+	}
+	if got := c.do(t, "CONFIG", "GET", "notify-*"); got != "notify-keyspace-events AKE" {
+		t.Errorf("CONFIG GET = %q", got)
+	}
+	if got := c.do(t, "CONFIG", "SET", "notify-keyspace-events", "Q"); !strings.HasPrefix(got, "ERR CONFIG SET failed") {
+		t.Errorf("CONFIG SET invalid = %q", got)
+	}
+
+	sub := dial(t, addr)
+	if got := sub.do(t, "PSUBSCRIBE", "__*key*@0__:*"); got != "psubscribe __*key*@0__:* 1" {
+		t.Fatalf("PSUBSCRIBE = %q", got)
+	}
+
+	c.do(t, "SADD", "s", "a")
+	if got, _ := sub.read(); got != "pmessage __*key*@0__:* __keyspace@0__:s sadd" {
+		t.Errorf("keyspace event = %q", got)
+	}
+	if got, _ := sub.read(); got != "pmessage __*key*@0__:* __keyevent@0__:sadd s" {
+		t.Errorf("keyevent event = %q", got)
+	}
+
+	// Remote-origin changes use the standard channels unless 'r' is enabled
+	apply := func(id, key string) {
+		t.Helper()
+		op := &proto.Operation{OperationId: id, Type: proto.OperationType_SET, Args: []string{key, "v"}, Timestamp: 1, ReplicaId: "r2"}
+		if err := rs.server.HandleOperation(context.Background(), op); err != nil {
+			t.Fatalf("HandleOperation failed: %v", err)
+		}
+	}
+	apply("1", "k1")
+	if got, _ := sub.read(); got != "pmessage __*key*@0__:* __keyspace@0__:k1 set" {
+		t.Errorf("remote keyspace event = %q", got)
+	}
+	sub.read()
+
+	c.do(t, "CONFIG", "SET", "notify-keyspace-events", "K$r")
+	apply("2", "k2")
+	if got, _ := sub.read(); got != "pmessage __*key*@0__:* __remote_keyspace@0__:k2 set" {
+		t.Errorf("flagged remote event = %q", got)
+	}
+	c.do(t, "SET", "k3", "v")
+	if got, _ := sub.read(); got != "pmessage __*key*@0__:* __keyspace@0__:k3 set" {
+		t.Errorf("local event = %q", got)
+	}
+}
@@ -0,0 +1,60 @@
+package redisprotocol
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/tidwall/redcon"
+)
+
+// PeerManager manages replication peers at runtime
+type PeerManager interface {
+	AddPeer(address string) error
+	RemovePeer(address string) error
+	Peers() []string
+}
+
+// SetPeerManager enables the CRDT.PEER command
+func (rs *RedisServer) SetPeerManager(pm PeerManager) {
+	rs.peers = pm
+}
+
+// handlePeerCommand implements CRDT.PEER ADD|REMOVE|LIST
+func (rs *RedisServer) handlePeerCommand(conn redcon.Conn, cmd redcon.Command) {
+	if len(cmd.Args) < 2 {
+		conn.WriteError("ERR wrong number of arguments for 'crdt.peer' command")
+		return
+	}
+	if rs.peers == nil {
+		conn.WriteError("ERR peer management is not enabled")
+		return
+	}
+
+	switch strings.ToLower(string(cmd.Args[1])) {
+	case "add", "remove":
+		if len(cmd.Args) != 3 {
+			conn.WriteError("ERR wrong number of arguments for 'crdt.peer' command")
+			return
+		}
+		address := string(cmd.Args[2])
+		var err error
+		if strings.ToLower(string(cmd.Args[1])) == "add" {
+			err = rs.peers.AddPeer(address)
+		} else {
+			err = rs.peers.RemovePeer(address)
+		}
+		if err != nil {
+			conn.WriteError(fmt.Sprintf("ERR %v", err))
+			return
+		}
+		conn.WriteString("OK")
+	case "list":
+		peers := rs.peers.Peers()
+		conn.WriteArray(len(peers))
+		for _, p := range peers {
+			conn.WriteBulkString(p)
+		}
+	default:
+		conn.WriteError(fmt.Sprintf("ERR unknown subcommand '%s' for 'crdt.peer'", string(cmd.Args[1])))
+	}
+}
@@ -0,0 +1,318 @@
+package redisprotocol
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/luoyjx/crdt-redis/acl"
+	"github.com/tidwall/redcon"
+)
+
+// pubsubHub tracks channel and pattern subscriptions of local clients
+type pubsubHub struct {
+	mu       sync.RWMutex
+	channels map[string]map[*subscriber]struct{}
+	patterns map[string]map[*subscriber]struct{}
+}
+
+// subscriber is a client connection detached from the redcon loop once it
+// subscribes; mu serializes its writes with messages from publishers
+type subscriber struct {
+	mu       sync.Mutex
+	conn     redcon.DetachedConn
+	channels map[string]struct{}
+	patterns map[string]struct{}
+}
+
+func newPubsubHub() *pubsubHub {
+	return &pubsubHub{
+		channels: make(map[string]map[*subscriber]struct{}),
+		patterns: make(map[string]map[*subscriber]struct{}),
+	}
+}
+
+// count returns the subscriber's total subscriptions; callers hold sub.mu
+func (sub *subscriber) count() int {
+	return len(sub.channels) + len(sub.patterns)
+}
+
+// SetPublishForwarder registers a function called with every message
+// published by a local client, used to deliver it in other regions
+func (rs *RedisServer) SetPublishForwarder(fn func(channel, message string)) {
+	rs.forwardPublish = fn
+}
+
+// Publish delivers a message to local subscribers and forwards it to peers,
+// returning the number of local clients that received it
+func (rs *RedisServer) Publish(channel, message string) int {
+	n := rs.DeliverMessage(channel, message)
+	if rs.forwardPublish != nil {
+		rs.forwardPublish(channel, message)
+	}
+	return n
+}
+
+// DeliverMessage delivers a message to local subscribers only, as done for
+// messages published in another region
+func (rs *RedisServer) DeliverMessage(channel, message string) int {
+	type delivery struct {
+		sub     *subscriber
+		pattern string
+	}
+	var targets []delivery
+
+	rs.pubsub.mu.RLock()
+	for sub := range rs.pubsub.channels[channel] {
+		targets = append(targets, delivery{sub: sub})
+	}
+	for pattern, subs := range rs.pubsub.patterns {
+		if !acl.MatchPattern(pattern, channel) {
+			continue
+		}
+		for sub := range subs {
+			targets = append(targets, delivery{sub: sub, pattern: pattern})
+		}
+	}
+	rs.pubsub.mu.RUnlock()
+
+	for _, d := range targets {
+		d.sub.mu.Lock()
+		if d.pattern != "" {
+			d.sub.conn.WriteArray(4)
+			d.sub.conn.WriteBulkString("pmessage")
+			d.sub.conn.WriteBulkString(d.pattern)
+		} else {
+			d.sub.conn.WriteArray(3)
+			d.sub.conn.WriteBulkString("message")
+		}
+		d.sub.conn.WriteBulkString(channel)
+		d.sub.conn.WriteBulkString(message)
+		d.sub.conn.Flush()
+		d.sub.mu.Unlock()
+	}
+	atomic.AddInt64(&rs.messagesDelivered, int64(len(targets)))
+	return len(targets)
+}
+
+// handleSubscribe implements SUBSCRIBE and PSUBSCRIBE. The first subscription
+// detaches the connection and serves it from its own goroutine.
+func (rs *RedisServer) handleSubscribe(conn redcon.Conn, cmd redcon.Command, pattern bool) {
+	name := strings.ToLower(string(cmd.Args[0]))
+	if len(cmd.Args) < 2 {
+		conn.WriteError(fmt.Sprintf("ERR wrong number of arguments for '%s' command", name))
+		return
+	}
+	st := rs.client(conn)
+	if st.sub != nil {
+		// Already detached: runSubscriber holds sub.mu and flushes
+		rs.subscribe(st.sub, cmd.Args[1:], pattern)
+		return
+	}
+
+	st.sub = &subscriber{
+		conn:     conn.Detach(),
+		channels: make(map[string]struct{}),
+		patterns: make(map[string]struct{}),
+	}
+	st.sub.mu.Lock()
+	rs.subscribe(st.sub, cmd.Args[1:], pattern)
+	st.sub.conn.Flush()
+	st.sub.mu.Unlock()
+	go rs.runSubscriber(st)
+}
+
+func (rs *RedisServer) subscribe(sub *subscriber, names [][]byte, pattern bool) {
+	kind, set, index := "subscribe", sub.channels, rs.pubsub.channels
+	if pattern {
+		kind, set, index = "psubscribe", sub.patterns, rs.pubsub.patterns
+	}
+	rs.pubsub.mu.Lock()
+	defer rs.pubsub.mu.Unlock()
+	for _, n := range names {
+		name := string(n)
+		set[name] = struct{}{}
+		if index[name] == nil {
+			index[name] = make(map[*subscriber]struct{})
+		}
+		index[name][sub] = struct{}{}
+		sub.conn.WriteArray(3)
+		sub.conn.WriteBulkString(kind)
+		sub.conn.WriteBulkString(name)
+		sub.conn.WriteInt(sub.count())
+	}
+}
+
+// handleUnsubscribe implements UNSUBSCRIBE and PUNSUBSCRIBE; without
+// arguments every channel (or pattern) is dropped
+func (rs *RedisServer) handleUnsubscribe(conn redcon.Conn, cmd redcon.Command, pattern bool) {
+	kind := "unsubscribe"
+	if pattern {
+		kind = "punsubscribe"
+	}
+	sub := rs.client(conn).sub
+	if sub == nil {
+		// Not subscribed to anything; Redis still acknowledges
+		conn.WriteArray(3)
+		conn.WriteBulkString(kind)
+		if len(cmd.Args) > 1 {
+			conn.WriteBulk(cmd.Args[1])
+		} else {
+			conn.WriteNull()
+		}
+		conn.WriteInt(0)
+		return
+	}
+
+	var names []string
+	for _, arg := range cmd.Args[1:] {
+		names = append(names, string(arg))
+	}
+	set := sub.channels
+	if pattern {
+		set = sub.patterns
+	}
+	if len(names) == 0 {
+		for name := range set {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+	}
+	if len(names) == 0 {
+		sub.conn.WriteArray(3)
+		sub.conn.WriteBulkString(kind)
+		sub.conn.WriteNull()
+		sub.conn.WriteInt(sub.count())
+		return
+	}
+	for _, name := range names {
+		rs.unsubscribe(sub, name, pattern)
+		sub.conn.WriteArray(3)
+		sub.conn.WriteBulkString(kind)
+		sub.conn.WriteBulkString(name)
+		sub.conn.WriteInt(sub.count())
+	}
+}
+
+func (rs *RedisServer) unsubscribe(sub *subscriber, name string, pattern bool) {
+	set, index := sub.channels, rs.pubsub.channels
+	if pattern {
+		set, index = sub.patterns, rs.pubsub.patterns
+	}
+	rs.pubsub.mu.Lock()
+	defer rs.pubsub.mu.Unlock()
+	delete(set, name)
+	if subs, ok := index[name]; ok {
+		delete(subs, sub)
+		if len(subs) == 0 {
+			delete(index, name)
+		}
+	}
+}
+
+// subscribedCommands are the commands a client may send while subscribed
+var subscribedCommands = map[string]bool{
+	"subscribe": true, "unsubscribe": true, "psubscribe": true, "punsubscribe": true,
+	"ping": true, "quit": true,
+}
+
+// runSubscriber serves a detached connection until the client goes away.
+// Once every subscription is dropped the client may run normal commands again.
+func (rs *RedisServer) runSubscriber(st *clientState) {
+	sub := st.sub
+	defer func() {
+		sub.mu.Lock()
+		for name := range sub.channels {
+			rs.unsubscribe(sub, name, false)
+		}
+		for name := range sub.patterns {
+			rs.unsubscribe(sub, name, true)
+		}
+		sub.conn.Close()
+		sub.mu.Unlock()
+		atomic.AddInt64(&rs.connectedClients, -1)
+	}()
+
+	for {
+		cmd, err := sub.conn.ReadCommand()
+		if err != nil {
+			return
+		}
+		if len(cmd.Args) == 0 {
+			continue
+		}
+		name := strings.ToLower(string(cmd.Args[0]))
+
+		sub.mu.Lock()
+		switch {
+		case name == "quit":
+			sub.conn.WriteString("OK")
+			sub.conn.Flush()
+			sub.mu.Unlock()
+			return
+		case name == "ping" && sub.count() > 0:
+			sub.conn.WriteArray(2)
+			sub.conn.WriteBulkString("pong")
+			if len(cmd.Args) > 1 {
+				sub.conn.WriteBulk(cmd.Args[1])
+			} else {
+				sub.conn.WriteBulkString("")
+			}
+		case sub.count() > 0 && !subscribedCommands[name]:
+			sub.conn.WriteError(fmt.Sprintf("ERR Can't execute '%s': only (P)SUBSCRIBE / (P)UNSUBSCRIBE / PING / QUIT are allowed in this context", name))
+		default:
+			rs.handleCommand(sub.conn, cmd)
+		}
+		err = sub.conn.Flush()
+		sub.mu.Unlock()
+		if err != nil {
+			return
+		}
+	}
+}
+
+// handlePubsubCommand implements PUBSUB CHANNELS|NUMSUB|NUMPAT
+func (rs *RedisServer) handlePubsubCommand(conn redcon.Conn, cmd redcon.Command) {
+	if len(cmd.Args) < 2 {
+		conn.WriteError("ERR wrong number of arguments for 'pubsub' command")
+		return
+	}
+	rs.pubsub.mu.RLock()
+	defer rs.pubsub.mu.RUnlock()
+
+	switch strings.ToLower(string(cmd.Args[1])) {
+	case "channels":
+		if len(cmd.Args) > 3 {
+			conn.WriteError("ERR wrong number of arguments for 'pubsub|channels' command")
+			return
+		}
+		var names []string
+		for name := range rs.pubsub.channels {
+			if len(cmd.Args) == 3 && !acl.MatchPattern(string(cmd.Args[2]), name) {
+				continue
+			}
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		conn.WriteArray(len(names))
+		for _, name := range names {
+			conn.WriteBulkString(name)
+		}
+	case "numsub":
+		conn.WriteArray(2 * (len(cmd.Args) - 2))
+		for _, arg := range cmd.Args[2:] {
+			conn.WriteBulk(arg)
+			conn.WriteInt(len(rs.pubsub.channels[string(arg)]))
+		}
+	case "numpat":
+		n := 0
+		for _, subs := range rs.pubsub.patterns {
+			n += len(subs)
+		}
+		conn.WriteInt(n)
+	default:
+		conn.WriteError(fmt.Sprintf("ERR unknown subcommand '%s' for 'pubsub'", string(cmd.Args[1])))
+	}
+}
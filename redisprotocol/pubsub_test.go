@@ -0,0 +1,94 @@
+package redisprotocol
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPubSub(t *testing.T) {
+	rs := newTestRedisServer(t)
+	var mu sync.Mutex
+	var forwarded []string
+	rs.SetPublishForwarder(func(channel, message string) {
+		mu.Lock()
+		forwarded = append(forwarded, channel+"="+message)
+		mu.Unlock()
+	})
+	addr := serveTest(t, rs)
+
+	sub := dial(t, addr)
+	if got := sub.do(t, "SUBSCRIBE", "news"); got != "subscribe news 1" {
+		t.Fatalf("SUBSCRIBE = %q", got)
+	}
+	if got := sub.do(t, "PSUBSCRIBE", "cache:*"); got != "psubscribe cache:* 2" {
+		t.Fatalf("PSUBSCRIBE = %q", got)
+	}
+	if got := sub.do(t, "GET", "k"); !strings.HasPrefix(got, "ERR Can't execute 'get'") {
+		t.Errorf("GET while subscribed = %q", got)
+	}
+	if got := sub.do(t, "PING"); got != "pong " {
+		t.Errorf("PING while subscribed = %q", got)
+	}
+
+	pub := dial(t, addr)
+	if got := pub.do(t, "PUBSUB", "NUMSUB", "news", "other"); got != "news 1 other 0" {
+		t.Errorf("PUBSUB NUMSUB = %q", got)
+	}
+	if got := pub.do(t, "PUBSUB", "NUMPAT"); got != "1" {
+		t.Errorf("PUBSUB NUMPAT = %q", got)
+	}
+	if got := pub.do(t, "PUBLISH", "news", "hello"); got != "1" {
+		t.Errorf("PUBLISH news = %q", got)
+	}
+	if got := pub.do(t, "PUBLISH", "cache:users", "flush"); got != "1" {
+		t.Errorf("PUBLISH cache:users = %q", got)
+	}
+	if got, _ := sub.read(); got != "message news hello" {
+		t.Errorf("message = %q", got)
+	}
+	if got, _ := sub.read(); got != "pmessage cache:* cache:users flush" {
+		t.Errorf("pmessage = %q", got)
+	}
+
+	// Messages from other regions reach subscribers but are not forwarded again
+	if n := rs.DeliverMessage("news", "remote"); n != 1 {
+		t.Errorf("DeliverMessage = %d, want 1", n)
+	}
+	if got, _ := sub.read(); got != "message news remote" {
+		t.Errorf("remote message = %q", got)
+	}
+	mu.Lock()
+	if strings.Join(forwarded, ",") != "news=hello,cache:users=flush" {
+		t.Errorf("forwarded = %v", forwarded)
+	}
+	mu.Unlock()
+
+	// Dropping every subscription returns the client to normal mode
+	if got := sub.do(t, "UNSUBSCRIBE"); got != "unsubscribe news 1" {
+		t.Errorf("UNSUBSCRIBE = %q", got)
+	}
+	if got := sub.do(t, "PUNSUBSCRIBE", "cache:*"); got != "punsubscribe cache:* 0" {
+		t.Errorf("PUNSUBSCRIBE = %q", got)
+	}
+	if got := sub.do(t, "SET", "k", "v"); got != "OK" {
+		t.Errorf("SET after unsubscribing = %q", got)
+	}
+	if got := pub.do(t, "PUBSUB", "CHANNELS"); got != "" {
+		t.Errorf("PUBSUB CHANNELS = %q", got)
+	}
+
+	// Closing a subscribed connection releases its subscriptions
+	if got := sub.do(t, "SUBSCRIBE", "news"); got != "subscribe news 1" {
+		t.Fatalf("SUBSCRIBE = %q", got)
+	}
+	sub.conn.Close()
+	deadline := time.Now().Add(2 * time.Second)
+	for pub.do(t, "PUBLISH", "news", "bye") != "0" {
+		if time.Now().After(deadline) {
+			t.Fatal("subscription not released after disconnect")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
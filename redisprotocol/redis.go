@@ -1,10 +1,18 @@
 package redisprotocol
 
 import (
+	"crypto/tls"
 	"fmt"
 	"strconv"
 	"strings"
-
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/luoyjx/crdt-redis/acl"
+	"github.com/luoyjx/crdt-redis/config"
+	"github.com/luoyjx/crdt-redis/metrics"
+	"github.com/luoyjx/crdt-redis/psync"
 	"github.com/luoyjx/crdt-redis/redisprotocol/commands"
 	"github.com/luoyjx/crdt-redis/server"
 	"github.com/luoyjx/crdt-redis/storage"
@@ -13,18 +21,63 @@ import (
 
 // RedisServer handles Redis protocol communication
 type RedisServer struct {
-	server *server.Server
+	server      *server.Server
+	peers       PeerManager
+	acl         *acl.Store
+	infoSources map[string][]InfoSource
+	cmdCalls    *metrics.CounterVec
+	cmdLatency  *metrics.HistogramVec
+	startTime   time.Time
+	listenAddr  string
+	tlsConfig   *tls.Config
+	pubsub      *pubsubHub
+	config      *config.Manager
+
+	forwardPublish func(channel, message string)
+	masterMu       sync.Mutex
+	master         *psync.Client // set while REPLICAOF is attached to a master
+	masterUser     string
+	masterAuth     string
+	notifyOnce     sync.Once
+	keyEvents      chan storage.KeyEvent
+
+	connectedClients  int64 // updated atomically
+	totalConnections  int64 // updated atomically
+	commandsProcessed int64 // updated atomically
+	messagesDelivered int64 // updated atomically
+	notifyFlags       int64 // notify-keyspace-events classes, updated atomically
+	keyEventsDropped  int64 // updated atomically
 }
 
 // NewRedisServer creates a new Redis protocol server
 func NewRedisServer(server *server.Server) *RedisServer {
-	return &RedisServer{
-		server: server,
+	rs := &RedisServer{
+		server:      server,
+		infoSources: make(map[string][]InfoSource),
+		startTime:   time.Now(),
+		pubsub:      newPubsubHub(),
 	}
+	// CONFIG works without a config file until SetConfigManager installs the real one
+	rs.SetConfigManager(config.NewManager(defaultConfig(server), ""))
+	return rs
+}
+
+// SetTLSConfig makes Start serve clients over TLS
+func (rs *RedisServer) SetTLSConfig(cfg *tls.Config) {
+	rs.tlsConfig = cfg
 }
 
 // Start starts the Redis protocol server
 func (rs *RedisServer) Start(addr string) error {
+	rs.listenAddr = addr
+	if rs.tlsConfig != nil {
+		return redcon.ListenAndServeTLS(addr,
+			rs.handleCommand,
+			rs.handleConnect,
+			rs.handleDisconnect,
+			rs.tlsConfig,
+		)
+	}
 	return redcon.ListenAndServe(addr,
 		rs.handleCommand,
 		rs.handleConnect,
@@ -34,7 +87,16 @@ func (rs *RedisServer) Start(addr string) error {
 
 // handleCommand processes Redis commands
 func (rs *RedisServer) handleCommand(conn redcon.Conn, cmd redcon.Command) {
-	switch strings.ToLower(string(cmd.Args[0])) {
+	name := strings.ToLower(string(cmd.Args[0]))
+	defer rs.observeCommand(&name, time.Now())
+	if !rs.authorize(conn, name, cmd.Args) {
+		return
+	}
+	if !rs.checkMemory(conn, name) {
+		return
+	}
+
+	switch name {
 	case "set":
 		// Parse the SET command arguments
 		setArgs, err := commands.ParseSetArgs(cmd)
@@ -98,9 +160,7 @@ func (rs *RedisServer) handleCommand(conn redcon.Conn, cmd redcon.Command) {
 		conn.WriteBulk(cmd.Args[1])
 
 	case "info":
-		// Simple INFO response for basic compatibility
-		info := "# Server\r\nredis_version:7.0.0-crdt\r\nredis_mode:standalone\r\n# Replication\r\nrole:master\r\n"
-		conn.WriteBulk([]byte(info))
+		conn.WriteBulk([]byte(rs.handleInfo(cmd.Args[1:])))
 
 	default:
 		switch strings.ToLower(string(cmd.Args[0])) {
@@ -198,6 +258,23 @@ func (rs *RedisServer) handleCommand(conn redcon.Conn, cmd redcon.Command) {
 				return
 			}
 			conn.WriteInt64(n)
+		case "pexpireat":
+			if len(cmd.Args) != 3 {
+				conn.WriteError("ERR wrong number of arguments for 'pexpireat' command")
+				return
+			}
+			key := string(cmd.Args[1])
+			ms, err := commands.ParseInt64(string(cmd.Args[2]))
+			if err != nil {
+				conn.WriteError("ERR value is not an integer or out of range")
+				return
+			}
+			n, err := rs.server.PExpireAt(key, ms)
+			if err != nil {
+				conn.WriteError(fmt.Sprintf("ERR %v", err))
+				return
+			}
+			conn.WriteInt64(n)
 		case "del":
 			if len(cmd.Args) < 2 {
 				conn.WriteError("ERR wrong number of arguments for 'del' command")
@@ -877,7 +954,34 @@ func (rs *RedisServer) handleCommand(conn redcon.Conn, cmd redcon.Command) {
 				return
 			}
 			conn.WriteBulkString(fmt.Sprintf("%.17g", newScore))
+		case "crdt.peer":
+			rs.handlePeerCommand(conn, cmd)
+		case "auth":
+			rs.handleAuth(conn, cmd)
+		case "acl":
+			rs.handleACLCommand(conn, cmd)
+		case "subscribe", "psubscribe":
+			rs.handleSubscribe(conn, cmd, name == "psubscribe")
+		case "unsubscribe", "punsubscribe":
+			rs.handleUnsubscribe(conn, cmd, name == "punsubscribe")
+		case "publish":
+			if len(cmd.Args) != 3 {
+				conn.WriteError("ERR wrong number of arguments for 'publish' command")
+				return
+			}
+			conn.WriteInt(rs.Publish(string(cmd.Args[1]), string(cmd.Args[2])))
+		case "pubsub":
+			rs.handlePubsubCommand(conn, cmd)
+		case "config":
+			rs.handleConfigCommand(conn, cmd)
+		case "save", "bgsave", "lastsave":
+			rs.handleSaveCommand(conn, name, cmd)
+		case "backup":
+			rs.handleBackupCommand(conn, cmd)
+		case "replicaof", "slaveof":
+			rs.handleReplicaOfCommand(conn, name, cmd)
 		default:
+			name = "unknown" // keep arbitrary client input out of metric labels
 			conn.WriteError("ERR unknown command")
 		}
 	}
@@ -885,10 +989,17 @@ func (rs *RedisServer) handleCommand(conn redcon.Conn, cmd redcon.Command) {
 
 // handleConnect handles new connections
 func (rs *RedisServer) handleConnect(conn redcon.Conn) bool {
+	conn.SetContext(rs.newClientState())
+	atomic.AddInt64(&rs.connectedClients, 1)
+	atomic.AddInt64(&rs.totalConnections, 1)
 	return true
 }
 
 // handleDisconnect handles client disconnections
 func (rs *RedisServer) handleDisconnect(conn redcon.Conn, err error) {
-	// Handle cleanup if needed
+	// Subscribed clients are detached; runSubscriber counts them out
+	if st, ok := conn.Context().(*clientState); ok && st.sub != nil {
+		return
+	}
+	atomic.AddInt64(&rs.connectedClients, -1)
 }
@@ -0,0 +1,243 @@
+package redisprotocol
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/luoyjx/crdt-redis/acl"
+	"github.com/luoyjx/crdt-redis/psync"
+	"github.com/luoyjx/crdt-redis/rdb"
+	"github.com/luoyjx/crdt-redis/storage"
+	"github.com/tidwall/redcon"
+)
+
+// SetMasterAuth sets the credentials REPLICAOF uses to authenticate to the
+// master; user may be empty for the default user
+func (rs *RedisServer) SetMasterAuth(user, password string) {
+	rs.masterMu.Lock()
+	defer rs.masterMu.Unlock()
+	rs.masterUser, rs.masterAuth = user, password
+}
+
+// ReplicaOf attaches to the Redis master at addr as a replica, replacing any
+// previous master, and applies what it sends as local writes so they are
+// logged and replicated to peers. An empty addr detaches.
+func (rs *RedisServer) ReplicaOf(addr string) error {
+	if addr != "" {
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			return fmt.Errorf("invalid master address %q: %v", addr, err)
+		}
+	}
+	rs.masterMu.Lock()
+	defer rs.masterMu.Unlock()
+	if rs.master != nil {
+		rs.master.Stop()
+		rs.master = nil
+		log.Printf("Detached from master")
+	}
+	if addr == "" {
+		return nil
+	}
+	port, _ := strconv.Atoi(listenPort(rs.listenAddr))
+	rs.master = psync.New(psync.Config{
+		Addr:       addr,
+		Username:   rs.masterUser,
+		Password:   rs.masterAuth,
+		ListenPort: port,
+	}, newMasterApplier(rs, addr))
+	rs.master.Start()
+	log.Printf("Replicating from master %s", addr)
+	return nil
+}
+
+// masterInfoLines renders the master link for the INFO replication section
+func (rs *RedisServer) masterInfoLines() []string {
+	rs.masterMu.Lock()
+	defer rs.masterMu.Unlock()
+	if rs.master == nil {
+		return nil
+	}
+	return rs.master.InfoLines()
+}
+
+// handleReplicaOfCommand implements REPLICAOF host port and REPLICAOF NO ONE
+// (and SLAVEOF). Unlike Redis, the node keeps accepting writes while attached.
+func (rs *RedisServer) handleReplicaOfCommand(conn redcon.Conn, name string, cmd redcon.Command) {
+	if len(cmd.Args) != 3 {
+		conn.WriteError(fmt.Sprintf("ERR wrong number of arguments for '%s' command", name))
+		return
+	}
+	host, port := string(cmd.Args[1]), string(cmd.Args[2])
+	addr := ""
+	if !strings.EqualFold(host, "no") || !strings.EqualFold(port, "one") {
+		if n, err := strconv.Atoi(port); err != nil || n <= 0 || n > 65535 {
+			conn.WriteError("ERR Invalid master port")
+			return
+		}
+		addr = net.JoinHostPort(host, port)
+	}
+	if err := rs.ReplicaOf(addr); err != nil {
+		conn.WriteError(fmt.Sprintf("ERR %v", err))
+		return
+	}
+	conn.WriteString("OK")
+}
+
+// masterApplier replays the master's keys and commands through the command
+// dispatch, as writes from a client that skips ACL checks
+type masterApplier struct {
+	rs      *RedisServer
+	conn    *replayConn
+	skipped map[string]bool // commands already logged as not applied
+}
+
+func newMasterApplier(rs *RedisServer, addr string) *masterApplier {
+	return &masterApplier{
+		rs:      rs,
+		conn:    &replayConn{addr: addr, ctx: &clientState{user: acl.DefaultUser, authenticated: true, master: true}},
+		skipped: make(map[string]bool),
+	}
+}
+
+// ApplyEntry replaces a key with its value from the full sync RDB
+func (a *masterApplier) ApplyEntry(e *rdb.Entry) error {
+	cmds := [][]string{{"del", e.Key}}
+	switch e.Type {
+	case storage.TypeString:
+		set := []string{"set", e.Key, e.String}
+		if !e.ExpireAt.IsZero() {
+			set = append(set, "pxat", strconv.FormatInt(e.ExpireAt.UnixMilli(), 10))
+		}
+		return a.exec(append(cmds, set))
+	case storage.TypeList:
+		cmds = append(cmds, append([]string{"rpush", e.Key}, e.Elements...))
+	case storage.TypeSet:
+		cmds = append(cmds, append([]string{"sadd", e.Key}, e.Elements...))
+	case storage.TypeHash:
+		for _, f := range e.Fields {
+			cmds = append(cmds, []string{"hset", e.Key, f.Name, f.Value})
+		}
+	case storage.TypeZSet:
+		zadd := []string{"zadd", e.Key}
+		for _, m := range e.Members {
+			zadd = append(zadd, strconv.FormatFloat(m.Score, 'g', -1, 64), m.Name)
+		}
+		cmds = append(cmds, zadd)
+	default:
+		return fmt.Errorf("unsupported type %d", e.Type)
+	}
+	if !e.ExpireAt.IsZero() {
+		cmds = append(cmds, []string{"pexpireat", e.Key, strconv.FormatInt(e.ExpireAt.UnixMilli(), 10)})
+	}
+	return a.exec(cmds)
+}
+
+// ApplyCommand applies a write from the replication stream. Commands Redis
+// has that this server lacks are rewritten where there is an equivalent and
+// skipped otherwise.
+func (a *masterApplier) ApplyCommand(args [][]byte) error {
+	var cmds [][]string
+	for _, cmd := range rewriteMasterCommand(args) {
+		name := strings.ToLower(cmd[0])
+		if !acl.InCategory(name, "write") && name != "publish" {
+			if !a.skipped[name] {
+				a.skipped[name] = true
+				log.Printf("Not applying %s from master %s: command not supported", strings.ToUpper(name), a.conn.addr)
+			}
+			return psync.ErrSkip
+		}
+		cmds = append(cmds, cmd)
+	}
+	return a.exec(cmds)
+}
+
+// exec runs commands in order, stopping at the first error reply
+func (a *masterApplier) exec(cmds [][]string) error {
+	for _, cmd := range cmds {
+		args := make([][]byte, len(cmd))
+		for i, s := range cmd {
+			args[i] = []byte(s)
+		}
+		a.conn.err = ""
+		a.rs.handleCommand(a.conn, redcon.Command{Args: args})
+		if a.conn.err != "" {
+			return fmt.Errorf("%s: %s", strings.ToUpper(cmd[0]), a.conn.err)
+		}
+	}
+	return nil
+}
+
+// rewriteMasterCommand turns a command into ones this server has: UNLINK
+// becomes DEL, SETEX, PSETEX, SETNX and MSET become SETs, and HSET or HMSET
+// with several fields becomes one HSET per field
+func rewriteMasterCommand(args [][]byte) [][]string {
+	cmd := make([]string, len(args))
+	for i, a := range args {
+		cmd[i] = string(a)
+	}
+	switch strings.ToLower(cmd[0]) {
+	case "unlink":
+		return [][]string{append([]string{"del"}, cmd[1:]...)}
+	case "setex", "psetex":
+		if len(cmd) == 4 {
+			unit := "ex"
+			if strings.EqualFold(cmd[0], "psetex") {
+				unit = "px"
+			}
+			return [][]string{{"set", cmd[1], cmd[3], unit, cmd[2]}}
+		}
+	case "setnx":
+		if len(cmd) == 3 {
+			return [][]string{{"set", cmd[1], cmd[2], "nx"}}
+		}
+	case "mset":
+		if len(cmd)%2 == 1 {
+			var out [][]string
+			for i := 1; i < len(cmd); i += 2 {
+				out = append(out, []string{"set", cmd[i], cmd[i+1]})
+			}
+			return out
+		}
+	case "hset", "hmset":
+		if len(cmd) >= 4 && len(cmd)%2 == 0 {
+			var out [][]string
+			for i := 2; i < len(cmd); i += 2 {
+				out = append(out, []string{"hset", cmd[1], cmd[i], cmd[i+1]})
+			}
+			return out
+		}
+	}
+	return [][]string{cmd}
+}
+
+// replayConn stands in for a client connection when replaying commands from
+// the master: replies are discarded except for errors
+type replayConn struct {
+	addr string
+	ctx  interface{}
+	err  string
+}
+
+func (c *replayConn) RemoteAddr() string             { return c.addr }
+func (c *replayConn) Close() error                   { return nil }
+func (c *replayConn) WriteError(msg string)          { c.err = msg }
+func (c *replayConn) WriteString(str string)         {}
+func (c *replayConn) WriteBulk(bulk []byte)          {}
+func (c *replayConn) WriteBulkString(bulk string)    {}
+func (c *replayConn) WriteInt(num int)               {}
+func (c *replayConn) WriteInt64(num int64)           {}
+func (c *replayConn) WriteUint64(num uint64)         {}
+func (c *replayConn) WriteArray(count int)           {}
+func (c *replayConn) WriteNull()                     {}
+func (c *replayConn) WriteRaw(data []byte)           {}
+func (c *replayConn) WriteAny(any interface{})       {}
+func (c *replayConn) Context() interface{}           { return c.ctx }
+func (c *replayConn) SetContext(v interface{})       { c.ctx = v }
+func (c *replayConn) SetReadBuffer(bytes int)        {}
+func (c *replayConn) Detach() redcon.DetachedConn    { return nil }
+func (c *replayConn) ReadPipeline() []redcon.Command { return nil }
+func (c *replayConn) PeekPipeline() []redcon.Command { return nil }
+func (c *replayConn) NetConn() net.Conn              { return nil }
@@ -0,0 +1,155 @@
+package redisprotocol
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/luoyjx/crdt-redis/acl"
+	"github.com/luoyjx/crdt-redis/rdb"
+	"github.com/luoyjx/crdt-redis/storage"
+)
+
+// fakeMaster answers the replica handshake, then sends an RDB of entries
+// followed by stream, a list of commands
+func fakeMaster(t *testing.T, entries []*rdb.Entry, stream [][]string) string {
+	t.Helper()
+	var file bytes.Buffer
+	w, _ := rdb.NewWriter(&file, time.Now())
+	for _, e := range entries {
+		w.Write(e)
+	}
+	w.Close()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		c := &respClient{conn: conn, r: bufio.NewReader(conn)}
+		for {
+			cmd, err := c.read()
+			if err != nil {
+				return
+			}
+			switch {
+			case strings.HasPrefix(cmd, "PSYNC"):
+				fmt.Fprintf(conn, "+FULLRESYNC 0123456789abcdef0123456789abcdef01234567 0\r\n$%d\r\n%s", file.Len(), file.Bytes())
+				for _, args := range stream {
+					fmt.Fprintf(conn, "*%d\r\n", len(args))
+					for _, a := range args {
+						fmt.Fprintf(conn, "$%d\r\n%s\r\n", len(a), a)
+					}
+				}
+			case cmd == "PING":
+				fmt.Fprint(conn, "+PONG\r\n")
+			case strings.HasPrefix(cmd, "REPLCONF ACK"):
+			default:
+				fmt.Fprint(conn, "+OK\r\n")
+			}
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func TestReplicaOf(t *testing.T) {
+	rs := newTestRedisServer(t)
+	users, err := acl.Load("", "secret")
+	if err != nil {
+		t.Fatalf("acl.Load failed: %v", err)
+	}
+	rs.SetACL(users)
+	rs.server.Set("local", "kept", nil)
+	c := dial(t, serveTest(t, rs))
+	c.do(t, "AUTH", "secret")
+
+	expireAt := time.Now().Add(time.Hour)
+	addr := fakeMaster(t, []*rdb.Entry{
+		{Key: "str", Type: storage.TypeString, String: "v", ExpireAt: expireAt},
+		{Key: "list", Type: storage.TypeList, Elements: []string{"a", "b"}},
+		{Key: "hash", Type: storage.TypeHash, Fields: []rdb.Field{{Name: "f1", Value: "v1"}}},
+		{Key: "zset", Type: storage.TypeZSet, Members: []rdb.Member{{Name: "m", Score: 1.5}}},
+		{DB: 2, Key: "other", Type: storage.TypeString, String: "x"},
+	}, [][]string{
+		{"SELECT", "0"},
+		{"SET", "counter", "10"},
+		{"INCRBY", "counter", "5"},
+		{"HSET", "hash", "f2", "v2", "f3", "v3"},
+		{"SETEX", "tmp", "100", "t"},
+		{"UNLINK", "list"},
+		{"PEXPIREAT", "hash", strconv.FormatInt(expireAt.UnixMilli(), 10)},
+		{"FLUSHALL"},
+		{"ZADD", "zset", "2", "n"},
+	})
+
+	host, port, _ := net.SplitHostPort(addr)
+	if got := c.do(t, "REPLICAOF", host, port); got != "OK" {
+		t.Fatalf("REPLICAOF = %q", got)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for c.do(t, "ZSCORE", "zset", "n") != "2" {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for the stream; INFO:\n%s", rs.buildInfo("replication"))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	for _, tc := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{"GET", "str"}, "v"},
+		{[]string{"GET", "counter"}, "15"},
+		{[]string{"GET", "tmp"}, "t"},
+		{[]string{"GET", "local"}, "kept"},
+		{[]string{"GET", "other"}, "(nil)"},
+		{[]string{"EXISTS", "list"}, "0"},
+		{[]string{"HGET", "hash", "f1"}, "v1"},
+		{[]string{"HGET", "hash", "f3"}, "v3"},
+		{[]string{"ZSCORE", "zset", "m"}, "1.5"},
+	} {
+		if got := c.do(t, tc.args...); got != tc.want {
+			t.Errorf("%v = %q, want %q", tc.args, got, tc.want)
+		}
+	}
+	for _, key := range []string{"str", "hash", "tmp"} {
+		if ttl, _ := strconv.Atoi(c.do(t, "TTL", key)); ttl <= 0 {
+			t.Errorf("TTL %s = %d, want an expiry", key, ttl)
+		}
+	}
+	// Applied writes go through the operation log, so peers get them
+	if ops, _ := rs.server.OpLog().GetOperations(0); len(ops) < 10 {
+		t.Errorf("oplog has %d operations, want the applied writes", len(ops))
+	}
+
+	info := rs.buildInfo("replication")
+	for _, want := range []string{"role:master", "master_host:" + host, "master_link_status:up", "master_keys_loaded:4", "master_skipped:2", "master_errors:0"} {
+		if !strings.Contains(info, want+"\r\n") {
+			t.Errorf("INFO replication missing %q:\n%s", want, info)
+		}
+	}
+
+	if got := c.do(t, "REPLICAOF", host, "nope"); got != "ERR Invalid master port" {
+		t.Errorf("REPLICAOF with a bad port = %q", got)
+	}
+	if got := c.do(t, "REPLICAOF", "NO", "ONE"); got != "OK" {
+		t.Errorf("REPLICAOF NO ONE = %q", got)
+	}
+	if info := rs.buildInfo("replication"); strings.Contains(info, "master_host") {
+		t.Errorf("INFO replication after REPLICAOF NO ONE:\n%s", info)
+	}
+	if got := c.do(t, "SET", "after", "1"); got != "OK" {
+		t.Errorf("SET after detaching = %q", got)
+	}
+}
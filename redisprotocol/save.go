@@ -0,0 +1,40 @@
+package redisprotocol
+
+import (
+	"fmt"
+
+	"github.com/luoyjx/crdt-redis/storage"
+	"github.com/tidwall/redcon"
+)
+
+// handleSaveCommand implements SAVE, BGSAVE and LASTSAVE
+func (rs *RedisServer) handleSaveCommand(conn redcon.Conn, name string, cmd redcon.Command) {
+	if len(cmd.Args) != 1 {
+		conn.WriteError(fmt.Sprintf("ERR wrong number of arguments for '%s' command", name))
+		return
+	}
+	switch name {
+	case "save":
+		if err := rs.server.Save(); err != nil {
+			writeSaveError(conn, err)
+			return
+		}
+		conn.WriteString("OK")
+	case "bgsave":
+		if err := rs.server.BGSave(); err != nil {
+			writeSaveError(conn, err)
+			return
+		}
+		conn.WriteString("Background saving started")
+	case "lastsave":
+		conn.WriteInt64(rs.server.SaveStatus().LastSave.Unix())
+	}
+}
+
+func writeSaveError(conn redcon.Conn, err error) {
+	if err == storage.ErrSaveInProgress {
+		conn.WriteError("ERR Background save already in progress")
+		return
+	}
+	conn.WriteError(fmt.Sprintf("ERR %v", err))
+}
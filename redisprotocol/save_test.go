@@ -0,0 +1,64 @@
+package redisprotocol
+
+import (
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSaveCommands(t *testing.T) {
+	rs := newTestRedisServer(t)
+	c := dial(t, serveTest(t, rs))
+
+	c.do(t, "SET", "a", "1")
+	before, _ := strconv.ParseInt(c.do(t, "LASTSAVE"), 10, 64)
+	if got := c.do(t, "SAVE"); got != "OK" {
+		t.Fatalf("SAVE = %q", got)
+	}
+	if got := c.do(t, "BGSAVE"); got != "Background saving started" {
+		t.Fatalf("BGSAVE = %q", got)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for !strings.Contains(c.do(t, "INFO", "persistence"), "rdb_bgsave_in_progress:0\r\n") {
+		if time.Now().After(deadline) {
+			t.Fatal("BGSAVE did not finish")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if after, _ := strconv.ParseInt(c.do(t, "LASTSAVE"), 10, 64); after < before {
+		t.Errorf("LASTSAVE went from %d to %d", before, after)
+	}
+	info := c.do(t, "INFO", "persistence")
+	for _, want := range []string{"rdb_changes_since_last_save:0\r\n", "rdb_last_bgsave_status:ok\r\n", "rdb_saves:2\r\n", "snapshots:"} {
+		if !strings.Contains(info, want) {
+			t.Errorf("INFO persistence missing %q", want)
+		}
+	}
+
+	if got := c.do(t, "CONFIG", "SET", "save", "60 1000"); got != "OK" {
+		t.Errorf("CONFIG SET save = %q", got)
+	}
+	if got := c.do(t, "CONFIG", "SET", "save", "60"); !strings.HasPrefix(got, "ERR CONFIG SET failed") {
+		t.Errorf("CONFIG SET invalid save = %q", got)
+	}
+}
+
+func TestBackupCommand(t *testing.T) {
+	rs := newTestRedisServer(t)
+	c := dial(t, serveTest(t, rs))
+
+	c.do(t, "SET", "a", "1")
+	path := filepath.Join(t.TempDir(), "backup.tar.gz")
+	got := strings.Fields(c.do(t, "BACKUP", path))
+	if len(got) != 10 || got[1] != path || got[3] != "r1" || got[5] != "1" || got[7] != "1" {
+		t.Errorf("BACKUP = %q", got)
+	}
+	if got := c.do(t, "BACKUP"); !strings.HasPrefix(got, "ERR wrong number") {
+		t.Errorf("BACKUP without a path = %q", got)
+	}
+	if got := c.do(t, "BACKUP", filepath.Join(path, "missing", "x")); !strings.HasPrefix(got, "ERR failed") {
+		t.Errorf("BACKUP to a bad path = %q", got)
+	}
+}
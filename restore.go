@@ -0,0 +1,57 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/luoyjx/crdt-redis/server"
+)
+
+// restore implements "crdt-redis restore": it recreates a node's data dir
+// from an archive written by BACKUP, as the replica that was backed up or as
+// a new one. It returns the exit status: 0 on success, 1 if the restore
+// failed, 2 on usage errors.
+func restore(args []string, out io.Writer) int {
+	fs := flag.NewFlagSet("restore", flag.ContinueOnError)
+	fs.SetOutput(out)
+	dataDir := fs.String("data", "./crdt-redis-data", "data directory to restore into; its store and oplog must not exist yet")
+	newReplica := fs.Bool("new-replica", false, "restore as a new replica instead of the one backed up")
+	replicaID := fs.String("replica-id", "", "ID of the new replica with -new-replica (generated if empty)")
+	fs.Usage = func() {
+		fmt.Fprintln(out, "usage: crdt-redis restore [-data dir] [-new-replica [-replica-id id]] <archive>")
+		fmt.Fprintln(out, "Restoring as the same replica is for replacing a node that is gone; a copy that runs alongside it needs -new-replica.")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 1 || (*replicaID != "" && !*newReplica) {
+		fs.Usage()
+		return 2
+	}
+
+	if err := os.MkdirAll(*dataDir, 0755); err != nil {
+		fmt.Fprintf(out, "restore: %v\n", err)
+		return 1
+	}
+	manifest, id, err := server.Restore(fs.Arg(0), server.RestoreOptions{
+		DataDir:    filepath.Join(*dataDir, "store"),
+		OpLogPath:  filepath.Join(*dataDir, "oplog"),
+		NewReplica: *newReplica,
+		ReplicaID:  *replicaID,
+	})
+	if err != nil {
+		fmt.Fprintf(out, "restore: %v\n", err)
+		return 1
+	}
+	if id != manifest.ReplicaID {
+		fmt.Fprintf(out, "restored %d keys of replica %s as new replica %s\n", manifest.Keys, manifest.ReplicaID, id)
+		return 0
+	}
+	fmt.Fprintf(out, "restored %d keys and %d operations as replica %s\n", manifest.Keys, manifest.Operations, id)
+	fmt.Fprintln(out, "the node merges a peer's snapshot before it accepts writes")
+	return 0
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/luoyjx/crdt-redis/server"
+)
+
+func TestRestore(t *testing.T) {
+	dir := t.TempDir()
+	srv, err := server.NewServerWithConfig(server.Config{
+		DataDir:   filepath.Join(dir, "store"),
+		OpLogPath: filepath.Join(dir, "oplog"),
+		ReplicaID: "origin",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	srv.Set("a", "1", nil)
+	archive := filepath.Join(dir, "backup.tar.gz")
+	if _, err := srv.Backup(archive); err != nil {
+		t.Fatalf("Backup failed: %v", err)
+	}
+	srv.Close()
+
+	var out bytes.Buffer
+	target := filepath.Join(dir, "restored")
+	if status := restore([]string{"-data", target, "-new-replica", "-replica-id", "copy", archive}, &out); status != 0 {
+		t.Fatalf("restore exited %d:\n%s", status, out.String())
+	}
+	if !strings.Contains(out.String(), "restored 1 keys of replica origin as new replica copy") {
+		t.Errorf("output:\n%s", out.String())
+	}
+	// Restoring over the restored node is refused
+	out.Reset()
+	if status := restore([]string{"-data", target, archive}, &out); status != 1 {
+		t.Errorf("restore over existing data exited %d:\n%s", status, out.String())
+	}
+	if status := restore([]string{"-replica-id", "copy", archive}, &out); status != 2 {
+		t.Errorf("restore with -replica-id but not -new-replica exited %d", status)
+	}
+
+	restored, err := server.NewServerWithConfig(server.Config{
+		DataDir:   filepath.Join(target, "store"),
+		OpLogPath: filepath.Join(target, "oplog"),
+	})
+	if err != nil {
+		t.Fatalf("Failed to start restored server: %v", err)
+	}
+	defer restored.Close()
+	if v, _ := restored.Get("a"); v != "1" || restored.ReplicaID() != "copy" {
+		t.Errorf("restored server has a = %q as %q", v, restored.ReplicaID())
+	}
+}
@@ -0,0 +1,303 @@
+package server
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/luoyjx/crdt-redis/operation"
+	"github.com/luoyjx/crdt-redis/proto"
+	"github.com/luoyjx/crdt-redis/storage"
+)
+
+// A backup archive is a gzipped tar of manifest.json, oplog.json (the
+// operation log as a JSON array) and store.snap (a store snapshot), in that
+// order, so that a restore checks the manifest before writing anything.
+
+// backupVersion is the archive format written by Backup
+const backupVersion = 1
+
+const (
+	backupManifestFile = "manifest.json"
+	backupOpLogFile    = "oplog.json"
+	backupStoreFile    = "store.snap"
+)
+
+// replicaIDFile, in the store data dir, records the replica ID a restore
+// assigned, so the node keeps it without -replica-id
+const replicaIDFile = "replica-id"
+
+// bootstrapFile, in the store data dir, marks a node restored as the same
+// replica that has not yet merged a peer's snapshot
+const bootstrapFile = "bootstrap-pending"
+
+// BackupManifest describes a backup archive
+type BackupManifest struct {
+	Version    int       `json:"version"`
+	ReplicaID  string    `json:"replica_id"`
+	Created    time.Time `json:"created"`
+	Keys       int64     `json:"keys"`
+	Operations int       `json:"operations"`
+	Watermark  int64     `json:"watermark"`           // timestamp of the newest logged operation, 0 if none
+	Truncated  int64     `json:"truncated,omitempty"` // operation log truncation point, 0 if never truncated
+}
+
+// Backup writes an archive of the store, the operation log and the replica
+// ID to path. The store and the log are captured together while local
+// writes are held off, so every logged operation is in the store snapshot
+// and every local write in the snapshot is logged.
+func (s *Server) Backup(path string) (*BackupManifest, error) {
+	s.mu.Lock()
+	write, err := s.store.Checkpoint()
+	if err != nil {
+		s.mu.Unlock()
+		return nil, err
+	}
+	ops, err := s.opLog.GetOperations(0)
+	truncated := s.opLog.Truncated()
+	s.mu.Unlock()
+	if err != nil {
+		write(io.Discard)
+		return nil, fmt.Errorf("failed to read operation log: %v", err)
+	}
+
+	// The snapshot goes to a temporary file first, as tar needs its size
+	snap, err := os.CreateTemp(filepath.Dir(path), ".backup-*.snap")
+	if err != nil {
+		write(io.Discard)
+		return nil, fmt.Errorf("failed to create backup: %v", err)
+	}
+	defer os.Remove(snap.Name())
+	defer snap.Close()
+	keys, err := write(snap)
+	if err != nil {
+		return nil, fmt.Errorf("failed to write store snapshot: %v", err)
+	}
+
+	manifest := &BackupManifest{
+		Version:    backupVersion,
+		ReplicaID:  s.replicaID,
+		Created:    time.Now().UTC(),
+		Keys:       keys,
+		Operations: len(ops),
+		Truncated:  truncated,
+	}
+	for _, op := range ops {
+		if op.Timestamp > manifest.Watermark {
+			manifest.Watermark = op.Timestamp
+		}
+	}
+	if ops == nil {
+		ops = []*proto.Operation{}
+	}
+	manifestData, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal manifest: %v", err)
+	}
+	opsData, err := json.Marshal(ops)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal operations: %v", err)
+	}
+
+	file, err := os.Create(path + ".tmp")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create backup: %v", err)
+	}
+	defer os.Remove(path + ".tmp")
+	defer file.Close()
+	gz := gzip.NewWriter(file)
+	tw := tar.NewWriter(gz)
+	add := func(name string, size int64, r io.Reader) error {
+		hdr := &tar.Header{Name: name, Mode: 0644, Size: size, ModTime: manifest.Created}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		_, err := io.Copy(tw, r)
+		return err
+	}
+	if err := add(backupManifestFile, int64(len(manifestData)), bytes.NewReader(manifestData)); err != nil {
+		return nil, fmt.Errorf("failed to write backup: %v", err)
+	}
+	if err := add(backupOpLogFile, int64(len(opsData)), bytes.NewReader(opsData)); err != nil {
+		return nil, fmt.Errorf("failed to write backup: %v", err)
+	}
+	size, err := snap.Seek(0, io.SeekCurrent)
+	if err == nil {
+		_, err = snap.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read store snapshot: %v", err)
+	}
+	if err := add(backupStoreFile, size, snap); err != nil {
+		return nil, fmt.Errorf("failed to write backup: %v", err)
+	}
+	if err := tw.Close(); err != nil {
+		return nil, fmt.Errorf("failed to write backup: %v", err)
+	}
+	if err := gz.Close(); err != nil {
+		return nil, fmt.Errorf("failed to write backup: %v", err)
+	}
+	if err := file.Sync(); err != nil {
+		return nil, fmt.Errorf("failed to sync backup: %v", err)
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return nil, fmt.Errorf("failed to install backup: %v", err)
+	}
+	return manifest, nil
+}
+
+// RestoreOptions says where and as which replica Restore recreates a node
+type RestoreOptions struct {
+	DataDir    string // store data dir; must not exist or be empty
+	OpLogPath  string // operation log file; must not exist
+	NewReplica bool   // restore as a new replica instead of the one backed up
+	ReplicaID  string // ID for the new replica; generated if empty
+}
+
+// Restore recreates the state of a node from a backup archive and returns
+// the archive's manifest and the replica ID the node now has. Restored as
+// the same replica, the node keeps its operation log; restored as a new
+// one, it starts with an empty log, so its peers only see its own writes
+// from then on under the new ID.
+//
+// A node restored as the same replica lost its counter contributions and
+// clock entries since the backup, which its peers keep and merge by max, so
+// its later writes would not reach them. Restore marks it to merge a peer's
+// snapshot before accepting writes, see NeedsBootstrap.
+func Restore(archive string, opts RestoreOptions) (*BackupManifest, string, error) {
+	if _, err := os.Stat(opts.OpLogPath); err == nil {
+		return nil, "", fmt.Errorf("operation log %s already exists", opts.OpLogPath)
+	}
+	file, err := os.Open(archive)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to open backup: %v", err)
+	}
+	defer file.Close()
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read backup: %v", err)
+	}
+	tr := tar.NewReader(gz)
+
+	next := func(name string) error {
+		hdr, err := tr.Next()
+		if err != nil {
+			return fmt.Errorf("failed to read backup: %v", err)
+		}
+		if hdr.Name != name {
+			return fmt.Errorf("invalid backup: found %s, want %s", hdr.Name, name)
+		}
+		return nil
+	}
+
+	var manifest BackupManifest
+	if err := next(backupManifestFile); err != nil {
+		return nil, "", err
+	}
+	if err := json.NewDecoder(tr).Decode(&manifest); err != nil {
+		return nil, "", fmt.Errorf("invalid backup manifest: %v", err)
+	}
+	if manifest.Version != backupVersion {
+		return nil, "", fmt.Errorf("unsupported backup version %d", manifest.Version)
+	}
+	replicaID := manifest.ReplicaID
+	if opts.NewReplica {
+		replicaID = opts.ReplicaID
+		if replicaID == "" {
+			replicaID = fmt.Sprintf("replica-%d", time.Now().UnixNano())
+		}
+		if replicaID == manifest.ReplicaID {
+			return nil, "", fmt.Errorf("new replica ID %q is the ID of the backed up replica", replicaID)
+		}
+	} else if opts.ReplicaID != "" && opts.ReplicaID != manifest.ReplicaID {
+		return nil, "", fmt.Errorf("backup is of replica %q, not %q", manifest.ReplicaID, opts.ReplicaID)
+	}
+
+	var ops []*proto.Operation
+	if err := next(backupOpLogFile); err != nil {
+		return nil, "", err
+	}
+	if err := json.NewDecoder(tr).Decode(&ops); err != nil {
+		return nil, "", fmt.Errorf("invalid backup operation log: %v", err)
+	}
+	truncated := manifest.Truncated
+	if opts.NewReplica {
+		ops, truncated = nil, 0
+	}
+	opsData, err := operation.MarshalLog(ops, truncated)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to marshal operations: %v", err)
+	}
+
+	if err := next(backupStoreFile); err != nil {
+		return nil, "", err
+	}
+	if _, err := storage.RestoreSnapshot(opts.DataDir, tr); err != nil {
+		return nil, "", err
+	}
+	if err := writeFileSync(opts.OpLogPath, opsData); err != nil {
+		return nil, "", fmt.Errorf("failed to write operation log: %v", err)
+	}
+	if err := writeFileSync(filepath.Join(opts.DataDir, replicaIDFile), []byte(replicaID+"\n")); err != nil {
+		return nil, "", fmt.Errorf("failed to write replica ID: %v", err)
+	}
+	if !opts.NewReplica {
+		if err := writeFileSync(filepath.Join(opts.DataDir, bootstrapFile), nil); err != nil {
+			return nil, "", fmt.Errorf("failed to mark restore for bootstrap: %v", err)
+		}
+	}
+	return &manifest, replicaID, nil
+}
+
+// NeedsBootstrap reports whether the node was restored as the same replica
+// and has to merge a peer's snapshot before accepting writes
+func (s *Server) NeedsBootstrap() bool {
+	_, err := os.Stat(filepath.Join(s.dataDir, bootstrapFile))
+	return err == nil
+}
+
+// BootstrapDone records that a restored node merged a peer's snapshot, or
+// that it has no peers to merge one from
+func (s *Server) BootstrapDone() error {
+	err := os.Remove(filepath.Join(s.dataDir, bootstrapFile))
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to clear bootstrap mark: %v", err)
+	}
+	return nil
+}
+
+// readReplicaID returns the replica ID a restore recorded in dataDir, or ""
+func readReplicaID(dataDir string) (string, error) {
+	data, err := os.ReadFile(filepath.Join(dataDir, replicaIDFile))
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read replica ID: %v", err)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// writeFileSync creates path with data and syncs it to disk
+func writeFileSync(path string, data []byte) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
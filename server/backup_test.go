@@ -0,0 +1,100 @@
+package server
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBackupRestore(t *testing.T) {
+	dir := t.TempDir()
+	srv, err := NewServerWithConfig(Config{
+		DataDir:   filepath.Join(dir, "store"),
+		OpLogPath: filepath.Join(dir, "oplog.json"),
+		ReplicaID: "origin",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	defer srv.Close()
+
+	srv.Set("a", "1", nil)
+	srv.SAdd("s", "x", "y")
+	srv.HSet("h", "f", "v")
+	archive := filepath.Join(dir, "backup.tar.gz")
+	manifest, err := srv.Backup(archive)
+	if err != nil {
+		t.Fatalf("Backup failed: %v", err)
+	}
+	if manifest.ReplicaID != "origin" || manifest.Keys != 3 || manifest.Operations != 3 || manifest.Watermark == 0 {
+		t.Errorf("manifest = %+v", manifest)
+	}
+	srv.Set("after", "1", nil)
+
+	restore := func(name string, opts RestoreOptions) (*Server, string) {
+		t.Helper()
+		opts.DataDir = filepath.Join(dir, name, "store")
+		opts.OpLogPath = filepath.Join(dir, name, "oplog.json")
+		_, id, err := Restore(archive, opts)
+		if err != nil {
+			t.Fatalf("Restore failed: %v", err)
+		}
+		restored, err := NewServerWithConfig(Config{DataDir: opts.DataDir, OpLogPath: opts.OpLogPath})
+		if err != nil {
+			t.Fatalf("Failed to start restored server: %v", err)
+		}
+		if v, _ := restored.Get("a"); v != "1" {
+			t.Errorf("%s: a = %q, want 1", name, v)
+		}
+		if members, _ := restored.SMembers("s"); len(members) != 2 {
+			t.Errorf("%s: s = %v", name, members)
+		}
+		if _, ok := restored.Get("after"); ok {
+			t.Errorf("%s: key written after the backup was restored", name)
+		}
+		return restored, id
+	}
+
+	same, id := restore("same", RestoreOptions{})
+	defer same.Close()
+	if id != "origin" || same.ReplicaID() != "origin" {
+		t.Errorf("restored as %q, server runs as %q, want origin", id, same.ReplicaID())
+	}
+	if ops, _ := same.OpLog().GetOperations(0); len(ops) != 3 || ops[2].Timestamp != manifest.Watermark {
+		t.Errorf("restored oplog = %v", ops)
+	}
+	if !same.NeedsBootstrap() {
+		t.Error("same replica restore does not need a bootstrap")
+	}
+	if err := same.BootstrapDone(); err != nil || same.NeedsBootstrap() {
+		t.Errorf("BootstrapDone = %v, still needs bootstrap: %v", err, same.NeedsBootstrap())
+	}
+
+	fresh, id := restore("fresh", RestoreOptions{NewReplica: true})
+	defer fresh.Close()
+	if id == "origin" || fresh.ReplicaID() != id {
+		t.Errorf("restored as %q, server runs as %q", id, fresh.ReplicaID())
+	}
+	if n := fresh.OpLog().Len(); n != 0 {
+		t.Errorf("new replica has %d logged operations, want 0", n)
+	}
+	if fresh.NeedsBootstrap() {
+		t.Error("new replica restore needs a bootstrap")
+	}
+
+	// The restored identity is not overridden by a different -replica-id
+	_, err = NewServerWithConfig(Config{
+		DataDir:   filepath.Join(dir, "fresh", "store"),
+		OpLogPath: filepath.Join(dir, "fresh", "oplog.json"),
+		ReplicaID: "origin",
+	})
+	if err == nil || !strings.Contains(err.Error(), "restored as replica") {
+		t.Errorf("starting a new replica as the original = %v", err)
+	}
+	if _, _, err := Restore(archive, RestoreOptions{DataDir: filepath.Join(dir, "same", "store"), OpLogPath: filepath.Join(dir, "x.json")}); err == nil {
+		t.Error("restored over existing data")
+	}
+	if _, _, err := Restore(archive, RestoreOptions{DataDir: filepath.Join(dir, "y"), OpLogPath: filepath.Join(dir, "y.json"), ReplicaID: "other"}); err == nil {
+		t.Error("restored as a different replica without -new-replica")
+	}
+}
@@ -0,0 +1,40 @@
+package server
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/luoyjx/crdt-redis/storage"
+)
+
+// Checkpoint captures the store for a peer that can no longer catch up from
+// the operation log, and returns the function that writes it as a snapshot
+// along with the timestamp of the newest logged operation. The two are
+// captured while local writes are held off, so the snapshot holds every
+// operation up to the watermark; the peer resumes pulling from there. The
+// returned function must be called exactly once.
+func (s *Server) Checkpoint() (func(w io.Writer) (int64, error), int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	write, err := s.store.Checkpoint()
+	if err != nil {
+		return nil, 0, err
+	}
+	// A log truncated down to nothing still covers the truncation point
+	st := s.opLog.Stats()
+	watermark := st.Newest
+	if st.Truncated > watermark {
+		watermark = st.Truncated
+	}
+	return write, watermark, nil
+}
+
+// MergeSnapshot merges a peer's Checkpoint snapshot into the store as
+// replicated writes, returning the number of keys merged
+func (s *Server) MergeSnapshot(r io.Reader) (int64, error) {
+	n, err := s.store.MergeSnapshot(r, storage.WithRemote())
+	if err != nil {
+		return n, fmt.Errorf("failed to merge snapshot: %v", err)
+	}
+	return n, nil
+}
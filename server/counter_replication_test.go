@@ -0,0 +1,88 @@
+package server
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/luoyjx/crdt-redis/proto"
+)
+
+func newCounterReplica(t *testing.T, id string) *Server {
+	t.Helper()
+	dir := t.TempDir()
+	srv, err := NewServerWithConfig(Config{
+		DataDir:   filepath.Join(dir, "store"),
+		OpLogPath: filepath.Join(dir, "oplog.json"),
+		ReplicaID: id,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	t.Cleanup(func() { srv.Close() })
+	return srv
+}
+
+// deliver applies every operation logged by from to each of the replicas
+func deliver(t *testing.T, from *Server, to ...*Server) {
+	t.Helper()
+	ops, err := from.OpLog().GetOperations(0)
+	if err != nil {
+		t.Fatalf("GetOperations failed: %v", err)
+	}
+	for _, srv := range to {
+		for _, op := range ops {
+			if err := srv.HandleOperation(context.Background(), op); err != nil {
+				t.Fatalf("%s failed to apply %s %v: %v", srv.replicaID, op.Command, op.Args, err)
+			}
+		}
+	}
+}
+
+func TestCounterOperationsRedelivered(t *testing.T) {
+	a, b, c := newCounterReplica(t, "a"), newCounterReplica(t, "b"), newCounterReplica(t, "c")
+
+	a.IncrBy("n", 10)
+	a.Decr("n")
+	b.IncrBy("n", 5)
+	a.IncrByFloat("f", 1.5)
+	b.IncrByFloat("f", -0.25)
+	a.HIncrBy("h", "hits", 3)
+	b.HIncrBy("h", "hits", 4)
+	b.HIncrByFloat("h", "score", 0.5)
+
+	// Operations arrive more than once, and c gets them from both
+	for i := 0; i < 2; i++ {
+		deliver(t, a, b, c)
+		deliver(t, b, a, c)
+	}
+
+	for _, srv := range []*Server{a, b, c} {
+		if v, _ := srv.Get("n"); v != "14" {
+			t.Errorf("%s: n = %q, want 14", srv.replicaID, v)
+		}
+		if v, _ := srv.Get("f"); v != "1.25" {
+			t.Errorf("%s: f = %q, want 1.25", srv.replicaID, v)
+		}
+		if v, _, _ := srv.HGet("h", "hits"); v != "7" {
+			t.Errorf("%s: h.hits = %q, want 7", srv.replicaID, v)
+		}
+		if v, _, _ := srv.HGet("h", "score"); v != "0.5" {
+			t.Errorf("%s: h.score = %q, want 0.5", srv.replicaID, v)
+		}
+	}
+}
+
+func TestCounterOperationWithoutShare(t *testing.T) {
+	srv := newCounterReplica(t, "a")
+	srv.IncrBy("n", 2)
+
+	// Peers that log only the delta still have it added
+	op := &proto.Operation{Type: proto.OperationType_INCR, Args: []string{"n", "3"}, Timestamp: 1, ReplicaId: "old"}
+	if err := srv.HandleOperation(context.Background(), op); err != nil {
+		t.Fatalf("HandleOperation failed: %v", err)
+	}
+	if v, _ := srv.Get("n"); v != "5" {
+		t.Errorf("n = %q, want 5", v)
+	}
+}
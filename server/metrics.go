@@ -0,0 +1,64 @@
+package server
+
+import (
+	"sync/atomic"
+
+	"github.com/luoyjx/crdt-redis/metrics"
+	"github.com/luoyjx/crdt-redis/storage"
+)
+
+var valueTypes = []storage.ValueType{
+	storage.TypeString, storage.TypeCounter, storage.TypeFloatCounter,
+	storage.TypeList, storage.TypeSet, storage.TypeHash, storage.TypeZSet,
+}
+
+// RemoteOpStats returns how many remote operations were applied and rejected
+func (s *Server) RemoteOpStats() (applied, rejected int64) {
+	return atomic.LoadInt64(&s.remoteApplied), atomic.LoadInt64(&s.remoteRejected)
+}
+
+// ResetStats zeroes the remote operation and store counters
+func (s *Server) ResetStats() {
+	atomic.StoreInt64(&s.remoteApplied, 0)
+	atomic.StoreInt64(&s.remoteRejected, 0)
+	s.store.ResetStats()
+}
+
+// StoreStats returns a point-in-time summary of the CRDT store
+func (s *Server) StoreStats() storage.StoreStats {
+	return s.store.Stats()
+}
+
+// ReplicaID returns the identifier this server stamps on local operations
+func (s *Server) ReplicaID() string {
+	return s.replicaID
+}
+
+// CollectMetrics writes storage, oplog and remote-apply metrics at scrape time
+func (s *Server) CollectMetrics(w *metrics.Writer) {
+	stats := s.store.Stats()
+	for _, t := range valueTypes {
+		w.Gauge("crdt_keys", "Number of keys per value type.", float64(stats.Keys[t]), "type", t.String())
+	}
+	for _, t := range valueTypes {
+		w.Gauge("crdt_memory_bytes", "Approximate memory used by keys and payloads per value type.", float64(stats.MemoryBytes[t]), "type", t.String())
+	}
+	for _, t := range valueTypes {
+		w.Gauge("crdt_tombstones", "Removed elements awaiting garbage collection per value type.", float64(stats.Tombstones[t]), "type", t.String())
+	}
+	w.Counter("crdt_gc_runs_total", "Tombstone garbage collection passes.", float64(stats.GCRuns))
+	w.Counter("crdt_gc_cleaned_total", "Tombstones removed by garbage collection.", float64(stats.GCCleaned))
+	w.Gauge("crdt_segments", "Number of persistence log segments.", float64(stats.Segments))
+	w.Summary("crdt_segment_compaction_seconds", "Time spent compacting persistence segments.", uint64(stats.Compactions), stats.CompactionSeconds)
+	w.Counter("crdt_evicted_keys_total", "Keys evicted locally to stay under maxmemory.", float64(stats.Evicted))
+	w.Gauge("crdt_backend_dirty_keys", "Keys whose storage backend copy is stale after a failed write.", float64(stats.BackendDirty))
+	w.Counter("crdt_backend_errors_total", "Failed storage backend writes.", float64(stats.BackendErrors))
+	w.Counter("crdt_conflicts_resolved_total", "Stale writes discarded by last-write-wins.", float64(stats.Conflicts))
+	oplog := s.opLog.Stats()
+	w.Gauge("crdt_oplog_operations", "Operations held in the replication log.", float64(oplog.Operations))
+	w.Counter("crdt_oplog_truncated_operations_total", "Operations dropped from the replication log since startup.", float64(oplog.TruncatedOps))
+
+	applied, rejected := s.RemoteOpStats()
+	w.Counter("crdt_remote_operations_total", "Remote operations received from peers by outcome.", float64(applied), "result", "applied")
+	w.Counter("crdt_remote_operations_total", "Remote operations received from peers by outcome.", float64(rejected), "result", "rejected")
+}
@@ -0,0 +1,49 @@
+package server
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/luoyjx/crdt-redis/metrics"
+	"github.com/luoyjx/crdt-redis/proto"
+)
+
+func TestCollectMetrics(t *testing.T) {
+	dir := t.TempDir()
+	srv, err := NewServerWithConfig(Config{
+		DataDir:   filepath.Join(dir, "store"),
+		OpLogPath: filepath.Join(dir, "oplog.json"),
+		ReplicaID: "local",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	defer srv.Close()
+
+	srv.Set("a", "1", nil)
+	srv.SAdd("s", "x", "y")
+	srv.SRem("s", "x")
+	srv.HandleOperation(context.Background(), &proto.Operation{Type: proto.OperationType_SET, Args: []string{"b", "2"}, ReplicaId: "remote"})
+	srv.HandleOperation(context.Background(), &proto.Operation{Type: proto.OperationType_SET, Args: []string{"only-key"}})
+	srv.store.GC()
+
+	reg := metrics.NewRegistry()
+	reg.AddCollector(srv.CollectMetrics)
+	out := reg.Render()
+	for _, want := range []string{
+		`crdt_keys{type="string"} 2`,
+		`crdt_keys{type="set"} 1`,
+		`crdt_tombstones{type="set"} 1`,
+		`crdt_gc_runs_total 1`,
+		`crdt_oplog_operations 3`,
+		`crdt_remote_operations_total{result="applied"} 1`,
+		`crdt_remote_operations_total{result="rejected"} 1`,
+		`crdt_segment_compaction_seconds_count 0`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("metrics missing %q:\n%s", want, out)
+		}
+	}
+}
@@ -3,11 +3,12 @@ package server
 import (
 	"context"
 	"fmt"
-	"log"
 	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/luoyjx/crdt-redis/logging"
 	"github.com/luoyjx/crdt-redis/operation"
 	"github.com/luoyjx/crdt-redis/proto"
 	"github.com/luoyjx/crdt-redis/storage"
@@ -15,27 +16,44 @@ import (
 
 // Server represents the main CRDT Redis server
 type Server struct {
-	mu         sync.RWMutex
-	store      *storage.Store
-	redisStore *storage.RedisStore
-	opLog      *operation.OperationLog
-	replicaID  string
+	mu        sync.RWMutex
+	store     *storage.Store
+	opLog     *operation.OperationLog
+	replicaID string
+	dataDir   string
+
+	remoteApplied  int64 // remote operations applied, updated atomically
+	remoteRejected int64 // remote operations that failed to apply, updated atomically
 }
 
 // HandleOperation implements the peer.OperationHandler interface
 func (s *Server) HandleOperation(ctx context.Context, op *proto.Operation) error {
-	log.Printf("Received operation from peer: %v", op)
-	return s.applyOperation(op)
+	logging.Debugf("Received operation from peer: %v", op)
+	if err := s.applyOperation(op); err != nil {
+		atomic.AddInt64(&s.remoteRejected, 1)
+		return err
+	}
+	atomic.AddInt64(&s.remoteApplied, 1)
+	// Replicated writes are never refused for memory; evict to make room
+	// instead, which is local to this replica
+	if err := s.store.FreeMemory(); err != nil && err != storage.ErrOOM {
+		return err
+	}
+	return nil
 }
 
 // Config holds server configuration
 type Config struct {
-	DataDir    string
-	RedisAddr  string
-	RedisDB    int
-	OpLogPath  string
-	ReplicaID  string
-	ListenAddr string // Address to listen for peer connections
+	DataDir         string
+	RedisAddr       string
+	RedisDB         int
+	Backend         string // storage backend mode; empty writes through to RedisAddr if set
+	Engine          string // storage engine, memory or disk; empty means memory
+	EngineCacheSize int64  // bytes of values cached by the disk engine, 0 for the default
+	SegmentRecovery string // handling of corrupt segment entries on load; empty means truncate
+	OpLogPath       string
+	ReplicaID       string
+	ListenAddr      string // Address to listen for peer connections
 }
 
 // NewServer creates a new CRDT Redis server instance with default configuration
@@ -51,20 +69,42 @@ func NewServer(dataDir string, redisAddr string, oplogPath string) (*Server, err
 
 // NewServerWithConfig creates a new CRDT Redis server instance with configuration
 func NewServerWithConfig(cfg Config) (*Server, error) {
-	redisStore, err := storage.NewRedisStore(cfg.RedisAddr, cfg.RedisDB, cfg.ReplicaID)
+	// A restored node keeps the replica ID the restore gave it
+	restoredID, err := readReplicaID(cfg.DataDir)
+	if err != nil {
+		return nil, err
+	}
+	if restoredID != "" && cfg.ReplicaID != "" && cfg.ReplicaID != restoredID {
+		return nil, fmt.Errorf("data dir was restored as replica %q, not %q", restoredID, cfg.ReplicaID)
+	}
+	if cfg.ReplicaID == "" {
+		cfg.ReplicaID = restoredID
+	}
+
+	mode := cfg.Backend
+	if mode == "" {
+		mode = storage.BackendWriteThrough
+		if cfg.RedisAddr == "" {
+			mode = storage.BackendNone
+		}
+	}
+	backend, err := storage.NewBackend(storage.BackendConfig{Mode: mode, RedisAddr: cfg.RedisAddr, RedisDB: cfg.RedisDB})
 	if err != nil {
-		return nil, fmt.Errorf("failed to create redis store: %v", err)
+		return nil, fmt.Errorf("failed to create storage backend: %v", err)
 	}
-	store, err := storage.NewStore(cfg.DataDir, cfg.RedisAddr, cfg.RedisDB)
+	store, err := storage.NewStoreWithOptions(cfg.DataDir, storage.StoreOptions{
+		Backend:         backend,
+		Engine:          storage.EngineConfig{Mode: cfg.Engine, CacheSize: cfg.EngineCacheSize},
+		SegmentRecovery: cfg.SegmentRecovery,
+	})
 	if err != nil {
-		redisStore.Close()
+		backend.Close()
 		return nil, fmt.Errorf("failed to create store: %v", err)
 	}
 
 	opLog, err := operation.NewOperationLog(cfg.OpLogPath)
 	if err != nil {
 		store.Close()
-		redisStore.Close()
 		return nil, fmt.Errorf("failed to create operation log: %v", err)
 	}
 
@@ -75,10 +115,10 @@ func NewServerWithConfig(cfg Config) (*Server, error) {
 	}
 
 	server := &Server{
-		store:      store,
-		redisStore: redisStore,
-		opLog:      opLog,
-		replicaID:  replicaID,
+		store:     store,
+		opLog:     opLog,
+		replicaID: replicaID,
+		dataDir:   cfg.DataDir,
 	}
 
 	return server, nil
@@ -86,12 +126,24 @@ func NewServerWithConfig(cfg Config) (*Server, error) {
 
 // applyOperation applies a single operation to the store
 func (s *Server) applyOperation(op *proto.Operation) error {
+	// Keyspace notifications flag changes that originated in another region
+	var origin []storage.OpOption
+	if op.ReplicaId != "" && op.ReplicaId != s.replicaID {
+		origin = append(origin, storage.WithRemote())
+	}
+
 	switch op.Type {
 	case proto.OperationType_SET:
-		if len(op.Args) != 2 {
-			return fmt.Errorf("invalid SET operation args: expected 2, got %d", len(op.Args))
+		if len(op.Args) != 2 && len(op.Args) != 3 {
+			return fmt.Errorf("invalid SET operation args: expected 2 or 3, got %d", len(op.Args))
 		}
 		key, value := op.Args[0], op.Args[1]
+		// A SET over a counter logs the contributions it reset; one without
+		// found no counter and reset none
+		observed := ""
+		if len(op.Args) == 3 {
+			observed = op.Args[2]
+		}
 		ts := op.Timestamp
 		if ts == 0 {
 			ts = time.Now().UnixNano()
@@ -101,14 +153,30 @@ func (s *Server) applyOperation(op *proto.Operation) error {
 			rep = s.replicaID
 		}
 		val := storage.NewStringValue(value, ts, rep)
-		return s.store.Set(key, val, nil)
+		return s.store.Set(key, val, nil, append([]storage.OpOption{storage.WithCounterObserved(observed)}, origin...)...)
 	case proto.OperationType_DELETE:
 		if len(op.Args) < 1 {
 			return fmt.Errorf("invalid DELETE operation args: expected >=1, got %d", len(op.Args))
 		}
-		// Support multiple keys
+		ts := op.Timestamp
+		if ts == 0 {
+			ts = time.Now().UnixNano()
+		}
+		rep := op.ReplicaId
+		if rep == "" {
+			rep = s.replicaID
+		}
+		opts := append([]storage.OpOption{storage.WithTimestamp(ts), storage.WithReplicaID(rep)}, origin...)
+		if op.Command == counterDelCommand {
+			if len(op.Args) != 2 {
+				return fmt.Errorf("invalid %s operation args: expected 2, got %d", counterDelCommand, len(op.Args))
+			}
+			return s.store.Delete(op.Args[0], append(opts, storage.WithCounterObserved(op.Args[1]))...)
+		}
+		// Support multiple keys; a plain DEL found no counter to observe
+		opts = append(opts, storage.WithCounterObserved(""))
 		for _, key := range op.Args {
-			_ = s.store.Delete(key)
+			_ = s.store.Delete(key, opts...)
 		}
 		return nil
 	case proto.OperationType_INCR:
@@ -132,7 +200,12 @@ func (s *Server) applyOperation(op *proto.Operation) error {
 		if rep == "" {
 			rep = s.replicaID
 		}
-		opts := []storage.OpOption{storage.WithTimestamp(ts), storage.WithReplicaID(rep)}
+		opts := append([]storage.OpOption{storage.WithTimestamp(ts), storage.WithReplicaID(rep)}, origin...)
+		// Replicas that log their share after the delta get it raised to
+		// that, so a redelivered operation is not counted twice
+		if len(op.Args) >= 4 {
+			opts = append(opts, storage.WithCounterShare(op.Args[2], op.Args[3]))
+		}
 
 		_, err := s.store.IncrBy(key, delta, opts...)
 		return err
@@ -152,7 +225,7 @@ func (s *Server) applyOperation(op *proto.Operation) error {
 			rep = s.replicaID
 		}
 
-		_, err := s.store.LPush(key, values, storage.WithTimestamp(ts), storage.WithReplicaID(rep))
+		_, err := s.store.LPush(key, values, append([]storage.OpOption{storage.WithTimestamp(ts), storage.WithReplicaID(rep)}, origin...)...)
 		return err
 	case proto.OperationType_RPUSH:
 		if len(op.Args) < 2 {
@@ -170,7 +243,7 @@ func (s *Server) applyOperation(op *proto.Operation) error {
 			rep = s.replicaID
 		}
 
-		_, err := s.store.RPush(key, values, storage.WithTimestamp(ts), storage.WithReplicaID(rep))
+		_, err := s.store.RPush(key, values, append([]storage.OpOption{storage.WithTimestamp(ts), storage.WithReplicaID(rep)}, origin...)...)
 		return err
 	case proto.OperationType_LPOP:
 		if len(op.Args) < 1 {
@@ -186,7 +259,7 @@ func (s *Server) applyOperation(op *proto.Operation) error {
 		if rep == "" {
 			rep = s.replicaID
 		}
-		opts := []storage.OpOption{storage.WithTimestamp(ts), storage.WithReplicaID(rep)}
+		opts := append([]storage.OpOption{storage.WithTimestamp(ts), storage.WithReplicaID(rep)}, origin...)
 
 		// For LPOP/RPOP, we need to apply the exact same removal that was logged
 		// This requires more sophisticated CRDT merge logic
@@ -206,7 +279,7 @@ func (s *Server) applyOperation(op *proto.Operation) error {
 		if rep == "" {
 			rep = s.replicaID
 		}
-		opts := []storage.OpOption{storage.WithTimestamp(ts), storage.WithReplicaID(rep)}
+		opts := append([]storage.OpOption{storage.WithTimestamp(ts), storage.WithReplicaID(rep)}, origin...)
 
 		_, _, err := s.store.RPop(key, opts...)
 		return err
@@ -216,7 +289,7 @@ func (s *Server) applyOperation(op *proto.Operation) error {
 		}
 		key := op.Args[0]
 		members := op.Args[1:]
-		_, err := s.store.SAdd(key, members...)
+		_, err := s.store.SAdd(key, members, origin...)
 		return err
 	case proto.OperationType_SREM:
 		if len(op.Args) < 2 {
@@ -224,7 +297,7 @@ func (s *Server) applyOperation(op *proto.Operation) error {
 		}
 		key := op.Args[0]
 		members := op.Args[1:]
-		_, err := s.store.SRem(key, members...)
+		_, err := s.store.SRem(key, members, origin...)
 		return err
 	case proto.OperationType_HSET:
 		if len(op.Args) != 3 {
@@ -233,7 +306,7 @@ func (s *Server) applyOperation(op *proto.Operation) error {
 		key := op.Args[0]
 		field := op.Args[1]
 		value := op.Args[2]
-		_, err := s.store.HSet(key, field, value)
+		_, err := s.store.HSet(key, field, value, origin...)
 		return err
 	case proto.OperationType_HDEL:
 		if len(op.Args) < 2 {
@@ -241,35 +314,14 @@ func (s *Server) applyOperation(op *proto.Operation) error {
 		}
 		key := op.Args[0]
 		fields := op.Args[1:]
-		_, err := s.store.HDel(key, fields...)
+		_, err := s.store.HDel(key, fields, origin...)
 		return err
 	case proto.OperationType_HINCRBY:
-		if len(op.Args) != 3 {
-			return fmt.Errorf("invalid HINCRBY operation args: expected 3 (key, field, delta), got %d", len(op.Args))
+		if len(op.Args) != 3 && len(op.Args) != 5 {
+			return fmt.Errorf("invalid HINCRBY operation args: expected 3 (key, field, delta) or 5 (with the writer's share), got %d", len(op.Args))
 		}
 		key := op.Args[0]
 		field := op.Args[1]
-		delta, err := strconv.ParseInt(op.Args[2], 10, 64)
-		if err != nil {
-			// Try as float for HINCRBYFLOAT
-			deltaFloat, err := strconv.ParseFloat(op.Args[2], 64)
-			if err != nil {
-				return fmt.Errorf("invalid HINCRBY delta: %v", err)
-			}
-			// Use HIncrByFloat opts
-			ts := op.Timestamp
-			if ts == 0 {
-				ts = time.Now().UnixNano()
-			}
-			rep := op.ReplicaId
-			if rep == "" {
-				rep = s.replicaID
-			}
-			// Note: HIncrByFloat not refactored yet in this turn, skipping strict logic for it or assume done?
-			// I didn't refactor HIncrByFloat yet.
-			_, err = s.store.HIncrByFloat(key, field, deltaFloat)
-			return err
-		}
 
 		ts := op.Timestamp
 		if ts == 0 {
@@ -279,8 +331,22 @@ func (s *Server) applyOperation(op *proto.Operation) error {
 		if rep == "" {
 			rep = s.replicaID
 		}
-		// HIncrBy not refactored yet
-		_, err = s.store.HIncrBy(key, field, delta)
+		opts := append([]storage.OpOption{storage.WithTimestamp(ts), storage.WithReplicaID(rep)}, origin...)
+		if len(op.Args) == 5 {
+			opts = append(opts, storage.WithCounterShare(op.Args[3], op.Args[4]))
+		}
+
+		delta, err := strconv.ParseInt(op.Args[2], 10, 64)
+		if err != nil {
+			// Try as float for HINCRBYFLOAT
+			deltaFloat, err := strconv.ParseFloat(op.Args[2], 64)
+			if err != nil {
+				return fmt.Errorf("invalid HINCRBY delta: %v", err)
+			}
+			_, err = s.store.HIncrByFloat(key, field, deltaFloat, opts...)
+			return err
+		}
+		_, err = s.store.HIncrBy(key, field, delta, opts...)
 		return err
 	case proto.OperationType_ZADD:
 		if len(op.Args) < 3 || len(op.Args)%2 != 1 {
@@ -291,7 +357,7 @@ func (s *Server) applyOperation(op *proto.Operation) error {
 		if err != nil {
 			return fmt.Errorf("failed to parse ZADD args: %v", err)
 		}
-		_, err = s.store.ZAdd(key, memberScores)
+		_, err = s.store.ZAdd(key, memberScores, origin...)
 		return err
 	case proto.OperationType_ZREM:
 		if len(op.Args) < 2 {
@@ -299,7 +365,7 @@ func (s *Server) applyOperation(op *proto.Operation) error {
 		}
 		key := op.Args[0]
 		members := op.Args[1:]
-		_, err := s.store.ZRem(key, members)
+		_, err := s.store.ZRem(key, members, origin...)
 		return err
 	case proto.OperationType_ZINCRBY:
 		if len(op.Args) != 3 {
@@ -311,7 +377,7 @@ func (s *Server) applyOperation(op *proto.Operation) error {
 			return fmt.Errorf("invalid ZINCRBY increment: %v", err)
 		}
 		member := op.Args[2]
-		_, err = s.store.ZIncrBy(key, member, increment)
+		_, err = s.store.ZIncrBy(key, member, increment, origin...)
 		return err
 	case proto.OperationType_INCRBYFLOAT:
 		if len(op.Args) < 2 {
@@ -331,7 +397,10 @@ func (s *Server) applyOperation(op *proto.Operation) error {
 		if rep == "" {
 			rep = s.replicaID
 		}
-		opts := []storage.OpOption{storage.WithTimestamp(ts), storage.WithReplicaID(rep)}
+		opts := append([]storage.OpOption{storage.WithTimestamp(ts), storage.WithReplicaID(rep)}, origin...)
+		if len(op.Args) >= 4 {
+			opts = append(opts, storage.WithCounterShare(op.Args[2], op.Args[3]))
+		}
 
 		_, err = s.store.IncrByFloat(key, delta, opts...)
 		return err
@@ -401,6 +470,11 @@ func (s *Server) Set(key string, value string, options *SetOptions) error {
 		ttl = &dur
 	}
 
+	// Replicas reset the counter contributions this SET observed
+	args := []string{key, value}
+	if observed, ok := s.store.CounterObserved(key); ok {
+		args = append(args, observed)
+	}
 	val := storage.NewStringValue(value, timestamp, s.replicaID)
 	if err := s.store.Set(key, val, ttl); err != nil {
 		return fmt.Errorf("failed to set value: %v", err)
@@ -411,8 +485,9 @@ func (s *Server) Set(key string, value string, options *SetOptions) error {
 		OperationId: fmt.Sprintf("%d-%s", timestamp, key),
 		Type:        proto.OperationType_SET,
 		Command:     "SET",
-		Args:        []string{key, value},
+		Args:        args,
 		Timestamp:   timestamp,
+		ReplicaId:   s.replicaID,
 	}
 	if err := s.opLog.AddOperation(op); err != nil {
 		return fmt.Errorf("failed to log operation: %v", err)
@@ -444,16 +519,10 @@ func (s *Server) GetDel(key string) (string, bool, error) {
 		return "", false, nil
 	}
 	// delete locally
-	_ = s.store.Delete(key)
-	// log delete op
 	timestamp := time.Now().UnixNano()
-	op := &proto.Operation{
-		OperationId: fmt.Sprintf("%d-%s", timestamp, key),
-		Type:        proto.OperationType_DELETE,
-		Command:     "DEL",
-		Args:        []string{key},
-		Timestamp:   timestamp,
-	}
+	op := s.deleteOperation(key, timestamp)
+	_ = s.store.Delete(key, storage.WithTimestamp(timestamp), storage.WithReplicaID(s.replicaID))
+	// log delete op
 	_ = s.opLog.AddOperation(op)
 	return value.String(), true, nil
 }
@@ -531,6 +600,18 @@ func (s *Server) ExpireAt(key string, unixSeconds int64) (int64, error) {
 	return 0, nil
 }
 
+// PExpireAt sets absolute expiration (milliseconds)
+func (s *Server) PExpireAt(key string, unixMillis int64) (int64, error) {
+	ok, err := s.store.UpdateExpireAt(key, time.UnixMilli(unixMillis))
+	if err != nil {
+		return 0, err
+	}
+	if ok {
+		return 1, nil
+	}
+	return 0, nil
+}
+
 // LPush implements the LPUSH command
 func (s *Server) LPush(key string, values ...string) (int64, error) {
 	s.mu.Lock()
@@ -709,7 +790,7 @@ func (s *Server) SAdd(key string, members ...string) (int64, error) {
 	defer s.mu.Unlock()
 
 	timestamp := time.Now().UnixNano()
-	added, err := s.store.SAdd(key, members...)
+	added, err := s.store.SAdd(key, members)
 	if err != nil {
 		return added, fmt.Errorf("failed to sadd: %v", err)
 	}
@@ -740,7 +821,7 @@ func (s *Server) SRem(key string, members ...string) (int64, error) {
 	defer s.mu.Unlock()
 
 	timestamp := time.Now().UnixNano()
-	removed, err := s.store.SRem(key, members...)
+	removed, err := s.store.SRem(key, members)
 	if err != nil {
 		return removed, fmt.Errorf("failed to srem: %v", err)
 	}
@@ -830,7 +911,7 @@ func (s *Server) HDel(key string, fields ...string) (int64, error) {
 	defer s.mu.Unlock()
 
 	timestamp := time.Now().UnixNano()
-	deleted, err := s.store.HDel(key, fields...)
+	deleted, err := s.store.HDel(key, fields)
 	if err != nil {
 		return deleted, fmt.Errorf("failed to hdel: %v", err)
 	}
@@ -866,12 +947,17 @@ func (s *Server) Incr(key string) (int64, error) {
 		return val, fmt.Errorf("failed to incr: %v", err)
 	}
 
-	// Log the operation with delta = 1 for CRDT counter semantics
+	// Log the operation with delta = 1 for CRDT counter semantics, and this
+	// replica's resulting share so that applying it twice is harmless
+	args := []string{key, "1"}
+	if inc, dec, ok := s.store.CounterShare(key, s.replicaID); ok {
+		args = append(args, inc, dec)
+	}
 	op := &proto.Operation{
 		OperationId: fmt.Sprintf("%d-%s", timestamp, key),
 		Type:        proto.OperationType_INCR,
 		Command:     "INCR",
-		Args:        []string{key, "1"},
+		Args:        args,
 		Timestamp:   timestamp,
 		ReplicaId:   s.replicaID,
 	}
@@ -893,11 +979,15 @@ func (s *Server) IncrBy(key string, delta int64) (int64, error) {
 		return val, fmt.Errorf("failed to incrby: %v", err)
 	}
 
+	args := []string{key, strconv.FormatInt(delta, 10)}
+	if inc, dec, ok := s.store.CounterShare(key, s.replicaID); ok {
+		args = append(args, inc, dec)
+	}
 	op := &proto.Operation{
 		OperationId: fmt.Sprintf("%d-%s", timestamp, key),
 		Type:        proto.OperationType_INCR,
 		Command:     "INCRBY",
-		Args:        []string{key, strconv.FormatInt(delta, 10)},
+		Args:        args,
 		Timestamp:   timestamp,
 		ReplicaId:   s.replicaID,
 	}
@@ -932,12 +1022,17 @@ func (s *Server) IncrByFloat(key string, delta float64) (float64, error) {
 		return val, fmt.Errorf("failed to incrbyfloat: %v", err)
 	}
 
-	// Log the operation for CRDT replication (log the delta, not final value)
+	// Log the operation for CRDT replication (log the delta, not final
+	// value, followed by this replica's share)
+	args := []string{key, fmt.Sprintf("%.17g", delta)}
+	if inc, dec, ok := s.store.CounterShare(key, s.replicaID); ok {
+		args = append(args, inc, dec)
+	}
 	op := &proto.Operation{
 		OperationId: fmt.Sprintf("%d-%s", timestamp, key),
 		Type:        proto.OperationType_INCRBYFLOAT,
 		Command:     "INCRBYFLOAT",
-		Args:        []string{key, fmt.Sprintf("%.17g", delta)},
+		Args:        args,
 		Timestamp:   timestamp,
 		ReplicaId:   s.replicaID,
 	}
@@ -947,6 +1042,30 @@ func (s *Server) IncrByFloat(key string, delta float64) (float64, error) {
 	return val, nil
 }
 
+// counterDelCommand is the command of a DELETE operation that deleted a
+// counter. Its args are the key and the contributions the DEL observed, as
+// returned by CounterObserved, so replicas keep only increments concurrent
+// with it.
+const counterDelCommand = "DELCOUNTER"
+
+// deleteOperation returns the operation to log for deleting key, taking the
+// counter contributions it observes before the delete
+func (s *Server) deleteOperation(key string, timestamp int64) *proto.Operation {
+	op := &proto.Operation{
+		OperationId: fmt.Sprintf("%d-%s", timestamp, key),
+		Type:        proto.OperationType_DELETE,
+		Command:     "DEL",
+		Args:        []string{key},
+		Timestamp:   timestamp,
+		ReplicaId:   s.replicaID,
+	}
+	if observed, ok := s.store.CounterObserved(key); ok {
+		op.Command = counterDelCommand
+		op.Args = append(op.Args, observed)
+	}
+	return op
+}
+
 // Del implements the DEL command for one or more keys
 func (s *Server) Del(keys ...string) (int64, error) {
 	s.mu.Lock()
@@ -956,16 +1075,10 @@ func (s *Server) Del(keys ...string) (int64, error) {
 	timestamp := time.Now().UnixNano()
 	for _, key := range keys {
 		if _, exists := s.store.Get(key); exists {
-			if err := s.store.Delete(key); err == nil {
-				removed++
-			}
 			// log per-key delete for idempotency and propagation
-			op := &proto.Operation{
-				OperationId: fmt.Sprintf("%d-%s", timestamp, key),
-				Type:        proto.OperationType_DELETE,
-				Command:     "DEL",
-				Args:        []string{key},
-				Timestamp:   timestamp,
+			op := s.deleteOperation(key, timestamp)
+			if err := s.store.Delete(key, storage.WithTimestamp(timestamp), storage.WithReplicaID(s.replicaID)); err == nil {
+				removed++
 			}
 			_ = s.opLog.AddOperation(op)
 		}
@@ -1031,17 +1144,21 @@ func (s *Server) HIncrBy(key, field string, delta int64) (int64, error) {
 	defer s.mu.Unlock()
 
 	timestamp := time.Now().UnixNano()
-	newValue, err := s.store.HIncrBy(key, field, delta)
+	newValue, err := s.store.HIncrBy(key, field, delta, storage.WithTimestamp(timestamp), storage.WithReplicaID(s.replicaID))
 	if err != nil {
 		return 0, err
 	}
 
 	// Log the operation for replication
+	args := []string{key, field, strconv.FormatInt(delta, 10)}
+	if inc, dec, ok := s.store.HCounterShare(key, field, s.replicaID); ok {
+		args = append(args, inc, dec)
+	}
 	op := &proto.Operation{
 		OperationId: fmt.Sprintf("%d-%s-%s", timestamp, key, field),
 		Timestamp:   timestamp,
 		Command:     "HINCRBY",
-		Args:        []string{key, field, strconv.FormatInt(delta, 10)},
+		Args:        args,
 		Type:        proto.OperationType_HINCRBY,
 		ReplicaId:   s.replicaID,
 	}
@@ -1058,17 +1175,21 @@ func (s *Server) HIncrByFloat(key, field string, delta float64) (float64, error)
 	defer s.mu.Unlock()
 
 	timestamp := time.Now().UnixNano()
-	newValue, err := s.store.HIncrByFloat(key, field, delta)
+	newValue, err := s.store.HIncrByFloat(key, field, delta, storage.WithTimestamp(timestamp), storage.WithReplicaID(s.replicaID))
 	if err != nil {
 		return 0, err
 	}
 
 	// Log the operation for replication
+	args := []string{key, field, fmt.Sprintf("%.17g", delta)}
+	if inc, dec, ok := s.store.HCounterShare(key, field, s.replicaID); ok {
+		args = append(args, inc, dec)
+	}
 	op := &proto.Operation{
 		OperationId: fmt.Sprintf("%d-%s-%s", timestamp, key, field),
 		Timestamp:   timestamp,
 		Command:     "HINCRBYFLOAT",
-		Args:        []string{key, field, fmt.Sprintf("%.17g", delta)},
+		Args:        args,
 		Type:        proto.OperationType_HINCRBY, // Use same type for now
 		ReplicaId:   s.replicaID,
 	}
@@ -1208,6 +1329,74 @@ func (s *Server) ZIncrBy(key, member string, increment float64) (float64, error)
 	return newScore, nil
 }
 
+// SetKeyspaceNotifier registers fn for every key change in the store,
+// including changes applied from other replicas
+func (s *Server) SetKeyspaceNotifier(fn func(storage.KeyEvent)) {
+	s.store.SetNotifier(fn)
+}
+
+// SetGCInterval changes how often tombstones are garbage collected
+func (s *Server) SetGCInterval(d time.Duration) error {
+	return s.store.SetGCInterval(d)
+}
+
+// SetTombstoneTTL changes how long tombstones are kept before collection
+func (s *Server) SetTombstoneTTL(d time.Duration) error {
+	return s.store.SetTombstoneTTL(d)
+}
+
+// SetMaxMemory sets the store memory limit in bytes; 0 means no limit
+func (s *Server) SetMaxMemory(bytes int64) error {
+	return s.store.SetMaxMemory(bytes)
+}
+
+// SetSyncPolicy sets when the segment log is synced to disk, as appendfsync
+func (s *Server) SetSyncPolicy(policy string) error {
+	return s.store.SetSyncPolicy(policy)
+}
+
+// SetSaveSchedule sets when background snapshots are taken from "<seconds>
+// <changes>" pairs, as the Redis save directive; "" disables them
+func (s *Server) SetSaveSchedule(spec string) error {
+	rules, err := storage.ParseSaveRules(spec)
+	if err != nil {
+		return err
+	}
+	s.store.SetSaveRules(rules)
+	return nil
+}
+
+// Save writes a snapshot of the store and waits until it is on disk
+func (s *Server) Save() error {
+	return s.store.Save()
+}
+
+// BGSave starts writing a snapshot of the store in the background
+func (s *Server) BGSave() error {
+	return s.store.BGSave()
+}
+
+// SaveStatus reports the last and in-progress snapshots
+func (s *Server) SaveStatus() storage.SaveStatus {
+	return s.store.SaveStatus()
+}
+
+// SetEvictionPolicy chooses how keys are evicted when over maxmemory
+func (s *Server) SetEvictionPolicy(policy string) error {
+	return s.store.SetEvictionPolicy(policy)
+}
+
+// FreeMemory evicts keys until used memory is within maxmemory, returning
+// storage.ErrOOM if the eviction policy cannot free enough
+func (s *Server) FreeMemory() error {
+	return s.store.FreeMemory()
+}
+
+// MemoryStats returns the accounted memory, the limit and the eviction policy
+func (s *Server) MemoryStats() (used, max int64, policy string) {
+	return s.store.UsedMemory(), s.store.MaxMemory(), s.store.EvictionPolicy()
+}
+
 // OpLog exposes the operation log for replication components
 func (s *Server) OpLog() *operation.OperationLog {
 	return s.opLog
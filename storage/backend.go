@@ -0,0 +1,189 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Backend modes
+const (
+	BackendNone         = "none"          // state lives only in the store and its segments
+	BackendWriteThrough = "write-through" // every change is written to Redis
+	BackendReadThrough  = "read-through"  // Redis caches keys as they are read; changes invalidate them
+)
+
+// Backend mirrors the CRDT state into an external store such as a local
+// Redis. The store's in-memory state is authoritative: the backend is written
+// after a change is applied, and a failed backend write never fails or rolls
+// back the change, since it may already be visible to readers and peers.
+// Instead the key is marked dirty and rewritten from the CRDT state by the
+// background reconcile loop.
+type Backend interface {
+	// Set writes the current value of key, or invalidates it for caches
+	Set(ctx context.Context, key string, value *Value, ttl *time.Duration) error
+	Get(ctx context.Context, key string) (*Value, bool, error)
+	Delete(ctx context.Context, key string) error
+	Close() error
+}
+
+// readObserver is implemented by backends that fill on reads
+type readObserver interface {
+	Read(ctx context.Context, key string, value *Value, ttl *time.Duration) error
+}
+
+// BackendConfig selects and configures a backend
+type BackendConfig struct {
+	Mode      string // BackendNone, BackendWriteThrough or BackendReadThrough
+	RedisAddr string
+	RedisDB   int
+}
+
+// NewBackend creates the backend for cfg.Mode
+func NewBackend(cfg BackendConfig) (Backend, error) {
+	switch cfg.Mode {
+	case BackendNone:
+		return &redisBackend{client: NewNullRedisClient()}, nil
+	case BackendWriteThrough, BackendReadThrough:
+		if cfg.RedisAddr == "" {
+			return nil, fmt.Errorf("%s backend requires a Redis address", cfg.Mode)
+		}
+		client, err := NewCustomRedisClient(cfg.RedisAddr, cfg.RedisDB)
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to Redis: %v", err)
+		}
+		return &redisBackend{client: client, cache: cfg.Mode == BackendReadThrough}, nil
+	default:
+		return nil, fmt.Errorf("invalid backend mode: %s (valid: %s, %s, %s)", cfg.Mode, BackendNone, BackendWriteThrough, BackendReadThrough)
+	}
+}
+
+// redisBackend writes values through to Redis, or caches them there when
+// cache is set
+type redisBackend struct {
+	client RedisClient
+	cache  bool
+}
+
+func (b *redisBackend) Set(ctx context.Context, key string, value *Value, ttl *time.Duration) error {
+	if b.cache {
+		return b.client.Del(ctx, key).Err()
+	}
+	return b.write(ctx, key, value, ttl)
+}
+
+// Read fills the cache with a value the store just served
+func (b *redisBackend) Read(ctx context.Context, key string, value *Value, ttl *time.Duration) error {
+	if !b.cache {
+		return nil
+	}
+	return b.write(ctx, key, value, ttl)
+}
+
+func (b *redisBackend) write(ctx context.Context, key string, value *Value, ttl *time.Duration) error {
+	var expiration time.Duration
+	if ttl != nil {
+		expiration = *ttl
+	}
+	return b.client.Set(ctx, key, value.String(), expiration).Err()
+}
+
+func (b *redisBackend) Get(ctx context.Context, key string) (*Value, bool, error) {
+	s, err := b.client.Get(ctx, key).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return NewStringValue(s, time.Now().UnixNano(), ""), true, nil
+}
+
+func (b *redisBackend) Delete(ctx context.Context, key string) error {
+	return b.client.Del(ctx, key).Err()
+}
+
+func (b *redisBackend) Close() error {
+	return b.client.Close()
+}
+
+// mirror writes the current state of key to the backend, marking it dirty
+// if that fails; callers must hold s.mu
+func (s *Store) mirror(key string) {
+	val, _ := s.items.Get(key)
+	s.mirrorValue(key, val)
+}
+
+// mirrorValue writes val, or a delete if it is nil, to the backend; callers
+// must hold s.mu
+func (s *Store) mirrorValue(key string, val *Value) {
+	var err error
+	if val != nil {
+		err = s.backend.Set(s.ctx, key, val, remainingTTL(val))
+	} else {
+		err = s.backend.Delete(s.ctx, key)
+	}
+	if err != nil {
+		if s.dirty == nil {
+			s.dirty = make(map[string]struct{})
+		}
+		s.dirty[key] = struct{}{}
+		s.backendErrors++
+		return
+	}
+	delete(s.dirty, key)
+}
+
+// remainingTTL returns the time left before val expires, or nil if it has
+// no TTL
+func remainingTTL(val *Value) *time.Duration {
+	if val.TTL == nil {
+		return nil
+	}
+	d := time.Until(val.ExpireAt)
+	if d <= 0 {
+		d = time.Millisecond
+	}
+	return &d
+}
+
+// Reconcile rewrites every key to the backend from the CRDT state, e.g. at
+// startup after the backend was unavailable or replaced. Keys that fail are
+// left dirty for the background loop; it returns how many failed.
+func (s *Store) Reconcile() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.items.ForEach(func(key string, val *Value) bool {
+		s.mirrorValue(key, val)
+		return true
+	})
+	return len(s.dirty)
+}
+
+// retryDirty rewrites keys whose backend write failed
+func (s *Store) retryDirty() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key := range s.dirty {
+		s.mirror(key)
+	}
+}
+
+// accessed records a read of key for eviction and lets caching backends
+// fill; callers must hold s.mu for reading or writing
+func (s *Store) accessed(key string) {
+	s.touch(key)
+	if val, exists := s.items.Get(key); exists {
+		s.observeRead(key, val)
+	}
+}
+
+// observeRead lets caching backends fill with a value the store served
+func (s *Store) observeRead(key string, val *Value) {
+	if ro, ok := s.backend.(readObserver); ok {
+		ro.Read(s.ctx, key, val, remainingTTL(val))
+	}
+}
@@ -0,0 +1,170 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// flakyBackend records writes in memory and fails them while down is set
+type flakyBackend struct {
+	mu   sync.Mutex
+	data map[string]string
+	down bool
+}
+
+func (b *flakyBackend) Set(ctx context.Context, key string, value *Value, ttl *time.Duration) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.down {
+		return errors.New("backend down")
+	}
+	b.data[key] = value.String()
+	return nil
+}
+
+func (b *flakyBackend) Get(ctx context.Context, key string) (*Value, bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	s, ok := b.data[key]
+	if !ok {
+		return nil, false, nil
+	}
+	return NewStringValue(s, 0, ""), true, nil
+}
+
+func (b *flakyBackend) Delete(ctx context.Context, key string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.down {
+		return errors.New("backend down")
+	}
+	delete(b.data, key)
+	return nil
+}
+
+func (b *flakyBackend) Close() error { return nil }
+
+func (b *flakyBackend) setDown(down bool) {
+	b.mu.Lock()
+	b.down = down
+	b.mu.Unlock()
+}
+
+func (b *flakyBackend) get(key string) (string, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	s, ok := b.data[key]
+	return s, ok
+}
+
+func TestBackendFailureMarksDirty(t *testing.T) {
+	backend := &flakyBackend{data: make(map[string]string)}
+	store, err := NewStoreWithBackend(t.TempDir(), backend)
+	if err != nil {
+		t.Fatalf("NewStoreWithBackend failed: %v", err)
+	}
+	defer store.Close()
+
+	backend.setDown(true)
+	if err := store.Set("k", NewStringValue("v1", 1, "r1"), nil); err != nil {
+		t.Fatalf("Set must not fail when the backend is down: %v", err)
+	}
+	if v, ok := store.Get("k"); !ok || v.String() != "v1" {
+		t.Fatalf("CRDT state lost the write: %v %v", v, ok)
+	}
+	stats := store.Stats()
+	if stats.BackendDirty != 1 || stats.BackendErrors == 0 {
+		t.Fatalf("expected one dirty key, got %d dirty, %d errors", stats.BackendDirty, stats.BackendErrors)
+	}
+
+	// Still down: the retry fails and the key stays dirty
+	store.retryDirty()
+	if store.Stats().BackendDirty != 1 {
+		t.Fatal("key should stay dirty while the backend is down")
+	}
+
+	backend.setDown(false)
+	store.retryDirty()
+	if got, _ := backend.get("k"); got != "v1" {
+		t.Fatalf("backend has %q after retry, want v1", got)
+	}
+	if store.Stats().BackendDirty != 0 {
+		t.Fatal("key still dirty after a successful retry")
+	}
+
+	// A failed delete is retried as a delete
+	backend.setDown(true)
+	store.Delete("k")
+	backend.setDown(false)
+	store.retryDirty()
+	if _, ok := backend.get("k"); ok {
+		t.Fatal("deleted key still in the backend after retry")
+	}
+}
+
+func TestReconcileOnStartup(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewStore(dir, "", 0)
+	if err != nil {
+		t.Fatalf("NewStore failed: %v", err)
+	}
+	store.Set("a", NewStringValue("1", 1, "r1"), nil)
+	store.Set("b", NewStringValue("2", 1, "r1"), nil)
+	store.Close()
+
+	// The backend missed every write made while the store used none
+	backend := &flakyBackend{data: make(map[string]string)}
+	store, err = NewStoreWithBackend(dir, backend)
+	if err != nil {
+		t.Fatalf("NewStoreWithBackend failed: %v", err)
+	}
+	defer store.Close()
+	for key, want := range map[string]string{"a": "1", "b": "2"} {
+		if got, _ := backend.get(key); got != want {
+			t.Errorf("backend %s = %q after reconcile, want %q", key, got, want)
+		}
+	}
+	if n := store.Reconcile(); n != 0 {
+		t.Errorf("Reconcile reported %d failures", n)
+	}
+}
+
+func TestReadThroughBackend(t *testing.T) {
+	client := NewMockRedisClient("", 0)
+	store, err := NewStoreWithBackend(t.TempDir(), &redisBackend{client: client, cache: true})
+	if err != nil {
+		t.Fatalf("NewStoreWithBackend failed: %v", err)
+	}
+	defer store.Close()
+	ctx := context.Background()
+
+	store.Set("k", NewStringValue("v1", 1, "r1"), nil)
+	if _, err := client.Get(ctx, "k").Result(); err == nil {
+		t.Fatal("read-through backend should not be filled by writes")
+	}
+
+	store.Get("k")
+	if got, err := client.Get(ctx, "k").Result(); err != nil || got != "v1" {
+		t.Fatalf("cache not filled by read: %q %v", got, err)
+	}
+
+	store.Set("k", NewStringValue("v2", 2, "r1"), nil)
+	if _, err := client.Get(ctx, "k").Result(); err == nil {
+		t.Fatal("write should invalidate the cached value")
+	}
+}
+
+func TestNewBackendModes(t *testing.T) {
+	if _, err := NewBackend(BackendConfig{Mode: BackendNone}); err != nil {
+		t.Errorf("none backend: %v", err)
+	}
+	if _, err := NewBackend(BackendConfig{Mode: BackendWriteThrough}); err == nil {
+		t.Error("write-through without an address should fail")
+	}
+	if _, err := NewBackend(BackendConfig{Mode: "bogus"}); err == nil {
+		t.Error("unknown mode should fail")
+	}
+}
@@ -0,0 +1,403 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/luoyjx/crdt-redis/proto"
+	protobuf "google.golang.org/protobuf/proto"
+)
+
+// Values and the CRDTs in their Data are stored as the protobuf messages in
+// proto/value.proto. Each top-level encoding starts with codecVersion, which
+// tells it apart from the JSON written by earlier versions: that starts with
+// '{'. Decoders skip fields they do not know, so fields can be added without
+// a new version.
+const codecVersion byte = 1
+
+// Map entries are written in key order, so that equal values encode the same
+// way
+var marshalOptions = protobuf.MarshalOptions{Deterministic: true}
+
+// marshalVersioned encodes m after the version byte
+func marshalVersioned(m protobuf.Message) ([]byte, error) {
+	return marshalOptions.MarshalAppend([]byte{codecVersion}, m)
+}
+
+// unmarshalVersioned decodes data into m and then fills v with convert
+// after checking its version byte, or as JSON into v if it was written
+// before the binary codec
+func unmarshalVersioned(data []byte, v interface{}, m protobuf.Message, convert func()) error {
+	if len(data) == 0 {
+		return fmt.Errorf("empty encoding")
+	}
+	switch data[0] {
+	case '{':
+		return json.Unmarshal(data, v)
+	case codecVersion:
+		if err := protobuf.Unmarshal(data[1:], m); err != nil {
+			return err
+		}
+		convert()
+		return nil
+	default:
+		return fmt.Errorf("unsupported encoding version %d", data[0])
+	}
+}
+
+// MarshalBinary encodes the value as a versioned Value message
+func (v *Value) MarshalBinary() ([]byte, error) {
+	return marshalVersioned(valueToProto(v))
+}
+
+// UnmarshalBinary decodes a value written by MarshalBinary or as JSON
+func (v *Value) UnmarshalBinary(data []byte) error {
+	*v = Value{}
+	var m proto.Value
+	return unmarshalVersioned(data, v, &m, func() { *v = *valueFromProto(&m) })
+}
+
+// MarshalBinary encodes the clock as a versioned VectorClock message
+func (vc *VectorClock) MarshalBinary() ([]byte, error) {
+	return marshalVersioned(vectorClockToProto(vc))
+}
+
+// UnmarshalBinary decodes a clock written by MarshalBinary or as JSON
+func (vc *VectorClock) UnmarshalBinary(data []byte) error {
+	*vc = VectorClock{}
+	var m proto.VectorClock
+	return unmarshalVersioned(data, vc, &m, func() { *vc = *vectorClockFromProto(&m) })
+}
+
+// MarshalBinary encodes the list as a versioned CRDTList message
+func (list *CRDTList) MarshalBinary() ([]byte, error) {
+	m := &proto.CRDTList{Elements: make([]*proto.ListElement, len(list.Elements)), NextSeq: list.NextSeq}
+	for i, e := range list.Elements {
+		m.Elements[i] = &proto.ListElement{
+			Value:        []byte(e.Value),
+			Id:           e.ID,
+			Timestamp:    e.Timestamp,
+			ReplicaId:    e.ReplicaID,
+			OriginLeftId: e.OriginLeftID,
+			Deleted:      e.Deleted,
+			DeletedAt:    e.DeletedAt,
+		}
+	}
+	return marshalVersioned(m)
+}
+
+// UnmarshalBinary decodes a list written by MarshalBinary or as JSON
+func (list *CRDTList) UnmarshalBinary(data []byte) error {
+	*list = CRDTList{}
+	var m proto.CRDTList
+	return unmarshalVersioned(data, list, &m, func() {
+		list.Elements = make([]ListElement, len(m.Elements))
+		for i, e := range m.Elements {
+			list.Elements[i] = ListElement{
+				Value:        string(e.Value),
+				ID:           e.Id,
+				Timestamp:    e.Timestamp,
+				ReplicaID:    e.ReplicaId,
+				OriginLeftID: e.OriginLeftId,
+				Deleted:      e.Deleted,
+				DeletedAt:    e.DeletedAt,
+			}
+		}
+		list.NextSeq = m.NextSeq
+	})
+}
+
+// MarshalBinary encodes the set as a versioned CRDTSet message
+func (s *CRDTSet) MarshalBinary() ([]byte, error) {
+	m := &proto.CRDTSet{Tombstones: s.Tombstones, ReplicaId: s.ReplicaID}
+	for _, key := range sortedKeys(s.Elements) {
+		e := s.Elements[key]
+		m.Elements = append(m.Elements, &proto.SetElement{
+			Value:     []byte(e.Value),
+			Id:        e.ID,
+			Timestamp: e.Timestamp,
+			ReplicaId: e.ReplicaID,
+		})
+	}
+	return marshalVersioned(m)
+}
+
+// UnmarshalBinary decodes a set written by MarshalBinary or as JSON
+func (s *CRDTSet) UnmarshalBinary(data []byte) error {
+	*s = CRDTSet{}
+	var m proto.CRDTSet
+	return unmarshalVersioned(data, s, &m, func() {
+		s.Elements = make(map[string]*SetElement, len(m.Elements))
+		for _, e := range m.Elements {
+			s.Elements[string(e.Value)] = &SetElement{
+				Value:     string(e.Value),
+				ID:        e.Id,
+				Timestamp: e.Timestamp,
+				ReplicaID: e.ReplicaId,
+			}
+		}
+		s.Tombstones = nonNilMap(m.Tombstones)
+		s.ReplicaID = m.ReplicaId
+	})
+}
+
+// MarshalBinary encodes the hash as a versioned CRDTHash message
+func (h *CRDTHash) MarshalBinary() ([]byte, error) {
+	m := &proto.CRDTHash{Tombstones: h.Tombstones, ReplicaId: h.ReplicaID}
+	for _, key := range sortedKeys(h.Fields) {
+		f := h.Fields[key]
+		m.Fields = append(m.Fields, &proto.HashField{
+			Key:          []byte(f.Key),
+			Value:        []byte(f.Value),
+			CounterValue: f.CounterValue,
+			CounterScale: f.CounterScale,
+			FieldType:    int32(f.FieldType),
+			Id:           f.ID,
+			Timestamp:    f.Timestamp,
+			ReplicaId:    f.ReplicaID,
+			Counts:       pnCounterToProto(f.Counts),
+		})
+	}
+	return marshalVersioned(m)
+}
+
+// UnmarshalBinary decodes a hash written by MarshalBinary or as JSON
+func (h *CRDTHash) UnmarshalBinary(data []byte) error {
+	*h = CRDTHash{}
+	var m proto.CRDTHash
+	return unmarshalVersioned(data, h, &m, func() {
+		h.Fields = make(map[string]*HashField, len(m.Fields))
+		for _, f := range m.Fields {
+			h.Fields[string(f.Key)] = &HashField{
+				Key:          string(f.Key),
+				Value:        string(f.Value),
+				CounterValue: f.CounterValue,
+				CounterScale: f.CounterScale,
+				FieldType:    FieldType(f.FieldType),
+				ID:           f.Id,
+				Timestamp:    f.Timestamp,
+				ReplicaID:    f.ReplicaId,
+				Counts:       pnCounterFromProto(f.Counts),
+			}
+		}
+		h.Tombstones = nonNilMap(m.Tombstones)
+		h.ReplicaID = m.ReplicaId
+	})
+}
+
+// MarshalBinary encodes the sorted set as a versioned CRDTZSet message
+func (zs *CRDTZSet) MarshalBinary() ([]byte, error) {
+	m := &proto.CRDTZSet{ReplicaId: zs.ReplicaID}
+	for _, key := range sortedKeys(zs.Elements) {
+		e := zs.Elements[key]
+		m.Elements = append(m.Elements, &proto.ZSetElement{
+			Member:    []byte(e.Member),
+			Score:     e.Score,
+			Delta:     e.Delta,
+			Id:        e.ID,
+			Timestamp: e.Timestamp,
+			ReplicaId: e.ReplicaID,
+			AddedVc:   vectorClockToProto(e.AddedVC),
+			RemovedVc: vectorClockToProto(e.RemovedVC),
+			IsRemoved: e.IsRemoved,
+			RemovedAt: e.RemovedAt,
+		})
+	}
+	return marshalVersioned(m)
+}
+
+// UnmarshalBinary decodes a sorted set written by MarshalBinary or as JSON
+func (zs *CRDTZSet) UnmarshalBinary(data []byte) error {
+	*zs = CRDTZSet{}
+	var m proto.CRDTZSet
+	return unmarshalVersioned(data, zs, &m, func() {
+		zs.Elements = make(map[string]*ZSetElement, len(m.Elements))
+		for _, e := range m.Elements {
+			zs.Elements[string(e.Member)] = &ZSetElement{
+				Member:    string(e.Member),
+				Score:     e.Score,
+				Delta:     e.Delta,
+				ID:        e.Id,
+				Timestamp: e.Timestamp,
+				ReplicaID: e.ReplicaId,
+				AddedVC:   vectorClockFromProto(e.AddedVc),
+				RemovedVC: vectorClockFromProto(e.RemovedVc),
+				IsRemoved: e.IsRemoved,
+				RemovedAt: e.RemovedAt,
+			}
+		}
+		zs.ReplicaID = m.ReplicaId
+	})
+}
+
+// marshalLogEntry encodes a LogEntry message, without a version byte
+func marshalLogEntry(entry *LogEntry) ([]byte, error) {
+	m := &proto.LogEntry{
+		Timestamp: entry.Timestamp,
+		Operation: entry.Operation,
+		Key:       []byte(entry.Key),
+		Metadata:  entry.Metadata,
+	}
+	if entry.Value != nil {
+		m.Value = valueToProto(entry.Value)
+	}
+	return marshalOptions.Marshal(m)
+}
+
+// unmarshalLogEntry decodes a LogEntry message written by marshalLogEntry
+func unmarshalLogEntry(b []byte) (*LogEntry, error) {
+	var m proto.LogEntry
+	if err := protobuf.Unmarshal(b, &m); err != nil {
+		return nil, err
+	}
+	entry := &LogEntry{
+		Timestamp: m.Timestamp,
+		Operation: m.Operation,
+		Key:       string(m.Key),
+		Metadata:  m.Metadata,
+	}
+	if m.Value != nil {
+		entry.Value = valueFromProto(m.Value)
+	}
+	return entry, nil
+}
+
+func valueToProto(v *Value) *proto.Value {
+	m := &proto.Value{
+		Type:        int32(v.Type),
+		Data:        v.Data,
+		Timestamp:   v.Timestamp,
+		ReplicaId:   v.ReplicaID,
+		VectorClock: vectorClockToProto(v.VectorClock),
+		Ttl:         v.TTL,
+		Counts:      pnCounterToProto(v.Counts),
+		FloatCounts: floatPNCounterToProto(v.FloatCounts),
+	}
+	if !v.ExpireAt.IsZero() {
+		m.ExpireAt = v.ExpireAt.UnixNano()
+	}
+	if r := v.Reset; r != nil {
+		m.Reset_ = &proto.CounterReset{
+			Base:      r.Base,
+			Timestamp: r.Timestamp,
+			ReplicaId: r.ReplicaID,
+			Deleted:   r.Deleted,
+			Observed:  pnCounterToProto(r.Observed),
+		}
+	}
+	if r := v.FloatReset; r != nil {
+		m.FloatReset = &proto.FloatCounterReset{
+			Base:      r.Base,
+			Timestamp: r.Timestamp,
+			ReplicaId: r.ReplicaID,
+			Deleted:   r.Deleted,
+			Observed:  floatPNCounterToProto(r.Observed),
+		}
+	}
+	return m
+}
+
+func valueFromProto(m *proto.Value) *Value {
+	v := &Value{
+		Type:        ValueType(m.Type),
+		Data:        m.Data,
+		Timestamp:   m.Timestamp,
+		ReplicaID:   m.ReplicaId,
+		VectorClock: vectorClockFromProto(m.VectorClock),
+		TTL:         m.Ttl,
+		Counts:      pnCounterFromProto(m.Counts),
+		FloatCounts: floatPNCounterFromProto(m.FloatCounts),
+	}
+	if m.ExpireAt != 0 {
+		v.ExpireAt = time.Unix(0, m.ExpireAt)
+	}
+	if r := m.Reset_; r != nil {
+		v.Reset = &CounterReset[int64]{
+			Base:      r.Base,
+			Timestamp: r.Timestamp,
+			ReplicaID: r.ReplicaId,
+			Deleted:   r.Deleted,
+			Observed:  pnCounterFromProto(r.Observed),
+		}
+		if v.Reset.Observed == nil {
+			v.Reset.Observed = NewPNCounter[int64]()
+		}
+	}
+	if r := m.FloatReset; r != nil {
+		v.FloatReset = &CounterReset[float64]{
+			Base:      r.Base,
+			Timestamp: r.Timestamp,
+			ReplicaID: r.ReplicaId,
+			Deleted:   r.Deleted,
+			Observed:  floatPNCounterFromProto(r.Observed),
+		}
+		if v.FloatReset.Observed == nil {
+			v.FloatReset.Observed = NewPNCounter[float64]()
+		}
+	}
+	return v
+}
+
+// The conversions below keep nil as an absent message, and never return nil
+// maps from a present one
+
+func vectorClockToProto(vc *VectorClock) *proto.VectorClock {
+	if vc == nil {
+		return nil
+	}
+	return &proto.VectorClock{Clock: vc.Clock}
+}
+
+func vectorClockFromProto(m *proto.VectorClock) *VectorClock {
+	if m == nil {
+		return nil
+	}
+	return &VectorClock{Clock: nonNilMap(m.Clock)}
+}
+
+func pnCounterToProto(c *PNCounter[int64]) *proto.PNCounter {
+	if c == nil {
+		return nil
+	}
+	return &proto.PNCounter{Inc: c.Inc, Dec: c.Dec}
+}
+
+func pnCounterFromProto(m *proto.PNCounter) *PNCounter[int64] {
+	if m == nil {
+		return nil
+	}
+	return &PNCounter[int64]{Inc: nonNilMap(m.Inc), Dec: nonNilMap(m.Dec)}
+}
+
+func floatPNCounterToProto(c *PNCounter[float64]) *proto.FloatPNCounter {
+	if c == nil {
+		return nil
+	}
+	return &proto.FloatPNCounter{Inc: c.Inc, Dec: c.Dec}
+}
+
+func floatPNCounterFromProto(m *proto.FloatPNCounter) *PNCounter[float64] {
+	if m == nil {
+		return nil
+	}
+	return &PNCounter[float64]{Inc: nonNilMap(m.Inc), Dec: nonNilMap(m.Dec)}
+}
+
+// nonNilMap returns m, or an empty map if the message had no entries
+func nonNilMap[T any](m map[string]T) map[string]T {
+	if m == nil {
+		return make(map[string]T)
+	}
+	return m
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,258 @@
+package storage
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+type codecCase struct {
+	name  string
+	value *Value
+	crdt  encoding.BinaryMarshaler
+	empty func() encoding.BinaryUnmarshaler
+}
+
+// codecCases builds a value of each collection type with n elements
+func codecCases(n int) []codecCase {
+	vc := NewVectorClock()
+	vc.Increment("replica-a")
+	vc.Increment("replica-b")
+
+	list := &CRDTList{}
+	set := NewCRDTSet("replica-a")
+	hash := NewCRDTHash("replica-a")
+	zset := NewCRDTZSet("replica-a")
+	for i := 0; i < n; i++ {
+		ts := int64(1700000000000000000 + i)
+		list.RPush(fmt.Sprintf("item-%d", i), ts, "replica-a")
+		set.Add(fmt.Sprintf("member-%d", i), ts, "replica-a")
+		hash.Set(fmt.Sprintf("field-%d", i), fmt.Sprintf("value-%d", i), ts, "replica-a")
+		zset.ZAdd(map[string]float64{fmt.Sprintf("member-%d", i): float64(i) * 1.5}, vc)
+	}
+	list.LPop(1700000000000000000 + int64(n))
+	set.Remove("member-0", 1700000000000000000+int64(n))
+	hash.Delete("field-0", 1700000000000000000+int64(n))
+	hash.IncrBy("counter", -42, 1700000000000000000+int64(n), "replica-b")
+	zset.ZRem([]string{"member-0"}, 1700000000000000000+int64(n), vc)
+
+	ttl := int64(60)
+	value := func(t ValueType) *Value {
+		return &Value{Type: t, Timestamp: 1700000000000000000, ReplicaID: "replica-a", VectorClock: vc.Copy(), TTL: &ttl, ExpireAt: time.Unix(1700000060, 0)}
+	}
+	return []codecCase{
+		{"list", value(TypeList), list, func() encoding.BinaryUnmarshaler { return &CRDTList{} }},
+		{"set", value(TypeSet), set, func() encoding.BinaryUnmarshaler { return &CRDTSet{} }},
+		{"hash", value(TypeHash), hash, func() encoding.BinaryUnmarshaler { return &CRDTHash{} }},
+		{"zset", value(TypeZSet), zset, func() encoding.BinaryUnmarshaler { return &CRDTZSet{} }},
+	}
+}
+
+func TestCodecRoundTrip(t *testing.T) {
+	for _, tc := range codecCases(20) {
+		t.Run(tc.name, func(t *testing.T) {
+			data, err := tc.crdt.MarshalBinary()
+			if err != nil {
+				t.Fatalf("MarshalBinary failed: %v", err)
+			}
+			decoded := tc.empty()
+			if err := decoded.UnmarshalBinary(data); err != nil {
+				t.Fatalf("UnmarshalBinary failed: %v", err)
+			}
+			// JSON is the reference encoding
+			jsonData, _ := json.Marshal(tc.crdt)
+			want := tc.empty()
+			json.Unmarshal(jsonData, want)
+			if !reflect.DeepEqual(decoded, want) {
+				t.Errorf("decoded %+v, want %+v", decoded, want)
+			}
+			// Equal collections encode the same way
+			if again, _ := decoded.(encoding.BinaryMarshaler).MarshalBinary(); string(again) != string(data) {
+				t.Error("re-encoding changed the bytes")
+			}
+
+			tc.value.Data = data
+			data, _ = tc.value.MarshalBinary()
+			var v Value
+			if err := v.UnmarshalBinary(data); err != nil {
+				t.Fatalf("Value.UnmarshalBinary failed: %v", err)
+			}
+			if !v.ExpireAt.Equal(tc.value.ExpireAt) {
+				t.Errorf("ExpireAt = %v, want %v", v.ExpireAt, tc.value.ExpireAt)
+			}
+			v.ExpireAt = tc.value.ExpireAt
+			if !reflect.DeepEqual(&v, tc.value) {
+				t.Errorf("decoded value %+v, want %+v", &v, tc.value)
+			}
+		})
+	}
+}
+
+func TestCodecCounterShares(t *testing.T) {
+	counter := NewCounterValue(7, 1700000000000000000, "replica-a")
+	counter.Merge(NewCounterValue(-3, 1700000000000000001, "replica-b"))
+	float := NewFloatCounterValue(1.5, 1700000000000000000, "replica-a")
+	float.Merge(NewFloatCounterValue(-0.25, 1700000000000000001, "replica-b"))
+	for _, v := range []*Value{counter, float} {
+		data, _ := v.MarshalBinary()
+		var decoded Value
+		if err := decoded.UnmarshalBinary(data); err != nil {
+			t.Fatalf("UnmarshalBinary failed: %v", err)
+		}
+		if !reflect.DeepEqual(decoded.Counts, v.Counts) || !reflect.DeepEqual(decoded.FloatCounts, v.FloatCounts) || decoded.String() != v.String() {
+			t.Errorf("decoded %+v, want %+v", decoded, v)
+		}
+	}
+}
+
+func TestCodecCounterResets(t *testing.T) {
+	counter := NewCounterValue(7, 1700000000000000000, "replica-a")
+	counter.Reset = &CounterReset[int64]{Base: -4, Observed: singleShare[int64]("replica-a", 2), Timestamp: 1700000000000000001, ReplicaID: "replica-b", Deleted: true}
+	float := NewFloatCounterValue(1.5, 1700000000000000000, "replica-a")
+	float.FloatReset = &CounterReset[float64]{Base: 0.5, Observed: NewPNCounter[float64](), Timestamp: 1700000000000000001, ReplicaID: "replica-b"}
+	for _, v := range []*Value{counter, float} {
+		data, _ := v.MarshalBinary()
+		var decoded Value
+		if err := decoded.UnmarshalBinary(data); err != nil {
+			t.Fatalf("UnmarshalBinary failed: %v", err)
+		}
+		if !reflect.DeepEqual(decoded.Reset, v.Reset) || !reflect.DeepEqual(decoded.FloatReset, v.FloatReset) {
+			t.Errorf("decoded resets %+v %+v, want %+v %+v", decoded.Reset, decoded.FloatReset, v.Reset, v.FloatReset)
+		}
+	}
+}
+
+func TestCodecBinaryKeys(t *testing.T) {
+	set := NewCRDTSet("r1")
+	set.Add("\xff\xfe", 1, "r1")
+	hash := NewCRDTHash("r1")
+	hash.Set("\xc3", "\x80\x81", 1, "r1")
+	data, err := set.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary of a binary member failed: %v", err)
+	}
+	decodedSet := &CRDTSet{}
+	if err := decodedSet.UnmarshalBinary(data); err != nil || !decodedSet.Contains("\xff\xfe") {
+		t.Errorf("set with a binary member = %+v, %v", decodedSet, err)
+	}
+	data, _ = hash.MarshalBinary()
+	decodedHash := &CRDTHash{}
+	if err := decodedHash.UnmarshalBinary(data); err != nil || decodedHash.Fields["\xc3"] == nil || decodedHash.Fields["\xc3"].Value != "\x80\x81" {
+		t.Errorf("hash with a binary field = %+v, %v", decodedHash, err)
+	}
+
+	entry := &LogEntry{Operation: "SET", Key: "\xff", Value: NewStringValue("\xfe", 1, "r1")}
+	record, err := encodeEntry(entry)
+	if err != nil {
+		t.Fatalf("encodeEntry of a binary key failed: %v", err)
+	}
+	if decoded, _, reason := decodeRecord(record); decoded == nil || decoded.Key != "\xff" {
+		t.Errorf("record with a binary key = %+v, %s", decoded, reason)
+	}
+}
+
+func TestCodecReadsJSON(t *testing.T) {
+	set := NewCRDTSet("r1")
+	set.Add("a", 1, "r1")
+	setData, _ := json.Marshal(set)
+	legacy, _ := json.Marshal(&Value{Type: TypeSet, Data: setData, Timestamp: 1, ReplicaID: "r1", VectorClock: NewVectorClock()})
+
+	var v Value
+	if err := v.UnmarshalBinary(legacy); err != nil {
+		t.Fatalf("UnmarshalBinary of JSON failed: %v", err)
+	}
+	if s := v.Set(); s == nil || !s.Contains("a") {
+		t.Errorf("set from JSON = %+v", s)
+	}
+	// The next write stores it in the binary encoding
+	s := v.Set()
+	s.Add("b", 2, "r1")
+	v.SetSet(s, 2)
+	if v.Data[0] != codecVersion || v.Set().Size() != 2 {
+		t.Errorf("set after a write = %q", v.Data)
+	}
+}
+
+func TestCodecVersions(t *testing.T) {
+	v := NewStringValue("x", 1, "r1")
+	data, _ := v.MarshalBinary()
+
+	// Fields added later are skipped
+	extended := protowire.AppendTag(append([]byte(nil), data...), 99, protowire.BytesType)
+	extended = protowire.AppendString(extended, "unknown")
+	var decoded Value
+	if err := decoded.UnmarshalBinary(extended); err != nil || decoded.String() != "x" {
+		t.Errorf("value with an unknown field = %+v, %v", decoded, err)
+	}
+
+	data[0] = codecVersion + 1
+	if err := decoded.UnmarshalBinary(data); err == nil {
+		t.Error("value from a newer codec version was decoded")
+	}
+	if err := decoded.UnmarshalBinary(data[:len(data)-1]); err == nil {
+		t.Error("truncated value was decoded")
+	}
+}
+
+// BenchmarkValueCodec compares the JSON encoding of a persisted collection,
+// the CRDT as JSON inside the value's JSON, with the binary one. Each run
+// reports the encoded size.
+func BenchmarkValueCodec(b *testing.B) {
+	for _, n := range []int{10, 1000} {
+		for _, tc := range codecCases(n) {
+			encodeJSON := func() []byte {
+				v := *tc.value
+				v.Data, _ = json.Marshal(tc.crdt)
+				data, _ := json.Marshal(&v)
+				return data
+			}
+			encodeBinary := func() []byte {
+				v := *tc.value
+				v.Data, _ = tc.crdt.MarshalBinary()
+				data, _ := v.MarshalBinary()
+				return data
+			}
+			decodeJSON := func(data []byte) {
+				var v Value
+				json.Unmarshal(data, &v)
+				tc.empty().UnmarshalBinary(v.Data)
+			}
+			decodeBinary := func(data []byte) {
+				var v Value
+				v.UnmarshalBinary(data)
+				tc.empty().UnmarshalBinary(v.Data)
+			}
+
+			for _, codec := range []struct {
+				name   string
+				encode func() []byte
+				decode func([]byte)
+			}{
+				{"json", encodeJSON, decodeJSON},
+				{"binary", encodeBinary, decodeBinary},
+			} {
+				name := fmt.Sprintf("%s-%d/%s", tc.name, n, codec.name)
+				data := codec.encode()
+				b.Run(name+"/encode", func(b *testing.B) {
+					b.ReportAllocs()
+					for i := 0; i < b.N; i++ {
+						codec.encode()
+					}
+					b.ReportMetric(float64(len(data)), "bytes")
+				})
+				b.Run(name+"/decode", func(b *testing.B) {
+					b.ReportAllocs()
+					for i := 0; i < b.N; i++ {
+						codec.decode(data)
+					}
+					b.ReportMetric(float64(len(data)), "bytes")
+				})
+			}
+		}
+	}
+}
@@ -0,0 +1,355 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// CounterReset records a SET or DEL that reset a counter. Observed holds the
+// contributions the writing replica had seen; contributions beyond them were
+// concurrent with the reset and still count on top of Base. Of two resets the
+// later one, by timestamp and then replica ID, wins.
+type CounterReset[T int64 | float64] struct {
+	Base      T             `json:"base"`
+	Observed  *PNCounter[T] `json:"observed"`
+	Timestamp int64         `json:"timestamp"`
+	ReplicaID string        `json:"replica_id"`
+	Deleted   bool          `json:"deleted,omitempty"` // reset by DEL rather than SET
+}
+
+// Copy returns a deep copy of the reset
+func (r *CounterReset[T]) Copy() *CounterReset[T] {
+	c := *r
+	c.Observed = r.Observed.Copy()
+	return &c
+}
+
+// newerThan reports whether r wins over other; a nil reset loses to any
+func (r *CounterReset[T]) newerThan(other *CounterReset[T]) bool {
+	if r == nil {
+		return false
+	}
+	if other == nil {
+		return true
+	}
+	if r.Timestamp != other.Timestamp {
+		return r.Timestamp > other.Timestamp
+	}
+	return r.ReplicaID > other.ReplicaID
+}
+
+// total returns the counter value of counts after the reset
+func (r *CounterReset[T]) total(counts *PNCounter[T]) T {
+	if r == nil {
+		return counts.Value()
+	}
+	return r.Base + counts.Since(r.Observed)
+}
+
+// floatReset converts an integer counter reset to a float one
+func floatReset(r *CounterReset[int64]) *CounterReset[float64] {
+	if r == nil {
+		return nil
+	}
+	return &CounterReset[float64]{
+		Base:      float64(r.Base),
+		Observed:  floatShares(r.Observed),
+		Timestamp: r.Timestamp,
+		ReplicaID: r.ReplicaID,
+		Deleted:   r.Deleted,
+	}
+}
+
+// intReset converts a float counter reset to an integer one, failing like
+// intShares
+func intReset(r *CounterReset[float64]) (*CounterReset[int64], error) {
+	if r == nil {
+		return nil, nil
+	}
+	observed, err := intShares(r.Observed)
+	if err != nil {
+		return nil, err
+	}
+	if r.Base != math.Trunc(r.Base) || math.Abs(r.Base) > math.MaxInt64 {
+		return nil, fmt.Errorf("value is not an integer")
+	}
+	return &CounterReset[int64]{
+		Base:      int64(r.Base),
+		Observed:  observed,
+		Timestamp: r.Timestamp,
+		ReplicaID: r.ReplicaID,
+		Deleted:   r.Deleted,
+	}, nil
+}
+
+// counterKind gives generic access to the fields of one flavour of counter
+type counterKind[T int64 | float64] struct {
+	typ       ValueType
+	counts    func(v *Value) **PNCounter[T]
+	reset     func(v *Value) **CounterReset[T]
+	total     func(v *Value) T // as stored in Data
+	setCounts func(v *Value, counts *PNCounter[T])
+	parse     func(s string) (T, error)
+}
+
+var intCounter = counterKind[int64]{
+	typ:       TypeCounter,
+	counts:    func(v *Value) **PNCounter[int64] { return &v.Counts },
+	reset:     func(v *Value) **CounterReset[int64] { return &v.Reset },
+	total:     (*Value).Counter,
+	setCounts: (*Value).setCounts,
+	parse:     func(s string) (int64, error) { return strconv.ParseInt(s, 10, 64) },
+}
+
+var floatCounter = counterKind[float64]{
+	typ:       TypeFloatCounter,
+	counts:    func(v *Value) **PNCounter[float64] { return &v.FloatCounts },
+	reset:     func(v *Value) **CounterReset[float64] { return &v.FloatReset },
+	total:     (*Value).FloatCounter,
+	setCounts: (*Value).setFloatCounts,
+	parse:     func(s string) (float64, error) { return strconv.ParseFloat(s, 64) },
+}
+
+// counterState is a counter, or a string whose SET reset one, seen as the
+// counter's contributions and the reset they apply after
+type counterState[T int64 | float64] struct {
+	counts  *PNCounter[T]
+	reset   *CounterReset[T]
+	str     *Value // the string whose SET is reset, nil for a counter
+	numeric bool   // str parses as a counter value
+}
+
+// state returns a copy of v's counter state. A string's reset is its own SET,
+// based on the value it holds.
+func (k counterKind[T]) state(v *Value) counterState[T] {
+	var st counterState[T]
+	switch counts := *k.counts(v); {
+	case counts != nil:
+		st.counts = counts.Copy()
+	case v.Type == k.typ:
+		st.counts = singleShare(v.ReplicaID, k.total(v))
+	default:
+		st.counts = NewPNCounter[T]()
+	}
+	if reset := *k.reset(v); reset != nil {
+		st.reset = reset.Copy()
+	}
+	if v.Type == TypeString {
+		observed := NewPNCounter[T]()
+		if st.reset != nil {
+			observed = st.reset.Observed
+		}
+		base, err := k.parse(string(v.Data))
+		st.reset = &CounterReset[T]{Base: base, Observed: observed, Timestamp: v.Timestamp, ReplicaID: v.ReplicaID}
+		st.str, st.numeric = v, err == nil
+	}
+	return st
+}
+
+// build stores counts and reset in v as a counter
+func (k counterKind[T]) build(v *Value, counts *PNCounter[T], reset *CounterReset[T]) {
+	v.Type = k.typ
+	*k.reset(v) = reset
+	k.setCounts(v, counts)
+}
+
+// mergeCounterState merges other into v under the counter reset rule: the
+// contributions of both are kept, the later reset wins, and the result is a
+// counter whenever contributions the winning reset did not observe remain.
+// A string whose SET wins stays a string if it observed them all, or if it
+// cannot be counted from.
+func mergeCounterState[T int64 | float64](k counterKind[T], v, other *Value) {
+	a, b := k.state(v), k.state(other)
+	counts := a.counts
+	counts.Merge(b.counts)
+	for _, r := range []*CounterReset[T]{a.reset, b.reset} {
+		if r != nil {
+			counts.Merge(r.Observed)
+		}
+	}
+	win := a
+	if b.reset.newerThan(a.reset) {
+		win = b
+	}
+	pending := win.reset == nil || !win.reset.Observed.Covers(counts)
+
+	vc, ttl, expireAt := v.VectorClock, v.TTL, v.ExpireAt
+	timestamp, replicaID := v.Timestamp, v.ReplicaID
+	if other.Timestamp > timestamp {
+		timestamp, replicaID = other.Timestamp, other.ReplicaID
+	}
+	if win.str != nil && (!pending || !win.numeric) {
+		reset := win.reset
+		reset.Base = 0
+		*v = *win.str.Clone()
+		*k.counts(v) = counts
+		*k.reset(v) = reset
+	} else {
+		*v = Value{Timestamp: timestamp, ReplicaID: replicaID}
+		k.build(v, counts, win.reset)
+	}
+	v.VectorClock, v.TTL, v.ExpireAt = vc, ttl, expireAt
+}
+
+// counterLike reports whether v is a counter or a string that reset one
+func (v *Value) counterLike() bool {
+	switch v.Type {
+	case TypeCounter, TypeFloatCounter:
+		return true
+	case TypeString:
+		return v.Counts != nil || v.FloatCounts != nil
+	}
+	return false
+}
+
+// mergeCounters merges other into v with mergeCounterState when both are
+// counters or strings and at least one is counterLike, and reports whether
+// it did. Integer and float counters do not merge with each other.
+func (v *Value) mergeCounters(other *Value) bool {
+	isCounterOrString := func(x *Value) bool {
+		return x.Type == TypeString || x.Type == TypeCounter || x.Type == TypeFloatCounter
+	}
+	if !isCounterOrString(v) || !isCounterOrString(other) || (!v.counterLike() && !other.counterLike()) {
+		return false
+	}
+	isInt := v.Type == TypeCounter || other.Type == TypeCounter
+	isFloat := v.Type == TypeFloatCounter || other.Type == TypeFloatCounter
+	switch {
+	case isInt && isFloat:
+		return false
+	case isInt, !isFloat && (v.Counts != nil || other.Counts != nil):
+		mergeCounterState(intCounter, v, other)
+	default:
+		mergeCounterState(floatCounter, v, other)
+	}
+	return true
+}
+
+// counterDeleted reports whether v is a counter whose last reset was a DEL
+// that observed all of its contributions, so that the key no longer exists
+func (v *Value) counterDeleted() bool {
+	switch v.Type {
+	case TypeCounter:
+		return v.Reset != nil && v.Reset.Deleted && v.Reset.Observed.Covers(v.counts())
+	case TypeFloatCounter:
+		return v.FloatReset != nil && v.FloatReset.Deleted && v.FloatReset.Observed.Covers(v.floatCounts())
+	}
+	return false
+}
+
+// asFloatCounter returns an integer counter converted to a float one
+func (v *Value) asFloatCounter() *Value {
+	c := v.Clone()
+	c.Type = TypeFloatCounter
+	c.Counts, c.Reset = nil, nil
+	c.FloatReset = floatReset(v.Reset)
+	c.setFloatCounts(floatShares(v.counts()))
+	return c
+}
+
+// asCounter returns a float counter converted to an integer one, failing if
+// a contribution has a fractional part
+func (v *Value) asCounter() (*Value, error) {
+	counts, err := intShares(v.floatCounts())
+	if err != nil {
+		return nil, err
+	}
+	reset, err := intReset(v.FloatReset)
+	if err != nil {
+		return nil, err
+	}
+	c := v.Clone()
+	c.Type = TypeCounter
+	c.FloatCounts, c.FloatReset = nil, nil
+	c.Reset = reset
+	c.setCounts(counts)
+	return c, nil
+}
+
+// counterObservation is what a SET or DEL saw of a counter, as logged for
+// replicas to apply with WithCounterObserved
+type counterObservation struct {
+	Counts      *PNCounter[int64]   `json:"counts,omitempty"`
+	FloatCounts *PNCounter[float64] `json:"float_counts,omitempty"`
+}
+
+// observationOf returns the contributions known to the counter state v
+func observationOf(v *Value) counterObservation {
+	switch v.Type {
+	case TypeCounter:
+		return counterObservation{Counts: v.counts()}
+	case TypeFloatCounter:
+		return counterObservation{FloatCounts: v.floatCounts()}
+	}
+	var o counterObservation
+	if v.Counts != nil {
+		o.Counts = v.Counts.Copy()
+	}
+	if v.FloatCounts != nil {
+		o.FloatCounts = v.FloatCounts.Copy()
+	}
+	return o
+}
+
+// decodeObservation parses an observation logged by CounterObserved; an
+// empty one observed nothing
+func decodeObservation(s string) (counterObservation, error) {
+	var o counterObservation
+	if s == "" {
+		return o, nil
+	}
+	if err := json.Unmarshal([]byte(s), &o); err != nil {
+		return o, fmt.Errorf("invalid counter observation: %v", err)
+	}
+	if o.Counts != nil {
+		o.Counts.ensureMaps()
+	}
+	if o.FloatCounts != nil {
+		o.FloatCounts.ensureMaps()
+	}
+	return o, nil
+}
+
+// observeReset makes the string value the reset of the contributions in o
+func (o counterObservation) observeReset(value *Value) {
+	if o.Counts != nil {
+		value.Counts = o.Counts.Copy()
+		value.Reset = &CounterReset[int64]{Observed: o.Counts.Copy(), Timestamp: value.Timestamp, ReplicaID: value.ReplicaID}
+	}
+	if o.FloatCounts != nil {
+		value.FloatCounts = o.FloatCounts.Copy()
+		value.FloatReset = &CounterReset[float64]{Observed: o.FloatCounts.Copy(), Timestamp: value.Timestamp, ReplicaID: value.ReplicaID}
+	}
+}
+
+// deletion returns the counter left by a DEL at timestamp that observed o,
+// shaped like the counter state prev when there is one
+func (o counterObservation) deletion(prev *Value, timestamp int64, replicaID string) *Value {
+	v := &Value{Timestamp: timestamp, ReplicaID: replicaID, VectorClock: NewVectorClock()}
+	float := o.FloatCounts != nil && o.Counts == nil
+	if prev != nil {
+		float = prev.Type == TypeFloatCounter || (prev.Type == TypeString && prev.Counts == nil && prev.FloatCounts != nil)
+	}
+	if float {
+		observed := o.FloatCounts
+		if observed == nil && o.Counts != nil {
+			observed = floatShares(o.Counts)
+		}
+		if observed == nil {
+			observed = NewPNCounter[float64]()
+		}
+		floatCounter.build(v, observed, &CounterReset[float64]{Observed: observed.Copy(), Timestamp: timestamp, ReplicaID: replicaID, Deleted: true})
+		return v
+	}
+	observed := o.Counts
+	if observed == nil && o.FloatCounts != nil {
+		observed, _ = intShares(o.FloatCounts)
+	}
+	if observed == nil {
+		observed = NewPNCounter[int64]()
+	}
+	intCounter.build(v, observed, &CounterReset[int64]{Observed: observed.Copy(), Timestamp: timestamp, ReplicaID: replicaID, Deleted: true})
+	return v
+}
@@ -0,0 +1,161 @@
+package storage
+
+import (
+	"fmt"
+	"math"
+)
+
+// PNCounter is a counter kept as per-replica contributions. A replica only
+// ever adds to its own increments and decrements, so merging keeps the larger
+// of each and merging the same state twice changes nothing.
+type PNCounter[T int64 | float64] struct {
+	Inc map[string]T `json:"inc"` // replica -> sum of its increments
+	Dec map[string]T `json:"dec"` // replica -> sum of its decrements, as a positive number
+}
+
+// NewPNCounter creates an empty counter
+func NewPNCounter[T int64 | float64]() *PNCounter[T] {
+	return &PNCounter[T]{Inc: make(map[string]T), Dec: make(map[string]T)}
+}
+
+// Add adds delta to replicaID's contribution
+func (c *PNCounter[T]) Add(replicaID string, delta T) {
+	c.ensureMaps()
+	if delta >= 0 {
+		c.Inc[replicaID] += delta
+	} else {
+		c.Dec[replicaID] -= delta
+	}
+}
+
+// Observe raises replicaID's contribution to at least inc and dec, as
+// reported by that replica after one of its writes
+func (c *PNCounter[T]) Observe(replicaID string, inc, dec T) {
+	c.ensureMaps()
+	if inc > c.Inc[replicaID] {
+		c.Inc[replicaID] = inc
+	}
+	if dec > c.Dec[replicaID] {
+		c.Dec[replicaID] = dec
+	}
+}
+
+// Share returns replicaID's increments and decrements
+func (c *PNCounter[T]) Share(replicaID string) (inc, dec T) {
+	return c.Inc[replicaID], c.Dec[replicaID]
+}
+
+// Value returns the counter total. Contributions are added in replica order
+// so that replicas holding the same state agree on a float total.
+func (c *PNCounter[T]) Value() T {
+	var total T
+	for _, id := range sortedKeys(c.Inc) {
+		total += c.Inc[id]
+	}
+	for _, id := range sortedKeys(c.Dec) {
+		total -= c.Dec[id]
+	}
+	return total
+}
+
+// Merge takes the larger contribution of each replica
+func (c *PNCounter[T]) Merge(other *PNCounter[T]) {
+	c.ensureMaps()
+	for id, n := range other.Inc {
+		if n > c.Inc[id] {
+			c.Inc[id] = n
+		}
+	}
+	for id, n := range other.Dec {
+		if n > c.Dec[id] {
+			c.Dec[id] = n
+		}
+	}
+}
+
+// Since returns the total of the contributions c holds beyond observed,
+// added in replica order like Value
+func (c *PNCounter[T]) Since(observed *PNCounter[T]) T {
+	var total T
+	for _, id := range sortedKeys(c.Inc) {
+		if n := c.Inc[id] - observed.Inc[id]; n > 0 {
+			total += n
+		}
+	}
+	for _, id := range sortedKeys(c.Dec) {
+		if n := c.Dec[id] - observed.Dec[id]; n > 0 {
+			total -= n
+		}
+	}
+	return total
+}
+
+// Covers reports whether c holds every contribution of other
+func (c *PNCounter[T]) Covers(other *PNCounter[T]) bool {
+	for id, n := range other.Inc {
+		if n > c.Inc[id] {
+			return false
+		}
+	}
+	for id, n := range other.Dec {
+		if n > c.Dec[id] {
+			return false
+		}
+	}
+	return true
+}
+
+// ensureMaps allocates the maps of a counter decoded from JSON without them
+func (c *PNCounter[T]) ensureMaps() {
+	if c.Inc == nil {
+		c.Inc = make(map[string]T)
+	}
+	if c.Dec == nil {
+		c.Dec = make(map[string]T)
+	}
+}
+
+// Copy returns a deep copy of the counter
+func (c *PNCounter[T]) Copy() *PNCounter[T] {
+	cp := NewPNCounter[T]()
+	cp.Merge(c)
+	return cp
+}
+
+// singleShare returns a counter holding total as replicaID's contribution,
+// for counters written before contributions were tracked
+func singleShare[T int64 | float64](replicaID string, total T) *PNCounter[T] {
+	c := NewPNCounter[T]()
+	c.Add(replicaID, total)
+	return c
+}
+
+// floatShares converts integer contributions to float ones
+func floatShares(c *PNCounter[int64]) *PNCounter[float64] {
+	f := NewPNCounter[float64]()
+	for id, n := range c.Inc {
+		f.Inc[id] = float64(n)
+	}
+	for id, n := range c.Dec {
+		f.Dec[id] = float64(n)
+	}
+	return f
+}
+
+// intShares converts float contributions to integer ones, failing if one
+// has a fractional part
+func intShares(f *PNCounter[float64]) (*PNCounter[int64], error) {
+	c := NewPNCounter[int64]()
+	for _, m := range []struct {
+		from map[string]float64
+		to   map[string]int64
+	}{{f.Inc, c.Inc}, {f.Dec, c.Dec}} {
+		for id, n := range m.from {
+			if n != math.Trunc(n) || math.Abs(n) > math.MaxInt64 {
+				return nil, fmt.Errorf("value is not an integer")
+			}
+			m.to[id] = int64(n)
+		}
+	}
+	return c, nil
+}
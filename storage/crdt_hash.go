@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"math"
 	"strconv"
+	"strings"
 )
 
 // FieldType represents the type of a hash field
@@ -25,6 +26,61 @@ type HashField struct {
 	ID           string    `json:"id"`            // Unique field ID (timestamp-replicaID-seq)
 	Timestamp    int64     `json:"timestamp"`     // Wall clock timestamp of last update
 	ReplicaID    string    `json:"replica_id"`
+
+	// Per-replica contributions to CounterValue, in scaled units. Nil for
+	// counters written before they were tracked.
+	Counts *PNCounter[int64] `json:"counts,omitempty"`
+}
+
+// floatCounterScale is the scale of counter fields once a float was added
+const floatCounterScale = 1000000
+
+// scale returns the field's counter scale
+func (f *HashField) scale() int64 {
+	if f.CounterScale == 0 {
+		return 1
+	}
+	return f.CounterScale
+}
+
+// counts returns a copy of the field's counter contributions; a counter
+// written before they were tracked counts as its last writer's alone
+func (f *HashField) counts() *PNCounter[int64] {
+	if f.Counts != nil {
+		return f.Counts.Copy()
+	}
+	return singleShare(f.ReplicaID, f.CounterValue)
+}
+
+// setCounts replaces the field's counter contributions and their total
+func (f *HashField) setCounts(counts *PNCounter[int64]) {
+	f.Counts = counts
+	f.CounterValue = counts.Value()
+}
+
+// rescale raises the field's counter scale, converting its contributions
+func (f *HashField) rescale(scale int64) {
+	if scale <= f.scale() {
+		return
+	}
+	factor := scale / f.scale()
+	counts := f.counts()
+	for id := range counts.Inc {
+		counts.Inc[id] *= factor
+	}
+	for id := range counts.Dec {
+		counts.Dec[id] *= factor
+	}
+	f.CounterScale = scale
+	f.setCounts(counts)
+}
+
+// formatCounter renders a scaled counter amount as the field value
+func formatCounter(n, scale int64) string {
+	if scale <= 1 {
+		return strconv.FormatInt(n, 10)
+	}
+	return strconv.FormatFloat(float64(n)/float64(scale), 'f', -1, 64)
 }
 
 // CRDTHash implements a Last-Write-Wins Hash with field-level granularity
@@ -84,14 +140,7 @@ func (h *CRDTHash) Get(key string) (string, bool) {
 	}
 	// For counter fields, return string representation of counter value
 	if field.FieldType == FieldTypeCounter {
-		scale := field.CounterScale
-		if scale == 0 {
-			scale = 1
-		}
-		if scale == 1 {
-			return fmt.Sprintf("%d", field.CounterValue), true
-		}
-		return strconv.FormatFloat(float64(field.CounterValue)/float64(scale), 'f', -1, 64), true
+		return formatCounter(field.CounterValue, field.scale()), true
 	}
 	return field.Value, true
 }
@@ -128,30 +177,30 @@ func (h *CRDTHash) IncrBy(key string, delta int64, timestamp int64, replicaID st
 			// Per Redis behavior: try to parse string as int
 			// For simplicity, we'll convert string field to counter
 			existingField.FieldType = FieldTypeCounter
-			existingField.CounterValue = 0
+			existingField.Value = ""
 			existingField.CounterScale = 1
+			existingField.setCounts(NewPNCounter[int64]())
 		}
-		if existingField.CounterScale == 0 {
-			existingField.CounterScale = 1
-		}
-		existingField.CounterValue += delta * existingField.CounterScale
+		counts := existingField.counts()
+		counts.Add(replicaID, delta*existingField.scale())
+		existingField.setCounts(counts)
 		existingField.Timestamp = timestamp
 		existingField.ReplicaID = replicaID
 		existingField.ID = id
-		return existingField.CounterValue / existingField.CounterScale, nil
+		return existingField.CounterValue / existingField.scale(), nil
 	}
 
 	// Create new counter field
 	field := &HashField{
 		Key:          key,
 		Value:        "",
-		CounterValue: delta,
 		CounterScale: 1,
 		FieldType:    FieldTypeCounter,
 		ID:           id,
 		Timestamp:    timestamp,
 		ReplicaID:    replicaID,
 	}
+	field.setCounts(singleShare(replicaID, delta))
 
 	h.Fields[key] = field
 	return delta, nil
@@ -172,49 +221,104 @@ func (h *CRDTHash) IncrByFloat(key string, delta float64, timestamp int64, repli
 
 	existingField, exists := h.Fields[key]
 	if exists {
-		// Parse existing value as float
-		var currentValue float64
-		if existingField.FieldType == FieldTypeCounter {
-			scale := existingField.CounterScale
-			if scale == 0 {
-				scale = 1
-			}
-			currentValue = float64(existingField.CounterValue) / float64(scale)
-		} else {
-			// Try to parse string as float
-			// For simplicity, start from 0
-			currentValue = 0
+		if existingField.FieldType != FieldTypeCounter {
+			// For simplicity, a string field starts from 0
+			existingField.FieldType = FieldTypeCounter
+			existingField.Value = ""
+			existingField.CounterScale = 1
+			existingField.setCounts(NewPNCounter[int64]())
 		}
-		newValue := currentValue + delta
-
-		// Convert to counter type (we'll store scaled value)
-		existingField.FieldType = FieldTypeCounter
 		// Switch to micro-unit scale for floats
-		existingField.CounterScale = 1000000
-		existingField.CounterValue = int64(math.Round(newValue * float64(existingField.CounterScale)))
-		existingField.Value = ""
+		existingField.rescale(floatCounterScale)
+		counts := existingField.counts()
+		counts.Add(replicaID, int64(math.Round(delta*floatCounterScale)))
+		existingField.setCounts(counts)
 		existingField.Timestamp = timestamp
 		existingField.ReplicaID = replicaID
 		existingField.ID = id
-		return newValue, nil
+		return float64(existingField.CounterValue) / floatCounterScale, nil
 	}
 
 	// Create new counter field
 	field := &HashField{
 		Key:          key,
 		Value:        "",
-		CounterValue: int64(math.Round(delta * 1000000)), // Store as micro-units
-		CounterScale: 1000000,
+		CounterScale: floatCounterScale, // Store as micro-units
 		FieldType:    FieldTypeCounter,
 		ID:           id,
 		Timestamp:    timestamp,
 		ReplicaID:    replicaID,
 	}
+	field.setCounts(singleShare(replicaID, int64(math.Round(delta*floatCounterScale))))
 
 	h.Fields[key] = field
 	return delta, nil
 }
 
+// CounterShare returns replicaID's increments and decrements of a counter
+// field, formatted like field values, for logging a counter write
+func (h *CRDTHash) CounterShare(key, replicaID string) (inc, dec string, ok bool) {
+	field, exists := h.Fields[key]
+	if !exists || field.FieldType != FieldTypeCounter {
+		return "", "", false
+	}
+	i, d := field.counts().Share(replicaID)
+	return formatCounter(i, field.scale()), formatCounter(d, field.scale()), true
+}
+
+// ObserveCounter raises replicaID's increments and decrements of a counter
+// field to inc and dec, as returned by CounterShare on that replica, creating
+// the counter if needed. Applying the same share twice changes nothing.
+func (h *CRDTHash) ObserveCounter(key, inc, dec string, timestamp int64, replicaID string) error {
+	if replicaID == "" {
+		replicaID = h.ReplicaID
+	}
+	field, exists := h.Fields[key]
+	if !exists || field.FieldType != FieldTypeCounter {
+		field = &HashField{Key: key, FieldType: FieldTypeCounter, CounterScale: 1}
+		field.setCounts(NewPNCounter[int64]())
+	}
+	if strings.Contains(inc+dec, ".") {
+		field.rescale(floatCounterScale)
+	}
+	i, err := parseScaled(inc, field.scale())
+	if err != nil {
+		return err
+	}
+	d, err := parseScaled(dec, field.scale())
+	if err != nil {
+		return err
+	}
+
+	counts := field.counts()
+	counts.Observe(replicaID, i, d)
+	field.setCounts(counts)
+	h.Fields[key] = field
+	if timestamp > field.Timestamp {
+		h.nextSeq++
+		field.ID = generateElementID(timestamp, replicaID, h.nextSeq)
+		field.Timestamp = timestamp
+		field.ReplicaID = replicaID
+	}
+	return nil
+}
+
+// parseScaled parses a counter amount formatted by formatCounter
+func parseScaled(s string, scale int64) (int64, error) {
+	if scale <= 1 {
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid counter share %q", s)
+		}
+		return n, nil
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid counter share %q", s)
+	}
+	return int64(math.Round(f * float64(scale))), nil
+}
+
 // Keys returns all field keys in the hash
 func (h *CRDTHash) Keys() []string {
 	keys := make([]string, 0, len(h.Fields))
@@ -229,15 +333,7 @@ func (h *CRDTHash) Values() []string {
 	values := make([]string, 0, len(h.Fields))
 	for _, field := range h.Fields {
 		if field.FieldType == FieldTypeCounter {
-			scale := field.CounterScale
-			if scale == 0 {
-				scale = 1
-			}
-			if scale == 1 {
-				values = append(values, fmt.Sprintf("%d", field.CounterValue))
-			} else {
-				values = append(values, strconv.FormatFloat(float64(field.CounterValue)/float64(scale), 'f', -1, 64))
-			}
+			values = append(values, formatCounter(field.CounterValue, field.scale()))
 		} else {
 			values = append(values, field.Value)
 		}
@@ -250,15 +346,7 @@ func (h *CRDTHash) GetAll() map[string]string {
 	result := make(map[string]string, len(h.Fields))
 	for key, field := range h.Fields {
 		if field.FieldType == FieldTypeCounter {
-			scale := field.CounterScale
-			if scale == 0 {
-				scale = 1
-			}
-			if scale == 1 {
-				result[key] = fmt.Sprintf("%d", field.CounterValue)
-			} else {
-				result[key] = strconv.FormatFloat(float64(field.CounterValue)/float64(scale), 'f', -1, 64)
-			}
+			result[key] = formatCounter(field.CounterValue, field.scale())
 		} else {
 			result[key] = field.Value
 		}
@@ -278,7 +366,8 @@ func (h *CRDTHash) Exists(key string) bool {
 }
 
 // Merge merges another CRDT hash into this one
-// String fields use LWW semantics, Counter fields use accumulative semantics
+// String fields use LWW semantics, Counter fields keep each replica's larger
+// contribution
 func (h *CRDTHash) Merge(other *CRDTHash) {
 	// Merge fields
 	for key, otherField := range other.Fields {
@@ -290,41 +379,23 @@ func (h *CRDTHash) Merge(other *CRDTHash) {
 		existingField, exists := h.Fields[key]
 		if !exists {
 			// Field doesn't exist locally, add it
-			h.Fields[key] = &HashField{
-				Key:          otherField.Key,
-				Value:        otherField.Value,
-				CounterValue: otherField.CounterValue,
-				CounterScale: otherField.CounterScale,
-				FieldType:    otherField.FieldType,
-				ID:           otherField.ID,
-				Timestamp:    otherField.Timestamp,
-				ReplicaID:    otherField.ReplicaID,
+			field := *otherField
+			if otherField.Counts != nil {
+				field.Counts = otherField.Counts.Copy()
 			}
+			h.Fields[key] = &field
 		} else {
 			// Field exists locally
 			// Handle based on field types
 			if existingField.FieldType == FieldTypeCounter && otherField.FieldType == FieldTypeCounter {
-				// Both are counters - accumulate values (counter semantics)
-				scaleA := existingField.CounterScale
-				if scaleA == 0 {
-					scaleA = 1
-				}
-				scaleB := otherField.CounterScale
-				if scaleB == 0 {
-					scaleB = 1
-				}
-
-				targetScale := scaleA
-				if scaleB > targetScale {
-					targetScale = scaleB
-				}
-
-				valueA := float64(existingField.CounterValue) / float64(scaleA)
-				valueB := float64(otherField.CounterValue) / float64(scaleB)
-				combined := valueA + valueB
-
-				existingField.CounterScale = targetScale
-				existingField.CounterValue = int64(math.Round(combined * float64(targetScale)))
+				// Both are counters - keep each replica's larger contribution,
+				// at the larger of the two scales
+				theirs := *otherField
+				theirs.rescale(existingField.scale())
+				existingField.rescale(theirs.scale())
+				counts := existingField.counts()
+				counts.Merge(theirs.counts())
+				existingField.setCounts(counts)
 
 				// Take latest timestamp
 				if otherField.Timestamp > existingField.Timestamp {
@@ -336,8 +407,8 @@ func (h *CRDTHash) Merge(other *CRDTHash) {
 				// Type mismatch - counter wins (as it's a more specific operation)
 				if otherField.FieldType == FieldTypeCounter {
 					existingField.FieldType = FieldTypeCounter
-					existingField.CounterValue = otherField.CounterValue
 					existingField.CounterScale = otherField.CounterScale
+					existingField.setCounts(otherField.counts())
 					existingField.Value = ""
 					existingField.Timestamp = otherField.Timestamp
 					existingField.ReplicaID = otherField.ReplicaID
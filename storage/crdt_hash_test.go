@@ -163,6 +163,42 @@ func TestHIncrByConcurrentMerge(t *testing.T) {
 	// Exact value depends on merge implementation
 }
 
+// TestHIncrByMergeIdempotent tests that counter fields merge by each
+// replica's contribution, so repeated merges do not double-count
+func TestHIncrByMergeIdempotent(t *testing.T) {
+	h1 := NewCRDTHash("replica1")
+	h2 := NewCRDTHash("replica2")
+	timestamp := time.Now().UnixNano()
+
+	h1.IncrBy("field1", 10, timestamp, "replica1")
+	h2.IncrBy("field1", 3, timestamp, "replica2")
+	h2.IncrByFloat("field1", 0.5, timestamp+1, "replica2")
+
+	h1.Merge(h2)
+	h1.Merge(h2)
+	h2.Merge(h1)
+	for i, h := range []*CRDTHash{h1, h2} {
+		if value, _ := h.Get("field1"); value != "13.5" {
+			t.Errorf("h%d: expected 13.5, got %s", i+1, value)
+		}
+	}
+
+	// A share logged by replica2 raises its contribution once
+	h1.IncrBy("field1", -1, timestamp+2, "replica1")
+	inc, dec, ok := h1.CounterShare("field1", "replica1")
+	if !ok || inc != "10" || dec != "1" {
+		t.Errorf("CounterShare = %s, %s, %v", inc, dec, ok)
+	}
+	for i := 0; i < 2; i++ {
+		if err := h2.ObserveCounter("field1", inc, dec, timestamp+2, "replica1"); err != nil {
+			t.Fatalf("ObserveCounter failed: %v", err)
+		}
+	}
+	if value, _ := h2.Get("field1"); value != "12.5" {
+		t.Errorf("Expected 12.5 after observing replica1's share, got %s", value)
+	}
+}
+
 // TestHIncrByFloatBasic tests HINCRBYFLOAT basic operation
 func TestHIncrByFloatBasic(t *testing.T) {
 	h := NewCRDTHash("replica1")
@@ -1,7 +1,6 @@
 package storage
 
 import (
-	"encoding/json"
 	"fmt"
 	"sort"
 	"strings"
@@ -32,7 +31,7 @@ func NewListValue(timestamp int64, replicaID string) *Value {
 		Elements: make([]ListElement, 0),
 		NextSeq:  0,
 	}
-	data, _ := json.Marshal(list)
+	data, _ := list.MarshalBinary()
 	return &Value{
 		Type:        TypeList,
 		Data:        data,
@@ -47,7 +46,7 @@ func NewSetValue(timestamp int64, replicaID string) *Value {
 	vc := NewVectorClock()
 	vc.Increment(replicaID)
 	set := NewCRDTSet(replicaID)
-	data, _ := json.Marshal(set)
+	data, _ := set.MarshalBinary()
 	return &Value{
 		Type:        TypeSet,
 		Data:        data,
@@ -62,7 +61,7 @@ func NewHashValue(timestamp int64, replicaID string) *Value {
 	vc := NewVectorClock()
 	vc.Increment(replicaID)
 	hash := NewCRDTHash(replicaID)
-	data, _ := json.Marshal(hash)
+	data, _ := hash.MarshalBinary()
 	return &Value{
 		Type:        TypeHash,
 		Data:        data,
@@ -78,7 +77,7 @@ func (v *Value) List() *CRDTList {
 		return nil
 	}
 	var list CRDTList
-	if err := json.Unmarshal(v.Data, &list); err != nil {
+	if err := list.UnmarshalBinary(v.Data); err != nil {
 		return nil
 	}
 	return &list
@@ -89,8 +88,7 @@ func (v *Value) SetList(list *CRDTList, timestamp int64) {
 	if v.Type != TypeList {
 		return
 	}
-	data, _ := json.Marshal(list)
-	v.Data = data
+	v.Data, _ = list.MarshalBinary()
 	v.Timestamp = timestamp
 }
 
@@ -100,7 +98,7 @@ func (v *Value) Set() *CRDTSet {
 		return nil
 	}
 	var set CRDTSet
-	if err := json.Unmarshal(v.Data, &set); err != nil {
+	if err := set.UnmarshalBinary(v.Data); err != nil {
 		return nil
 	}
 	return &set
@@ -111,8 +109,7 @@ func (v *Value) SetSet(set *CRDTSet, timestamp int64) {
 	if v.Type != TypeSet {
 		return
 	}
-	data, _ := json.Marshal(set)
-	v.Data = data
+	v.Data, _ = set.MarshalBinary()
 	v.Timestamp = timestamp
 }
 
@@ -122,7 +119,7 @@ func (v *Value) Hash() *CRDTHash {
 		return nil
 	}
 	var hash CRDTHash
-	if err := json.Unmarshal(v.Data, &hash); err != nil {
+	if err := hash.UnmarshalBinary(v.Data); err != nil {
 		return nil
 	}
 	return &hash
@@ -133,8 +130,7 @@ func (v *Value) SetHash(hash *CRDTHash, timestamp int64) {
 	if v.Type != TypeHash {
 		return
 	}
-	data, _ := json.Marshal(hash)
-	v.Data = data
+	v.Data, _ = hash.MarshalBinary()
 	v.Timestamp = timestamp
 }
 
@@ -211,6 +207,28 @@ func (list *CRDTList) RPush(value string, timestamp int64, replicaID string) str
 	return elementID
 }
 
+// RPushAll adds values to the tail of the list in order, as RPush of each
+// would, but linearizes the list once rather than per element
+func (list *CRDTList) RPushAll(values []string, timestamp int64, replicaID string) {
+	originLeft := ""
+	if len(list.Elements) > 0 {
+		originLeft = list.Elements[len(list.Elements)-1].ID
+	}
+	for _, value := range values {
+		elementID := generateElementID(timestamp, replicaID, list.NextSeq)
+		list.NextSeq++
+		list.Elements = append(list.Elements, ListElement{
+			Value:        value,
+			ID:           elementID,
+			Timestamp:    timestamp,
+			ReplicaID:    replicaID,
+			OriginLeftID: originLeft,
+		})
+		originLeft = elementID
+	}
+	list.rebuildRGA()
+}
+
 // insertRGA inserts an element maintaining RGA order
 func (list *CRDTList) insertRGA(newElem ListElement) {
 	// 1. Find the target position based on OriginLeftID
@@ -36,6 +36,26 @@ func TestListLPushRPush(t *testing.T) {
 	}
 }
 
+func TestListRPushAll(t *testing.T) {
+	one := &CRDTList{Elements: make([]ListElement, 0)}
+	all := &CRDTList{Elements: make([]ListElement, 0)}
+	one.LPush("head", 1, "replica1")
+	all.LPush("head", 1, "replica1")
+	for _, v := range []string{"a", "b", "c"} {
+		one.RPush(v, 2, "replica1")
+	}
+	all.RPushAll([]string{"a", "b", "c"}, 2, "replica1")
+
+	if got := all.Range(0, -1); len(got) != 4 || got[0] != "head" || got[3] != "c" {
+		t.Errorf("Range = %v", got)
+	}
+	for i := range one.Elements {
+		if one.Elements[i] != all.Elements[i] {
+			t.Errorf("element %d = %+v, RPush gives %+v", i, all.Elements[i], one.Elements[i])
+		}
+	}
+}
+
 func TestListLPopRPop(t *testing.T) {
 	list := &CRDTList{Elements: make([]ListElement, 0)}
 	timestamp := time.Now().UnixNano()
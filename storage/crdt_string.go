@@ -30,6 +30,17 @@ type Value struct {
 	VectorClock *VectorClock `json:"vector_clock"`        // Vector clock for causality tracking
 	TTL         *int64       `json:"ttl,omitempty"`       // TTL in seconds, nil means no expiration
 	ExpireAt    time.Time    `json:"expire_at,omitempty"` // Absolute expiration time
+
+	// Per-replica contributions of a TypeCounter or TypeFloatCounter; Data
+	// holds their total. Nil for counters written before they were tracked.
+	Counts      *PNCounter[int64]   `json:"counts,omitempty"`
+	FloatCounts *PNCounter[float64] `json:"float_counts,omitempty"`
+
+	// The SET or DEL that last reset the counter, which Data counts from; nil
+	// if it never was. A TypeString that reset a counter keeps the
+	// contributions it observed here and in Counts or FloatCounts.
+	Reset      *CounterReset[int64]   `json:"reset,omitempty"`
+	FloatReset *CounterReset[float64] `json:"float_reset,omitempty"`
 }
 
 // NewStringValue creates a new Value for regular strings
@@ -45,38 +56,47 @@ func NewStringValue(value string, timestamp int64, replicaID string) *Value {
 	}
 }
 
-// NewCounterValue creates a new Value for counters
+// NewCounterValue creates a new Value for counters, with counter as
+// replicaID's contribution
 func NewCounterValue(counter int64, timestamp int64, replicaID string) *Value {
+	return newCounterValue(singleShare(replicaID, counter), timestamp, replicaID)
+}
+
+// newCounterValue creates a counter Value from per-replica contributions
+func newCounterValue(counts *PNCounter[int64], timestamp int64, replicaID string) *Value {
 	vc := NewVectorClock()
 	vc.Increment(replicaID)
-	data := make([]byte, 8)
-	binary.BigEndian.PutUint64(data, uint64(counter))
-	return &Value{
+	v := &Value{
 		Type:        TypeCounter,
-		Data:        data,
 		Timestamp:   timestamp,
 		ReplicaID:   replicaID,
 		VectorClock: vc,
 	}
+	v.setCounts(counts)
+	return v
 }
 
-// NewFloatCounterValue creates a new Value for float counters
+// NewFloatCounterValue creates a new Value for float counters, with counter
+// as replicaID's contribution
 func NewFloatCounterValue(counter float64, timestamp int64, replicaID string) *Value {
+	return newFloatCounterValue(singleShare(replicaID, counter), timestamp, replicaID)
+}
+
+// newFloatCounterValue creates a float counter Value from per-replica
+// contributions
+func newFloatCounterValue(counts *PNCounter[float64], timestamp int64, replicaID string) *Value {
 	vc := NewVectorClock()
 	if replicaID != "" {
 		vc.Increment(replicaID)
 	}
-	// Store float64 as IEEE 754 binary representation
-	data := make([]byte, 8)
-	bits := math.Float64bits(counter)
-	binary.BigEndian.PutUint64(data, bits)
-	return &Value{
+	v := &Value{
 		Type:        TypeFloatCounter,
-		Data:        data,
 		Timestamp:   timestamp,
 		ReplicaID:   replicaID,
 		VectorClock: vc,
 	}
+	v.setFloatCounts(counts)
+	return v
 }
 
 // String returns the string representation of the value
@@ -127,15 +147,32 @@ func (v *Value) Counter() int64 {
 	return int64(binary.BigEndian.Uint64(v.Data))
 }
 
-// SetCounter sets the counter value and updates the internal bytes
+// SetCounter sets the counter value and updates the internal bytes. The
+// value becomes the contribution of the value's replica alone.
 func (v *Value) SetCounter(counter int64) {
 	if v.Type != TypeCounter {
 		return
 	}
+	v.setCounts(singleShare(v.ReplicaID, counter))
+}
+
+// counts returns a copy of the counter's contributions; a counter written
+// before they were tracked counts as its replica's alone
+func (v *Value) counts() *PNCounter[int64] {
+	if v.Counts != nil {
+		return v.Counts.Copy()
+	}
+	return singleShare(v.ReplicaID, v.Counter())
+}
+
+// setCounts replaces the counter's contributions and its total since the
+// last reset in Data
+func (v *Value) setCounts(counts *PNCounter[int64]) {
+	v.Counts = counts
 	if len(v.Data) != 8 {
 		v.Data = make([]byte, 8)
 	}
-	binary.BigEndian.PutUint64(v.Data, uint64(counter))
+	binary.BigEndian.PutUint64(v.Data, uint64(v.Reset.total(counts)))
 }
 
 // FloatCounter returns the float counter value if type is TypeFloatCounter
@@ -147,16 +184,58 @@ func (v *Value) FloatCounter() float64 {
 	return math.Float64frombits(bits)
 }
 
-// SetFloatCounter sets the float counter value and updates the internal bytes
+// SetFloatCounter sets the float counter value and updates the internal
+// bytes. The value becomes the contribution of the value's replica alone.
 func (v *Value) SetFloatCounter(counter float64) {
 	if v.Type != TypeFloatCounter {
 		return
 	}
+	v.setFloatCounts(singleShare(v.ReplicaID, counter))
+}
+
+// floatCounts returns a copy of the float counter's contributions, like
+// counts
+func (v *Value) floatCounts() *PNCounter[float64] {
+	if v.FloatCounts != nil {
+		return v.FloatCounts.Copy()
+	}
+	return singleShare(v.ReplicaID, v.FloatCounter())
+}
+
+// setFloatCounts replaces the float counter's contributions and its total
+// since the last reset in Data, stored as IEEE 754 bits
+func (v *Value) setFloatCounts(counts *PNCounter[float64]) {
+	v.FloatCounts = counts
 	if len(v.Data) != 8 {
 		v.Data = make([]byte, 8)
 	}
-	bits := math.Float64bits(counter)
-	binary.BigEndian.PutUint64(v.Data, bits)
+	binary.BigEndian.PutUint64(v.Data, math.Float64bits(v.FloatReset.total(counts)))
+}
+
+// Clone returns a deep copy of the value
+func (v *Value) Clone() *Value {
+	c := *v
+	c.Data = append([]byte(nil), v.Data...)
+	if v.VectorClock != nil {
+		c.VectorClock = v.VectorClock.Copy()
+	}
+	if v.TTL != nil {
+		ttl := *v.TTL
+		c.TTL = &ttl
+	}
+	if v.Counts != nil {
+		c.Counts = v.Counts.Copy()
+	}
+	if v.FloatCounts != nil {
+		c.FloatCounts = v.FloatCounts.Copy()
+	}
+	if v.Reset != nil {
+		c.Reset = v.Reset.Copy()
+	}
+	if v.FloatReset != nil {
+		c.FloatReset = v.FloatReset.Copy()
+	}
+	return &c
 }
 
 func (v *Value) SetExpireAt(expireAt *time.Time) {
@@ -175,6 +254,12 @@ func (v *Value) Merge(other *Value) {
 		v.VectorClock.Update(other.VectorClock)
 	}
 
+	// Counters, and strings set over them, follow the counter reset rule
+	if v.mergeCounters(other) {
+		v.mergeTTL(other)
+		return
+	}
+
 	if v.Type != other.Type {
 		// If types don't match, use the most recent value based on vector clock
 		shouldUpdate := false
@@ -220,22 +305,6 @@ func (v *Value) Merge(other *Value) {
 			v.Timestamp = other.Timestamp
 			v.ReplicaID = other.ReplicaID
 		}
-	case TypeCounter:
-		// Accumulate counter values
-		newCounter := v.Counter() + other.Counter()
-		v.SetCounter(newCounter)
-		// Take the latest timestamp
-		if other.Timestamp > v.Timestamp {
-			v.Timestamp = other.Timestamp
-		}
-	case TypeFloatCounter:
-		// Accumulate float counter values (same semantics as integer counter)
-		newCounter := v.FloatCounter() + other.FloatCounter()
-		v.SetFloatCounter(newCounter)
-		// Take the latest timestamp
-		if other.Timestamp > v.Timestamp {
-			v.Timestamp = other.Timestamp
-		}
 	case TypeList:
 		// Merge CRDT lists
 		myList := v.List()
@@ -282,7 +351,11 @@ func (v *Value) Merge(other *Value) {
 		}
 	}
 
-	// Merge TTL - take the longer TTL if both exist
+	v.mergeTTL(other)
+}
+
+// mergeTTL takes the longer TTL if both exist
+func (v *Value) mergeTTL(other *Value) {
 	if v.TTL != nil && other.TTL != nil {
 		if time.Until(other.ExpireAt) > time.Until(v.ExpireAt) {
 			v.TTL = other.TTL
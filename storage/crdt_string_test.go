@@ -154,6 +154,64 @@ func TestCounterMergeMultipleAccumulation(t *testing.T) {
 	}
 }
 
+func TestCounterMergeIdempotent(t *testing.T) {
+	base := time.Now().UnixNano()
+	val1 := NewCounterValue(10, base, "replica1")
+	val2 := NewCounterValue(5, base+1, "replica2")
+	val2.Merge(NewCounterValue(-2, base+2, "replica3"))
+
+	// Merging the same state again, or in either direction, adds nothing
+	for i := 0; i < 3; i++ {
+		val1.Merge(val2)
+	}
+	val2.Merge(val1)
+	if val1.Counter() != 13 || val2.Counter() != 13 {
+		t.Errorf("Expected 13 on both replicas, got %d and %d", val1.Counter(), val2.Counter())
+	}
+
+	// A replica's newer contribution replaces its older one
+	newer := NewCounterValue(12, base+3, "replica1")
+	val2.Merge(newer)
+	if val2.Counter() != 15 {
+		t.Errorf("Expected 15 after replica1 grew to 12, got %d", val2.Counter())
+	}
+}
+
+func TestCounterResetMerge(t *testing.T) {
+	base := time.Now().UnixNano()
+	counter := NewCounterValue(10, base, "replica1")
+
+	// replica2 SETs over the 10 it saw while replica1 adds 5 more
+	set := NewStringValue("100", base+1, "replica2")
+	counterObservation{Counts: counter.counts()}.observeReset(set)
+	counter.Merge(NewCounterValue(15, base+2, "replica1"))
+
+	left, right := counter.Clone(), set.Clone()
+	left.Merge(set)
+	right.Merge(counter)
+	for _, v := range []*Value{left, right} {
+		if v.Type != TypeCounter || v.Counter() != 105 {
+			t.Errorf("Expected counter 105, got %v %s", v.Type, v.String())
+		}
+	}
+
+	// Once the SET has observed every contribution it is a plain value again
+	observed := NewStringValue("7", base+3, "replica2")
+	counterObservation{Counts: left.counts()}.observeReset(observed)
+	left.Merge(observed)
+	left.Merge(counter)
+	if left.Type != TypeString || left.String() != "7" {
+		t.Errorf("Expected string 7, got %v %s", left.Type, left.String())
+	}
+
+	// A DEL that observed everything leaves a deleted counter
+	deletion := counterObservation{Counts: right.counts()}.deletion(right, base+4, "replica3")
+	right.Merge(deletion)
+	if !right.counterDeleted() {
+		t.Errorf("Expected a deleted counter, got %v %s", right.Type, right.String())
+	}
+}
+
 func TestCounterNegativeValue(t *testing.T) {
 	timestamp := time.Now().UnixNano()
 	val := NewCounterValue(-10, timestamp, "replica1")
@@ -396,9 +454,9 @@ func TestFloatCounterPrecision(t *testing.T) {
 	timestamp := time.Now().UnixNano()
 	val := NewFloatCounterValue(0.0, timestamp, "replica1")
 
-	// Add 0.1 ten times
+	// Add 0.1 from ten replicas
 	for i := 0; i < 10; i++ {
-		other := NewFloatCounterValue(0.1, timestamp+int64(i+1), "replica1")
+		other := NewFloatCounterValue(0.1, timestamp+int64(i+1), fmt.Sprintf("replica%d", i+2))
 		val.Merge(other)
 	}
 
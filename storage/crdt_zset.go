@@ -1,7 +1,6 @@
 package storage
 
 import (
-	"encoding/json"
 	"fmt"
 	"sort"
 	"time"
@@ -49,8 +48,12 @@ func (e *ZSetElement) EffectiveScore() float64 {
 // ZAdd adds one or more members with scores to the sorted set
 // Returns the number of elements that were added (not updated)
 func (zs *CRDTZSet) ZAdd(memberScores map[string]float64, vc *VectorClock) int {
+	return zs.ZAddAt(memberScores, generateTimestamp(), vc)
+}
+
+// ZAddAt is ZAdd stamped with the given timestamp instead of the current time
+func (zs *CRDTZSet) ZAddAt(memberScores map[string]float64, timestamp int64, vc *VectorClock) int {
 	added := 0
-	timestamp := generateTimestamp()
 
 	if vc == nil {
 		vc = NewVectorClock()
@@ -416,7 +419,7 @@ func (zs *CRDTZSet) GC(cutoffTimestamp int64) int {
 // NewZSetValue creates a new Value containing a CRDT sorted set
 func NewZSetValue(replicaID string, vc *VectorClock) *Value {
 	zset := NewCRDTZSet(replicaID)
-	data, _ := json.Marshal(zset)
+	data, _ := zset.MarshalBinary()
 
 	if vc == nil {
 		vc = NewVectorClock()
@@ -439,7 +442,7 @@ func (v *Value) GetZSet() (*CRDTZSet, error) {
 	}
 
 	var zset CRDTZSet
-	err := json.Unmarshal(v.Data, &zset)
+	err := zset.UnmarshalBinary(v.Data)
 	if err != nil {
 		return nil, fmt.Errorf("failed to unmarshal sorted set: %v", err)
 	}
@@ -453,7 +456,7 @@ func (v *Value) SetZSet(zset *CRDTZSet) error {
 		return fmt.Errorf("value is not a sorted set")
 	}
 
-	data, err := json.Marshal(zset)
+	data, err := zset.MarshalBinary()
 	if err != nil {
 		return fmt.Errorf("failed to marshal sorted set: %v", err)
 	}
@@ -0,0 +1,956 @@
+package storage
+
+import (
+	"bufio"
+	"container/list"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	defaultEngineCacheSize    = 64 << 20
+	defaultEngineMemtableSize = 4 << 20
+	tableBlockSize            = 4 << 10 // bytes of records between sparse index entries
+	maxTables                 = 4       // tables before they are merged into one
+	tableMagic                = 0x63726474626c3031
+	tableFooterSize           = 24
+)
+
+var errCorruptRecord = errors.New("corrupt record")
+
+// diskEngine is a log-structured merge tree. Writes are appended to a
+// write-ahead log and buffered in a memtable, which is written out as an
+// immutable sorted table once it grows past memtableSize. When there are
+// more than maxTables tables they are merged into one in the background.
+// Decoded values of hot keys are kept in a bounded LRU cache, so memory use
+// does not grow with the dataset, and opening the engine only reads the
+// manifest, the table footers and the write-ahead log.
+//
+// The write-ahead log is synced by Sync, which the store calls as its
+// appendfsync policy requires, as well as when the memtable is flushed and
+// on close.
+type diskEngine struct {
+	mu           sync.Mutex
+	dir          string
+	wal          *os.File
+	written      int64             // WAL records appended since open
+	synced       int64             // WAL records known to be on disk
+	fsyncs       int64             // WAL fsyncs since open
+	syncMu       sync.Mutex        // serializes fsyncs, so writers waiting on one share the next
+	mem          map[string][]byte // encoded values; nil marks a deleted key
+	memSize      int64
+	memtableSize int64
+	tables       []*sstable // oldest first
+	nextSeq      int64
+	gen          int64 // bumped on every write, so a Get racing a write does not cache a stale value
+	cache        *valueCache
+	compacting   bool
+	compactions  sync.WaitGroup
+	closed       bool
+}
+
+// engineManifest lists the live tables
+type engineManifest struct {
+	Tables  []string `json:"tables"`
+	NextSeq int64    `json:"next_seq"`
+}
+
+func openDiskEngine(dir string, cfg EngineConfig) (*diskEngine, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create engine directory: %v", err)
+	}
+	e := &diskEngine{
+		dir:          dir,
+		mem:          make(map[string][]byte),
+		memtableSize: cfg.MemtableSize,
+		cache:        newValueCache(cfg.CacheSize),
+	}
+	if e.memtableSize <= 0 {
+		e.memtableSize = defaultEngineMemtableSize
+	}
+
+	var manifest engineManifest
+	data, err := os.ReadFile(filepath.Join(dir, "MANIFEST"))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read engine manifest: %v", err)
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, &manifest); err != nil {
+			return nil, fmt.Errorf("failed to parse engine manifest: %v", err)
+		}
+	}
+	e.nextSeq = manifest.NextSeq
+	live := make(map[string]bool)
+	for _, name := range manifest.Tables {
+		t, err := openTable(filepath.Join(dir, name))
+		if err != nil {
+			e.closeTables()
+			return nil, err
+		}
+		e.tables = append(e.tables, t)
+		live[name] = true
+	}
+
+	// Remove tables left behind by a flush or compaction that did not finish
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		e.closeTables()
+		return nil, err
+	}
+	for _, f := range files {
+		if strings.HasSuffix(f.Name(), ".sst") && !live[f.Name()] || strings.HasSuffix(f.Name(), ".tmp") {
+			os.Remove(filepath.Join(dir, f.Name()))
+		}
+	}
+
+	if err := e.replayWAL(); err != nil {
+		e.closeTables()
+		return nil, err
+	}
+	e.wal, err = os.OpenFile(e.walPath(), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		e.closeTables()
+		return nil, fmt.Errorf("failed to open engine WAL: %v", err)
+	}
+	return e, nil
+}
+
+func (e *diskEngine) walPath() string {
+	return filepath.Join(e.dir, "wal.log")
+}
+
+// replayWAL loads writes that were not flushed to a table into the memtable.
+// A torn record at the end of the log, from a crash mid-write, is dropped.
+func (e *diskEngine) replayWAL() error {
+	f, err := os.Open(e.walPath())
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open engine WAL: %v", err)
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	var valid int64
+	for {
+		key, value, n, err := readRecord(r)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			log.Printf("Disk engine: dropping WAL tail after %d bytes: %v", valid, err)
+			return os.Truncate(e.walPath(), valid)
+		}
+		valid += int64(n)
+		e.mem[key] = value
+		e.memSize += int64(len(key) + len(value))
+	}
+}
+
+func (e *diskEngine) Get(key string) (*Value, bool) {
+	e.mu.Lock()
+	if v, ok := e.cache.get(key); ok {
+		e.mu.Unlock()
+		return v, true
+	}
+	if data, ok := e.mem[key]; ok {
+		defer e.mu.Unlock()
+		if data == nil {
+			return nil, false
+		}
+		v, err := decodeValue(data)
+		if err != nil {
+			log.Printf("Disk engine: failed to decode %s: %v", key, err)
+			return nil, false
+		}
+		e.cache.add(key, v)
+		return v, true
+	}
+	gen := e.gen
+	tables := e.refTables()
+	e.mu.Unlock()
+	defer e.release(tables)
+
+	// Tables are read without the lock; newer tables shadow older ones
+	for i := len(tables) - 1; i >= 0; i-- {
+		data, found, err := tables[i].get(key)
+		if err != nil {
+			log.Printf("Disk engine: failed to read %s from %s: %v", key, tables[i].path, err)
+			return nil, false
+		}
+		if !found {
+			continue
+		}
+		if data == nil {
+			return nil, false
+		}
+		v, err := decodeValue(data)
+		if err != nil {
+			log.Printf("Disk engine: failed to decode %s: %v", key, err)
+			return nil, false
+		}
+		e.mu.Lock()
+		if e.gen == gen {
+			e.cache.add(key, v)
+		} else if cached, ok := e.cache.get(key); ok {
+			v = cached
+		}
+		e.mu.Unlock()
+		return v, true
+	}
+	return nil, false
+}
+
+func (e *diskEngine) Put(key string, value *Value) error {
+	data, err := encodeValue(value)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s: %v", key, err)
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if err := e.write(key, data); err != nil {
+		return err
+	}
+	e.cache.add(key, value)
+	return e.maybeFlush()
+}
+
+func (e *diskEngine) Delete(key string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if err := e.write(key, nil); err != nil {
+		return err
+	}
+	e.cache.remove(key)
+	return e.maybeFlush()
+}
+
+// write appends a record to the WAL and the memtable; callers must hold e.mu
+func (e *diskEngine) write(key string, data []byte) error {
+	if e.closed {
+		return fmt.Errorf("disk engine is closed")
+	}
+	if _, err := e.wal.Write(appendRecord(nil, key, data)); err != nil {
+		return fmt.Errorf("failed to write engine WAL: %v", err)
+	}
+	e.mem[key] = data
+	e.memSize += int64(len(key) + len(data))
+	e.gen++
+	e.written++
+	return nil
+}
+
+// Sync waits until every write so far is on disk. Like SegmentManager.SyncTo,
+// writers that call it while an fsync is running share the next one.
+func (e *diskEngine) Sync() error {
+	e.syncMu.Lock()
+	defer e.syncMu.Unlock()
+
+	e.mu.Lock()
+	if e.closed || e.synced >= e.written {
+		e.mu.Unlock()
+		return nil
+	}
+	wal, target := e.wal, e.written
+	e.mu.Unlock()
+
+	err := wal.Sync()
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if err != nil && e.synced >= target {
+		err = nil // flushed meanwhile, which made the writes durable in a table
+	}
+	if err != nil {
+		return fmt.Errorf("failed to sync engine WAL: %v", err)
+	}
+	if target > e.synced {
+		e.synced = target
+	}
+	e.fsyncs++
+	return nil
+}
+
+// maybeFlush writes the memtable to a new table once it is full; callers
+// must hold e.mu
+func (e *diskEngine) maybeFlush() error {
+	if e.memSize < e.memtableSize {
+		return nil
+	}
+	return e.flush()
+}
+
+// flush writes the memtable to a new table, records it in the manifest and
+// truncates the WAL; callers must hold e.mu
+func (e *diskEngine) flush() error {
+	if len(e.mem) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(e.mem))
+	for key := range e.mem {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	name := fmt.Sprintf("table-%06d.sst", e.nextSeq)
+	e.nextSeq++
+	i := 0
+	t, err := writeTable(filepath.Join(e.dir, name), func() (string, []byte, bool) {
+		if i == len(keys) {
+			return "", nil, false
+		}
+		key := keys[i]
+		i++
+		return key, e.mem[key], true
+	})
+	if err != nil {
+		return err
+	}
+	e.tables = append(e.tables, t)
+	if err := e.writeManifest(); err != nil {
+		return err
+	}
+
+	if err := e.wal.Close(); err != nil {
+		return fmt.Errorf("failed to close engine WAL: %v", err)
+	}
+	e.wal, err = os.OpenFile(e.walPath(), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to reset engine WAL: %v", err)
+	}
+	e.mem = make(map[string][]byte)
+	e.memSize = 0
+	e.synced = e.written
+
+	if len(e.tables) > maxTables && !e.compacting {
+		e.compacting = true
+		e.compactions.Add(1)
+		go e.compact()
+	}
+	return nil
+}
+
+// writeManifest atomically replaces the manifest; callers must hold e.mu
+func (e *diskEngine) writeManifest() error {
+	manifest := engineManifest{NextSeq: e.nextSeq}
+	for _, t := range e.tables {
+		manifest.Tables = append(manifest.Tables, filepath.Base(t.path))
+	}
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(e.dir, "MANIFEST")
+	if err := writeFileSync(path+".tmp", data); err != nil {
+		return fmt.Errorf("failed to write engine manifest: %v", err)
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+// compact merges every table that existed when it started into one, dropping
+// deleted keys since no older table can hold them
+func (e *diskEngine) compact() {
+	defer e.compactions.Done()
+
+	e.mu.Lock()
+	tables := e.refTables()
+	name := fmt.Sprintf("table-%06d.sst", e.nextSeq)
+	e.nextSeq++
+	e.mu.Unlock()
+
+	it := newMergeIterator(nil, tables, "")
+	merged, err := writeTable(filepath.Join(e.dir, name), func() (string, []byte, bool) {
+		for it.next() {
+			if it.value != nil {
+				return it.key, it.value, true
+			}
+		}
+		return "", nil, false
+	})
+	if err == nil {
+		err = it.err
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.compacting = false
+	if err != nil {
+		log.Printf("Disk engine: compaction failed: %v", err)
+		if merged != nil {
+			merged.file.Close()
+			os.Remove(merged.path)
+		}
+		e.unrefTables(tables)
+		return
+	}
+	// Flushes only append tables, so the merged ones are still the oldest
+	e.tables = append([]*sstable{merged}, e.tables[len(tables):]...)
+	if err := e.writeManifest(); err != nil {
+		log.Printf("Disk engine: failed to record compaction: %v", err)
+	}
+	for _, t := range tables {
+		t.obsolete = true
+		t.refs-- // the engine's own reference
+	}
+	e.unrefTables(tables)
+}
+
+func (e *diskEngine) ForEach(fn func(key string, value *Value) bool) error {
+	return e.Range("", fn)
+}
+
+func (e *diskEngine) Range(start string, fn func(key string, value *Value) bool) error {
+	snap, err := e.Snapshot()
+	if err != nil {
+		return err
+	}
+	defer snap.Release()
+	return snap.Range(start, fn)
+}
+
+// Snapshot copies the memtable and holds a reference to the current tables,
+// which are immutable, so it costs at most one memtable of memory
+func (e *diskEngine) Snapshot() (EngineSnapshot, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.closed {
+		return nil, fmt.Errorf("disk engine is closed")
+	}
+	mem := make([]record, 0, len(e.mem))
+	for key, data := range e.mem {
+		mem = append(mem, record{key: key, value: data})
+	}
+	sort.Slice(mem, func(i, j int) bool { return mem[i].key < mem[j].key })
+	return &diskSnapshot{engine: e, mem: mem, tables: e.refTables()}, nil
+}
+
+func (e *diskEngine) Resident() bool {
+	return false
+}
+
+// CacheBytes returns the approximate memory held by cached values
+func (e *diskEngine) CacheBytes() int64 {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.cache.size
+}
+
+func (e *diskEngine) Close() error {
+	e.mu.Lock()
+	if e.closed {
+		e.mu.Unlock()
+		return nil
+	}
+	e.closed = true
+	e.mu.Unlock()
+	e.compactions.Wait()
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	err := e.wal.Sync()
+	if cerr := e.wal.Close(); err == nil {
+		err = cerr
+	}
+	e.closeTables()
+	return err
+}
+
+func (e *diskEngine) closeTables() {
+	for _, t := range e.tables {
+		t.file.Close()
+	}
+}
+
+// refTables returns the live tables with a reference held on each; callers
+// must hold e.mu
+func (e *diskEngine) refTables() []*sstable {
+	tables := append([]*sstable(nil), e.tables...)
+	for _, t := range tables {
+		t.refs++
+	}
+	return tables
+}
+
+// release drops references taken by refTables
+func (e *diskEngine) release(tables []*sstable) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.unrefTables(tables)
+}
+
+// unrefTables drops references taken by refTables and removes merged tables
+// nobody reads any more; callers must hold e.mu
+func (e *diskEngine) unrefTables(tables []*sstable) {
+	for _, t := range tables {
+		t.refs--
+		if t.refs == 0 && t.obsolete {
+			t.file.Close()
+			os.Remove(t.path)
+		}
+	}
+}
+
+// diskSnapshot is a consistent view of a diskEngine
+type diskSnapshot struct {
+	engine *diskEngine
+	mem    []record
+	tables []*sstable
+}
+
+func (s *diskSnapshot) Range(start string, fn func(key string, value *Value) bool) error {
+	i := sort.Search(len(s.mem), func(i int) bool { return s.mem[i].key >= start })
+	it := newMergeIterator(s.mem[i:], s.tables, start)
+	for it.next() {
+		if it.value == nil {
+			continue
+		}
+		v, err := decodeValue(it.value)
+		if err != nil {
+			return fmt.Errorf("failed to decode %s: %v", it.key, err)
+		}
+		if !fn(it.key, v) {
+			return nil
+		}
+	}
+	return it.err
+}
+
+func (s *diskSnapshot) Release() {
+	if s.tables == nil {
+		return
+	}
+	s.engine.release(s.tables)
+	s.tables = nil
+}
+
+// record is a key and its encoded value, nil for a deleted key
+type record struct {
+	key   string
+	value []byte
+}
+
+// appendRecord encodes a record as a CRC32 of the rest, a deleted flag, the
+// key and value lengths as uvarints, the key and the value
+func appendRecord(buf []byte, key string, value []byte) []byte {
+	start := len(buf)
+	buf = append(buf, 0, 0, 0, 0)
+	if value == nil {
+		buf = append(buf, 1)
+	} else {
+		buf = append(buf, 0)
+	}
+	buf = binary.AppendUvarint(buf, uint64(len(key)))
+	buf = binary.AppendUvarint(buf, uint64(len(value)))
+	buf = append(buf, key...)
+	buf = append(buf, value...)
+	binary.BigEndian.PutUint32(buf[start:], crc32.ChecksumIEEE(buf[start+4:]))
+	return buf
+}
+
+// readRecord decodes a record written by appendRecord and returns its size
+func readRecord(r *bufio.Reader) (string, []byte, int, error) {
+	var head [5]byte
+	if _, err := io.ReadFull(r, head[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return "", nil, 0, errCorruptRecord
+		}
+		return "", nil, 0, err
+	}
+	var lens []byte
+	keyLen, err := binary.ReadUvarint(byteRecorder{r, &lens})
+	if err != nil {
+		return "", nil, 0, errCorruptRecord
+	}
+	valueLen, err := binary.ReadUvarint(byteRecorder{r, &lens})
+	if err != nil || keyLen > 1<<30 || valueLen > 1<<30 {
+		return "", nil, 0, errCorruptRecord
+	}
+	body := make([]byte, keyLen+valueLen)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return "", nil, 0, errCorruptRecord
+	}
+	crc := crc32.NewIEEE()
+	crc.Write(head[4:])
+	crc.Write(lens)
+	crc.Write(body)
+	if crc.Sum32() != binary.BigEndian.Uint32(head[:4]) {
+		return "", nil, 0, errCorruptRecord
+	}
+	key := string(body[:keyLen])
+	var value []byte
+	if head[4] == 0 {
+		value = body[keyLen:]
+	}
+	return key, value, len(head) + len(lens) + len(body), nil
+}
+
+// byteRecorder keeps the bytes read through it, for checksumming
+type byteRecorder struct {
+	r   io.ByteReader
+	buf *[]byte
+}
+
+func (b byteRecorder) ReadByte() (byte, error) {
+	c, err := b.r.ReadByte()
+	if err == nil {
+		*b.buf = append(*b.buf, c)
+	}
+	return c, err
+}
+
+// sstable is an immutable sorted table: records in key order, a sparse index
+// with the first key of every block, and a footer with the index offset,
+// record count and magic number
+type sstable struct {
+	path      string
+	file      *os.File
+	dataSize  int64 // records end where the index starts
+	count     int64
+	indexOnce sync.Once
+	index     []indexEntry
+	indexErr  error
+	refs      int  // guarded by the engine lock
+	obsolete  bool // merged into another table; removed once refs drops to 0
+}
+
+type indexEntry struct {
+	key    string
+	offset int64
+}
+
+// writeTable writes the records returned by next, which must be in key
+// order, to a new table at path
+func writeTable(path string, next func() (string, []byte, bool)) (*sstable, error) {
+	f, err := os.Create(path + ".tmp")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create table: %v", err)
+	}
+	w := bufio.NewWriter(f)
+	var index []byte
+	var offset, blockStart, count int64
+	var buf []byte
+	for {
+		key, value, ok := next()
+		if !ok {
+			break
+		}
+		if count == 0 || offset-blockStart >= tableBlockSize {
+			index = appendRecord(index, key, binary.AppendUvarint(nil, uint64(offset)))
+			blockStart = offset
+		}
+		buf = appendRecord(buf[:0], key, value)
+		if _, err := w.Write(buf); err != nil {
+			f.Close()
+			return nil, fmt.Errorf("failed to write table: %v", err)
+		}
+		offset += int64(len(buf))
+		count++
+	}
+	w.Write(index)
+	var footer [tableFooterSize]byte
+	binary.BigEndian.PutUint64(footer[0:], uint64(offset))
+	binary.BigEndian.PutUint64(footer[8:], uint64(count))
+	binary.BigEndian.PutUint64(footer[16:], tableMagic)
+	w.Write(footer[:])
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to write table: %v", err)
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to sync table: %v", err)
+	}
+	f.Close()
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return nil, err
+	}
+	return openTable(path)
+}
+
+// openTable opens a table and reads its footer; the index is read on first use
+func openTable(path string) (*sstable, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open table: %v", err)
+	}
+	stat, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	var footer [tableFooterSize]byte
+	if stat.Size() < tableFooterSize {
+		f.Close()
+		return nil, fmt.Errorf("table %s is truncated", path)
+	}
+	if _, err := f.ReadAt(footer[:], stat.Size()-tableFooterSize); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to read table footer: %v", err)
+	}
+	if binary.BigEndian.Uint64(footer[16:]) != tableMagic {
+		f.Close()
+		return nil, fmt.Errorf("table %s has a bad footer", path)
+	}
+	return &sstable{
+		path:     path,
+		file:     f,
+		dataSize: int64(binary.BigEndian.Uint64(footer[0:])),
+		count:    int64(binary.BigEndian.Uint64(footer[8:])),
+		refs:     1,
+	}, nil
+}
+
+func (t *sstable) loadIndex() error {
+	t.indexOnce.Do(func() {
+		stat, err := t.file.Stat()
+		if err != nil {
+			t.indexErr = err
+			return
+		}
+		r := bufio.NewReader(io.NewSectionReader(t.file, t.dataSize, stat.Size()-tableFooterSize-t.dataSize))
+		for {
+			key, value, _, err := readRecord(r)
+			if err == io.EOF {
+				return
+			}
+			if err != nil {
+				t.indexErr = fmt.Errorf("failed to read table index: %v", err)
+				return
+			}
+			offset, n := binary.Uvarint(value)
+			if n <= 0 {
+				t.indexErr = fmt.Errorf("failed to read table index: %v", errCorruptRecord)
+				return
+			}
+			t.index = append(t.index, indexEntry{key: key, offset: int64(offset)})
+		}
+	})
+	return t.indexErr
+}
+
+// block returns the index of the block that would hold key, or -1
+func (t *sstable) block(key string) int {
+	return sort.Search(len(t.index), func(i int) bool { return t.index[i].key > key }) - 1
+}
+
+// get returns the encoded value of key; found with a nil value means the key
+// was deleted
+func (t *sstable) get(key string) (value []byte, found bool, err error) {
+	if err := t.loadIndex(); err != nil {
+		return nil, false, err
+	}
+	i := t.block(key)
+	if i < 0 {
+		return nil, false, nil
+	}
+	end := t.dataSize
+	if i+1 < len(t.index) {
+		end = t.index[i+1].offset
+	}
+	r := bufio.NewReader(io.NewSectionReader(t.file, t.index[i].offset, end-t.index[i].offset))
+	for {
+		k, v, _, err := readRecord(r)
+		if err == io.EOF {
+			return nil, false, nil
+		}
+		if err != nil {
+			return nil, false, err
+		}
+		if k == key {
+			return v, true, nil
+		}
+		if k > key {
+			return nil, false, nil
+		}
+	}
+}
+
+// tableIterator reads a table's records in order from a start key
+type tableIterator struct {
+	r     *bufio.Reader
+	start string
+	cur   record
+	err   error
+}
+
+func (t *sstable) iterator(start string) *tableIterator {
+	it := &tableIterator{start: start}
+	if it.err = t.loadIndex(); it.err != nil {
+		return it
+	}
+	var offset int64
+	if i := t.block(start); i >= 0 {
+		offset = t.index[i].offset
+	}
+	it.r = bufio.NewReader(io.NewSectionReader(t.file, offset, t.dataSize-offset))
+	return it
+}
+
+func (it *tableIterator) next() bool {
+	if it.err != nil || it.r == nil {
+		return false
+	}
+	for {
+		key, value, _, err := readRecord(it.r)
+		if err == io.EOF {
+			it.r = nil
+			return false
+		}
+		if err != nil {
+			it.err = err
+			return false
+		}
+		if key >= it.start {
+			it.cur = record{key: key, value: value}
+			return true
+		}
+	}
+}
+
+// mergeIterator merges the memtable and tables in key order; for a key in
+// several sources the newest wins
+type mergeIterator struct {
+	sources []*tableIterator // newest first; nil for the memtable
+	mem     []record
+	heads   []*record
+	key     string
+	value   []byte
+	err     error
+}
+
+func newMergeIterator(mem []record, tables []*sstable, start string) *mergeIterator {
+	it := &mergeIterator{mem: mem}
+	// Source 0 is the memtable, then tables newest first
+	it.sources = append(it.sources, nil)
+	for i := len(tables) - 1; i >= 0; i-- {
+		it.sources = append(it.sources, tables[i].iterator(start))
+	}
+	it.heads = make([]*record, len(it.sources))
+	for i := range it.sources {
+		it.advance(i)
+	}
+	return it
+}
+
+// advance moves source i to its next record
+func (it *mergeIterator) advance(i int) {
+	if i == 0 {
+		if len(it.mem) == 0 {
+			it.heads[0] = nil
+			return
+		}
+		it.heads[0] = &it.mem[0]
+		it.mem = it.mem[1:]
+		return
+	}
+	src := it.sources[i]
+	if src.next() {
+		cur := src.cur
+		it.heads[i] = &cur
+		return
+	}
+	if src.err != nil && it.err == nil {
+		it.err = src.err
+	}
+	it.heads[i] = nil
+}
+
+func (it *mergeIterator) next() bool {
+	if it.err != nil {
+		return false
+	}
+	winner := -1
+	for i, head := range it.heads {
+		if head != nil && (winner < 0 || head.key < it.heads[winner].key) {
+			winner = i
+		}
+	}
+	if winner < 0 {
+		return false
+	}
+	it.key = it.heads[winner].key
+	it.value = it.heads[winner].value
+	for i, head := range it.heads {
+		if head != nil && head.key == it.key {
+			it.advance(i)
+		}
+	}
+	return it.err == nil
+}
+
+// valueCache is an LRU cache of decoded values bounded by their approximate
+// size; it is guarded by the engine lock
+type valueCache struct {
+	maxBytes int64
+	size     int64
+	ll       *list.List
+	items    map[string]*list.Element
+}
+
+type cacheEntry struct {
+	key   string
+	value *Value
+	size  int64
+}
+
+func newValueCache(maxBytes int64) *valueCache {
+	if maxBytes <= 0 {
+		maxBytes = defaultEngineCacheSize
+	}
+	return &valueCache{maxBytes: maxBytes, ll: list.New(), items: make(map[string]*list.Element)}
+}
+
+func (c *valueCache) get(key string) (*Value, bool) {
+	el, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	c.ll.MoveToFront(el)
+	return el.Value.(*cacheEntry).value, true
+}
+
+func (c *valueCache) add(key string, value *Value) {
+	c.remove(key)
+	entry := &cacheEntry{key: key, value: value, size: valueSize(key, value)}
+	c.items[key] = c.ll.PushFront(entry)
+	c.size += entry.size
+	for c.size > c.maxBytes && c.ll.Len() > 1 {
+		c.remove(c.ll.Back().Value.(*cacheEntry).key)
+	}
+}
+
+func (c *valueCache) remove(key string) {
+	if el, ok := c.items[key]; ok {
+		c.size -= el.Value.(*cacheEntry).size
+		c.ll.Remove(el)
+		delete(c.items, key)
+	}
+}
+
+// writeFileSync writes data to path and syncs it
+func writeFileSync(path string, data []byte) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,285 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func openTestDiskEngine(t *testing.T, dir string, memtableSize int64) *diskEngine {
+	t.Helper()
+	e, err := openDiskEngine(dir, EngineConfig{Mode: EngineDisk, CacheSize: 1 << 10, MemtableSize: memtableSize})
+	if err != nil {
+		t.Fatalf("openDiskEngine failed: %v", err)
+	}
+	return e
+}
+
+func rangeKeys(t *testing.T, fn func(start string, fn func(string, *Value) bool) error, start string) []string {
+	t.Helper()
+	var keys []string
+	if err := fn(start, func(key string, _ *Value) bool {
+		keys = append(keys, key)
+		return true
+	}); err != nil {
+		t.Fatalf("Range failed: %v", err)
+	}
+	return keys
+}
+
+func TestDiskEngineReopen(t *testing.T) {
+	dir := t.TempDir()
+	e := openTestDiskEngine(t, dir, 0)
+	e.Put("a", NewStringValue("1", 1, "r1"))
+	e.Put("b", NewStringValue("2", 1, "r1"))
+	e.Delete("a")
+	if err := e.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	// Nothing was flushed, so this replays the WAL
+	e = openTestDiskEngine(t, dir, 0)
+	defer e.Close()
+	if _, ok := e.Get("a"); ok {
+		t.Error("deleted key a is back after reopen")
+	}
+	if v, ok := e.Get("b"); !ok || v.String() != "2" {
+		t.Errorf("b = %v %v after reopen, want 2", v, ok)
+	}
+}
+
+func TestDiskEngineFlushAndCompact(t *testing.T) {
+	dir := t.TempDir()
+	e := openTestDiskEngine(t, dir, 512)
+	for i := 0; i < 500; i++ {
+		key := fmt.Sprintf("key%03d", i%200)
+		if err := e.Put(key, NewStringValue(fmt.Sprint(i), int64(i), "r1")); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+	}
+	for i := 0; i < 200; i += 2 {
+		e.Delete(fmt.Sprintf("key%03d", i))
+	}
+	if err := e.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	e = openTestDiskEngine(t, dir, 512)
+	defer e.Close()
+	if len(e.tables) == 0 || len(e.tables) > maxTables+1 {
+		t.Errorf("%d tables after compaction", len(e.tables))
+	}
+	keys := rangeKeys(t, e.Range, "")
+	if len(keys) != 100 {
+		t.Fatalf("got %d keys, want 100", len(keys))
+	}
+	for i, key := range keys {
+		if want := fmt.Sprintf("key%03d", 2*i+1); key != want {
+			t.Fatalf("keys[%d] = %s, want %s", i, key, want)
+		}
+	}
+	// The last write to key199 was i=399
+	if v, ok := e.Get("key199"); !ok || v.String() != "399" {
+		t.Errorf("key199 = %v %v, want 399", v, ok)
+	}
+	if keys := rangeKeys(t, e.Range, "key190"); len(keys) != 5 || keys[0] != "key191" {
+		t.Errorf("range from key190 = %v", keys)
+	}
+
+	// Only live tables remain on disk
+	files, _ := filepath.Glob(filepath.Join(dir, "*.sst"))
+	if len(files) != len(e.tables) {
+		t.Errorf("%d table files for %d live tables", len(files), len(e.tables))
+	}
+}
+
+func TestDiskEngineSnapshot(t *testing.T) {
+	e := openTestDiskEngine(t, t.TempDir(), 256)
+	defer e.Close()
+	for i := 0; i < 20; i++ {
+		e.Put(fmt.Sprintf("k%02d", i), NewStringValue("old", 1, "r1"))
+	}
+
+	snap, err := e.Snapshot()
+	if err != nil {
+		t.Fatalf("Snapshot failed: %v", err)
+	}
+	defer snap.Release()
+	for i := 0; i < 20; i++ {
+		e.Put(fmt.Sprintf("k%02d", i), NewStringValue("new", 2, "r1"))
+	}
+	e.Delete("k00")
+	e.Put("k99", NewStringValue("new", 2, "r1"))
+
+	n := 0
+	snap.Range("", func(key string, v *Value) bool {
+		if v.String() != "old" {
+			t.Errorf("snapshot sees %s = %s", key, v.String())
+		}
+		n++
+		return true
+	})
+	if n != 20 {
+		t.Errorf("snapshot has %d keys, want 20", n)
+	}
+}
+
+func TestDiskEngineTornWAL(t *testing.T) {
+	dir := t.TempDir()
+	e := openTestDiskEngine(t, dir, 0)
+	e.Put("a", NewStringValue("1", 1, "r1"))
+	e.Put("b", NewStringValue("2", 1, "r1"))
+	e.Close()
+
+	// Cut the last record short, as a crash mid-write would
+	wal := filepath.Join(dir, "wal.log")
+	info, _ := os.Stat(wal)
+	os.Truncate(wal, info.Size()-3)
+
+	e = openTestDiskEngine(t, dir, 0)
+	defer e.Close()
+	if _, ok := e.Get("a"); !ok {
+		t.Error("a was lost with the torn tail")
+	}
+	if _, ok := e.Get("b"); ok {
+		t.Error("torn record b was loaded")
+	}
+	e.Put("c", NewStringValue("3", 1, "r1"))
+	e.Close()
+
+	// Writes after the truncated tail replay cleanly
+	e = openTestDiskEngine(t, dir, 0)
+	if _, ok := e.Get("c"); !ok {
+		t.Error("c was lost after the WAL was repaired")
+	}
+}
+
+func TestStoreDiskEngine(t *testing.T) {
+	dir := t.TempDir()
+	open := func() *Store {
+		store, err := NewStoreWithOptions(dir, StoreOptions{Engine: EngineConfig{Mode: EngineDisk, CacheSize: 4 << 10, MemtableSize: 4 << 10}})
+		if err != nil {
+			t.Fatalf("NewStoreWithOptions failed: %v", err)
+		}
+		return store
+	}
+
+	store := open()
+	for i := 0; i < 300; i++ {
+		store.Set(fmt.Sprintf("s%03d", i), NewStringValue(fmt.Sprint(i), time.Now().UnixNano(), "r1"), nil)
+	}
+	store.HSet("h", "f", "v")
+	store.SAdd("set", []string{"a", "b"})
+	store.ZAdd("z", map[string]float64{"m": 1})
+	ttl := int64(1)
+	store.Set("gone", NewStringValue("x", time.Now().UnixNano(), "r1"), &ttl)
+	if used := store.UsedMemory(); used > 8<<10 {
+		t.Errorf("cache holds %d bytes, over its bound", used)
+	}
+	store.Close()
+
+	store = open()
+	defer store.Close()
+	if v, ok := store.Get("s123"); !ok || v.String() != "123" {
+		t.Errorf("s123 = %v %v after reopen", v, ok)
+	}
+	if v, _, _ := store.HGet("h", "f"); v != "v" {
+		t.Errorf("HGET h f = %q after reopen", v)
+	}
+	if n, _ := store.SCard("set"); n != 2 {
+		t.Errorf("SCARD set = %d after reopen", n)
+	}
+	if n, _ := store.ZCard("z"); n != 1 {
+		t.Errorf("ZCARD z = %d after reopen", n)
+	}
+
+	// Scan pages through every live key in order
+	time.Sleep(1100 * time.Millisecond)
+	var all []string
+	cursor := ""
+	for {
+		keys, next, err := store.Scan(cursor, 64)
+		if err != nil {
+			t.Fatalf("Scan failed: %v", err)
+		}
+		all = append(all, keys...)
+		if next == "" {
+			break
+		}
+		cursor = next
+	}
+	if len(all) != 303 {
+		t.Errorf("scan returned %d keys, want 303", len(all))
+	}
+	for i := 1; i < len(all); i++ {
+		if all[i-1] >= all[i] {
+			t.Fatalf("scan out of order at %s, %s", all[i-1], all[i])
+		}
+	}
+}
+
+func TestStoreMigratesToDiskEngine(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewStore(dir, "", 0)
+	if err != nil {
+		t.Fatalf("NewStore failed: %v", err)
+	}
+	store.Set("k", NewStringValue("v", 1, "r1"), nil)
+	store.Close()
+
+	// store.json from the memory engine is imported into the new disk engine
+	store, err = NewStoreWithOptions(dir, StoreOptions{Engine: EngineConfig{Mode: EngineDisk}})
+	if err != nil {
+		t.Fatalf("NewStoreWithOptions failed: %v", err)
+	}
+	defer store.Close()
+	if v, ok := store.Get("k"); !ok || v.String() != "v" {
+		t.Errorf("k = %v %v after switching engines", v, ok)
+	}
+}
+
+func TestDiskEngineFollowsSyncPolicy(t *testing.T) {
+	store, err := NewStoreWithOptions(t.TempDir(), StoreOptions{Engine: EngineConfig{Mode: EngineDisk}})
+	if err != nil {
+		t.Fatalf("NewStoreWithOptions failed: %v", err)
+	}
+	defer store.Close()
+	engine := store.engine.(*diskEngine)
+	durable := func() (synced, written int64) {
+		engine.mu.Lock()
+		defer engine.mu.Unlock()
+		return engine.synced, engine.written
+	}
+
+	if err := store.SetSyncPolicy(SyncNo); err != nil {
+		t.Fatalf("SetSyncPolicy failed: %v", err)
+	}
+	store.Set("a", NewStringValue("1", 1, "r1"), nil)
+	if synced, written := durable(); synced != 0 || written != 1 {
+		t.Errorf("appendfsync no: %d of %d writes synced, want 0 of 1", synced, written)
+	}
+
+	// Under always a write returns once the engine WAL holds it on disk
+	if err := store.SetSyncPolicy(SyncAlways); err != nil {
+		t.Fatalf("SetSyncPolicy failed: %v", err)
+	}
+	store.Set("b", NewStringValue("2", 1, "r1"), nil)
+	if synced, written := durable(); synced != written {
+		t.Errorf("appendfsync always: %d of %d writes synced", synced, written)
+	}
+
+	// Under everysec the background pass syncs it
+	if err := store.SetSyncPolicy(SyncEverySec); err != nil {
+		t.Fatalf("SetSyncPolicy failed: %v", err)
+	}
+	store.Set("c", NewStringValue("3", 1, "r1"), nil)
+	deadline := time.Now().Add(5 * time.Second)
+	for synced, written := durable(); synced != written; synced, written = durable() {
+		if time.Now().After(deadline) {
+			t.Fatalf("appendfsync everysec: %d of %d writes synced after 5s", synced, written)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
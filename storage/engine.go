@@ -0,0 +1,198 @@
+package storage
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Engine modes
+const (
+	EngineMemory = "memory" // every value lives in memory, persisted by the segment log
+	EngineDisk   = "disk"   // values live on disk with a bounded cache of hot keys
+)
+
+// Engine holds the keyspace of a Store. Values returned by Get may be
+// mutated in place by the store, which then calls Put with the same value;
+// the store serializes writes with its own lock.
+type Engine interface {
+	Get(key string) (*Value, bool)
+	Put(key string, value *Value) error
+	Delete(key string) error
+	// ForEach calls fn for every key in no particular order until fn returns
+	// false. fn must not modify the engine.
+	ForEach(fn func(key string, value *Value) bool) error
+	// Range calls fn for keys >= start in key order until fn returns false.
+	// fn must not modify the engine.
+	Range(start string, fn func(key string, value *Value) bool) error
+	// Snapshot returns a point-in-time view that later writes do not change
+	Snapshot() (EngineSnapshot, error)
+	// Resident reports whether every value is held in memory
+	Resident() bool
+	Close() error
+}
+
+// EngineSnapshot is a consistent view of an Engine; Release must be called
+// once it is no longer needed
+type EngineSnapshot interface {
+	Range(start string, fn func(key string, value *Value) bool) error
+	Release()
+}
+
+// EngineConfig selects and configures an engine
+type EngineConfig struct {
+	Mode         string // EngineMemory or EngineDisk; empty means memory
+	CacheSize    int64  // disk engine: bytes of decoded values to cache
+	MemtableSize int64  // disk engine: bytes of writes to buffer before flushing a table
+}
+
+// NewEngine creates the engine for cfg.Mode; dir holds the disk engine files
+func NewEngine(dir string, cfg EngineConfig) (Engine, error) {
+	switch cfg.Mode {
+	case "", EngineMemory:
+		return newMemEngine(), nil
+	case EngineDisk:
+		return openDiskEngine(dir, cfg)
+	default:
+		return nil, fmt.Errorf("invalid storage engine: %s (valid: %s, %s)", cfg.Mode, EngineMemory, EngineDisk)
+	}
+}
+
+// encodeValue serializes a value for the disk engine
+func encodeValue(v *Value) ([]byte, error) {
+	return v.MarshalBinary()
+}
+
+// decodeValue parses a value written by encodeValue, or as JSON by earlier
+// versions
+func decodeValue(data []byte) (*Value, error) {
+	var v Value
+	if err := v.UnmarshalBinary(data); err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+// memEngine keeps the keyspace in a map
+type memEngine struct {
+	items map[string]*Value
+}
+
+func newMemEngine() *memEngine {
+	return &memEngine{items: make(map[string]*Value)}
+}
+
+func (e *memEngine) Get(key string) (*Value, bool) {
+	v, ok := e.items[key]
+	return v, ok
+}
+
+func (e *memEngine) Put(key string, value *Value) error {
+	e.items[key] = value
+	return nil
+}
+
+func (e *memEngine) Delete(key string) error {
+	delete(e.items, key)
+	return nil
+}
+
+func (e *memEngine) ForEach(fn func(key string, value *Value) bool) error {
+	for key, val := range e.items {
+		if !fn(key, val) {
+			break
+		}
+	}
+	return nil
+}
+
+func (e *memEngine) Range(start string, fn func(key string, value *Value) bool) error {
+	keys := make([]string, 0, len(e.items))
+	for key := range e.items {
+		if key >= start {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if !fn(key, e.items[key]) {
+			break
+		}
+	}
+	return nil
+}
+
+// Snapshot copies every value, since the store mutates values in place
+func (e *memEngine) Snapshot() (EngineSnapshot, error) {
+	snap := &memSnapshot{items: make(map[string]*Value, len(e.items))}
+	for key, val := range e.items {
+		snap.items[key] = val.Clone()
+	}
+	return snap, nil
+}
+
+func (e *memEngine) Resident() bool {
+	return true
+}
+
+func (e *memEngine) Close() error {
+	return nil
+}
+
+// memSnapshot is a copy of a memEngine
+type memSnapshot struct {
+	items map[string]*Value
+}
+
+func (s *memSnapshot) Range(start string, fn func(key string, value *Value) bool) error {
+	return (&memEngine{items: s.items}).Range(start, fn)
+}
+
+func (s *memSnapshot) Release() {
+	s.items = nil
+}
+
+// liveEngine is the keyspace as clients see it. A deleted counter leaves its
+// last reset stored under the key, so that it is persisted and replicated
+// like any value; liveEngine hides those tombstones from reads.
+type liveEngine struct {
+	Engine
+}
+
+func (e liveEngine) Get(key string) (*Value, bool) {
+	v, ok := e.Engine.Get(key)
+	if ok && v.counterDeleted() {
+		return nil, false
+	}
+	return v, ok
+}
+
+func (e liveEngine) ForEach(fn func(key string, value *Value) bool) error {
+	return e.Engine.ForEach(skipCounterTombstones(fn))
+}
+
+func (e liveEngine) Range(start string, fn func(key string, value *Value) bool) error {
+	return e.Engine.Range(start, skipCounterTombstones(fn))
+}
+
+func (e liveEngine) Snapshot() (EngineSnapshot, error) {
+	snap, err := e.Engine.Snapshot()
+	if err != nil {
+		return nil, err
+	}
+	return liveSnapshot{snap}, nil
+}
+
+// liveSnapshot is a snapshot of a liveEngine
+type liveSnapshot struct {
+	EngineSnapshot
+}
+
+func (s liveSnapshot) Range(start string, fn func(key string, value *Value) bool) error {
+	return s.EngineSnapshot.Range(start, skipCounterTombstones(fn))
+}
+
+func skipCounterTombstones(fn func(key string, value *Value) bool) func(key string, value *Value) bool {
+	return func(key string, value *Value) bool {
+		return value.counterDeleted() || fn(key, value)
+	}
+}
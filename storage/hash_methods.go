@@ -2,11 +2,13 @@ package storage
 
 import (
 	"fmt"
+	"strconv"
 	"time"
 )
 
 // HSet sets a field in a hash
-func (s *Store) HSet(key, field, value string) (int64, error) {
+func (s *Store) HSet(key, field, value string, opts ...OpOption) (int64, error) {
+	defer s.awaitDurable()
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
@@ -14,16 +16,16 @@ func (s *Store) HSet(key, field, value string) (int64, error) {
 	var hash *CRDTHash
 	var isNewField int64
 
-	if val, exists := s.items[key]; exists && val.Type == TypeHash {
+	val, exists := s.items.Get(key)
+	if exists && val.Type == TypeHash {
 		hash = val.Hash()
 		if hash == nil {
 			return 0, fmt.Errorf("invalid hash data")
 		}
 	} else {
 		// Create new hash
-		newVal := NewHashValue(timestamp, "")
-		hash = newVal.Hash()
-		s.items[key] = newVal
+		val = NewHashValue(timestamp, "")
+		hash = val.Hash()
 	}
 
 	// Check if field is new
@@ -35,17 +37,13 @@ func (s *Store) HSet(key, field, value string) (int64, error) {
 	hash.Set(field, value, timestamp, "")
 
 	// Update the value
-	s.items[key].SetHash(hash, timestamp)
-
-	// Update Redis
-	if err := s.redis.Set(s.ctx, key, s.items[key], nil); err != nil {
-		return isNewField, fmt.Errorf("failed to write to Redis: %v", err)
-	}
+	val.SetHash(hash, timestamp)
 
-	if err := s.save(); err != nil {
+	if err := s.commit(key, val); err != nil {
 		return isNewField, fmt.Errorf("failed to save to disk: %v", err)
 	}
 
+	s.keyChanged("hset", key, writeOptions(opts))
 	return isNewField, nil
 }
 
@@ -54,7 +52,8 @@ func (s *Store) HGet(key, field string) (string, bool, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	val, exists := s.items[key]
+	val, exists := s.items.Get(key)
+	s.accessed(key)
 	if !exists || val.Type != TypeHash {
 		return "", false, nil
 	}
@@ -69,11 +68,12 @@ func (s *Store) HGet(key, field string) (string, bool, error) {
 }
 
 // HDel deletes fields from a hash
-func (s *Store) HDel(key string, fields ...string) (int64, error) {
+func (s *Store) HDel(key string, fields []string, opts ...OpOption) (int64, error) {
+	defer s.awaitDurable()
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	val, exists := s.items[key]
+	val, exists := s.items.Get(key)
 	if !exists || val.Type != TypeHash {
 		return 0, nil
 	}
@@ -95,14 +95,10 @@ func (s *Store) HDel(key string, fields ...string) (int64, error) {
 		// Update the value
 		val.SetHash(hash, timestamp)
 
-		// Update Redis
-		if err := s.redis.Set(s.ctx, key, val, nil); err != nil {
-			return deleted, fmt.Errorf("failed to write to Redis: %v", err)
-		}
-
-		if err := s.save(); err != nil {
+		if err := s.commit(key, val); err != nil {
 			return deleted, fmt.Errorf("failed to save to disk: %v", err)
 		}
+		s.keyChanged("hdel", key, writeOptions(opts))
 	}
 
 	return deleted, nil
@@ -113,7 +109,8 @@ func (s *Store) HKeys(key string) ([]string, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	val, exists := s.items[key]
+	val, exists := s.items.Get(key)
+	s.accessed(key)
 	if !exists || val.Type != TypeHash {
 		return []string{}, nil
 	}
@@ -131,7 +128,8 @@ func (s *Store) HVals(key string) ([]string, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	val, exists := s.items[key]
+	val, exists := s.items.Get(key)
+	s.accessed(key)
 	if !exists || val.Type != TypeHash {
 		return []string{}, nil
 	}
@@ -149,7 +147,8 @@ func (s *Store) HGetAll(key string) (map[string]string, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	val, exists := s.items[key]
+	val, exists := s.items.Get(key)
+	s.accessed(key)
 	if !exists || val.Type != TypeHash {
 		return map[string]string{}, nil
 	}
@@ -167,7 +166,8 @@ func (s *Store) HLen(key string) (int64, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	val, exists := s.items[key]
+	val, exists := s.items.Get(key)
+	s.accessed(key)
 	if !exists || val.Type != TypeHash {
 		return 0, nil
 	}
@@ -185,7 +185,8 @@ func (s *Store) HExists(key, field string) (bool, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	val, exists := s.items[key]
+	val, exists := s.items.Get(key)
+	s.accessed(key)
 	if !exists || val.Type != TypeHash {
 		return false, nil
 	}
@@ -199,83 +200,116 @@ func (s *Store) HExists(key, field string) (bool, error) {
 }
 
 // HIncrBy increments a hash field's counter value by delta using accumulative semantics
-func (s *Store) HIncrBy(key, field string, delta int64) (int64, error) {
+func (s *Store) HIncrBy(key, field string, delta int64, opts ...OpOption) (int64, error) {
+	defer s.awaitDurable()
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	timestamp := time.Now().UnixNano()
+	options := writeOptions(opts)
+	timestamp := options.Timestamp
 	var hash *CRDTHash
 
-	if val, exists := s.items[key]; exists && val.Type == TypeHash {
+	val, exists := s.items.Get(key)
+	if exists && val.Type == TypeHash {
 		hash = val.Hash()
 		if hash == nil {
 			return 0, fmt.Errorf("invalid hash data")
 		}
 	} else {
 		// Create new hash
-		newVal := NewHashValue(timestamp, "")
-		hash = newVal.Hash()
-		s.items[key] = newVal
+		val = NewHashValue(timestamp, "")
+		hash = val.Hash()
 	}
 
-	// Increment the field
-	newValue, err := hash.IncrBy(field, delta, timestamp, "")
-	if err != nil {
-		return 0, err
+	// Increment the field, or raise the writer's contribution to the logged one
+	var newValue int64
+	if options.Share != nil {
+		if err := hash.ObserveCounter(field, options.Share[0], options.Share[1], timestamp, options.ReplicaID); err != nil {
+			return 0, err
+		}
+		current, _ := hash.Get(field)
+		newValue, _ = strconv.ParseInt(current, 10, 64)
+	} else {
+		var err error
+		newValue, err = hash.IncrBy(field, delta, timestamp, options.ReplicaID)
+		if err != nil {
+			return 0, err
+		}
 	}
 
 	// Update the value
-	s.items[key].SetHash(hash, timestamp)
-
-	// Update Redis
-	if err := s.redis.Set(s.ctx, key, s.items[key], nil); err != nil {
-		return newValue, fmt.Errorf("failed to write to Redis: %v", err)
-	}
+	val.SetHash(hash, timestamp)
 
-	if err := s.save(); err != nil {
+	if err := s.commit(key, val); err != nil {
 		return newValue, fmt.Errorf("failed to save to disk: %v", err)
 	}
 
+	s.keyChanged("hincrby", key, options)
 	return newValue, nil
 }
 
+// HCounterShare returns replicaID's increments and decrements of a counter
+// field, formatted for WithCounterShare, for logging a counter write
+func (s *Store) HCounterShare(key, field, replicaID string) (inc, dec string, ok bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	val, exists := s.items.Get(key)
+	if !exists || val.Type != TypeHash {
+		return "", "", false
+	}
+	hash := val.Hash()
+	if hash == nil {
+		return "", "", false
+	}
+	return hash.CounterShare(field, replicaID)
+}
+
 // HIncrByFloat increments a hash field's value by a float delta
-func (s *Store) HIncrByFloat(key, field string, delta float64) (float64, error) {
+func (s *Store) HIncrByFloat(key, field string, delta float64, opts ...OpOption) (float64, error) {
+	defer s.awaitDurable()
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	timestamp := time.Now().UnixNano()
+	options := writeOptions(opts)
+	timestamp := options.Timestamp
 	var hash *CRDTHash
 
-	if val, exists := s.items[key]; exists && val.Type == TypeHash {
+	val, exists := s.items.Get(key)
+	if exists && val.Type == TypeHash {
 		hash = val.Hash()
 		if hash == nil {
 			return 0, fmt.Errorf("invalid hash data")
 		}
 	} else {
 		// Create new hash
-		newVal := NewHashValue(timestamp, "")
-		hash = newVal.Hash()
-		s.items[key] = newVal
+		val = NewHashValue(timestamp, "")
+		hash = val.Hash()
 	}
 
-	// Increment the field
-	newValue, err := hash.IncrByFloat(field, delta, timestamp, "")
-	if err != nil {
-		return 0, err
+	// Increment the field, or raise the writer's contribution to the logged one
+	var newValue float64
+	if options.Share != nil {
+		if err := hash.ObserveCounter(field, options.Share[0], options.Share[1], timestamp, options.ReplicaID); err != nil {
+			return 0, err
+		}
+		current, _ := hash.Get(field)
+		newValue, _ = strconv.ParseFloat(current, 64)
+	} else {
+		var err error
+		newValue, err = hash.IncrByFloat(field, delta, timestamp, options.ReplicaID)
+		if err != nil {
+			return 0, err
+		}
 	}
 
 	// Update the value
-	s.items[key].SetHash(hash, timestamp)
-
-	// Update Redis
-	if err := s.redis.Set(s.ctx, key, s.items[key], nil); err != nil {
-		return newValue, fmt.Errorf("failed to write to Redis: %v", err)
-	}
+	val.SetHash(hash, timestamp)
 
-	if err := s.save(); err != nil {
+	if err := s.commit(key, val); err != nil {
 		return newValue, fmt.Errorf("failed to save to disk: %v", err)
 	}
 
+	s.keyChanged("hincrbyfloat", key, options)
 	return newValue, nil
 }
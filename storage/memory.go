@@ -0,0 +1,259 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync/atomic"
+	"time"
+)
+
+// Eviction policies, as configured by maxmemory-policy
+const (
+	PolicyNoEviction  = "noeviction"
+	PolicyAllKeysLRU  = "allkeys-lru"
+	PolicyAllKeysLFU  = "allkeys-lfu"
+	PolicyVolatileTTL = "volatile-ttl"
+	PolicyVolatileLRU = "volatile-lru"
+)
+
+// EvictionPolicies lists the supported maxmemory-policy values
+var EvictionPolicies = []string{PolicyNoEviction, PolicyAllKeysLRU, PolicyAllKeysLFU, PolicyVolatileTTL, PolicyVolatileLRU}
+
+// ErrOOM is returned when used memory is over the limit and the eviction
+// policy cannot free enough of it
+var ErrOOM = errors.New("command not allowed when used memory > 'maxmemory'")
+
+const (
+	evictionSamples = 5  // keys sampled per eviction, like Redis maxmemory-samples
+	valueOverhead   = 64 // approximate per-key bookkeeping bytes
+	lfuInitVal      = 5  // LFU counter of a new key, so it is not evicted right away
+	lfuLogFactor    = 10
+)
+
+// keyMeta holds memory accounting and access tracking for one key. size is
+// guarded by the store lock; access and freq are updated atomically so reads
+// under the read lock can record them.
+type keyMeta struct {
+	size   int64
+	access int64 // last access, unix nanoseconds
+	freq   int64 // logarithmic LFU counter, 0-255
+}
+
+// valueSize approximates the memory held by key and its value
+func valueSize(key string, v *Value) int64 {
+	size := int64(len(key) + len(v.Data) + len(v.ReplicaID) + valueOverhead)
+	if v.VectorClock != nil {
+		for replica := range v.VectorClock.Clock {
+			size += int64(len(replica) + 8)
+		}
+	}
+	return size
+}
+
+// account updates memory accounting after key changed; callers must hold
+// s.mu. Only resident engines are accounted: a disk engine bounds its memory
+// by its cache size.
+func (s *Store) account(key string) {
+	if !s.items.Resident() {
+		return
+	}
+	meta := s.meta[key]
+	val, exists := s.items.Get(key)
+	if !exists {
+		if meta != nil {
+			atomic.AddInt64(&s.usedMemory, -meta.size)
+			delete(s.meta, key)
+		}
+		return
+	}
+	if meta == nil {
+		if s.meta == nil {
+			s.meta = make(map[string]*keyMeta)
+		}
+		meta = &keyMeta{freq: lfuInitVal}
+		s.meta[key] = meta
+	}
+	size := valueSize(key, val)
+	atomic.AddInt64(&s.usedMemory, size-meta.size)
+	meta.size = size
+	s.touch(key)
+}
+
+// recomputeMemory rebuilds accounting for every key, e.g. after loading or
+// GC; callers must hold s.mu
+func (s *Store) recomputeMemory() {
+	if !s.items.Resident() {
+		return
+	}
+	if s.meta == nil {
+		s.meta = make(map[string]*keyMeta)
+	}
+	var used int64
+	s.items.ForEach(func(key string, val *Value) bool {
+		meta := s.meta[key]
+		if meta == nil {
+			meta = &keyMeta{freq: lfuInitVal, access: time.Now().UnixNano()}
+			s.meta[key] = meta
+		}
+		meta.size = valueSize(key, val)
+		used += meta.size
+		return true
+	})
+	for key := range s.meta {
+		if _, exists := s.items.Get(key); !exists {
+			delete(s.meta, key)
+		}
+	}
+	atomic.StoreInt64(&s.usedMemory, used)
+}
+
+// touch records an access to key for LRU and LFU eviction; callers must hold
+// s.mu for reading or writing
+func (s *Store) touch(key string) {
+	meta := s.meta[key]
+	if meta == nil {
+		return
+	}
+	now := time.Now().UnixNano()
+	freq := lfuDecay(atomic.LoadInt64(&meta.freq), atomic.LoadInt64(&meta.access), now)
+	atomic.StoreInt64(&meta.freq, lfuIncr(freq))
+	atomic.StoreInt64(&meta.access, now)
+}
+
+// lfuIncr increments the counter with decreasing probability as it grows,
+// so 255 represents around a million accesses, as in Redis
+func lfuIncr(freq int64) int64 {
+	if freq >= 255 {
+		return 255
+	}
+	base := float64(freq - lfuInitVal)
+	if base < 0 {
+		base = 0
+	}
+	if rand.Float64() < 1/(base*lfuLogFactor+1) {
+		freq++
+	}
+	return freq
+}
+
+// lfuDecay lowers the counter by one per idle minute, so keys that were
+// popular long ago become eviction candidates
+func lfuDecay(freq, access, now int64) int64 {
+	idle := (now - access) / int64(time.Minute)
+	if idle >= freq {
+		return 0
+	}
+	return freq - idle
+}
+
+// UsedMemory returns the approximate memory held by keys and values, or by
+// cached values with the disk engine
+func (s *Store) UsedMemory() int64 {
+	if e, ok := s.engine.(*diskEngine); ok {
+		return e.CacheBytes()
+	}
+	return atomic.LoadInt64(&s.usedMemory)
+}
+
+// EvictionPolicy returns the maxmemory-policy in effect
+func (s *Store) EvictionPolicy() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.evictionPolicy
+}
+
+// SetEvictionPolicy chooses how keys are evicted when used memory exceeds
+// the limit
+func (s *Store) SetEvictionPolicy(policy string) error {
+	for _, p := range EvictionPolicies {
+		if p == policy {
+			s.mu.Lock()
+			s.evictionPolicy = policy
+			s.mu.Unlock()
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid maxmemory policy: %s (valid: %v)", policy, EvictionPolicies)
+}
+
+// FreeMemory evicts keys until used memory is within the limit. It returns
+// ErrOOM if the policy does not allow evicting enough keys. Evictions are
+// local only: the key is removed from this replica's state, Redis and disk,
+// but no delete is replicated, so other regions keep their copy. Only plain
+// strings are evicted, see evictable. The disk engine never evicts, since
+// its memory is bounded by the cache.
+func (s *Store) FreeMemory() error {
+	limit := s.MaxMemory()
+	if limit <= 0 || !s.items.Resident() || s.UsedMemory() <= limit {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for s.UsedMemory() > limit {
+		key, ok := s.evictionCandidate()
+		if !ok {
+			break
+		}
+		if err := s.commit(key, nil); err != nil {
+			return fmt.Errorf("failed to save to disk: %v", err)
+		}
+		s.evicted++
+		s.keyChanged("evicted", key, nil)
+	}
+	if s.UsedMemory() > limit {
+		return ErrOOM
+	}
+	return nil
+}
+
+// evictionCandidate samples keys allowed by the policy and returns the best
+// one to evict; callers must hold s.mu
+func (s *Store) evictionCandidate() (string, bool) {
+	if s.evictionPolicy == PolicyNoEviction || s.evictionPolicy == "" {
+		return "", false
+	}
+	volatile := s.evictionPolicy == PolicyVolatileTTL || s.evictionPolicy == PolicyVolatileLRU
+	now := time.Now().UnixNano()
+
+	var best string
+	var bestScore int64
+	sampled := 0
+	// Map iteration starts at a random position, which makes this a random sample
+	s.items.ForEach(func(key string, val *Value) bool {
+		if !val.evictable() || (volatile && val.TTL == nil) {
+			return true
+		}
+		meta := s.meta[key]
+		if meta == nil {
+			return true
+		}
+		// Higher scores are better eviction candidates
+		var score int64
+		switch s.evictionPolicy {
+		case PolicyAllKeysLFU:
+			score = 255 - lfuDecay(atomic.LoadInt64(&meta.freq), atomic.LoadInt64(&meta.access), now)
+		case PolicyVolatileTTL:
+			score = -val.ExpireAt.UnixNano()
+		default:
+			score = now - atomic.LoadInt64(&meta.access)
+		}
+		if sampled == 0 || score > bestScore {
+			best, bestScore = key, score
+		}
+		sampled++
+		return sampled < evictionSamples
+	})
+	return best, sampled > 0
+}
+
+// evictable reports whether v may be evicted. Peers merge a counter's
+// per-replica contributions and reset by taking the max, and a collection's
+// elements by their tags and clocks, so dropping that state here would make
+// this replica's next write start from nothing and the regions would never
+// converge again. Only a plain string, which the next write replaces
+// outright, can be dropped.
+func (v *Value) evictable() bool {
+	return v.Type == TypeString && !v.counterLike() && v.Reset == nil && v.FloatReset == nil
+}
@@ -0,0 +1,118 @@
+package storage
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func newMemoryTestStore(t *testing.T) *Store {
+	t.Helper()
+	store, err := NewStore(t.TempDir(), "", 0)
+	if err != nil {
+		t.Fatalf("NewStore failed: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+func TestMemoryAccounting(t *testing.T) {
+	store := newMemoryTestStore(t)
+	if got := store.UsedMemory(); got != 0 {
+		t.Fatalf("empty store uses %d bytes", got)
+	}
+
+	store.Set("k", NewStringValue("hello", 1, "r1"), nil)
+	size := store.UsedMemory()
+	if size <= int64(len("k")+len("hello")) {
+		t.Fatalf("used memory %d does not cover the key and value", size)
+	}
+	store.SAdd("s", []string{"a", "b", "c"})
+	if store.UsedMemory() <= size {
+		t.Error("adding a set should increase used memory")
+	}
+	store.Delete("s")
+	if got := store.UsedMemory(); got != size {
+		t.Errorf("used memory after delete = %d, want %d", got, size)
+	}
+	store.Delete("k")
+	if got := store.UsedMemory(); got != 0 {
+		t.Errorf("used memory after deleting everything = %d", got)
+	}
+}
+
+func TestFreeMemoryNoEviction(t *testing.T) {
+	store := newMemoryTestStore(t)
+	store.Set("k", NewStringValue("value", 1, "r1"), nil)
+	if err := store.SetMaxMemory(1); err != nil {
+		t.Fatalf("SetMaxMemory failed: %v", err)
+	}
+	if err := store.FreeMemory(); err != ErrOOM {
+		t.Errorf("FreeMemory with noeviction = %v, want ErrOOM", err)
+	}
+	if _, ok := store.Get("k"); !ok {
+		t.Error("noeviction must not remove keys")
+	}
+}
+
+func TestFreeMemoryEvictsLocally(t *testing.T) {
+	store := newMemoryTestStore(t)
+	var events []KeyEvent
+	store.SetNotifier(func(ev KeyEvent) { events = append(events, ev) })
+	if err := store.SetEvictionPolicy(PolicyAllKeysLRU); err != nil {
+		t.Fatalf("SetEvictionPolicy failed: %v", err)
+	}
+	if err := store.SetEvictionPolicy("random"); err == nil {
+		t.Error("unknown policy should be rejected")
+	}
+
+	for i := 0; i < 4; i++ {
+		store.Set(fmt.Sprintf("k%d", i), NewStringValue("value", int64(i+1), "r1"), nil)
+	}
+	// k0 is the most recently used key
+	time.Sleep(time.Millisecond)
+	store.Get("k0")
+	per := store.UsedMemory() / 4
+	events = nil
+
+	if err := store.SetMaxMemory(per); err != nil {
+		t.Fatalf("SetMaxMemory failed: %v", err)
+	}
+	if got := store.UsedMemory(); got > per {
+		t.Errorf("used memory %d is over the limit %d", got, per)
+	}
+	if _, ok := store.Get("k0"); !ok {
+		t.Error("the most recently used key should survive LRU eviction")
+	}
+	if len(events) != 3 || events[0].Event != "evicted" {
+		t.Errorf("events = %v, want 3 evicted", events)
+	}
+	if got := store.Stats().Evicted; got != 3 {
+		t.Errorf("Evicted = %d, want 3", got)
+	}
+}
+
+func TestFreeMemoryVolatileTTL(t *testing.T) {
+	store := newMemoryTestStore(t)
+	store.SetEvictionPolicy(PolicyVolatileTTL)
+	short, long := int64(60), int64(3600)
+	store.Set("persistent", NewStringValue("v", 1, "r1"), nil)
+	store.Set("short", NewStringValue("v", 2, "r1"), &short)
+	store.Set("long", NewStringValue("v", 3, "r1"), &long)
+
+	store.SetMaxMemory(store.UsedMemory() - 1)
+	if _, ok := store.Get("short"); ok {
+		t.Error("the key closest to expiry should be evicted first")
+	}
+	if _, ok := store.Get("long"); !ok {
+		t.Error("one eviction should have been enough")
+	}
+
+	store.SetMaxMemory(1)
+	if err := store.FreeMemory(); err != ErrOOM {
+		t.Errorf("FreeMemory without volatile keys = %v, want ErrOOM", err)
+	}
+	if _, ok := store.Get("persistent"); !ok {
+		t.Error("volatile policies must not evict keys without a TTL")
+	}
+}
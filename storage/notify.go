@@ -0,0 +1,47 @@
+package storage
+
+import "time"
+
+// KeyEvent describes a change to a key, as published by keyspace notifications
+type KeyEvent struct {
+	Event  string // Redis event name, e.g. "set", "del", "lpush", "expired"
+	Key    string
+	Remote bool // the change was replicated from another region
+}
+
+// SetNotifier registers fn to be called for every key change. fn runs with
+// the store lock held, so it must not block or call back into the store.
+func (s *Store) SetNotifier(fn func(KeyEvent)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.notifier = fn
+}
+
+// keyChanged runs after every change to key: it updates memory accounting,
+// mirrors the key to the backend and publishes the keyspace event. Callers
+// must hold s.mu.
+func (s *Store) keyChanged(event, key string, options *WriteOptions) {
+	s.account(key)
+	s.mirror(key)
+	if s.notifier == nil {
+		return
+	}
+	s.notifier(KeyEvent{Event: event, Key: key, Remote: options != nil && options.Remote})
+}
+
+// WithRemote marks a write as replicated from another region, so its keyspace
+// notifications are flagged as remote
+func WithRemote() OpOption {
+	return func(o *WriteOptions) {
+		o.Remote = true
+	}
+}
+
+// writeOptions applies opts over defaults stamped with the current time
+func writeOptions(opts []OpOption) *WriteOptions {
+	options := &WriteOptions{Timestamp: time.Now().UnixNano()}
+	for _, opt := range opts {
+		opt(options)
+	}
+	return options
+}
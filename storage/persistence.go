@@ -1,9 +1,8 @@
 package storage
 
 import (
-	"bufio"
-	"encoding/json"
 	"fmt"
+	"log"
 	"os"
 	"path/filepath"
 	"sort"
@@ -22,17 +21,41 @@ type LogEntry struct {
 	Metadata  string `json:"metadata,omitempty"` // Additional metadata if needed
 }
 
+// Segment sync policies, as configured by appendfsync
+const (
+	SyncAlways   = "always"   // fsync before a write is acknowledged; concurrent writers share one fsync
+	SyncEverySec = "everysec" // fsync once a second in the background
+	SyncNo       = "no"       // leave flushing to the operating system
+)
+
+// SyncPolicies lists the supported appendfsync values
+var SyncPolicies = []string{SyncAlways, SyncEverySec, SyncNo}
+
 // SegmentManager manages append-only log segments for efficient persistence
 type SegmentManager struct {
 	mu                  sync.RWMutex
 	dataDir             string
 	currentSegment      *os.File
 	currentSegmentID    int64
+	currentSize         int64         // bytes in the current segment
 	maxSegmentSize      int64         // Maximum size per segment in bytes
 	compactionThreshold int           // Number of segments before compaction
 	segments            []string      // List of segment file paths
+	snapshots           []int64       // segments at which snapshot files start, oldest first
 	lastCompaction      time.Time     // Last compaction time
 	compactionInterval  time.Duration // Minimum interval between compactions
+	compactions         int64         // Completed compactions since start
+	compactionSeconds   float64       // Total time spent in completed compactions
+	syncPolicy          string
+	recovery            string               // how corrupt entries are handled on load
+	corruptEntries      int64                // corrupt entries found on load
+	droppedSegments     int64                // segments taken out of the log on load
+	written             int64                // entries appended since start
+	synced              int64                // entries known to be on disk
+	fsyncs              int64                // fsyncs of the current segment since start
+	syncMu              sync.Mutex           // serializes fsyncs, so writers waiting on one share the next
+	syncFile            func(*os.File) error // fsyncs the current segment; tests replace it
+	stopSync            chan struct{}
 }
 
 // NewSegmentManager creates a new segment manager
@@ -47,6 +70,10 @@ func NewSegmentManager(dataDir string) (*SegmentManager, error) {
 		compactionThreshold: 10,               // Compact after 10 segments
 		compactionInterval:  5 * time.Minute,  // Compact at most every 5 minutes
 		lastCompaction:      time.Now(),
+		syncPolicy:          SyncEverySec,
+		recovery:            RecoveryTruncate,
+		syncFile:            (*os.File).Sync,
+		stopSync:            make(chan struct{}),
 	}
 
 	// Load existing segments
@@ -59,18 +86,26 @@ func NewSegmentManager(dataDir string) (*SegmentManager, error) {
 		return nil, fmt.Errorf("failed to open current segment: %v", err)
 	}
 
+	go sm.syncLoop()
 	return sm, nil
 }
 
-// loadSegments discovers and loads existing segment files
+// loadSegments discovers and loads existing segment files. A compacted
+// segment replaces every segment up to its ID, so those are removed if a
+// compaction was interrupted before it could remove them.
 func (sm *SegmentManager) loadSegments() error {
 	files, err := os.ReadDir(sm.dataDir)
 	if err != nil {
 		return err
 	}
 
-	var segmentFiles []string
-	var maxID int64 = -1
+	type segmentFile struct {
+		path      string
+		id        int64
+		compacted bool
+	}
+	var found []segmentFile
+	var maxID, compactedID int64 = -1, -1
 
 	for _, file := range files {
 		if file.IsDir() {
@@ -78,28 +113,44 @@ func (sm *SegmentManager) loadSegments() error {
 		}
 
 		name := file.Name()
-		if strings.HasPrefix(name, "segment-") && strings.HasSuffix(name, ".log") {
-			// Extract segment ID
-			idStr := strings.TrimPrefix(name, "segment-")
-			idStr = strings.TrimSuffix(idStr, ".log")
-
-			id, err := strconv.ParseInt(idStr, 10, 64)
-			if err != nil {
-				continue // Skip invalid segment files
-			}
-
-			segmentPath := filepath.Join(sm.dataDir, name)
-			segmentFiles = append(segmentFiles, segmentPath)
+		if segment, ok := parseSnapshotName(name); ok {
+			sm.snapshots = append(sm.snapshots, segment)
+			continue
+		}
+		id, compacted, ok := parseSegmentName(name)
+		if !ok {
+			continue // Skip invalid segment files
+		}
 
-			if id > maxID {
-				maxID = id
-			}
+		found = append(found, segmentFile{filepath.Join(sm.dataDir, name), id, compacted})
+		if id > maxID {
+			maxID = id
 		}
+		if compacted && id > compactedID {
+			compactedID = id
+		}
+	}
+	sort.Slice(sm.snapshots, func(i, j int) bool { return sm.snapshots[i] < sm.snapshots[j] })
+	if n := len(sm.snapshots); n > 0 && sm.snapshots[n-1] > maxID {
+		// A snapshot is always followed by the segment it starts at
+		maxID = sm.snapshots[n-1]
 	}
 
-	// Sort segments by filename (which includes the ID)
-	sort.Strings(segmentFiles)
-	sm.segments = segmentFiles
+	// Sort segments by ID; a compacted segment sorts before a plain one with its ID
+	sort.Slice(found, func(i, j int) bool {
+		if found[i].id != found[j].id {
+			return found[i].id < found[j].id
+		}
+		return found[i].compacted && !found[j].compacted
+	})
+	sm.segments = nil
+	for _, f := range found {
+		if f.id < compactedID || f.id == compactedID && !f.compacted {
+			os.Remove(f.path)
+			continue
+		}
+		sm.segments = append(sm.segments, f.path)
+	}
 
 	// Set next segment ID
 	sm.currentSegmentID = maxID + 1
@@ -107,6 +158,22 @@ func (sm *SegmentManager) loadSegments() error {
 	return nil
 }
 
+// parseSegmentName extracts the ID from a segment file name of the form
+// segment-<id>.log or segment-<id>-compacted.log
+func parseSegmentName(name string) (id int64, compacted bool, ok bool) {
+	if !strings.HasPrefix(name, "segment-") || !strings.HasSuffix(name, ".log") {
+		return 0, false, false
+	}
+	idStr := strings.TrimSuffix(strings.TrimPrefix(name, "segment-"), ".log")
+	compacted = strings.HasSuffix(idStr, "-compacted")
+	idStr = strings.TrimSuffix(idStr, "-compacted")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		return 0, false, false
+	}
+	return id, compacted, true
+}
+
 // openCurrentSegment opens or creates the current segment for writing
 func (sm *SegmentManager) openCurrentSegment() error {
 	segmentPath := filepath.Join(sm.dataDir, fmt.Sprintf("segment-%d.log", sm.currentSegmentID))
@@ -115,8 +182,24 @@ func (sm *SegmentManager) openCurrentSegment() error {
 	if err != nil {
 		return err
 	}
+	stat, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	if stat.Size() == 0 {
+		if _, err := file.Write(segmentHeader()); err != nil {
+			file.Close()
+			return err
+		}
+	}
 
 	sm.currentSegment = file
+	sm.currentSize = stat.Size()
+	if sm.currentSize == 0 {
+		sm.currentSize = int64(len(segmentHeader()))
+	}
 
 	// Add to segments list if not already present
 	found := false
@@ -133,58 +216,138 @@ func (sm *SegmentManager) openCurrentSegment() error {
 	return nil
 }
 
-// WriteEntry writes a log entry to the current segment
+// WriteEntry writes a log entry to the current segment and, with the always
+// policy, waits until it is on disk
 func (sm *SegmentManager) WriteEntry(entry *LogEntry) error {
-	sm.mu.Lock()
-	defer sm.mu.Unlock()
-
-	// Serialize entry
-	data, err := json.Marshal(entry)
+	seq, err := sm.Append(entry)
 	if err != nil {
-		return fmt.Errorf("failed to marshal entry: %v", err)
+		return err
 	}
+	if sm.SyncPolicy() == SyncAlways {
+		return sm.SyncTo(seq)
+	}
+	return nil
+}
 
-	// Add newline for easier parsing
-	data = append(data, '\n')
-
-	// Write to current segment
-	_, err = sm.currentSegment.Write(data)
+// Append writes a log entry to the current segment without waiting for it to
+// reach the disk, and returns its sequence number for SyncTo
+func (sm *SegmentManager) Append(entry *LogEntry) (int64, error) {
+	data, err := encodeEntry(entry)
 	if err != nil {
-		return fmt.Errorf("failed to write entry: %v", err)
+		return 0, err
 	}
 
-	// Sync to disk for durability
-	if err := sm.currentSegment.Sync(); err != nil {
-		return fmt.Errorf("failed to sync segment: %v", err)
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	// Write to current segment
+	if _, err := sm.currentSegment.Write(data); err != nil {
+		return 0, fmt.Errorf("failed to write entry: %v", err)
 	}
+	sm.currentSize += int64(len(data))
+	sm.written++
+	seq := sm.written
 
 	// Check if we need to rotate segment
-	if err := sm.checkSegmentRotation(); err != nil {
-		return fmt.Errorf("failed to check segment rotation: %v", err)
+	if sm.currentSize >= sm.maxSegmentSize {
+		if err := sm.rotateSegment(); err != nil {
+			return seq, fmt.Errorf("failed to rotate segment: %v", err)
+		}
 	}
 
 	// Check if we need compaction
 	sm.checkCompaction()
 
-	return nil
+	return seq, nil
 }
 
-// checkSegmentRotation rotates to a new segment if current one is too large
-func (sm *SegmentManager) checkSegmentRotation() error {
-	stat, err := sm.currentSegment.Stat()
+// SyncTo waits until the entry with sequence number seq is on disk. Writers
+// that call it while an fsync is running are covered together by the next
+// one, so concurrent writes are committed as a group.
+func (sm *SegmentManager) SyncTo(seq int64) error {
+	sm.syncMu.Lock()
+	defer sm.syncMu.Unlock()
+
+	sm.mu.RLock()
+	if sm.synced >= seq {
+		sm.mu.RUnlock()
+		return nil
+	}
+	file, target := sm.currentSegment, sm.written
+	sm.mu.RUnlock()
+
+	err := sm.syncFile(file)
+
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	if err != nil && sm.synced >= target {
+		err = nil // rotated meanwhile, which synced the file before closing it
+	}
 	if err != nil {
-		return err
+		return fmt.Errorf("failed to sync segment: %v", err)
 	}
+	if target > sm.synced {
+		sm.synced = target
+	}
+	sm.fsyncs++
+	return nil
+}
 
-	if stat.Size() >= sm.maxSegmentSize {
-		return sm.rotateSegment()
+// Sync waits until every entry appended so far is on disk
+func (sm *SegmentManager) Sync() error {
+	sm.mu.RLock()
+	seq := sm.written
+	sm.mu.RUnlock()
+	return sm.SyncTo(seq)
+}
+
+// SyncPolicy returns the appendfsync policy in effect
+func (sm *SegmentManager) SyncPolicy() string {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+	return sm.syncPolicy
+}
+
+// SetSyncPolicy sets when appended entries are synced to disk
+func (sm *SegmentManager) SetSyncPolicy(policy string) error {
+	for _, p := range SyncPolicies {
+		if p == policy {
+			sm.mu.Lock()
+			sm.syncPolicy = policy
+			sm.mu.Unlock()
+			return nil
+		}
 	}
+	return fmt.Errorf("invalid appendfsync policy: %s (valid: %v)", policy, SyncPolicies)
+}
 
-	return nil
+// syncLoop syncs the current segment once a second under the everysec policy
+func (sm *SegmentManager) syncLoop() {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if sm.SyncPolicy() != SyncEverySec {
+				continue
+			}
+			if err := sm.Sync(); err != nil {
+				log.Printf("Background segment sync failed: %v", err)
+			}
+		case <-sm.stopSync:
+			return
+		}
+	}
 }
 
-// rotateSegment closes current segment and opens a new one
+// rotateSegment syncs and closes the current segment and opens a new one
 func (sm *SegmentManager) rotateSegment() error {
+	if err := sm.currentSegment.Sync(); err != nil {
+		return err
+	}
+	sm.synced = sm.written
+
 	// Close current segment
 	if err := sm.currentSegment.Close(); err != nil {
 		return err
@@ -220,6 +383,7 @@ func (sm *SegmentManager) checkCompaction() {
 func (sm *SegmentManager) performCompaction() error {
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
+	start := time.Now()
 
 	// Don't compact the current segment
 	segmentsToCompact := sm.segments[:len(sm.segments)-1]
@@ -232,45 +396,47 @@ func (sm *SegmentManager) performCompaction() error {
 	var orderedKeys []string
 
 	for _, segmentPath := range segmentsToCompact {
-		entries, err := sm.readSegment(segmentPath)
+		entries, _, err := sm.readSegment(segmentPath)
 		if err != nil {
 			return fmt.Errorf("failed to read segment %s: %v", segmentPath, err)
 		}
 
 		for _, entry := range entries {
-			// Keep only the latest entry for each key
-			if existing, exists := allEntries[entry.Key]; !exists || entry.Timestamp > existing.Timestamp {
-				if !exists {
-					orderedKeys = append(orderedKeys, entry.Key)
-				}
-				allEntries[entry.Key] = entry
+			// Keep only the latest entry for each key; later entries in the log win
+			if _, exists := allEntries[entry.Key]; !exists {
+				orderedKeys = append(orderedKeys, entry.Key)
 			}
+			allEntries[entry.Key] = entry
 		}
 	}
 
-	// Write compacted data to new segment
-	compactedPath := filepath.Join(sm.dataDir, fmt.Sprintf("segment-%d-compacted.log", time.Now().UnixNano()))
-	compactedFile, err := os.Create(compactedPath)
+	// Write compacted data to a new segment named after the last one it
+	// replaces, so loadSegments can tell which segments it supersedes
+	lastPath := segmentsToCompact[len(segmentsToCompact)-1]
+	compactedPath := strings.TrimSuffix(lastPath, ".log")
+	compactedPath = strings.TrimSuffix(compactedPath, "-compacted") + "-compacted.log"
+	compactedFile, err := os.Create(compactedPath + ".tmp")
 	if err != nil {
 		return fmt.Errorf("failed to create compacted segment: %v", err)
 	}
 	defer compactedFile.Close()
+	if _, err := compactedFile.Write(segmentHeader()); err != nil {
+		return fmt.Errorf("failed to write compacted segment: %v", err)
+	}
 
 	// Write entries in order
 	for _, key := range orderedKeys {
 		entry := allEntries[key]
-		// Skip deleted entries (no value)
-		if entry.Operation == "DELETE" {
+		// Skip deleted entries (no value), unless a snapshot still holds the key
+		if entry.Operation == "DELETE" && len(sm.snapshots) == 0 {
 			continue
 		}
 
-		data, err := json.Marshal(entry)
+		record, err := encodeEntry(entry)
 		if err != nil {
-			return fmt.Errorf("failed to marshal compacted entry: %v", err)
+			return err
 		}
-		data = append(data, '\n')
-
-		if _, err := compactedFile.Write(data); err != nil {
+		if _, err := compactedFile.Write(record); err != nil {
 			return fmt.Errorf("failed to write compacted entry: %v", err)
 		}
 	}
@@ -278,9 +444,15 @@ func (sm *SegmentManager) performCompaction() error {
 	if err := compactedFile.Sync(); err != nil {
 		return fmt.Errorf("failed to sync compacted segment: %v", err)
 	}
+	if err := os.Rename(compactedPath+".tmp", compactedPath); err != nil {
+		return fmt.Errorf("failed to install compacted segment: %v", err)
+	}
 
 	// Remove old segments
 	for _, segmentPath := range segmentsToCompact {
+		if segmentPath == compactedPath {
+			continue
+		}
 		if err := os.Remove(segmentPath); err != nil {
 			fmt.Printf("Warning: failed to remove old segment %s: %v\n", segmentPath, err)
 		}
@@ -295,56 +467,108 @@ func (sm *SegmentManager) performCompaction() error {
 	sm.segments = newSegments
 
 	sm.lastCompaction = time.Now()
+	sm.compactions++
+	sm.compactionSeconds += sm.lastCompaction.Sub(start).Seconds()
 	return nil
 }
 
-// readSegment reads all entries from a segment file
-func (sm *SegmentManager) readSegment(segmentPath string) ([]*LogEntry, error) {
-	file, err := os.Open(segmentPath)
+// readSegment reads the entries of a segment file as the recovery mode
+// allows: strict fails on any corrupt entry, skip leaves out corrupt
+// entries, and truncate stops at the first one
+func (sm *SegmentManager) readSegment(segmentPath string) ([]*LogEntry, *SegmentReport, error) {
+	type located struct {
+		offset int64
+		entry  *LogEntry
+	}
+	var all []located
+	report, err := ReadSegmentFile(segmentPath, func(offset int64, entry *LogEntry) {
+		all = append(all, located{offset, entry})
+	})
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+	if !report.Clean() && sm.recovery == RecoveryStrict {
+		c := report.Corrupt[0]
+		return nil, nil, fmt.Errorf("corrupt entry at offset %d: %s (%d corrupt entries; start with segment-recovery skip or truncate, or repair with check-segments)", c.Offset, c.Reason, len(report.Corrupt))
 	}
-	defer file.Close()
-
-	var entries []*LogEntry
-	scanner := bufio.NewScanner(file)
-
-	for scanner.Scan() {
-		line := scanner.Text()
-		if strings.TrimSpace(line) == "" {
-			continue
-		}
 
-		var entry LogEntry
-		if err := json.Unmarshal([]byte(line), &entry); err != nil {
-			// Skip invalid entries but log the error
-			fmt.Printf("Warning: failed to unmarshal entry in %s: %v\n", segmentPath, err)
-			continue
+	entries := make([]*LogEntry, 0, len(all))
+	for _, e := range all {
+		if sm.recovery == RecoveryTruncate && e.offset >= report.ValidEnd() {
+			break
 		}
-
-		entries = append(entries, &entry)
+		entries = append(entries, e.entry)
 	}
+	return entries, report, nil
+}
 
-	if err := scanner.Err(); err != nil {
-		return nil, fmt.Errorf("error reading segment: %v", err)
+// SetRecoveryMode sets how corrupt segment entries are handled when
+// segments are loaded: RecoveryStrict, RecoverySkip or RecoveryTruncate
+func (sm *SegmentManager) SetRecoveryMode(mode string) error {
+	if err := validRecoveryMode(mode); err != nil {
+		return err
 	}
-
-	return entries, nil
+	sm.mu.Lock()
+	sm.recovery = mode
+	sm.mu.Unlock()
+	return nil
 }
 
-// LoadAllEntries loads all entries from all segments
+// LoadAllEntries recovers the state from the newest valid snapshot and the
+// segments written after it, or from every segment if there is no snapshot
 func (sm *SegmentManager) LoadAllEntries() (map[string]*Value, error) {
-	sm.mu.RLock()
-	defer sm.mu.RUnlock()
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
 
 	result := make(map[string]*Value)
+	from := int64(0)
+	for i := len(sm.snapshots) - 1; i >= 0; i-- {
+		items, err := readSnapshot(sm.snapshotPath(sm.snapshots[i]))
+		if err != nil {
+			log.Printf("Skipping snapshot %d: %v", sm.snapshots[i], err)
+			continue
+		}
+		result, from = items, sm.snapshots[i]
+		break
+	}
 
-	// Read all segments in order
+	// Read the remaining segments in order. Once truncate recovery cuts a
+	// segment, the later ones are dropped rather than replayed over the gap.
+	current := filepath.Join(sm.dataDir, fmt.Sprintf("segment-%d.log", sm.currentSegmentID))
+	truncated := ""
+	kept := sm.segments[:0]
 	for _, segmentPath := range sm.segments {
-		entries, err := sm.readSegment(segmentPath)
+		if id, _, _ := parseSegmentName(filepath.Base(segmentPath)); id < from {
+			kept = append(kept, segmentPath)
+			continue
+		}
+		if truncated != "" && segmentPath != current {
+			dropped, err := DropSegment(segmentPath)
+			if err != nil {
+				return nil, err
+			}
+			sm.droppedSegments++
+			log.Printf("Dropped %s as %s: it follows the truncated %s", segmentPath, dropped, truncated)
+			continue
+		}
+		kept = append(kept, segmentPath)
+		entries, report, err := sm.readSegment(segmentPath)
 		if err != nil {
 			return nil, fmt.Errorf("failed to read segment %s: %v", segmentPath, err)
 		}
+		if !report.Clean() {
+			sm.corruptEntries += int64(len(report.Corrupt))
+			for _, c := range report.Corrupt {
+				log.Printf("Corrupt entry in %s at offset %d (%d bytes): %s", segmentPath, c.Offset, c.Length, c.Reason)
+			}
+			if sm.recovery == RecoveryTruncate {
+				if err := os.Truncate(segmentPath, report.ValidEnd()); err != nil {
+					return nil, fmt.Errorf("failed to truncate segment %s: %v", segmentPath, err)
+				}
+				log.Printf("Truncated %s to %d bytes", segmentPath, report.ValidEnd())
+				truncated = segmentPath
+			}
+		}
 
 		// Apply entries in order
 		for _, entry := range entries {
@@ -358,16 +582,29 @@ func (sm *SegmentManager) LoadAllEntries() (map[string]*Value, error) {
 			}
 		}
 	}
+	sm.segments = kept
 
 	return result, nil
 }
 
-// Close closes the segment manager and current segment
+// Close syncs and closes the current segment
 func (sm *SegmentManager) Close() error {
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
 
+	select {
+	case <-sm.stopSync:
+		return nil // already closed
+	default:
+		close(sm.stopSync)
+	}
+
 	if sm.currentSegment != nil {
+		if err := sm.currentSegment.Sync(); err != nil {
+			sm.currentSegment.Close()
+			return fmt.Errorf("failed to sync segment: %v", err)
+		}
+		sm.synced = sm.written
 		return sm.currentSegment.Close()
 	}
 
@@ -385,6 +622,16 @@ func (sm *SegmentManager) GetStats() map[string]interface{} {
 		"max_segment_size":     sm.maxSegmentSize,
 		"compaction_threshold": sm.compactionThreshold,
 		"last_compaction":      sm.lastCompaction,
+		"compactions":          sm.compactions,
+		"sync_policy":          sm.syncPolicy,
+		"fsyncs":               sm.fsyncs,
+		"snapshots":            len(sm.snapshots),
+		"recovery_mode":        sm.recovery,
+		"corrupt_entries":      sm.corruptEntries,
+		"dropped_segments":     sm.droppedSegments,
+	}
+	if n := len(sm.snapshots); n > 0 {
+		stats["snapshot_segment"] = sm.snapshots[n-1]
 	}
 
 	// Calculate total size
@@ -0,0 +1,184 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestStoreReopensFromSegmentLog(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewStore(dir, "", 0)
+	if err != nil {
+		t.Fatalf("NewStore failed: %v", err)
+	}
+	store.Set("a", NewStringValue("1", 1, "r1"), nil)
+	store.Set("b", NewStringValue("2", 1, "r1"), nil)
+	store.Set("a", NewStringValue("3", 2, "r1"), nil)
+	store.Delete("b")
+	store.HSet("h", "f", "v")
+	store.Close()
+
+	if _, err := os.Stat(filepath.Join(dir, "store.json")); !os.IsNotExist(err) {
+		t.Errorf("store.json was written: %v", err)
+	}
+
+	store, err = NewStore(dir, "", 0)
+	if err != nil {
+		t.Fatalf("NewStore failed: %v", err)
+	}
+	defer store.Close()
+	if v, ok := store.Get("a"); !ok || v.String() != "3" {
+		t.Errorf("a = %v %v after reopen, want 3", v, ok)
+	}
+	if _, ok := store.Get("b"); ok {
+		t.Error("deleted key b is back after reopen")
+	}
+	if v, _, _ := store.HGet("h", "f"); v != "v" {
+		t.Errorf("HGET h f = %q after reopen", v)
+	}
+}
+
+func TestStoreGroupCommit(t *testing.T) {
+	store, err := NewStore(t.TempDir(), "", 0)
+	if err != nil {
+		t.Fatalf("NewStore failed: %v", err)
+	}
+	defer store.Close()
+	if err := store.SetSyncPolicy(SyncAlways); err != nil {
+		t.Fatalf("SetSyncPolicy failed: %v", err)
+	}
+
+	// The first fsync blocks until every writer has appended, so the rest
+	// queue behind it and are covered by one more
+	sm := store.segmentManager
+	release := make(chan struct{})
+	var calls int32
+	sm.syncMu.Lock()
+	sm.syncFile = func(f *os.File) error {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			<-release
+		}
+		return f.Sync()
+	}
+	sm.syncMu.Unlock()
+
+	const writers = 16
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			store.Set(fmt.Sprintf("k%d", w), NewStringValue("v", 1, "r1"), nil)
+		}(w)
+	}
+	for {
+		sm.mu.RLock()
+		written := sm.written
+		sm.mu.RUnlock()
+		if written == writers {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+	wg.Wait()
+
+	stats := store.GetPersistenceStats()
+	fsyncs := stats["fsyncs"].(int64)
+	if fsyncs < 1 || fsyncs > 2 {
+		t.Errorf("%d fsyncs for %d concurrent writes, want 1 or 2", fsyncs, writers)
+	}
+	if stats["sync_policy"] != SyncAlways {
+		t.Errorf("sync_policy = %v", stats["sync_policy"])
+	}
+	if err := store.SetSyncPolicy("sometimes"); err == nil {
+		t.Error("invalid sync policy was accepted")
+	}
+}
+
+func TestStoreMigratesLegacyFile(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewStore(dir, "", 0)
+	if err != nil {
+		t.Fatalf("NewStore failed: %v", err)
+	}
+	store.Set("stale", NewStringValue("old", 1, "r1"), nil)
+	store.Set("kept", NewStringValue("old", 1, "r1"), nil)
+	store.Close()
+
+	// An older version kept writing store.json after the segments
+	legacy := map[string]*Value{
+		"kept":  NewStringValue("new", 2, "r1"),
+		"added": NewStringValue("new", 2, "r1"),
+	}
+	data, _ := json.Marshal(legacy)
+	if err := os.WriteFile(filepath.Join(dir, "store.json"), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		store, err = NewStore(dir, "", 0)
+		if err != nil {
+			t.Fatalf("NewStore failed: %v", err)
+		}
+		if _, ok := store.Get("stale"); ok {
+			t.Error("key missing from store.json survived the migration")
+		}
+		for _, key := range []string{"kept", "added"} {
+			if v, ok := store.Get(key); !ok || v.String() != "new" {
+				t.Errorf("%s = %v %v, want new", key, v, ok)
+			}
+		}
+		store.Close()
+
+		if _, err := os.Stat(filepath.Join(dir, "store.json")); !os.IsNotExist(err) {
+			t.Errorf("store.json was not removed: %v", err)
+		}
+	}
+}
+
+func TestSegmentCompactionReload(t *testing.T) {
+	dir := t.TempDir()
+	sm, err := NewSegmentManager(dir)
+	if err != nil {
+		t.Fatalf("NewSegmentManager failed: %v", err)
+	}
+	sm.maxSegmentSize = 256
+	for i := 0; i < 100; i++ {
+		entry := &LogEntry{Timestamp: 1, Operation: "SET", Key: fmt.Sprintf("k%d", i%10), Value: NewStringValue(fmt.Sprint(i), 1, "r1")}
+		if err := sm.WriteEntry(entry); err != nil {
+			t.Fatalf("WriteEntry failed: %v", err)
+		}
+	}
+	sm.WriteEntry(&LogEntry{Timestamp: 1, Operation: "DELETE", Key: "k0"})
+	if len(sm.segments) < 12 {
+		t.Fatalf("only %d segments, want 12 or more", len(sm.segments))
+	}
+	if err := sm.performCompaction(); err != nil {
+		t.Fatalf("performCompaction failed: %v", err)
+	}
+	sm.Close()
+
+	sm, err = NewSegmentManager(dir)
+	if err != nil {
+		t.Fatalf("NewSegmentManager failed: %v", err)
+	}
+	defer sm.Close()
+	items, err := sm.LoadAllEntries()
+	if err != nil {
+		t.Fatalf("LoadAllEntries failed: %v", err)
+	}
+	if len(items) != 9 {
+		t.Errorf("%d keys after compaction, want 9", len(items))
+	}
+	// Equal timestamps: the last write in the log wins
+	if v := items["k9"]; v == nil || v.String() != "99" {
+		t.Errorf("k9 = %v after compaction, want 99", v)
+	}
+}
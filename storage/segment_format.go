@@ -0,0 +1,380 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// A segment file starts with a header line naming its version. Version 2
+// segments follow it with binary records: a two-byte marker, the CRC32 of
+// the rest of the record, the length of the entry as a uvarint and the entry
+// as a LogEntry message (see proto/value.proto). The marker lets a reader skip a
+// corrupt record and find the next one. Version 1 segments hold one entry
+// per line, the CRC32 of the entry JSON as 8 hex digits, a space and the
+// JSON; segments written before version 1 have no header and plain JSON
+// lines. Both are still read.
+const (
+	segmentMagic   = "crdt-redis-segment"
+	segmentVersion = 2
+)
+
+var recordMarker = [2]byte{0xc5, 0x9e}
+
+// recordHeaderSize is the size of a record before its entry, with a one
+// byte length
+const recordHeaderSize = len(recordMarker) + 4 + 1
+
+// Segment recovery modes, chosen with segment-recovery, decide what startup
+// does with corrupt entries
+const (
+	RecoveryStrict   = "strict"   // refuse to start
+	RecoverySkip     = "skip"     // load every valid entry and report the corrupt ones
+	RecoveryTruncate = "truncate" // cut the segment at its first corrupt entry
+)
+
+// RecoveryModes lists the supported segment-recovery values
+var RecoveryModes = []string{RecoveryStrict, RecoverySkip, RecoveryTruncate}
+
+func validRecoveryMode(mode string) error {
+	for _, m := range RecoveryModes {
+		if m == mode {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid segment recovery mode: %s (valid: %v)", mode, RecoveryModes)
+}
+
+// segmentHeader is the first line of a segment file
+func segmentHeader() []byte {
+	return []byte(fmt.Sprintf("%s v%d\n", segmentMagic, segmentVersion))
+}
+
+// encodeEntry renders an entry as a segment record
+func encodeEntry(entry *LogEntry) ([]byte, error) {
+	payload, err := marshalLogEntry(entry)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode entry: %v", err)
+	}
+	record := make([]byte, 0, recordHeaderSize+binary.MaxVarintLen64+len(payload))
+	record = append(record, recordMarker[:]...)
+	record = append(record, 0, 0, 0, 0)
+	record = binary.AppendUvarint(record, uint64(len(payload)))
+	record = append(record, payload...)
+	binary.BigEndian.PutUint32(record[len(recordMarker):], crc32.ChecksumIEEE(record[len(recordMarker)+4:]))
+	return record, nil
+}
+
+// decodeRecord parses the record at the start of buf, returning its size or
+// why it is invalid
+func decodeRecord(buf []byte) (*LogEntry, int, string) {
+	if len(buf) < recordHeaderSize {
+		return nil, 0, "torn write"
+	}
+	if buf[0] != recordMarker[0] || buf[1] != recordMarker[1] {
+		return nil, 0, "invalid record"
+	}
+	start := len(recordMarker) + 4
+	length, n := binary.Uvarint(buf[start:])
+	if n == 0 {
+		return nil, 0, "torn write"
+	}
+	if n < 0 {
+		return nil, 0, "invalid record"
+	}
+	if length > uint64(len(buf)-start-n) {
+		return nil, 0, "torn write"
+	}
+	size := start + n + int(length)
+	if crc32.ChecksumIEEE(buf[start:size]) != binary.BigEndian.Uint32(buf[len(recordMarker):]) {
+		return nil, 0, "checksum mismatch"
+	}
+	entry, err := unmarshalLogEntry(buf[start+n : size])
+	if err != nil {
+		return nil, 0, fmt.Sprintf("invalid entry: %v", err)
+	}
+	return entry, size, ""
+}
+
+// nextRecord returns the offset of the first valid record in buf after
+// from, or -1 if there is none
+func nextRecord(buf []byte, from int) int {
+	for from < len(buf) {
+		i := bytes.Index(buf[from:], recordMarker[:])
+		if i < 0 {
+			return -1
+		}
+		if entry, _, _ := decodeRecord(buf[from+i:]); entry != nil {
+			return from + i
+		}
+		from += i + 1
+	}
+	return -1
+}
+
+// CorruptEntry locates a segment line that failed validation
+type CorruptEntry struct {
+	Offset int64
+	Length int64
+	Reason string
+}
+
+// SegmentReport describes what was found in a segment file
+type SegmentReport struct {
+	Path    string
+	Version int // 0 for segments written before headers and checksums
+	Size    int64
+	Entries int // valid entries
+	Corrupt []CorruptEntry
+}
+
+// Clean reports whether the segment has no corrupt entries
+func (r *SegmentReport) Clean() bool {
+	return len(r.Corrupt) == 0
+}
+
+// ValidEnd returns the offset of the first corrupt entry, or the file size if
+// there is none
+func (r *SegmentReport) ValidEnd() int64 {
+	if len(r.Corrupt) > 0 {
+		return r.Corrupt[0].Offset
+	}
+	return r.Size
+}
+
+// ReadSegmentFile parses the segment at path and calls fn with the offset of
+// every valid entry. Corrupt entries are skipped and listed in the report; an
+// error means the file could not be read at all.
+func ReadSegmentFile(path string, fn func(offset int64, entry *LogEntry)) (*SegmentReport, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &SegmentReport{Path: path, Size: int64(len(data))}
+	body := 0
+	if bytes.HasPrefix(data, []byte(segmentMagic+" v")) {
+		end := bytes.IndexByte(data, '\n')
+		if end < 0 {
+			// A crash while the header was written
+			report.Corrupt = append(report.Corrupt, CorruptEntry{0, int64(len(data)), "torn write"})
+			return report, nil
+		}
+		body = end + 1
+		version, err := strconv.Atoi(string(data[len(segmentMagic)+2 : end]))
+		switch {
+		case err != nil || version < 1:
+			report.Corrupt = append(report.Corrupt, CorruptEntry{0, int64(body), "invalid header"})
+		case version > segmentVersion:
+			return nil, fmt.Errorf("unsupported segment version %d in %s", version, path)
+		default:
+			report.Version = version
+		}
+	}
+
+	if report.Version >= 2 {
+		readRecords(data, body, report, fn)
+	} else {
+		readLines(data, body, report, fn)
+	}
+	return report, nil
+}
+
+// readRecords reads the binary records of a version 2 segment from offset
+func readRecords(data []byte, offset int, report *SegmentReport, fn func(int64, *LogEntry)) {
+	for offset < len(data) {
+		entry, size, reason := decodeRecord(data[offset:])
+		if entry != nil {
+			report.Entries++
+			fn(int64(offset), entry)
+			offset += size
+			continue
+		}
+		next := nextRecord(data, offset+1)
+		if next < 0 {
+			next = len(data)
+		} else if reason == "torn write" {
+			// A damaged length, as valid records follow
+			reason = "invalid record"
+		}
+		report.Corrupt = append(report.Corrupt, CorruptEntry{int64(offset), int64(next - offset), reason})
+		offset = next
+	}
+}
+
+// readLines reads the entry lines of a version 0 or 1 segment from offset
+func readLines(data []byte, offset int, report *SegmentReport, fn func(int64, *LogEntry)) {
+	for offset < len(data) {
+		start := offset
+		end := bytes.IndexByte(data[start:], '\n')
+		if end < 0 {
+			// A crash between writing an entry and its newline
+			report.Corrupt = append(report.Corrupt, CorruptEntry{int64(start), int64(len(data) - start), "torn write"})
+			return
+		}
+		line := data[start : start+end]
+		offset = start + end + 1
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+
+		entry, reason := decodeEntryLine(line, report.Version)
+		if entry == nil {
+			report.Corrupt = append(report.Corrupt, CorruptEntry{int64(start), int64(len(line)) + 1, reason})
+			continue
+		}
+		report.Entries++
+		fn(int64(start), entry)
+	}
+}
+
+// decodeEntryLine parses a segment line, returning why it is invalid if it is
+func decodeEntryLine(line []byte, version int) (*LogEntry, string) {
+	data := line
+	if len(line) > 9 && line[8] == ' ' && isHex(line[:8]) {
+		sum, _ := strconv.ParseUint(string(line[:8]), 16, 32)
+		data = line[9:]
+		if crc32.ChecksumIEEE(data) != uint32(sum) {
+			return nil, "checksum mismatch"
+		}
+	} else if version >= 1 {
+		return nil, "missing checksum"
+	}
+
+	var entry LogEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, fmt.Sprintf("invalid entry: %v", err)
+	}
+	return &entry, ""
+}
+
+func isHex(b []byte) bool {
+	_, err := hex.DecodeString(string(b))
+	return err == nil
+}
+
+// RepairSegment rewrites the segment at path without its corrupt entries:
+// with RecoverySkip every valid entry is kept, with RecoveryTruncate only
+// those before the first corruption. A clean segment is left untouched. It
+// returns the report for the file as it was before the repair.
+func RepairSegment(path, mode string) (*SegmentReport, error) {
+	if err := validRecoveryMode(mode); err != nil {
+		return nil, err
+	}
+	if mode == RecoveryStrict {
+		return nil, fmt.Errorf("strict mode does not repair segments")
+	}
+
+	type located struct {
+		offset int64
+		entry  *LogEntry
+	}
+	var entries []located
+	report, err := ReadSegmentFile(path, func(offset int64, entry *LogEntry) {
+		entries = append(entries, located{offset, entry})
+	})
+	if err != nil || report.Clean() {
+		return report, err
+	}
+
+	file, err := os.Create(path + ".tmp")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create repaired segment: %v", err)
+	}
+	defer os.Remove(path + ".tmp")
+	defer file.Close()
+
+	w := bufio.NewWriter(file)
+	w.Write(segmentHeader())
+	for _, e := range entries {
+		if mode == RecoveryTruncate && e.offset >= report.ValidEnd() {
+			break
+		}
+		record, err := encodeEntry(e.entry)
+		if err != nil {
+			return nil, err
+		}
+		w.Write(record)
+	}
+	if err := w.Flush(); err != nil {
+		return nil, fmt.Errorf("failed to write repaired segment: %v", err)
+	}
+	if err := file.Sync(); err != nil {
+		return nil, fmt.Errorf("failed to sync repaired segment: %v", err)
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return nil, fmt.Errorf("failed to install repaired segment: %v", err)
+	}
+	return report, nil
+}
+
+// droppedSuffix marks a segment taken out of the log by truncate recovery.
+// Its entries follow a truncated segment, so replaying them would apply
+// writes without the ones that were cut; the file is kept for inspection.
+const droppedSuffix = ".dropped"
+
+// DropSegment takes the segment at path out of the log and returns the path
+// it was moved to
+func DropSegment(path string) (string, error) {
+	dropped := path + droppedSuffix
+	if err := os.Rename(path, dropped); err != nil {
+		return "", fmt.Errorf("failed to drop segment %s: %v", path, err)
+	}
+	return dropped, nil
+}
+
+// DroppedSegments returns the segments in dir that were taken out of the log
+func DroppedSegments(dir string) ([]string, error) {
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	for _, file := range files {
+		name := strings.TrimSuffix(file.Name(), droppedSuffix)
+		if _, _, ok := parseSegmentName(name); ok && name != file.Name() && !file.IsDir() {
+			paths = append(paths, filepath.Join(dir, file.Name()))
+		}
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// SegmentFiles returns the segment files in dir in log order
+func SegmentFiles(dir string) ([]string, error) {
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	type segmentFile struct {
+		path      string
+		id        int64
+		compacted bool
+	}
+	var found []segmentFile
+	for _, file := range files {
+		if id, compacted, ok := parseSegmentName(file.Name()); ok && !file.IsDir() {
+			found = append(found, segmentFile{filepath.Join(dir, file.Name()), id, compacted})
+		}
+	}
+	sort.Slice(found, func(i, j int) bool {
+		if found[i].id != found[j].id {
+			return found[i].id < found[j].id
+		}
+		return found[i].compacted && !found[j].compacted
+	})
+	paths := make([]string, len(found))
+	for i, f := range found {
+		paths[i] = f.path
+	}
+	return paths, nil
+}
@@ -0,0 +1,233 @@
+package storage
+
+import (
+	"fmt"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeTestSegments stores keys k0..k9 and returns the segment holding them
+func writeTestSegments(t *testing.T, dir string) string {
+	t.Helper()
+	store, err := NewStore(dir, "", 0)
+	if err != nil {
+		t.Fatalf("NewStore failed: %v", err)
+	}
+	for i := 0; i < 10; i++ {
+		store.Set(fmt.Sprintf("k%d", i), NewStringValue(fmt.Sprint(i), time.Now().UnixNano(), "r1"), nil)
+	}
+	store.Close()
+	return filepath.Join(dir, "segments", "segment-0.log")
+}
+
+// flipByte corrupts the entry for key in the segment at path
+func flipByte(t *testing.T, path, key string) {
+	t.Helper()
+	offset := int64(-1)
+	ReadSegmentFile(path, func(o int64, entry *LogEntry) {
+		if entry.Key == key {
+			offset = o
+		}
+	})
+	if offset < 0 {
+		t.Fatalf("%s not found in %s", key, path)
+	}
+	data, _ := os.ReadFile(path)
+	data[offset+int64(recordHeaderSize)+2] ^= 0x01
+	os.WriteFile(path, data, 0644)
+}
+
+func openWithRecovery(dir, mode string) (*Store, error) {
+	return NewStoreWithOptions(dir, StoreOptions{SegmentRecovery: mode})
+}
+
+func liveKeys(store *Store) []string {
+	var keys []string
+	for i := 0; i < 10; i++ {
+		if _, ok := store.Get(fmt.Sprintf("k%d", i)); ok {
+			keys = append(keys, fmt.Sprintf("k%d", i))
+		}
+	}
+	return keys
+}
+
+func TestSegmentBitFlip(t *testing.T) {
+	for _, tc := range []struct {
+		mode string
+		keys int
+	}{
+		{RecoveryStrict, -1},
+		{RecoverySkip, 9},
+		{RecoveryTruncate, 4},
+	} {
+		t.Run(tc.mode, func(t *testing.T) {
+			dir := t.TempDir()
+			path := writeTestSegments(t, dir)
+			flipByte(t, path, "k4")
+
+			store, err := openWithRecovery(dir, tc.mode)
+			if tc.keys < 0 {
+				if err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+					t.Fatalf("strict open = %v, want a checksum error", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("open failed: %v", err)
+			}
+			defer store.Close()
+			if keys := liveKeys(store); len(keys) != tc.keys {
+				t.Errorf("recovered %v, want %d keys", keys, tc.keys)
+			}
+			if n := store.GetPersistenceStats()["corrupt_entries"]; n != int64(1) {
+				t.Errorf("corrupt_entries = %v, want 1", n)
+			}
+		})
+	}
+}
+
+func TestSegmentTornWrite(t *testing.T) {
+	dir := t.TempDir()
+	path := writeTestSegments(t, dir)
+	info, _ := os.Stat(path)
+	os.Truncate(path, info.Size()-5)
+
+	if _, err := openWithRecovery(dir, RecoveryStrict); err == nil || !strings.Contains(err.Error(), "torn write") {
+		t.Fatalf("strict open = %v, want a torn write error", err)
+	}
+
+	store, err := openWithRecovery(dir, RecoveryTruncate)
+	if err != nil {
+		t.Fatalf("open failed: %v", err)
+	}
+	if keys := liveKeys(store); len(keys) != 9 {
+		t.Errorf("recovered %v, want k0..k8", keys)
+	}
+	store.Close()
+
+	// The torn tail was cut off, so even strict mode now starts
+	report, err := ReadSegmentFile(path, func(int64, *LogEntry) {})
+	if err != nil || !report.Clean() || report.Entries != 9 {
+		t.Errorf("segment after truncation: %+v %v", report, err)
+	}
+	store, err = openWithRecovery(dir, RecoveryStrict)
+	if err != nil {
+		t.Fatalf("strict open after truncation failed: %v", err)
+	}
+	store.Close()
+}
+
+func TestTruncateDropsLaterSegments(t *testing.T) {
+	dir := t.TempDir()
+	sm, err := NewSegmentManager(dir)
+	if err != nil {
+		t.Fatalf("NewSegmentManager failed: %v", err)
+	}
+	sm.maxSegmentSize = 256
+	for i := 0; i < 30; i++ {
+		sm.WriteEntry(&LogEntry{Timestamp: 1, Operation: "SET", Key: fmt.Sprintf("k%d", i), Value: NewStringValue(fmt.Sprint(i), 1, "r1")})
+	}
+	written := len(sm.segments)
+	sm.Close()
+	if written < 3 {
+		t.Fatalf("only %d segments, want 3 or more", written)
+	}
+	flipByte(t, filepath.Join(dir, "segment-0.log"), "k1")
+
+	sm, err = NewSegmentManager(dir)
+	if err != nil {
+		t.Fatalf("NewSegmentManager failed: %v", err)
+	}
+	items, err := sm.LoadAllEntries()
+	if err != nil {
+		t.Fatalf("LoadAllEntries failed: %v", err)
+	}
+	if len(items) != 1 || items["k0"] == nil {
+		t.Errorf("recovered %d keys, want only k0 from before the corruption", len(items))
+	}
+	dropped, _ := DroppedSegments(dir)
+	if len(dropped) != written-1 || sm.GetStats()["dropped_segments"] != int64(written-1) {
+		t.Errorf("dropped %v, want the %d segments after segment-0", dropped, written-1)
+	}
+	sm.WriteEntry(&LogEntry{Timestamp: 2, Operation: "SET", Key: "after", Value: NewStringValue("x", 2, "r1")})
+	sm.Close()
+
+	// Writes made after recovery replay, and the log is clean again
+	sm, err = NewSegmentManager(dir)
+	if err != nil {
+		t.Fatalf("NewSegmentManager failed: %v", err)
+	}
+	defer sm.Close()
+	sm.SetRecoveryMode(RecoveryStrict)
+	items, err = sm.LoadAllEntries()
+	if err != nil {
+		t.Fatalf("strict load after recovery failed: %v", err)
+	}
+	if len(items) != 2 || items["k0"] == nil || items["after"] == nil {
+		t.Errorf("recovered %d keys, want k0 and after", len(items))
+	}
+}
+
+func TestRepairSegment(t *testing.T) {
+	for _, tc := range []struct {
+		mode    string
+		entries int
+	}{
+		{RecoverySkip, 8},
+		{RecoveryTruncate, 3},
+	} {
+		t.Run(tc.mode, func(t *testing.T) {
+			path := writeTestSegments(t, t.TempDir())
+			flipByte(t, path, "k3")
+			flipByte(t, path, "k7")
+
+			report, err := RepairSegment(path, tc.mode)
+			if err != nil {
+				t.Fatalf("RepairSegment failed: %v", err)
+			}
+			if len(report.Corrupt) != 2 {
+				t.Errorf("report lists %d corrupt entries, want 2", len(report.Corrupt))
+			}
+			report, err = ReadSegmentFile(path, func(int64, *LogEntry) {})
+			if err != nil || !report.Clean() || report.Entries != tc.entries || report.Version != segmentVersion {
+				t.Errorf("repaired segment: %+v %v, want %d clean entries", report, err, tc.entries)
+			}
+		})
+	}
+}
+
+func TestLegacySegment(t *testing.T) {
+	dir := t.TempDir()
+	segDir := filepath.Join(dir, "segments")
+	os.MkdirAll(segDir, 0755)
+	// Written before headers and checksums
+	legacy := `{"timestamp":1,"operation":"SET","key":"a","value":{"type":0,"data":"eA==","timestamp":1,"replica_id":"r1","vector_clock":null}}
+{"timestamp":2,"operation":"SET","key":"b","value":{"type":0,"data":"eQ==","timestamp":2,"replica_id":"r1","vector_clock":null}}
+`
+	os.WriteFile(filepath.Join(segDir, "segment-0.log"), []byte(legacy), 0644)
+	// Written with checksummed JSON lines
+	entry := `{"timestamp":3,"operation":"SET","key":"c","value":{"type":0,"data":"eg==","timestamp":3,"replica_id":"r1","vector_clock":{"clock":{"r1":1}}}}`
+	v1 := fmt.Sprintf("%s v1\n%08x %s\n", segmentMagic, crc32.ChecksumIEEE([]byte(entry)), entry)
+	os.WriteFile(filepath.Join(segDir, "segment-1.log"), []byte(v1), 0644)
+
+	store, err := openWithRecovery(dir, RecoveryStrict)
+	if err != nil {
+		t.Fatalf("open failed: %v", err)
+	}
+	defer store.Close()
+	if v, ok := store.Get("b"); !ok || v.String() != "y" {
+		t.Errorf("b = %v %v from a legacy segment", v, ok)
+	}
+	if v, ok := store.Get("c"); !ok || v.String() != "z" || v.VectorClock.GetTime("r1") != 1 {
+		t.Errorf("c = %v %v from a version 1 segment", v, ok)
+	}
+
+	os.WriteFile(filepath.Join(segDir, "segment-9.log"), []byte(segmentMagic+" v99\n"), 0644)
+	if _, err := ReadSegmentFile(filepath.Join(segDir, "segment-9.log"), func(int64, *LogEntry) {}); err == nil {
+		t.Error("segment from a newer version was read")
+	}
+}
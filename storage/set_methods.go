@@ -6,7 +6,8 @@ import (
 )
 
 // SAdd adds members to a set
-func (s *Store) SAdd(key string, members ...string) (int64, error) {
+func (s *Store) SAdd(key string, members []string, opts ...OpOption) (int64, error) {
+	defer s.awaitDurable()
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
@@ -14,16 +15,16 @@ func (s *Store) SAdd(key string, members ...string) (int64, error) {
 	var set *CRDTSet
 	var added int64
 
-	if val, exists := s.items[key]; exists && val.Type == TypeSet {
+	val, exists := s.items.Get(key)
+	if exists && val.Type == TypeSet {
 		set = val.Set()
 		if set == nil {
 			return 0, fmt.Errorf("invalid set data")
 		}
 	} else {
 		// Create new set
-		newVal := NewSetValue(timestamp, "")
-		set = newVal.Set()
-		s.items[key] = newVal
+		val = NewSetValue(timestamp, "")
+		set = val.Set()
 	}
 
 	// Add members and count new additions
@@ -35,26 +36,25 @@ func (s *Store) SAdd(key string, members ...string) (int64, error) {
 	}
 
 	// Update the value
-	s.items[key].SetSet(set, timestamp)
-
-	// Update Redis
-	if err := s.redis.Set(s.ctx, key, s.items[key], nil); err != nil {
-		return added, fmt.Errorf("failed to write to Redis: %v", err)
-	}
+	val.SetSet(set, timestamp)
 
-	if err := s.save(); err != nil {
+	if err := s.commit(key, val); err != nil {
 		return added, fmt.Errorf("failed to save to disk: %v", err)
 	}
 
+	if added > 0 {
+		s.keyChanged("sadd", key, writeOptions(opts))
+	}
 	return added, nil
 }
 
 // SRem removes members from a set
-func (s *Store) SRem(key string, members ...string) (int64, error) {
+func (s *Store) SRem(key string, members []string, opts ...OpOption) (int64, error) {
+	defer s.awaitDurable()
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	val, exists := s.items[key]
+	val, exists := s.items.Get(key)
 	if !exists || val.Type != TypeSet {
 		return 0, nil
 	}
@@ -76,14 +76,10 @@ func (s *Store) SRem(key string, members ...string) (int64, error) {
 		// Update the value
 		val.SetSet(set, timestamp)
 
-		// Update Redis
-		if err := s.redis.Set(s.ctx, key, val, nil); err != nil {
-			return removed, fmt.Errorf("failed to write to Redis: %v", err)
-		}
-
-		if err := s.save(); err != nil {
+		if err := s.commit(key, val); err != nil {
 			return removed, fmt.Errorf("failed to save to disk: %v", err)
 		}
+		s.keyChanged("srem", key, writeOptions(opts))
 	}
 
 	return removed, nil
@@ -94,7 +90,8 @@ func (s *Store) SMembers(key string) ([]string, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	val, exists := s.items[key]
+	val, exists := s.items.Get(key)
+	s.accessed(key)
 	if !exists || val.Type != TypeSet {
 		return []string{}, nil
 	}
@@ -112,7 +109,8 @@ func (s *Store) SCard(key string) (int64, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	val, exists := s.items[key]
+	val, exists := s.items.Get(key)
+	s.accessed(key)
 	if !exists || val.Type != TypeSet {
 		return 0, nil
 	}
@@ -130,7 +128,8 @@ func (s *Store) SIsMember(key string, member string) (bool, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	val, exists := s.items[key]
+	val, exists := s.items.Get(key)
+	s.accessed(key)
 	if !exists || val.Type != TypeSet {
 		return false, nil
 	}
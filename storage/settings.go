@@ -0,0 +1,59 @@
+package storage
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// GCInterval returns how often tombstone garbage collection runs
+func (s *Store) GCInterval() time.Duration {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.gcInterval
+}
+
+// SetGCInterval changes how often tombstone garbage collection runs; the
+// running GC loop switches to the new interval immediately
+func (s *Store) SetGCInterval(d time.Duration) error {
+	if d <= 0 {
+		return fmt.Errorf("GC interval must be positive")
+	}
+	s.mu.Lock()
+	s.gcInterval = d
+	s.mu.Unlock()
+	select {
+	case s.gcReset <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// SetTombstoneTTL changes how long tombstones are kept before GC removes them
+func (s *Store) SetTombstoneTTL(d time.Duration) error {
+	if d < 0 {
+		return fmt.Errorf("tombstone TTL cannot be negative")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.TombstoneTTL = d
+	return nil
+}
+
+// MaxMemory returns the memory limit in bytes; 0 means no limit
+func (s *Store) MaxMemory() int64 {
+	return atomic.LoadInt64(&s.maxMemory)
+}
+
+// SetMaxMemory sets the memory limit in bytes; 0 means no limit. Lowering
+// the limit evicts keys right away when the policy allows it.
+func (s *Store) SetMaxMemory(bytes int64) error {
+	if bytes < 0 {
+		return fmt.Errorf("max memory cannot be negative")
+	}
+	atomic.StoreInt64(&s.maxMemory, bytes)
+	if err := s.FreeMemory(); err != nil && err != ErrOOM {
+		return err
+	}
+	return nil
+}
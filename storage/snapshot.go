@@ -0,0 +1,226 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A snapshot file holds the whole keyspace as of a segment boundary:
+// snapshot-<N>.snap covers every entry in the segments before segment N, so
+// recovery loads it and replays segments from N on. The file is a header
+// line, one SET entry per key, and a trailer with the key count and a CRC32
+// of everything before it, all as JSON lines.
+
+// snapshotsKept is how many snapshots are kept; segments are removed once
+// the oldest kept snapshot covers them, so a damaged newest snapshot can
+// still be recovered from the one before it
+const snapshotsKept = 2
+
+const snapshotVersion = 2
+
+type snapshotHeader struct {
+	Segment int64 `json:"segment"`           // first segment not covered by the snapshot
+	Created int64 `json:"created"`           // unix time the snapshot was taken
+	Version int   `json:"version,omitempty"` // absent before version 2
+}
+
+type snapshotTrailer struct {
+	Keys  int64  `json:"keys"`
+	CRC32 uint32 `json:"crc32"`
+}
+
+// parseSnapshotName extracts the segment from a file name of the form
+// snapshot-<segment>.snap
+func parseSnapshotName(name string) (int64, bool) {
+	if !strings.HasPrefix(name, "snapshot-") || !strings.HasSuffix(name, ".snap") {
+		return 0, false
+	}
+	segment, err := strconv.ParseInt(strings.TrimSuffix(strings.TrimPrefix(name, "snapshot-"), ".snap"), 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return segment, true
+}
+
+func (sm *SegmentManager) snapshotPath(segment int64) string {
+	return filepath.Join(sm.dataDir, fmt.Sprintf("snapshot-%d.snap", segment))
+}
+
+// Rotate starts a new segment and returns its ID, so that every entry
+// appended so far is in earlier segments. Callers taking a snapshot must
+// keep appends out until they have captured the state.
+func (sm *SegmentManager) Rotate() (int64, error) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	if sm.currentSize <= int64(len(segmentHeader())) {
+		return sm.currentSegmentID, nil // nothing appended yet
+	}
+	if err := sm.rotateSegment(); err != nil {
+		return 0, err
+	}
+	return sm.currentSegmentID, nil
+}
+
+// WriteSnapshot writes the keyspace visited by ranger as the snapshot for
+// segment, then removes snapshots and segments it makes redundant
+func (sm *SegmentManager) WriteSnapshot(segment int64, ranger func(start string, fn func(key string, value *Value) bool) error) error {
+	path := sm.snapshotPath(segment)
+	file, err := os.Create(path + ".tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create snapshot: %v", err)
+	}
+	defer os.Remove(path + ".tmp")
+	defer file.Close()
+
+	if _, err := encodeSnapshot(file, segment, ranger); err != nil {
+		return fmt.Errorf("failed to write snapshot: %v", err)
+	}
+	if err := file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync snapshot: %v", err)
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return fmt.Errorf("failed to install snapshot: %v", err)
+	}
+
+	sm.installSnapshot(segment)
+	return nil
+}
+
+// encodeSnapshot writes the keyspace visited by ranger to w as a snapshot
+// for segment and returns the number of keys written
+func encodeSnapshot(w io.Writer, segment int64, ranger func(start string, fn func(key string, value *Value) bool) error) (int64, error) {
+	crc := crc32.NewIEEE()
+	bw := bufio.NewWriter(io.MultiWriter(w, crc))
+	header := snapshotHeader{Segment: segment, Created: time.Now().Unix(), Version: snapshotVersion}
+	if err := json.NewEncoder(bw).Encode(header); err != nil {
+		return 0, err
+	}
+	var keys int64
+	var writeErr error
+	err := ranger("", func(key string, value *Value) bool {
+		var record []byte
+		if record, writeErr = encodeEntry(&LogEntry{Timestamp: value.Timestamp, Operation: "SET", Key: key, Value: value}); writeErr != nil {
+			return false
+		}
+		if _, writeErr = bw.Write(record); writeErr != nil {
+			return false
+		}
+		keys++
+		return true
+	})
+	if err == nil {
+		err = writeErr
+	}
+	if err == nil {
+		err = bw.WriteByte('\n')
+	}
+	if err == nil {
+		err = bw.Flush()
+	}
+	if err != nil {
+		return 0, err
+	}
+	// The trailer is written past the checksum it carries
+	if err := json.NewEncoder(w).Encode(snapshotTrailer{Keys: keys, CRC32: crc.Sum32()}); err != nil {
+		return 0, err
+	}
+	return keys, nil
+}
+
+// installSnapshot records a new snapshot and removes the snapshots and
+// segments that are no longer needed for recovery
+func (sm *SegmentManager) installSnapshot(segment int64) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	sm.snapshots = append(sm.snapshots, segment)
+	for len(sm.snapshots) > snapshotsKept {
+		os.Remove(sm.snapshotPath(sm.snapshots[0]))
+		sm.snapshots = sm.snapshots[1:]
+	}
+
+	oldest := sm.snapshots[0]
+	kept := sm.segments[:0]
+	for _, segmentPath := range sm.segments {
+		if id, _, _ := parseSegmentName(filepath.Base(segmentPath)); id < oldest {
+			if err := os.Remove(segmentPath); err != nil {
+				fmt.Printf("Warning: failed to remove old segment %s: %v\n", segmentPath, err)
+			}
+			continue
+		}
+		kept = append(kept, segmentPath)
+	}
+	sm.segments = kept
+}
+
+// readSnapshot loads a snapshot file
+func readSnapshot(path string) (map[string]*Value, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return decodeSnapshot(data)
+}
+
+// decodeSnapshot parses a snapshot, verifying its checksum and key count
+func decodeSnapshot(data []byte) (map[string]*Value, error) {
+	// The trailer is the last line; the checksum covers everything before it
+	body := bytes.TrimSuffix(data, []byte("\n"))
+	cut := bytes.LastIndexByte(body, '\n')
+	if cut < 0 {
+		return nil, fmt.Errorf("snapshot is truncated")
+	}
+	var trailer snapshotTrailer
+	if err := json.Unmarshal(body[cut+1:], &trailer); err != nil {
+		return nil, fmt.Errorf("snapshot is truncated: %v", err)
+	}
+	body = body[:cut+1]
+	if crc32.ChecksumIEEE(body) != trailer.CRC32 {
+		return nil, fmt.Errorf("snapshot checksum mismatch")
+	}
+
+	end := bytes.IndexByte(body, '\n')
+	var header snapshotHeader
+	if err := json.Unmarshal(body[:end], &header); err != nil {
+		return nil, fmt.Errorf("invalid snapshot header: %v", err)
+	}
+	items := make(map[string]*Value)
+	if header.Version >= 2 {
+		records := body[end+1 : len(body)-1]
+		for len(records) > 0 {
+			entry, size, reason := decodeRecord(records)
+			if entry == nil {
+				return nil, fmt.Errorf("invalid snapshot entry: %s", reason)
+			}
+			if entry.Value != nil {
+				items[entry.Key] = entry.Value
+			}
+			records = records[size:]
+		}
+	} else {
+		dec := json.NewDecoder(bytes.NewReader(body[end+1:]))
+		for dec.More() {
+			var entry LogEntry
+			if err := dec.Decode(&entry); err != nil {
+				return nil, fmt.Errorf("invalid snapshot entry: %v", err)
+			}
+			if entry.Value != nil {
+				items[entry.Key] = entry.Value
+			}
+		}
+	}
+	if int64(len(items)) != trailer.Keys {
+		return nil, fmt.Errorf("snapshot has %d keys, trailer says %d", len(items), trailer.Keys)
+	}
+	return items, nil
+}
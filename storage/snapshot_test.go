@@ -0,0 +1,195 @@
+package storage
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSnapshotRecovery(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewStore(dir, "", 0)
+	if err != nil {
+		t.Fatalf("NewStore failed: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 10; j++ {
+			store.Set(fmt.Sprintf("k%d", j), NewStringValue(fmt.Sprint(i), time.Now().UnixNano(), "r1"), nil)
+		}
+		store.Delete(fmt.Sprintf("k%d", i))
+		if err := store.Save(); err != nil {
+			t.Fatalf("Save failed: %v", err)
+		}
+	}
+	// Written after the last snapshot, so recovered by replay
+	store.Set("k0", NewStringValue("after", time.Now().UnixNano(), "r1"), nil)
+	store.Delete("k9")
+	if st := store.SaveStatus(); st.Saves != 3 || st.Changes != 2 {
+		t.Errorf("save status = %+v, want 3 saves and 2 changes", st)
+	}
+	store.Close()
+
+	segDir := filepath.Join(dir, "segments")
+	snaps, _ := filepath.Glob(filepath.Join(segDir, "*.snap"))
+	if len(snaps) != snapshotsKept {
+		t.Errorf("%d snapshots on disk, want %d", len(snaps), snapshotsKept)
+	}
+	// Segments covered by the older kept snapshot are gone
+	segs, _ := filepath.Glob(filepath.Join(segDir, "segment-*.log"))
+	if len(segs) != 2 {
+		t.Errorf("%d segments on disk, want 2: %v", len(segs), segs)
+	}
+
+	check := func() {
+		t.Helper()
+		store, err := NewStore(dir, "", 0)
+		if err != nil {
+			t.Fatalf("NewStore failed: %v", err)
+		}
+		defer store.Close()
+		for j := 0; j < 10; j++ {
+			key := fmt.Sprintf("k%d", j)
+			v, ok := store.Get(key)
+			switch {
+			case j == 0:
+				if !ok || v.String() != "after" {
+					t.Errorf("%s = %v %v, want after", key, v, ok)
+				}
+			case j == 2 || j == 9:
+				if ok {
+					t.Errorf("deleted key %s was recovered", key)
+				}
+			case !ok || v.String() != "2":
+				t.Errorf("%s = %v %v, want 2", key, v, ok)
+			}
+		}
+	}
+	check()
+
+	// A damaged newest snapshot falls back to the one before it
+	sm, _ := NewSegmentManager(segDir)
+	newest := sm.snapshotPath(sm.snapshots[len(sm.snapshots)-1])
+	sm.Close()
+	data, _ := os.ReadFile(newest)
+	data[len(data)/2] ^= 0xff
+	os.WriteFile(newest, data, 0644)
+	check()
+}
+
+func TestBGSaveInProgress(t *testing.T) {
+	store, err := NewStore(t.TempDir(), "", 0)
+	if err != nil {
+		t.Fatalf("NewStore failed: %v", err)
+	}
+	defer store.Close()
+	store.Set("a", NewStringValue("1", 1, "r1"), nil)
+
+	write, err := store.beginSnapshot()
+	if err != nil {
+		t.Fatalf("beginSnapshot failed: %v", err)
+	}
+	// Writers are not blocked while the snapshot is pending
+	store.Set("b", NewStringValue("2", 1, "r1"), nil)
+	if err := store.BGSave(); err != ErrSaveInProgress {
+		t.Errorf("BGSave during a save = %v", err)
+	}
+	if err := write(); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	if st := store.SaveStatus(); st.InProgress || !st.LastSaveOK || st.Changes != 1 {
+		t.Errorf("save status = %+v", st)
+	}
+}
+
+func TestScheduledSave(t *testing.T) {
+	store, err := NewStore(t.TempDir(), "", 0)
+	if err != nil {
+		t.Fatalf("NewStore failed: %v", err)
+	}
+	defer store.Close()
+	rules, err := ParseSaveRules("1 2")
+	if err != nil {
+		t.Fatalf("ParseSaveRules failed: %v", err)
+	}
+	store.SetSaveRules(rules)
+
+	store.Set("a", NewStringValue("1", 1, "r1"), nil)
+	time.Sleep(2100 * time.Millisecond)
+	if st := store.SaveStatus(); st.Saves != 0 {
+		t.Fatalf("saved after 1 change with a rule for 2")
+	}
+	store.Set("b", NewStringValue("1", 1, "r1"), nil)
+	deadline := time.Now().Add(3 * time.Second)
+	for store.SaveStatus().Saves == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("scheduled save did not run")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	for _, spec := range []string{"60", "0 1", "x 1", "60 -1"} {
+		if _, err := ParseSaveRules(spec); err == nil {
+			t.Errorf("ParseSaveRules(%q) succeeded", spec)
+		}
+	}
+}
+
+func TestCheckpointRestore(t *testing.T) {
+	store, err := NewStore(t.TempDir(), "", 0)
+	if err != nil {
+		t.Fatalf("NewStore failed: %v", err)
+	}
+	defer store.Close()
+	store.Set("a", NewStringValue("1", 1, "r1"), nil)
+	store.SAdd("s", []string{"x", "y"})
+	write, err := store.Checkpoint()
+	if err != nil {
+		t.Fatalf("Checkpoint failed: %v", err)
+	}
+	// Writes after the capture are not in the checkpoint
+	store.Set("b", NewStringValue("2", 2, "r1"), nil)
+	var buf bytes.Buffer
+	if keys, err := write(&buf); err != nil || keys != 2 {
+		t.Fatalf("checkpoint wrote %d keys, %v", keys, err)
+	}
+
+	dir := t.TempDir()
+	if keys, err := RestoreSnapshot(dir, bytes.NewReader(buf.Bytes())); err != nil || keys != 2 {
+		t.Fatalf("RestoreSnapshot = %d, %v", keys, err)
+	}
+	if _, err := RestoreSnapshot(dir, bytes.NewReader(buf.Bytes())); err == nil {
+		t.Error("restored into a non-empty directory")
+	}
+	corrupt := append([]byte(nil), buf.Bytes()...)
+	corrupt[len(corrupt)/2] ^= 0xff
+	if _, err := RestoreSnapshot(t.TempDir(), bytes.NewReader(corrupt)); err == nil {
+		t.Error("restored a corrupt snapshot")
+	}
+
+	for i := 0; i < 2; i++ {
+		restored, err := NewStore(dir, "", 0)
+		if err != nil {
+			t.Fatalf("NewStore failed: %v", err)
+		}
+		if v, ok := restored.Get("a"); !ok || v.String() != "1" {
+			t.Errorf("a = %v %v, want 1", v, ok)
+		}
+		if members, _ := restored.SMembers("s"); len(members) != 2 {
+			t.Errorf("s = %v, want 2 members", members)
+		}
+		if _, ok := restored.Get("b"); ok {
+			t.Error("b was written after the checkpoint but restored")
+		}
+		// Writes to the restored store survive a restart
+		restored.Set("c", NewStringValue("3", 3, "r2"), nil)
+		if i == 1 {
+			if v, ok := restored.Get("c"); !ok || v.String() != "3" {
+				t.Errorf("c = %v %v after restart", v, ok)
+			}
+		}
+		restored.Close()
+	}
+}
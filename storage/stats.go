@@ -0,0 +1,164 @@
+package storage
+
+import "time"
+
+// String returns the lowercase type name used in stats and metrics labels
+func (t ValueType) String() string {
+	switch t {
+	case TypeString:
+		return "string"
+	case TypeCounter:
+		return "counter"
+	case TypeFloatCounter:
+		return "float_counter"
+	case TypeList:
+		return "list"
+	case TypeSet:
+		return "set"
+	case TypeHash:
+		return "hash"
+	case TypeZSet:
+		return "zset"
+	default:
+		return "unknown"
+	}
+}
+
+// StoreStats is a point-in-time summary of the in-memory CRDT state
+type StoreStats struct {
+	Keys              map[ValueType]int   // live keys per type
+	MemoryBytes       map[ValueType]int64 // approximate key and payload bytes per type
+	Tombstones        map[ValueType]int   // removed elements awaiting GC per type
+	Expires           int                 // keys with a TTL
+	AvgTTL            time.Duration       // average remaining TTL of keys with a TTL
+	Clock             map[string]int64    // element-wise maximum of all value vector clocks
+	GCRuns            int64
+	GCCleaned         int64 // tombstones removed by GC since start
+	Conflicts         int64 // stale writes discarded by last-write-wins
+	Expired           int64 // keys removed by TTL expiry
+	Evicted           int64 // keys removed to stay under maxmemory
+	BackendDirty      int   // keys whose backend copy is stale after a failed write
+	BackendErrors     int64 // failed backend writes
+	Segments          int
+	Compactions       int64
+	CompactionSeconds float64 // total time spent compacting segments
+}
+
+// Stats walks the store and returns counts, approximate memory and tombstones.
+// Tombstone counting decodes every collection, so it is meant for scrapes, not hot paths.
+// With the disk engine the walk reads the whole dataset from a snapshot, without
+// holding the store lock.
+func (s *Store) Stats() StoreStats {
+	stats := StoreStats{
+		Keys:        make(map[ValueType]int),
+		MemoryBytes: make(map[ValueType]int64),
+		Tombstones:  make(map[ValueType]int),
+		Clock:       make(map[string]int64),
+	}
+
+	now := time.Now()
+	var ttlSum time.Duration
+	walk := func(key string, val *Value) bool {
+		stats.Keys[val.Type]++
+		stats.MemoryBytes[val.Type] += valueSize(key, val)
+		stats.Tombstones[val.Type] += countTombstones(val)
+		if val.TTL != nil {
+			stats.Expires++
+			if remaining := val.ExpireAt.Sub(now); remaining > 0 {
+				ttlSum += remaining
+			}
+		}
+		if val.VectorClock != nil {
+			for replica, tick := range val.VectorClock.Clock {
+				// Values written before a replica ID was assigned carry an empty entry
+				if replica != "" && tick > stats.Clock[replica] {
+					stats.Clock[replica] = tick
+				}
+			}
+		}
+		return true
+	}
+
+	s.mu.RLock()
+	if s.items.Resident() {
+		s.items.ForEach(walk)
+	} else if snap, err := s.items.Snapshot(); err == nil {
+		s.mu.RUnlock()
+		snap.Range("", walk)
+		snap.Release()
+		s.mu.RLock()
+	}
+	stats.GCRuns = s.gcRuns
+	stats.GCCleaned = s.gcCleaned
+	stats.Conflicts = s.conflicts
+	stats.Expired = s.expired
+	stats.Evicted = s.evicted
+	stats.BackendDirty = len(s.dirty)
+	stats.BackendErrors = s.backendErrors
+	s.mu.RUnlock()
+	if stats.Expires > 0 {
+		stats.AvgTTL = ttlSum / time.Duration(stats.Expires)
+	}
+
+	sm := s.segmentManager
+	sm.mu.RLock()
+	stats.Segments = len(sm.segments)
+	stats.Compactions = sm.compactions
+	stats.CompactionSeconds = sm.compactionSeconds
+	sm.mu.RUnlock()
+
+	return stats
+}
+
+// countTombstones returns how many removed elements a collection still carries
+func countTombstones(val *Value) int {
+	switch val.Type {
+	case TypeList:
+		if list := val.List(); list != nil {
+			n := 0
+			for _, elem := range list.Elements {
+				if elem.Deleted {
+					n++
+				}
+			}
+			return n
+		}
+	case TypeSet:
+		if set := val.Set(); set != nil {
+			return len(set.Tombstones)
+		}
+	case TypeHash:
+		if hash := val.Hash(); hash != nil {
+			return len(hash.Tombstones)
+		}
+	case TypeZSet:
+		if zset, _ := val.GetZSet(); zset != nil {
+			n := 0
+			for _, elem := range zset.Elements {
+				if elem.IsRemoved {
+					n++
+				}
+			}
+			return n
+		}
+	}
+	return 0
+}
+
+// ResetStats zeroes the GC, conflict, expiry, eviction, backend and compaction counters
+func (s *Store) ResetStats() {
+	s.mu.Lock()
+	s.gcRuns = 0
+	s.gcCleaned = 0
+	s.conflicts = 0
+	s.expired = 0
+	s.evicted = 0
+	s.backendErrors = 0
+	s.mu.Unlock()
+
+	sm := s.segmentManager
+	sm.mu.Lock()
+	sm.compactions = 0
+	sm.compactionSeconds = 0
+	sm.mu.Unlock()
+}
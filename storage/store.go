@@ -4,7 +4,6 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"io/ioutil"
 	"log"
 	"os"
 	"path/filepath"
@@ -14,56 +13,160 @@ import (
 	// Import crdt package
 )
 
+// sweepBatch is how many keys a background pass over a disk engine examines
+// per acquisition of the store lock
+const sweepBatch = 1000
+
 // Store manages the persistent storage of values with CRDT resolution
 type Store struct {
 	mu              sync.RWMutex
-	items           map[string]*Value // In-memory CRDT state
-	dataPath        string            // Path to persist CRDT state (legacy)
-	redis           *RedisStore       // Local Redis instance
-	segmentManager  *SegmentManager   // Optimized persistence with append-only logs
+	items           Engine              // CRDT state, in memory or on disk
+	engine          Engine              // items including the tombstones of deleted counters
+	dataPath        string              // Path to persist CRDT state (legacy)
+	backend         Backend             // Mirror of the CRDT state, e.g. a local Redis
+	dirty           map[string]struct{} // keys whose last backend write failed
+	backendErrors   int64               // failed backend writes since start
+	segmentManager  *SegmentManager     // Optimized persistence with append-only logs
 	cleanupInterval time.Duration
 	TombstoneTTL    time.Duration
 	gcInterval      time.Duration
+	gcReset         chan struct{} // signals gcLoop that gcInterval changed
+	expireCursor    string        // where the next expiry pass over a disk engine starts
+	maxMemory       int64         // memory limit in bytes, 0 for none; updated atomically
+	usedMemory      int64         // accounted bytes of all keys, updated atomically
+	meta            map[string]*keyMeta
+	evictionPolicy  string
 	stopCleanup     chan struct{}
-	closed          bool // Flag to prevent multiple closes
+	closed          bool  // Flag to prevent multiple closes
+	gcRuns          int64 // GC passes since start
+	gcCleaned       int64 // tombstones removed by GC since start
+	conflicts       int64 // stale writes discarded by last-write-wins since start
+	expired         int64 // keys removed by TTL expiry since start
+	evicted         int64 // keys removed to stay under maxmemory since start
+	changes         int64 // writes since the last successful snapshot began
+	saveRules       []SaveRule
+	saving          bool // a snapshot is being written
+	saveWG          sync.WaitGroup
+	lastSave        time.Time // last successful snapshot, or when the store was opened
+	lastSaveOK      bool
+	lastSaveAttempt time.Time
+	saves           int64 // snapshots written since start
+	notifier        func(KeyEvent)
 	ctx             context.Context
 	cancel          context.CancelFunc
 }
 
-// NewStore creates a new store instance with persistence and Redis connection
+// NewStore creates a new store instance with persistence, writing through to
+// the Redis at redisAddr, or keeping no backend if redisAddr is empty
 func NewStore(dataDir string, redisAddr string, redisDB int) (*Store, error) {
+	mode := BackendWriteThrough
+	if redisAddr == "" {
+		mode = BackendNone
+	}
+	backend, err := NewBackend(BackendConfig{Mode: mode, RedisAddr: redisAddr, RedisDB: redisDB})
+	if err != nil {
+		return nil, err
+	}
+	return NewStoreWithBackend(dataDir, backend)
+}
+
+// NewStoreWithBackend creates a new store instance with persistence that
+// mirrors its state into backend. Loaded state is reconciled into the
+// backend before the store is returned.
+func NewStoreWithBackend(dataDir string, backend Backend) (*Store, error) {
+	return NewStoreWithOptions(dataDir, StoreOptions{Backend: backend})
+}
+
+// StoreOptions configures a store
+type StoreOptions struct {
+	Backend Backend // mirror of the CRDT state; nil for none
+	Engine  EngineConfig
+	// SegmentRecovery handles corrupt segment entries on load: RecoveryStrict,
+	// RecoverySkip or RecoveryTruncate; empty means RecoveryTruncate
+	SegmentRecovery string
+}
+
+// NewStoreWithOptions creates a new store instance with persistence. With
+// the memory engine the whole dataset is loaded from segments and reconciled
+// into the backend before the store is returned. The disk engine opens
+// without reading the dataset, so the backend is reconciled in the
+// background.
+func NewStoreWithOptions(dataDir string, opts StoreOptions) (*Store, error) {
 	if err := os.MkdirAll(dataDir, 0755); err != nil {
 		return nil, err
 	}
 
-	redis, err := NewRedisStore(redisAddr, redisDB, "") // Add empty replicaID
+	backend := opts.Backend
+	if backend == nil {
+		var err error
+		if backend, err = NewBackend(BackendConfig{Mode: BackendNone}); err != nil {
+			return nil, err
+		}
+	}
+
+	engine, err := NewEngine(filepath.Join(dataDir, "engine"), opts.Engine)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("failed to open storage engine: %v", err)
 	}
 
 	// Initialize segment manager for optimized persistence
 	segmentManager, err := NewSegmentManager(filepath.Join(dataDir, "segments"))
 	if err != nil {
+		engine.Close()
 		return nil, fmt.Errorf("failed to create segment manager: %v", err)
 	}
+	if opts.SegmentRecovery != "" {
+		if err := segmentManager.SetRecoveryMode(opts.SegmentRecovery); err != nil {
+			engine.Close()
+			segmentManager.Close()
+			return nil, err
+		}
+	}
 
 	ctx, cancel := context.WithCancel(context.Background())
 	store := &Store{
-		items:           make(map[string]*Value),
+		items:           liveEngine{engine},
+		engine:          engine,
+		meta:            make(map[string]*keyMeta),
+		evictionPolicy:  PolicyNoEviction,
 		dataPath:        filepath.Join(dataDir, "store.json"),
-		redis:           redis,
+		backend:         backend,
+		dirty:           make(map[string]struct{}),
 		segmentManager:  segmentManager,
 		cleanupInterval: time.Second * 1,
 		TombstoneTTL:    time.Hour * 1, // Default 1 hour
 		gcInterval:      time.Minute * 5,
+		gcReset:         make(chan struct{}, 1),
 		stopCleanup:     make(chan struct{}),
+		lastSave:        time.Now(),
+		lastSaveOK:      true,
 		ctx:             ctx,
 		cancel:          cancel,
 	}
 
-	// Load existing CRDT state from segments
-	if err := store.loadFromSegments(); err != nil {
-		return nil, err
+	if engine.Resident() {
+		// Load existing CRDT state from segments
+		if err := store.loadFromSegments(); err != nil {
+			engine.Close()
+			segmentManager.Close()
+			return nil, err
+		}
+		store.recomputeMemory()
+		if failed := store.Reconcile(); failed > 0 {
+			log.Printf("Failed to reconcile %d keys into the storage backend, retrying in the background", failed)
+		}
+	} else {
+		// A new disk engine imports data written by the memory engine once
+		if err := store.importToEngine(); err != nil {
+			engine.Close()
+			segmentManager.Close()
+			return nil, err
+		}
+		go func() {
+			if failed := store.Reconcile(); failed > 0 {
+				log.Printf("Failed to reconcile %d keys into the storage backend, retrying in the background", failed)
+			}
+		}()
 	}
 
 	// Start cleanup goroutine
@@ -74,48 +177,85 @@ func NewStore(dataDir string, redisAddr string, redisDB int) (*Store, error) {
 }
 
 // Set stores a value with CRDT metadata and optional TTL using LWW semantics only
-func (s *Store) Set(key string, value *Value, ttl *int64) error {
+func (s *Store) Set(key string, value *Value, ttl *int64, opts ...OpOption) error {
+	defer s.awaitDurable()
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	options := writeOptions(opts)
 
 	// Calculate expiration time if TTL is provided
 	var expireAt time.Time
-	var redisTTL *time.Duration
 	if ttl != nil {
-		duration := time.Duration(*ttl) * time.Second
-		redisTTL = &duration
-		expireAt = time.Now().Add(duration)
+		expireAt = time.Now().Add(time.Duration(*ttl) * time.Second)
 	}
 
-	existingValue, exists := s.items[key]
+	if value.Type == TypeString {
+		observed, err := s.observation(key, options)
+		if err != nil {
+			return err
+		}
+		observed.observeReset(value)
+		if prev := s.counterBefore(key); prev != nil {
+			return s.resetCounter(key, prev, value, ttl, expireAt, options)
+		}
+	}
+
+	existingValue, exists := s.items.Get(key)
 	if exists {
 		if value.Timestamp <= existingValue.Timestamp {
+			s.conflicts++
 			return nil // Do not update if new timestamp is not greater
 		}
 	}
 
-	// Update Redis first
-	if err := s.redis.Set(s.ctx, key, value, redisTTL); err != nil {
-		return fmt.Errorf("failed to write to Redis: %v", err)
-	}
-
-	// Then update CRDT state
-	s.items[key] = value
-	s.items[key].TTL = ttl
-	s.items[key].ExpireAt = expireAt
+	// Update CRDT state
+	value.TTL = ttl
+	value.ExpireAt = expireAt
 
 	// Save to disk
-	if err := s.save(); err != nil {
+	if err := s.commit(key, value); err != nil {
 		return fmt.Errorf("failed to save to disk: %v", err)
 	}
 
+	s.keyChanged("set", key, options)
+	if ttl != nil {
+		s.keyChanged("expire", key, options)
+	}
 	return nil
 }
 
-// Get retrieves a value, checking both CRDT state and Redis
+// resetCounter stores a SET of the string value over the counter state prev
+// under the counter reset rule, so increments the SET did not observe are
+// kept
+func (s *Store) resetCounter(key string, prev, value *Value, ttl *int64, expireAt time.Time, options *WriteOptions) error {
+	merged := prev.Clone()
+	merged.Merge(value)
+	if value.Timestamp <= prev.Timestamp {
+		s.conflicts++
+	} else {
+		merged.TTL = ttl
+		merged.ExpireAt = expireAt
+	}
+	if merged.counterDeleted() {
+		// An older SET than the DEL that removed the key
+		merged.TTL, merged.ExpireAt = nil, time.Time{}
+		return s.commit(key, merged)
+	}
+	if err := s.commit(key, merged); err != nil {
+		return fmt.Errorf("failed to save to disk: %v", err)
+	}
+	s.keyChanged("set", key, options)
+	if ttl != nil {
+		s.keyChanged("expire", key, options)
+	}
+	return nil
+}
+
+// Get retrieves a value from the CRDT state
 func (s *Store) Get(key string) (*Value, bool) {
 	s.mu.RLock()
-	value, exists := s.items[key]
+	value, exists := s.items.Get(key)
+	s.touch(key)
 	s.mu.RUnlock()
 
 	if !exists {
@@ -126,106 +266,126 @@ func (s *Store) Get(key string) (*Value, bool) {
 	if value.TTL != nil && time.Now().After(value.ExpireAt) {
 		// Remove expired key
 		s.mu.Lock()
-		delete(s.items, key)
-		s.save() // Save the expired state
+		if _, ok := s.items.Get(key); ok {
+			s.commit(key, nil) // Save the expired state
+			s.keyChanged("expired", key, nil)
+		}
 		s.mu.Unlock()
-
-		// Also remove from Redis
-		s.redis.Delete(s.ctx, key)
 		return nil, false
 	}
 
+	s.observeRead(key, value)
 	return value, true
 }
 
-// Delete removes a value from both CRDT state and Redis
-func (s *Store) Delete(key string) error {
+// Delete removes a value from the CRDT state and the backend. Deleting a
+// counter resets the contributions the DEL observed: if others remain the
+// key survives holding them, and otherwise the reset is stored under the key
+// as a tombstone until TombstoneTTL, so that increments concurrent with the
+// DEL still count, also after a restart.
+func (s *Store) Delete(key string, opts ...OpOption) error {
+	defer s.awaitDurable()
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	options := writeOptions(opts)
 
-	delete(s.items, key)
-	if err := s.save(); err != nil {
+	observed, err := s.observation(key, options)
+	if err != nil {
 		return err
 	}
+	_, existed := s.items.Get(key)
+	var tombstone *Value
+	prev := s.counterBefore(key)
+	if prev != nil || observed.Counts != nil || observed.FloatCounts != nil {
+		merged := observed.deletion(prev, options.Timestamp, options.ReplicaID)
+		if prev != nil {
+			deletion := merged
+			merged = prev.Clone()
+			merged.Merge(deletion)
+		}
+		if !merged.counterDeleted() {
+			return s.commit(key, merged)
+		}
+		merged.TTL, merged.ExpireAt = nil, time.Time{}
+		tombstone = merged
+	}
 
-	return s.redis.Delete(s.ctx, key)
+	if err := s.commit(key, tombstone); err != nil {
+		return err
+	}
+	if existed {
+		s.keyChanged("del", key, options)
+	}
+	return nil
 }
 
 // UpdateTTLDuration sets TTL for a key using a duration. If duration <= 0, the key is deleted.
 func (s *Store) UpdateTTLDuration(key string, d time.Duration) (bool, error) {
+	defer s.awaitDurable()
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	v, ok := s.items[key]
+	v, ok := s.items.Get(key)
 	if !ok {
 		return false, nil
 	}
 	if d <= 0 {
-		delete(s.items, key)
-		if err := s.save(); err != nil {
-			return false, err
-		}
-		if err := s.redis.Delete(s.ctx, key); err != nil {
+		if err := s.commit(key, nil); err != nil {
 			return false, err
 		}
+		s.keyChanged("del", key, nil)
 		return true, nil
 	}
 	expireAt := time.Now().Add(d)
 	v.SetExpireAt(&expireAt)
-	if err := s.save(); err != nil {
-		return false, err
-	}
-	if err := s.redis.SetTTL(s.ctx, key, &d); err != nil {
+	if err := s.commit(key, v); err != nil {
 		return false, err
 	}
+	s.keyChanged("expire", key, nil)
 	return true, nil
 }
 
 // UpdateExpireAt sets absolute expiration time for a key.
 func (s *Store) UpdateExpireAt(key string, at time.Time) (bool, error) {
+	defer s.awaitDurable()
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	v, ok := s.items[key]
+	v, ok := s.items.Get(key)
 	if !ok {
 		return false, nil
 	}
 	if time.Now().After(at) || time.Now().Equal(at) {
-		delete(s.items, key)
-		if err := s.save(); err != nil {
-			return false, err
-		}
-		if err := s.redis.Delete(s.ctx, key); err != nil {
+		if err := s.commit(key, nil); err != nil {
 			return false, err
 		}
+		s.keyChanged("del", key, nil)
 		return true, nil
 	}
 	v.SetExpireAt(&at)
-	if err := s.save(); err != nil {
-		return false, err
-	}
-	d := time.Until(at)
-	if err := s.redis.SetTTL(s.ctx, key, &d); err != nil {
+	if err := s.commit(key, v); err != nil {
 		return false, err
 	}
+	s.keyChanged("expire", key, nil)
 	return true, nil
 }
 
 // Close closes the store and its resources
 func (s *Store) Close() error {
 	s.mu.Lock()
-	defer s.mu.Unlock()
-
 	// Prevent multiple closes
 	if s.closed {
+		s.mu.Unlock()
 		return nil
 	}
 	s.closed = true
+	s.mu.Unlock()
 
-	// Save current state to disk
-	if err := s.save(); err != nil {
-		return fmt.Errorf("failed to save state on close: %v", err)
-	}
+	// Let a background save finish; none can start once closed is set
+	s.saveWG.Wait()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
 	// Stop cleanup goroutine
 	close(s.stopCleanup)
@@ -233,6 +393,16 @@ func (s *Store) Close() error {
 	// Cancel context
 	s.cancel()
 
+	if err := s.items.Close(); err != nil {
+		return fmt.Errorf("failed to close storage engine: %v", err)
+	}
+
+	if s.backend != nil {
+		if err := s.backend.Close(); err != nil {
+			return fmt.Errorf("failed to close storage backend: %v", err)
+		}
+	}
+
 	// Close segment manager
 	if s.segmentManager != nil {
 		if err := s.segmentManager.Close(); err != nil {
@@ -245,13 +415,15 @@ func (s *Store) Close() error {
 
 // gcLoop periodically runs garbage collection for tombstones
 func (s *Store) gcLoop() {
-	ticker := time.NewTicker(s.gcInterval)
+	ticker := time.NewTicker(s.GCInterval())
 	defer ticker.Stop()
 
 	for {
 		select {
 		case <-ticker.C:
 			s.GC()
+		case <-s.gcReset:
+			ticker.Reset(s.GCInterval())
 		case <-s.stopCleanup:
 			return
 		}
@@ -261,53 +433,78 @@ func (s *Store) gcLoop() {
 // GC performs garbage collection on all CRDTs
 func (s *Store) GC() {
 	s.mu.Lock()
-	defer s.mu.Unlock()
-
 	cutoff := time.Now().Add(-s.TombstoneTTL).UnixNano()
-	changed := false
-
-	for _, val := range s.items {
-		cleaned := 0
-		switch val.Type {
-		case TypeList:
-			if list := val.List(); list != nil {
-				cleaned = list.GC(cutoff)
-				if cleaned > 0 {
-					val.SetList(list, val.Timestamp)
-				}
-			}
-		case TypeSet:
-			if set := val.Set(); set != nil {
-				cleaned = set.GC(cutoff)
-				if cleaned > 0 {
-					val.SetSet(set, val.Timestamp)
+	s.gcRuns++
+	s.mu.Unlock()
+
+	var cursor string
+	for done := false; !done; {
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			return
+		}
+		changed := make(map[string]*Value)
+		done = s.scan(&cursor, func(key string, val *Value) {
+			if val.counterDeleted() {
+				if val.Timestamp < cutoff {
+					changed[key] = nil
+					s.gcCleaned++
 				}
+				return
 			}
-		case TypeHash:
-			if hash := val.Hash(); hash != nil {
-				cleaned = hash.GC(cutoff)
-				if cleaned > 0 {
-					val.SetHash(hash, val.Timestamp)
-				}
+			if cleaned := gcValue(val, cutoff); cleaned > 0 {
+				changed[key] = val
+				s.gcCleaned += int64(cleaned)
 			}
-		case TypeZSet:
-			if zset, _ := val.GetZSet(); zset != nil {
-				cleaned = zset.GC(cutoff)
-				if cleaned > 0 {
-					val.SetZSet(zset)
-				}
+		})
+		for key, val := range changed {
+			if err := s.commit(key, val); err != nil {
+				log.Printf("Error saving after GC: %v", err)
 			}
 		}
-		if cleaned > 0 {
-			changed = true
+		if len(changed) > 0 {
+			s.recomputeMemory()
 		}
+		s.mu.Unlock()
 	}
+}
 
-	if changed {
-		if err := s.save(); err != nil {
-			log.Printf("Error saving after GC: %v", err)
+// gcValue removes tombstones older than cutoff from a collection and returns
+// how many it removed
+func gcValue(val *Value, cutoff int64) int {
+	cleaned := 0
+	switch val.Type {
+	case TypeList:
+		if list := val.List(); list != nil {
+			cleaned = list.GC(cutoff)
+			if cleaned > 0 {
+				val.SetList(list, val.Timestamp)
+			}
+		}
+	case TypeSet:
+		if set := val.Set(); set != nil {
+			cleaned = set.GC(cutoff)
+			if cleaned > 0 {
+				val.SetSet(set, val.Timestamp)
+			}
+		}
+	case TypeHash:
+		if hash := val.Hash(); hash != nil {
+			cleaned = hash.GC(cutoff)
+			if cleaned > 0 {
+				val.SetHash(hash, val.Timestamp)
+			}
+		}
+	case TypeZSet:
+		if zset, _ := val.GetZSet(); zset != nil {
+			cleaned = zset.GC(cutoff)
+			if cleaned > 0 {
+				val.SetZSet(zset)
+			}
 		}
 	}
+	return cleaned
 }
 
 // cleanupLoop periodically removes expired keys
@@ -319,91 +516,131 @@ func (s *Store) cleanupLoop() {
 		select {
 		case <-ticker.C:
 			s.cleanupExpired()
+			s.retryDirty()
+			s.maybeSave()
+			s.syncEngine()
 		case <-s.stopCleanup:
 			return
 		}
 	}
 }
 
+// syncEngine syncs the write-ahead log of a disk engine under the everysec
+// policy; the segment log syncs itself
+func (s *Store) syncEngine() {
+	if s.segmentManager == nil || s.items.Resident() || s.SyncPolicy() != SyncEverySec {
+		return
+	}
+	if err := s.syncWrites(); err != nil {
+		log.Printf("Background engine sync failed: %v", err)
+	}
+}
+
 // cleanupExpired removes all expired keys
 func (s *Store) cleanupExpired() {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	now := time.Now()
-	changed := false
+	if s.closed {
+		return
+	}
 
-	for key, value := range s.items {
-		if value.TTL != nil && now.After(value.ExpireAt) {
-			delete(s.items, key)
-			changed = true
-			// Remove from Redis synchronously to ensure it's gone
-			s.redis.Delete(s.ctx, key)
+	now := time.Now()
+	var expired []string
+	s.scan(&s.expireCursor, func(key string, value *Value) {
+		if value.TTL != nil && now.After(value.ExpireAt) && !value.counterDeleted() {
+			expired = append(expired, key)
 		}
-	}
+	})
 
-	// Save changes to disk if any keys were removed
-	if changed {
-		if err := s.save(); err != nil {
+	for _, key := range expired {
+		if err := s.commit(key, nil); err != nil {
 			log.Printf("Error saving after cleanup: %v", err)
+			continue
 		}
+		s.expired++
+		s.keyChanged("expired", key, nil)
 	}
 }
 
-// load reads the store data from disk and syncs with Redis
-func (s *Store) load() error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	data, err := ioutil.ReadFile(s.dataPath)
-	if err != nil {
-		if os.IsNotExist(err) {
-			return nil // No data file yet
+// scan visits keys, and the tombstones of deleted counters, for a background
+// pass; callers must hold s.mu. Resident
+// engines are visited in full. A disk engine is visited sweepBatch keys at a
+// time from *cursor, so a pass over a large keyspace does not hold the lock
+// throughout; *cursor is advanced and wraps around at the end. scan reports
+// whether the pass reached the end of the keyspace.
+func (s *Store) scan(cursor *string, fn func(key string, val *Value)) bool {
+	if s.engine.Resident() {
+		s.engine.ForEach(func(key string, val *Value) bool {
+			fn(key, val)
+			return true
+		})
+		return true
+	}
+	n := 0
+	last := ""
+	err := s.engine.Range(*cursor, func(key string, val *Value) bool {
+		if n == sweepBatch {
+			return false
 		}
-		return fmt.Errorf("failed to read data file: %v", err)
+		fn(key, val)
+		last = key
+		n++
+		return true
+	})
+	if err != nil {
+		log.Printf("Error scanning storage engine: %v", err)
 	}
-
-	if err := json.Unmarshal(data, &s.items); err != nil {
-		return fmt.Errorf("failed to unmarshal data: %v", err)
+	if n < sweepBatch || err != nil {
+		*cursor = ""
+		return true
 	}
+	*cursor = last + "\x00"
+	return false
+}
 
-	// Sync with Redis and remove expired items
-	now := time.Now()
-	for key, value := range s.items {
-		// Check if the value has expired
-		if value.TTL != nil {
-			if now.After(value.ExpireAt) {
-				delete(s.items, key)
-				continue
-			}
-			// Update TTL for Redis
-			remaining := time.Until(value.ExpireAt)
-			if remaining <= 0 {
-				delete(s.items, key)
-				continue
-			}
-			duration := remaining
-			if err := s.redis.Set(s.ctx, key, value, &duration); err != nil {
-				return fmt.Errorf("failed to sync key %s to Redis with TTL: %v", key, err)
-			}
-		} else {
-			// No TTL, just set the value
-			if err := s.redis.Set(s.ctx, key, value, nil); err != nil {
-				return fmt.Errorf("failed to sync key %s to Redis: %v", key, err)
-			}
+// commit writes the new state of key, nil if it was removed, to the engine;
+// callers must hold s.mu. With a resident engine the change is appended to
+// the segment log, which is how the store is persisted; the disk engine keeps
+// its own write-ahead log. Either append is not synced here: mutating methods
+// call awaitDurable once s.mu is released, so concurrent writers can share
+// one fsync.
+func (s *Store) commit(key string, val *Value) error {
+	if val == nil {
+		if err := s.items.Delete(key); err != nil {
+			return err
 		}
+	} else if err := s.items.Put(key, val); err != nil {
+		return err
+	}
+	s.changes++
+	if !s.items.Resident() || s.segmentManager == nil {
+		return nil
+	}
+	if val == nil {
+		return s.persistDelete(key)
 	}
+	return s.persistSet(key, val)
+}
 
-	return nil
+// awaitDurable waits for the write to reach the disk when the appendfsync
+// policy is always. It must be called without holding s.mu.
+func (s *Store) awaitDurable() {
+	if s.segmentManager == nil || s.segmentManager.SyncPolicy() != SyncAlways {
+		return
+	}
+	if err := s.syncWrites(); err != nil {
+		log.Printf("Failed to sync writes: %v", err)
+	}
 }
 
-// save writes the store data to disk
-func (s *Store) save() error {
-	data, err := json.Marshal(s.items)
-	if err != nil {
-		return err
+// syncWrites syncs the log commit appends to: the segment log, or the
+// write-ahead log of an engine that keeps its own
+func (s *Store) syncWrites() error {
+	if engine, ok := s.engine.(interface{ Sync() error }); ok && !s.engine.Resident() {
+		return engine.Sync()
 	}
-	return ioutil.WriteFile(s.dataPath, data, 0644)
+	return s.segmentManager.Sync()
 }
 
 // GetPath returns the path to the store's data file
@@ -411,12 +648,77 @@ func (s *Store) GetPath() string {
 	return filepath.Dir(s.dataPath)
 }
 
+// Scan returns up to count live keys in key order starting at cursor, and
+// the cursor to continue from, which is "" once every key has been returned
+func (s *Store) Scan(cursor string, count int) ([]string, string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	now := time.Now()
+	var keys []string
+	next := ""
+	err := s.items.Range(cursor, func(key string, val *Value) bool {
+		if len(keys) == count {
+			next = key
+			return false
+		}
+		if val.TTL == nil || now.Before(val.ExpireAt) {
+			keys = append(keys, key)
+		}
+		return true
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return keys, next, nil
+}
+
+// Range calls fn with every live key in key order and its value, from a
+// consistent view of the keyspace, until fn returns false. Writers are held
+// off only while the view is taken.
+func (s *Store) Range(fn func(key string, value *Value) bool) error {
+	s.mu.Lock()
+	snap, err := s.items.Snapshot()
+	s.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("failed to snapshot storage engine: %v", err)
+	}
+	defer snap.Release()
+
+	now := time.Now()
+	return snap.Range("", func(key string, val *Value) bool {
+		if val.TTL != nil && !now.Before(val.ExpireAt) {
+			return true
+		}
+		return fn(key, val)
+	})
+}
+
+// Import stores a value built outside the replicated history, such as a key
+// loaded from an RDB file, keeping its expiration. Like Set, it only
+// replaces a value with an older timestamp.
+func (s *Store) Import(key string, value *Value) error {
+	defer s.awaitDurable()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if existing, exists := s.items.Get(key); exists && value.Timestamp <= existing.Timestamp {
+		s.conflicts++
+		return nil
+	}
+	if err := s.commit(key, value); err != nil {
+		return fmt.Errorf("failed to save to disk: %v", err)
+	}
+	s.keyChanged("restore", key, nil)
+	return nil
+}
+
 // GetTTL returns the remaining TTL in seconds for a key
 func (s *Store) GetTTL(key string) (int64, bool) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	if value, exists := s.items[key]; exists {
+	if value, exists := s.items.Get(key); exists {
 		if value.TTL == nil {
 			return -1, true // -1 indicates no TTL
 		}
@@ -425,47 +727,15 @@ func (s *Store) GetTTL(key string) (int64, bool) {
 			return int64(remaining), true
 		}
 		// Key has expired, remove it
-		delete(s.items, key)
-		s.save()
+		s.commit(key, nil)
+		s.account(key)
 	}
 	return 0, false
 }
 
 // Incr increments the value at key by 1 using counter semantics
 func (s *Store) Incr(key string, opts ...OpOption) (int64, error) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	options := &WriteOptions{
-		Timestamp: time.Now().UnixNano(),
-	}
-	for _, opt := range opts {
-		opt(options)
-	}
-	timestamp := options.Timestamp
-
-	var counter int64
-	if val, exists := s.items[key]; exists {
-		if val.Type == TypeCounter {
-			counter = val.Counter()
-		} else {
-			parsed, err := strconv.ParseInt(val.String(), 10, 64)
-			if err != nil {
-				return 0, fmt.Errorf("value is not an integer")
-			}
-			counter = parsed
-		}
-	}
-	counter++
-	newVal := NewCounterValue(counter, timestamp, options.ReplicaID)
-	if err := s.redis.Set(s.ctx, key, newVal, nil); err != nil {
-		return counter, fmt.Errorf("failed to write to Redis: %v", err)
-	}
-	s.items[key] = newVal
-	if err := s.save(); err != nil {
-		return counter, fmt.Errorf("failed to save to disk: %v", err)
-	}
-	return counter, nil
+	return s.IncrBy(key, 1, opts...)
 }
 
 // WriteOptions holds metadata for write operations (CRDT replication)
@@ -473,6 +743,16 @@ type WriteOptions struct {
 	Timestamp int64
 	ReplicaID string
 	TTL       *time.Duration
+	Remote    bool // replicated from another region; flags keyspace notifications
+
+	// The writing replica's increments and decrements after a replicated
+	// counter write, as logged there; nil to add the delta instead
+	Share []string
+
+	// The counter contributions a replicated SET or DEL observed, as returned
+	// by CounterObserved there; nil for a local write, which observes the
+	// ones known here
+	Observed *string
 }
 
 // OpOption is a function that configures WriteOptions
@@ -485,6 +765,24 @@ func WithTimestamp(ts int64) OpOption {
 	}
 }
 
+// WithCounterShare makes a counter write raise the writing replica's
+// contribution to inc and dec, as returned by CounterShare on that replica,
+// rather than add the delta; applying the same write twice changes nothing
+func WithCounterShare(inc, dec string) OpOption {
+	return func(o *WriteOptions) {
+		o.Share = []string{inc, dec}
+	}
+}
+
+// WithCounterObserved makes a SET or DEL reset the counter contributions in
+// observed, as returned by CounterObserved on the writing replica; empty if
+// it observed none. Contributions beyond them survive the reset.
+func WithCounterObserved(observed string) OpOption {
+	return func(o *WriteOptions) {
+		o.Observed = &observed
+	}
+}
+
 // WithReplicaID sets the replica ID for the operation
 func WithReplicaID(id string) OpOption {
 	return func(o *WriteOptions) {
@@ -501,6 +799,7 @@ func WithTTL(d time.Duration) OpOption {
 
 // LPush adds elements to the head of a list
 func (s *Store) LPush(key string, values []string, opts ...OpOption) (int64, error) {
+	defer s.awaitDurable()
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
@@ -516,16 +815,16 @@ func (s *Store) LPush(key string, values []string, opts ...OpOption) (int64, err
 	timestamp := options.Timestamp
 	var list *CRDTList
 
-	if val, exists := s.items[key]; exists && val.Type == TypeList {
+	val, exists := s.items.Get(key)
+	if exists && val.Type == TypeList {
 		list = val.List()
 		if list == nil {
 			return 0, fmt.Errorf("invalid list data")
 		}
 	} else {
 		// Create new list
-		newVal := NewListValue(timestamp, options.ReplicaID)
-		list = newVal.List()
-		s.items[key] = newVal
+		val = NewListValue(timestamp, options.ReplicaID)
+		list = val.List()
 	}
 
 	// Add values in reverse order to maintain Redis LPUSH semantics
@@ -534,22 +833,19 @@ func (s *Store) LPush(key string, values []string, opts ...OpOption) (int64, err
 	}
 
 	// Update the value
-	s.items[key].SetList(list, timestamp)
-
-	// Update Redis (store as JSON for now)
-	if err := s.redis.Set(s.ctx, key, s.items[key], nil); err != nil {
-		return int64(list.Len()), fmt.Errorf("failed to write to Redis: %v", err)
-	}
+	val.SetList(list, timestamp)
 
-	if err := s.save(); err != nil {
+	if err := s.commit(key, val); err != nil {
 		return int64(list.Len()), fmt.Errorf("failed to save to disk: %v", err)
 	}
 
+	s.keyChanged("lpush", key, options)
 	return int64(list.Len()), nil
 }
 
 // RPush adds elements to the tail of a list
 func (s *Store) RPush(key string, values []string, opts ...OpOption) (int64, error) {
+	defer s.awaitDurable()
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
@@ -563,16 +859,16 @@ func (s *Store) RPush(key string, values []string, opts ...OpOption) (int64, err
 	timestamp := options.Timestamp
 	var list *CRDTList
 
-	if val, exists := s.items[key]; exists && val.Type == TypeList {
+	val, exists := s.items.Get(key)
+	if exists && val.Type == TypeList {
 		list = val.List()
 		if list == nil {
 			return 0, fmt.Errorf("invalid list data")
 		}
 	} else {
 		// Create new list
-		newVal := NewListValue(timestamp, options.ReplicaID)
-		list = newVal.List()
-		s.items[key] = newVal
+		val = NewListValue(timestamp, options.ReplicaID)
+		list = val.List()
 	}
 
 	// Add values in order
@@ -581,22 +877,19 @@ func (s *Store) RPush(key string, values []string, opts ...OpOption) (int64, err
 	}
 
 	// Update the value
-	s.items[key].SetList(list, timestamp)
-
-	// Update Redis
-	if err := s.redis.Set(s.ctx, key, s.items[key], nil); err != nil {
-		return int64(list.Len()), fmt.Errorf("failed to write to Redis: %v", err)
-	}
+	val.SetList(list, timestamp)
 
-	if err := s.save(); err != nil {
+	if err := s.commit(key, val); err != nil {
 		return int64(list.Len()), fmt.Errorf("failed to save to disk: %v", err)
 	}
 
+	s.keyChanged("rpush", key, options)
 	return int64(list.Len()), nil
 }
 
 // LPop removes and returns the first element from a list
 func (s *Store) LPop(key string, opts ...OpOption) (string, bool, error) {
+	defer s.awaitDurable()
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
@@ -607,7 +900,7 @@ func (s *Store) LPop(key string, opts ...OpOption) (string, bool, error) {
 		opt(options)
 	}
 
-	val, exists := s.items[key]
+	val, exists := s.items.Get(key)
 	if !exists || val.Type != TypeList {
 		return "", false, nil
 	}
@@ -626,20 +919,17 @@ func (s *Store) LPop(key string, opts ...OpOption) (string, bool, error) {
 	// Update the value
 	val.SetList(list, timestamp)
 
-	// Update Redis
-	if err := s.redis.Set(s.ctx, key, val, nil); err != nil {
-		return value, true, fmt.Errorf("failed to write to Redis: %v", err)
-	}
-
-	if err := s.save(); err != nil {
+	if err := s.commit(key, val); err != nil {
 		return value, true, fmt.Errorf("failed to save to disk: %v", err)
 	}
 
+	s.keyChanged("lpop", key, options)
 	return value, true, nil
 }
 
 // RPop removes and returns the last element from a list
 func (s *Store) RPop(key string, opts ...OpOption) (string, bool, error) {
+	defer s.awaitDurable()
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
@@ -650,7 +940,7 @@ func (s *Store) RPop(key string, opts ...OpOption) (string, bool, error) {
 		opt(options)
 	}
 
-	val, exists := s.items[key]
+	val, exists := s.items.Get(key)
 	if !exists || val.Type != TypeList {
 		return "", false, nil
 	}
@@ -669,15 +959,11 @@ func (s *Store) RPop(key string, opts ...OpOption) (string, bool, error) {
 	// Update the value
 	val.SetList(list, timestamp)
 
-	// Update Redis
-	if err := s.redis.Set(s.ctx, key, val, nil); err != nil {
-		return value, true, fmt.Errorf("failed to write to Redis: %v", err)
-	}
-
-	if err := s.save(); err != nil {
+	if err := s.commit(key, val); err != nil {
 		return value, true, fmt.Errorf("failed to save to disk: %v", err)
 	}
 
+	s.keyChanged("rpop", key, options)
 	return value, true, nil
 }
 
@@ -686,7 +972,8 @@ func (s *Store) LRange(key string, start, stop int) ([]string, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	val, exists := s.items[key]
+	val, exists := s.items.Get(key)
+	s.accessed(key)
 	if !exists || val.Type != TypeList {
 		return []string{}, nil
 	}
@@ -704,7 +991,8 @@ func (s *Store) LLen(key string) (int64, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	val, exists := s.items[key]
+	val, exists := s.items.Get(key)
+	s.accessed(key)
 	if !exists || val.Type != TypeList {
 		return 0, nil
 	}
@@ -722,7 +1010,8 @@ func (s *Store) LIndex(key string, index int) (string, bool, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	val, exists := s.items[key]
+	val, exists := s.items.Get(key)
+	s.accessed(key)
 	if !exists || val.Type != TypeList {
 		return "", false, nil
 	}
@@ -738,10 +1027,11 @@ func (s *Store) LIndex(key string, index int) (string, bool, error) {
 
 // LSet sets the element at the specified index to a new value
 func (s *Store) LSet(key string, index int, value string) error {
+	defer s.awaitDurable()
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	val, exists := s.items[key]
+	val, exists := s.items.Get(key)
 	if !exists || val.Type != TypeList {
 		return fmt.Errorf("ERR no such key")
 	}
@@ -759,24 +1049,21 @@ func (s *Store) LSet(key string, index int, value string) error {
 	// Update the value
 	val.SetList(list, timestamp)
 
-	// Update Redis
-	if err := s.redis.Set(s.ctx, key, val, nil); err != nil {
-		return fmt.Errorf("failed to write to Redis: %v", err)
-	}
-
-	if err := s.save(); err != nil {
+	if err := s.commit(key, val); err != nil {
 		return fmt.Errorf("failed to save to disk: %v", err)
 	}
 
+	s.keyChanged("lset", key, nil)
 	return nil
 }
 
 // LInsert inserts a value before or after the pivot element
 func (s *Store) LInsert(key string, before bool, pivot string, value string) (int64, error) {
+	defer s.awaitDurable()
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	val, exists := s.items[key]
+	val, exists := s.items.Get(key)
 	if !exists || val.Type != TypeList {
 		return 0, nil // Key not found, return 0
 	}
@@ -797,24 +1084,21 @@ func (s *Store) LInsert(key string, before bool, pivot string, value string) (in
 	// Update the value
 	val.SetList(list, timestamp)
 
-	// Update Redis
-	if err := s.redis.Set(s.ctx, key, val, nil); err != nil {
-		return int64(result), fmt.Errorf("failed to write to Redis: %v", err)
-	}
-
-	if err := s.save(); err != nil {
+	if err := s.commit(key, val); err != nil {
 		return int64(result), fmt.Errorf("failed to save to disk: %v", err)
 	}
 
+	s.keyChanged("linsert", key, nil)
 	return int64(result), nil
 }
 
 // LTrim trims a list to the specified range
 func (s *Store) LTrim(key string, start, stop int) error {
+	defer s.awaitDurable()
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	val, exists := s.items[key]
+	val, exists := s.items.Get(key)
 	if !exists || val.Type != TypeList {
 		return nil // Key not found, no error per Redis semantics
 	}
@@ -830,24 +1114,21 @@ func (s *Store) LTrim(key string, start, stop int) error {
 	// Update the value
 	val.SetList(list, timestamp)
 
-	// Update Redis
-	if err := s.redis.Set(s.ctx, key, val, nil); err != nil {
-		return fmt.Errorf("failed to write to Redis: %v", err)
-	}
-
-	if err := s.save(); err != nil {
+	if err := s.commit(key, val); err != nil {
 		return fmt.Errorf("failed to save to disk: %v", err)
 	}
 
+	s.keyChanged("ltrim", key, nil)
 	return nil
 }
 
 // LRem removes elements from a list by value
 func (s *Store) LRem(key string, count int, value string) (int64, error) {
+	defer s.awaitDurable()
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	val, exists := s.items[key]
+	val, exists := s.items.Get(key)
 	if !exists || val.Type != TypeList {
 		return 0, nil // Key not found
 	}
@@ -863,98 +1144,166 @@ func (s *Store) LRem(key string, count int, value string) (int64, error) {
 	// Update the value
 	val.SetList(list, timestamp)
 
-	// Update Redis
-	if err := s.redis.Set(s.ctx, key, val, nil); err != nil {
-		return int64(removed), fmt.Errorf("failed to write to Redis: %v", err)
-	}
-
-	if err := s.save(); err != nil {
+	if err := s.commit(key, val); err != nil {
 		return int64(removed), fmt.Errorf("failed to save to disk: %v", err)
 	}
 
+	if removed > 0 {
+		s.keyChanged("lrem", key, nil)
+	}
 	return int64(removed), nil
 }
 
-// IncrBy increments the value at key by increment using counter semantics
+// IncrBy increments the value at key by increment using counter semantics.
+// The increment is added to the writing replica's contribution, or with
+// WithCounterShare the contribution is raised to the logged one.
 func (s *Store) IncrBy(key string, increment int64, opts ...OpOption) (int64, error) {
+	defer s.awaitDurable()
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	options := &WriteOptions{
-		Timestamp: time.Now().UnixNano(),
+	notInteger := fmt.Errorf("value is not an integer")
+	val, err := incrCounter(s, intCounter, key, increment, writeOptions(opts), "incrby", notInteger, notInteger)
+	if val == nil {
+		return 0, err
 	}
-	for _, opt := range opts {
-		opt(options)
+	return val.Counter(), err
+}
+
+// IncrByFloat increments the float value at key by increment using counter
+// semantics, like IncrBy
+func (s *Store) IncrByFloat(key string, increment float64, opts ...OpOption) (float64, error) {
+	defer s.awaitDurable()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	wrongType := fmt.Errorf("WRONGTYPE Operation against a key holding the wrong kind of value")
+	val, err := incrCounter(s, floatCounter, key, increment, writeOptions(opts), "incrbyfloat", wrongType, fmt.Errorf("value is not a valid float"))
+	if val == nil {
+		return 0, err
 	}
-	timestamp := options.Timestamp
+	return val.FloatCounter(), err
+}
 
-	var counter int64
-	if val, exists := s.items[key]; exists {
-		if val.Type == TypeCounter {
-			counter = val.Counter()
-		} else {
-			parsed, err := strconv.ParseInt(val.String(), 10, 64)
+// incrCounter applies a counter write to the counter at key, or to the reset
+// left by deleting one. An integer and a float counter convert to k's kind. A
+// string counts from its value as its reset; one that is not a number only
+// takes replicated writes, which leave it in place by the reset rule.
+func incrCounter[T int64 | float64](s *Store, k counterKind[T], key string, delta T, options *WriteOptions, event string, wrongType, notNumber error) (*Value, error) {
+	prev, _ := s.engine.Get(key)
+	st := counterState[T]{counts: NewPNCounter[T]()}
+	if prev != nil {
+		switch {
+		case k.typ == TypeFloatCounter && prev.Type == TypeCounter:
+			prev = prev.asFloatCounter()
+		case k.typ == TypeCounter && prev.Type == TypeFloatCounter:
+			converted, err := prev.asCounter()
 			if err != nil {
-				return 0, fmt.Errorf("value is not an integer")
+				return nil, err
 			}
-			counter = parsed
+			prev = converted
+		case prev.Type != k.typ && prev.Type != TypeString:
+			return nil, wrongType
+		}
+		st = k.state(prev)
+		if st.str != nil && !st.numeric && options.Share == nil {
+			return nil, notNumber
+		}
+	}
+
+	if options.Share != nil {
+		inc, err1 := k.parse(options.Share[0])
+		dec, err2 := k.parse(options.Share[1])
+		if err1 != nil || err2 != nil {
+			return nil, fmt.Errorf("invalid counter share %v", options.Share)
 		}
+		st.counts.Observe(options.ReplicaID, inc, dec)
+	} else {
+		st.counts.Add(options.ReplicaID, delta)
+	}
+
+	val := &Value{Timestamp: options.Timestamp, ReplicaID: options.ReplicaID, VectorClock: NewVectorClock()}
+	if options.ReplicaID != "" {
+		val.VectorClock.Increment(options.ReplicaID)
+	}
+	if st.str != nil {
+		// The write is concurrent with or after the string's SET
+		k.build(val, st.counts, nil)
+		merged := st.str.Clone()
+		merged.Merge(val)
+		val = merged
+	} else {
+		k.build(val, st.counts, st.reset)
 	}
-	counter += increment
-	newVal := NewCounterValue(counter, timestamp, options.ReplicaID)
-	if err := s.redis.Set(s.ctx, key, newVal, nil); err != nil {
-		return counter, fmt.Errorf("failed to write to Redis: %v", err)
+
+	if err := s.commit(key, val); err != nil {
+		return val, fmt.Errorf("failed to save to disk: %v", err)
 	}
-	s.items[key] = newVal
-	if err := s.save(); err != nil {
-		return counter, fmt.Errorf("failed to save to disk: %v", err)
+	if !val.counterDeleted() {
+		// Else a redelivered write the DEL already observed
+		s.keyChanged(event, key, options)
 	}
-	return counter, nil
+	return val, nil
 }
 
-// IncrByFloat increments the float value at key by increment using counter semantics
-func (s *Store) IncrByFloat(key string, increment float64, opts ...OpOption) (float64, error) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+// CounterShare returns replicaID's increments and decrements of the counter
+// at key, formatted for WithCounterShare, for logging a counter write
+func (s *Store) CounterShare(key, replicaID string) (inc, dec string, ok bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 
-	options := &WriteOptions{
-		Timestamp: time.Now().UnixNano(),
+	val, exists := s.items.Get(key)
+	if !exists {
+		return "", "", false
 	}
-	for _, opt := range opts {
-		opt(options)
+	switch val.Type {
+	case TypeCounter:
+		i, d := val.counts().Share(replicaID)
+		return strconv.FormatInt(i, 10), strconv.FormatInt(d, 10), true
+	case TypeFloatCounter:
+		i, d := val.floatCounts().Share(replicaID)
+		return fmt.Sprintf("%.17g", i), fmt.Sprintf("%.17g", d), true
 	}
-	timestamp := options.Timestamp
+	return "", "", false
+}
 
-	var counter float64
-
-	if val, exists := s.items[key]; exists {
-		switch val.Type {
-		case TypeFloatCounter:
-			counter = val.FloatCounter()
-		case TypeCounter:
-			// Convert integer counter to float
-			counter = float64(val.Counter())
-		case TypeString:
-			// Try to parse string as float
-			parsed, err := strconv.ParseFloat(val.String(), 64)
-			if err != nil {
-				return 0, fmt.Errorf("value is not a valid float")
-			}
-			counter = parsed
-		default:
-			return 0, fmt.Errorf("WRONGTYPE Operation against a key holding the wrong kind of value")
-		}
+// CounterObserved returns the counter contributions a SET or DEL of key would
+// reset, encoded for WithCounterObserved, and false if key holds no counter
+// state. Callers log it before the write so replicas reset the same ones.
+func (s *Store) CounterObserved(key string) (string, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	prev := s.counterBefore(key)
+	if prev == nil {
+		return "", false
+	}
+	data, err := json.Marshal(observationOf(prev))
+	if err != nil {
+		return "", false
 	}
+	return string(data), true
+}
 
-	counter += increment
-	newVal := NewFloatCounterValue(counter, timestamp, options.ReplicaID)
+// counterBefore returns the counter state a SET or DEL of key resets: the
+// value at key if it is counterLike, or the tombstone left by deleting a
+// counter
+func (s *Store) counterBefore(key string) *Value {
+	if val, exists := s.engine.Get(key); exists && val.counterLike() {
+		return val
+	}
+	return nil
+}
 
-	if err := s.redis.Set(s.ctx, key, newVal, nil); err != nil {
-		return counter, fmt.Errorf("failed to write to Redis: %v", err)
+// observation returns the contributions a SET or DEL of key observed: those
+// logged with WithCounterObserved for a replicated write, else those known
+// here
+func (s *Store) observation(key string, options *WriteOptions) (counterObservation, error) {
+	if options.Observed != nil {
+		return decodeObservation(*options.Observed)
 	}
-	s.items[key] = newVal
-	if err := s.save(); err != nil {
-		return counter, fmt.Errorf("failed to save to disk: %v", err)
+	if prev := s.counterBefore(key); prev != nil {
+		return observationOf(prev), nil
 	}
-	return counter, nil
+	return counterObservation{}, nil
 }
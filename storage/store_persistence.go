@@ -3,33 +3,55 @@ package storage
 import (
 	"encoding/json"
 	"fmt"
+	"log"
 	"os"
 	"time"
 )
 
 // loadFromSegments loads CRDT state from segment manager
 func (s *Store) loadFromSegments() error {
-	// Try to load from segments first
 	items, err := s.segmentManager.LoadAllEntries()
 	if err != nil {
 		return fmt.Errorf("failed to load from segments: %v", err)
 	}
 
-	// If no segments exist, try legacy load
-	if len(items) == 0 {
-		return s.loadLegacy()
+	// A store.json left by an older version is newer than the segments
+	if err := s.migrateLegacy(items); err != nil {
+		return err
 	}
 
-	// Load items into memory
+	// Load items into the engine
 	for key, value := range items {
-		s.items[key] = value
+		if err := s.items.Put(key, value); err != nil {
+			return fmt.Errorf("failed to load key %s: %v", key, err)
+		}
 	}
 
 	return nil
 }
 
-// loadLegacy loads from the legacy JSON file format
-func (s *Store) loadLegacy() error {
+// importToEngine loads data written by the memory engine into a disk engine
+// that is still empty, e.g. the first time a node starts with the disk engine
+func (s *Store) importToEngine() error {
+	empty := true
+	if err := s.engine.Range("", func(string, *Value) bool {
+		empty = false
+		return false
+	}); err != nil {
+		return fmt.Errorf("failed to read storage engine: %v", err)
+	}
+	if !empty {
+		return nil
+	}
+	return s.loadFromSegments()
+}
+
+// migrateLegacy moves the state in a legacy store.json into the segment log
+// and removes the file. Older versions rewrote store.json on every mutation
+// and only wrote segments once, so store.json replaces whatever the segments
+// hold: keys it lacks are deleted and its keys are set. items is updated to
+// match.
+func (s *Store) migrateLegacy(items map[string]*Value) error {
 	data, err := os.ReadFile(s.dataPath)
 	if os.IsNotExist(err) {
 		return nil // No existing data
@@ -38,35 +60,39 @@ func (s *Store) loadLegacy() error {
 		return fmt.Errorf("failed to read data file: %v", err)
 	}
 
-	if err := json.Unmarshal(data, &s.items); err != nil {
+	var legacy map[string]*Value
+	if err := json.Unmarshal(data, &legacy); err != nil {
 		return fmt.Errorf("failed to unmarshal data: %v", err)
 	}
 
-	// Migrate to segments
-	return s.migrateToSegments()
-}
-
-// migrateToSegments migrates legacy data to segment format
-func (s *Store) migrateToSegments() error {
-	timestamp := time.Now().UnixNano()
-
-	for key, value := range s.items {
-		entry := &LogEntry{
-			Timestamp: timestamp,
-			Operation: "SET",
-			Key:       key,
-			Value:     value,
+	for key := range items {
+		if _, ok := legacy[key]; ok {
+			continue
 		}
-
-		if err := s.segmentManager.WriteEntry(entry); err != nil {
+		if err := s.persistDelete(key); err != nil {
 			return fmt.Errorf("failed to migrate key %s: %v", key, err)
 		}
+		delete(items, key)
+	}
+	for key, value := range legacy {
+		if err := s.persistSet(key, value); err != nil {
+			return fmt.Errorf("failed to migrate key %s: %v", key, err)
+		}
+		items[key] = value
 	}
 
+	// The file may only go once its contents are safely in the log
+	if err := s.segmentManager.Sync(); err != nil {
+		return fmt.Errorf("failed to migrate data file: %v", err)
+	}
+	if err := os.Remove(s.dataPath); err != nil {
+		return fmt.Errorf("failed to remove migrated data file: %v", err)
+	}
+	log.Printf("Migrated %d keys from %s to the segment log", len(legacy), s.dataPath)
 	return nil
 }
 
-// persistSet writes a SET operation to the segment log
+// persistSet appends a SET operation to the segment log
 func (s *Store) persistSet(key string, value *Value) error {
 	entry := &LogEntry{
 		Timestamp: time.Now().UnixNano(),
@@ -75,10 +101,11 @@ func (s *Store) persistSet(key string, value *Value) error {
 		Value:     value,
 	}
 
-	return s.segmentManager.WriteEntry(entry)
+	_, err := s.segmentManager.Append(entry)
+	return err
 }
 
-// persistDelete writes a DELETE operation to the segment log
+// persistDelete appends a DELETE operation to the segment log
 func (s *Store) persistDelete(key string) error {
 	entry := &LogEntry{
 		Timestamp: time.Now().UnixNano(),
@@ -86,10 +113,22 @@ func (s *Store) persistDelete(key string) error {
 		Key:       key,
 	}
 
-	return s.segmentManager.WriteEntry(entry)
+	_, err := s.segmentManager.Append(entry)
+	return err
 }
 
 // GetPersistenceStats returns statistics about the persistence layer
 func (s *Store) GetPersistenceStats() map[string]interface{} {
 	return s.segmentManager.GetStats()
 }
+
+// SyncPolicy returns the appendfsync policy of the segment log
+func (s *Store) SyncPolicy() string {
+	return s.segmentManager.SyncPolicy()
+}
+
+// SetSyncPolicy sets when the segment log is synced to disk: SyncAlways,
+// SyncEverySec or SyncNo
+func (s *Store) SetSyncPolicy(policy string) error {
+	return s.segmentManager.SetSyncPolicy(policy)
+}
@@ -0,0 +1,279 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrSaveInProgress is returned when a snapshot is requested while another
+// one is being written
+var ErrSaveInProgress = errors.New("background save already in progress")
+
+// saveRetryDelay is how long scheduled saves wait after a failed one
+const saveRetryDelay = 5 * time.Second
+
+// SaveRule triggers a background save once Seconds have passed since the
+// last successful save and at least Changes writes were made since, as the
+// Redis save directive does
+type SaveRule struct {
+	Seconds int64
+	Changes int64
+}
+
+// ParseSaveRules parses "<seconds> <changes>" pairs, e.g. "3600 1 300 100";
+// an empty string means no scheduled saves
+func ParseSaveRules(spec string) ([]SaveRule, error) {
+	fields := strings.Fields(spec)
+	if len(fields)%2 != 0 {
+		return nil, fmt.Errorf("invalid save schedule: %q (want <seconds> <changes> pairs)", spec)
+	}
+	var rules []SaveRule
+	for i := 0; i < len(fields); i += 2 {
+		seconds, err1 := strconv.ParseInt(fields[i], 10, 64)
+		changes, err2 := strconv.ParseInt(fields[i+1], 10, 64)
+		if err1 != nil || err2 != nil || seconds <= 0 || changes < 0 {
+			return nil, fmt.Errorf("invalid save schedule: %q (want <seconds> <changes> pairs)", spec)
+		}
+		rules = append(rules, SaveRule{Seconds: seconds, Changes: changes})
+	}
+	return rules, nil
+}
+
+// SaveStatus describes the snapshots taken by the store
+type SaveStatus struct {
+	InProgress bool
+	LastSave   time.Time // last successful save, or when the store was opened
+	LastSaveOK bool      // whether the last attempt succeeded
+	Changes    int64     // writes since the last successful save began
+	Saves      int64     // successful saves since start
+}
+
+// SetSaveRules replaces the schedule of background saves; nil disables them
+func (s *Store) SetSaveRules(rules []SaveRule) {
+	s.mu.Lock()
+	s.saveRules = rules
+	s.mu.Unlock()
+}
+
+// SaveStatus returns the state of snapshotting
+func (s *Store) SaveStatus() SaveStatus {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return SaveStatus{
+		InProgress: s.saving,
+		LastSave:   s.lastSave,
+		LastSaveOK: s.lastSaveOK,
+		Changes:    s.changes,
+		Saves:      s.saves,
+	}
+}
+
+// Save writes a snapshot and returns once it is on disk, as SAVE does
+func (s *Store) Save() error {
+	write, err := s.beginSnapshot()
+	if err != nil {
+		return err
+	}
+	return write()
+}
+
+// BGSave starts writing a snapshot in the background, as BGSAVE does
+func (s *Store) BGSave() error {
+	write, err := s.beginSnapshot()
+	if err != nil {
+		return err
+	}
+	go func() {
+		if err := write(); err != nil {
+			log.Printf("Background save failed: %v", err)
+		}
+	}()
+	return nil
+}
+
+// beginSnapshot captures the keyspace at a new segment boundary and returns
+// the function that writes it out. Writers wait only while the state is
+// captured: a copy of every value with the memory engine, a consistent view
+// with the disk engine. Encoding and writing happen without the lock.
+func (s *Store) beginSnapshot() (func() error, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return nil, fmt.Errorf("store is closed")
+	}
+	if s.saving {
+		return nil, ErrSaveInProgress
+	}
+	segment, err := s.segmentManager.Rotate()
+	if err != nil {
+		s.lastSaveOK, s.lastSaveAttempt = false, time.Now()
+		return nil, fmt.Errorf("failed to rotate segment: %v", err)
+	}
+	snap, err := s.engine.Snapshot()
+	if err != nil {
+		s.lastSaveOK, s.lastSaveAttempt = false, time.Now()
+		return nil, fmt.Errorf("failed to snapshot storage engine: %v", err)
+	}
+	s.saving = true
+	s.saveWG.Add(1)
+	changes := s.changes
+	started := time.Now()
+
+	return func() error {
+		defer s.saveWG.Done()
+		err := s.segmentManager.WriteSnapshot(segment, snap.Range)
+		snap.Release()
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.saving = false
+		s.lastSaveOK = err == nil
+		s.lastSaveAttempt = time.Now()
+		if err != nil {
+			return err
+		}
+		s.changes -= changes
+		s.lastSave = started
+		s.saves++
+		return nil
+	}, nil
+}
+
+// Checkpoint captures the keyspace, with the tombstones of deleted counters,
+// for a backup and returns the function that writes it to w in the snapshot
+// format, returning the number of keys.
+// Unlike Save it starts no segment and leaves the store's own snapshots
+// alone. The returned function must be called exactly once.
+func (s *Store) Checkpoint() (func(w io.Writer) (int64, error), error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return nil, fmt.Errorf("store is closed")
+	}
+	snap, err := s.engine.Snapshot()
+	if err != nil {
+		return nil, fmt.Errorf("failed to snapshot storage engine: %v", err)
+	}
+	s.saveWG.Add(1)
+
+	return func(w io.Writer) (int64, error) {
+		defer s.saveWG.Done()
+		defer snap.Release()
+		return encodeSnapshot(w, 0, snap.Range)
+	}, nil
+}
+
+// MergeSnapshot merges every live key of a snapshot written by a Checkpoint
+// function into the store with the CRDT merge rules, as a replica does when
+// bootstrapping from a peer. The tombstones of deleted counters are merged
+// too. It returns the number of live keys merged.
+func (s *Store) MergeSnapshot(r io.Reader, opts ...OpOption) (int64, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read snapshot: %v", err)
+	}
+	items, err := decodeSnapshot(data)
+	if err != nil {
+		return 0, fmt.Errorf("invalid snapshot: %v", err)
+	}
+
+	defer s.awaitDurable()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	options := writeOptions(opts)
+	now := time.Now()
+	var merged int64
+	for key, val := range items {
+		if val.TTL != nil && !now.Before(val.ExpireAt) {
+			continue
+		}
+		if existing, exists := s.engine.Get(key); exists {
+			// Merge into a copy: engine snapshots may share the stored value
+			mine := existing.Clone()
+			mine.Merge(val)
+			val = mine
+		}
+		if err := s.commit(key, val); err != nil {
+			return merged, fmt.Errorf("failed to save to disk: %v", err)
+		}
+		if val.counterDeleted() {
+			// The tombstone of a deleted counter
+			continue
+		}
+		s.keyChanged("restore", key, options)
+		merged++
+	}
+	return merged, nil
+}
+
+// RestoreSnapshot installs a snapshot written by a Checkpoint function as
+// the state of a new store in dataDir, which must not exist or be empty.
+// It returns the number of keys restored.
+func RestoreSnapshot(dataDir string, r io.Reader) (int64, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read snapshot: %v", err)
+	}
+	items, err := decodeSnapshot(data)
+	if err != nil {
+		return 0, fmt.Errorf("invalid snapshot: %v", err)
+	}
+	if entries, err := os.ReadDir(dataDir); err == nil && len(entries) > 0 {
+		return 0, fmt.Errorf("%s is not empty", dataDir)
+	}
+
+	// Snapshot 0 covers no segments, so the store replays none and writes
+	// from segment 1 on
+	segmentDir := filepath.Join(dataDir, "segments")
+	if err := os.MkdirAll(segmentDir, 0755); err != nil {
+		return 0, fmt.Errorf("failed to create segment directory: %v", err)
+	}
+	path := filepath.Join(segmentDir, "snapshot-0.snap")
+	file, err := os.Create(path + ".tmp")
+	if err != nil {
+		return 0, fmt.Errorf("failed to create snapshot: %v", err)
+	}
+	defer os.Remove(path + ".tmp")
+	defer file.Close()
+	if _, err := file.Write(data); err != nil {
+		return 0, fmt.Errorf("failed to write snapshot: %v", err)
+	}
+	if err := file.Sync(); err != nil {
+		return 0, fmt.Errorf("failed to sync snapshot: %v", err)
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return 0, fmt.Errorf("failed to install snapshot: %v", err)
+	}
+	return int64(len(items)), nil
+}
+
+// maybeSave starts a background save when a save rule is due
+func (s *Store) maybeSave() {
+	s.mu.RLock()
+	due := false
+	if !s.closed && !s.saving && (s.lastSaveOK || time.Since(s.lastSaveAttempt) >= saveRetryDelay) {
+		for _, r := range s.saveRules {
+			if s.changes >= r.Changes && time.Since(s.lastSave) >= time.Duration(r.Seconds)*time.Second {
+				due = true
+				break
+			}
+		}
+	}
+	s.mu.RUnlock()
+
+	if !due {
+		return
+	}
+	if err := s.BGSave(); err != nil && err != ErrSaveInProgress {
+		log.Printf("Scheduled save failed: %v", err)
+	}
+}
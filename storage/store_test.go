@@ -21,15 +21,22 @@ func setupTestStore(t *testing.T) *testStore {
 	}
 
 	mockRedis := NewMockRedisClient("localhost:6379", 0)
-	redisStore, err := NewRedisStore("localhost:6379", 0, "test-replica-id")
+	redisStore, err := NewRedisStore("", 0, "test-replica-id")
 	if err != nil {
 		t.Fatalf("Failed to create RedisStore: %v", err)
 	}
 	redisStore.client = mockRedis
+	segmentManager, err := NewSegmentManager(tmpDir + "/segments")
+	if err != nil {
+		t.Fatalf("Failed to create SegmentManager: %v", err)
+	}
+	engine := newMemEngine()
 	store := &Store{
-		items:           make(map[string]*Value),
+		items:           liveEngine{engine},
+		engine:          engine,
 		dataPath:        tmpDir + "/store.json",
-		redis:           redisStore,
+		backend:         redisStore,
+		segmentManager:  segmentManager,
 		cleanupInterval: time.Second * 1,
 		stopCleanup:     make(chan struct{}),
 		ctx:             context.Background(),
@@ -235,7 +242,7 @@ func TestStore_CRDT(t *testing.T) {
 	}
 
 	// Check Redis state
-	redisValue, exists, err := ts.Store.redis.Get(ts.Store.ctx, "key1")
+	redisValue, exists, err := ts.Store.backend.Get(ts.Store.ctx, "key1")
 	if err != nil {
 		t.Errorf("Redis Get failed: %v", err)
 	}
@@ -262,7 +269,7 @@ func TestStore_CRDT(t *testing.T) {
 	}
 
 	// Verify Redis wasn't updated
-	redisValue, exists, err = ts.Store.redis.Get(ts.Store.ctx, "key1")
+	redisValue, exists, err = ts.Store.backend.Get(ts.Store.ctx, "key1")
 	if err != nil {
 		t.Errorf("Redis Get failed: %v", err)
 	}
@@ -294,15 +301,23 @@ func TestStore_Persistence(t *testing.T) {
 
 	// Create new store with same directory
 	mockRedis := NewMockRedisClient("localhost:6379", 0)
-	redisStore2, err := NewRedisStore("localhost:6379", 0, "test-replica-id")
+	redisStore2, err := NewRedisStore("", 0, "test-replica-id")
 	if err != nil {
 		t.Fatalf("Failed to create RedisStore: %v", err)
 	}
 	redisStore2.client = mockRedis // Replace real redis client with mock client
+	segmentManager2, err := NewSegmentManager(tmpDir + "/segments")
+	if err != nil {
+		t.Fatalf("Failed to create SegmentManager: %v", err)
+	}
+	engine2 := newMemEngine()
 	store2 := &Store{
-		items:           make(map[string]*Value),
+		items:           liveEngine{engine2},
+		engine:          engine2,
 		dataPath:        tmpDir + "/store.json",
-		redis:           redisStore2,
+		backend:         redisStore2,
+		dirty:           make(map[string]struct{}),
+		segmentManager:  segmentManager2,
 		cleanupInterval: time.Second * 1,
 		stopCleanup:     make(chan struct{}),
 		ctx:             context.Background(),
@@ -314,9 +329,10 @@ func TestStore_Persistence(t *testing.T) {
 	}()
 
 	// Load existing data
-	if err := store2.load(); err != nil {
+	if err := store2.loadFromSegments(); err != nil {
 		t.Fatalf("Failed to load data: %v", err)
 	}
+	store2.Reconcile()
 
 	// Verify CRDT state persisted
 	value, exists := store2.Get("key1")
@@ -328,7 +344,7 @@ func TestStore_Persistence(t *testing.T) {
 	}
 
 	// Verify Redis was updated
-	redisValue, exists, err := store2.redis.Get(store2.ctx, "key1")
+	redisValue, exists, err := store2.backend.Get(store2.ctx, "key1")
 	if err != nil {
 		t.Errorf("Redis Get failed: %v", err)
 	}
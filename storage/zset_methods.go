@@ -8,17 +8,17 @@ import (
 )
 
 // ZAdd adds one or more members with scores to the sorted set
-func (s *Store) ZAdd(key string, memberScores map[string]float64) (int, error) {
+func (s *Store) ZAdd(key string, memberScores map[string]float64, opts ...OpOption) (int, error) {
+	defer s.awaitDurable()
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	value, exists := s.items[key]
+	value, exists := s.items.Get(key)
 	var zset *CRDTZSet
 
 	if !exists {
 		// Create new sorted set
 		value = NewZSetValue("", nil)
-		s.items[key] = value
 		var err error
 		zset, err = value.GetZSet()
 		if err != nil {
@@ -40,24 +40,28 @@ func (s *Store) ZAdd(key string, memberScores map[string]float64) (int, error) {
 
 	// Update the value in the store
 	value.SetZSet(zset)
+	if err := s.commit(key, value); err != nil {
+		return added, fmt.Errorf("failed to save to disk: %v", err)
+	}
 
+	s.keyChanged("zadd", key, writeOptions(opts))
 	return added, nil
 }
 
 // ZIncrBy increments the score of a member by increment using counter semantics
 // If the member does not exist, it is added with increment as its score
 // Returns the new effective score
-func (s *Store) ZIncrBy(key string, member string, increment float64) (float64, error) {
+func (s *Store) ZIncrBy(key string, member string, increment float64, opts ...OpOption) (float64, error) {
+	defer s.awaitDurable()
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	value, exists := s.items[key]
+	value, exists := s.items.Get(key)
 	var zset *CRDTZSet
 
 	if !exists {
 		// Create new sorted set
 		value = NewZSetValue("", nil)
-		s.items[key] = value
 		var err error
 		zset, err = value.GetZSet()
 		if err != nil {
@@ -79,16 +83,21 @@ func (s *Store) ZIncrBy(key string, member string, increment float64) (float64,
 
 	// Update the value in the store
 	value.SetZSet(zset)
+	if err := s.commit(key, value); err != nil {
+		return newScore, fmt.Errorf("failed to save to disk: %v", err)
+	}
 
+	s.keyChanged("zincr", key, writeOptions(opts))
 	return newScore, nil
 }
 
 // ZRem removes one or more members from the sorted set
-func (s *Store) ZRem(key string, members []string) (int, error) {
+func (s *Store) ZRem(key string, members []string, opts ...OpOption) (int, error) {
+	defer s.awaitDurable()
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	value, exists := s.items[key]
+	value, exists := s.items.Get(key)
 	if !exists {
 		return 0, nil // Key doesn't exist
 	}
@@ -109,6 +118,12 @@ func (s *Store) ZRem(key string, members []string) (int, error) {
 	// Update the value
 	value.SetZSet(zset)
 
+	if removed > 0 {
+		if err := s.commit(key, value); err != nil {
+			return removed, fmt.Errorf("failed to save to disk: %v", err)
+		}
+		s.keyChanged("zrem", key, writeOptions(opts))
+	}
 	return removed, nil
 }
 
@@ -117,7 +132,8 @@ func (s *Store) ZScore(key, member string) (*float64, bool, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	value, exists := s.items[key]
+	value, exists := s.items.Get(key)
+	s.accessed(key)
 	if !exists {
 		return nil, false, nil // Key doesn't exist
 	}
@@ -140,7 +156,8 @@ func (s *Store) ZCard(key string) (int, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	value, exists := s.items[key]
+	value, exists := s.items.Get(key)
+	s.accessed(key)
 	if !exists {
 		return 0, nil // Key doesn't exist
 	}
@@ -162,7 +179,8 @@ func (s *Store) ZRange(key string, start, stop int, withScores bool) ([]string,
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	value, exists := s.items[key]
+	value, exists := s.items.Get(key)
+	s.accessed(key)
 	if !exists {
 		return []string{}, []float64{}, nil // Key doesn't exist
 	}
@@ -185,7 +203,8 @@ func (s *Store) ZRangeByScore(key string, min, max float64, withScores bool) ([]
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	value, exists := s.items[key]
+	value, exists := s.items.Get(key)
+	s.accessed(key)
 	if !exists {
 		return []string{}, []float64{}, nil // Key doesn't exist
 	}
@@ -208,7 +227,8 @@ func (s *Store) ZRank(key, member string) (*int, bool, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	value, exists := s.items[key]
+	value, exists := s.items.Get(key)
+	s.accessed(key)
 	if !exists {
 		return nil, false, nil // Key doesn't exist
 	}
@@ -0,0 +1,342 @@
+package syncer
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/luoyjx/crdt-redis/logging"
+)
+
+// Headers carrying replication request and response signatures
+const (
+	HeaderPeer      = "X-CRDT-Peer"      // replica ID of the signer
+	HeaderTimestamp = "X-CRDT-Timestamp" // unix nanoseconds when signed
+	HeaderNonce     = "X-CRDT-Nonce"     // random per request; responses echo the request nonce
+	HeaderSignature = "X-CRDT-Signature" // hex HMAC-SHA256 of the canonical request or response
+)
+
+// AuthConfig configures peer authentication for the replication endpoints.
+// With PeerKeys every peer signs with its own key, so a peer can only speak
+// for its own replica ID; otherwise all peers share ClusterSecret.
+type AuthConfig struct {
+	SelfID        string            // this node's replica ID
+	ClusterSecret string            // shared secret used when PeerKeys is empty
+	PeerKeys      map[string]string // replica ID -> key, including this node's own key
+	MaxSkew       time.Duration     // accepted clock difference; also bounds the replay window
+	RateLimit     float64           // requests per second allowed per remote host; 0 disables
+	RateBurst     int
+}
+
+// Authenticator signs outgoing replication requests, verifies incoming ones
+// and rate-limits the endpoints
+type Authenticator struct {
+	cfg AuthConfig
+	now func() time.Time
+
+	mu         sync.Mutex
+	nonces     map[string]time.Time // peer+nonce -> expiry, for replay protection
+	nonceOrder []seenID             // nonces in the order they expire
+	buckets    map[string]*bucket
+	lastPrune  time.Time // when idle buckets were last dropped
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// bucketPruneInterval is how often allow drops the buckets of hosts idle
+// long enough to have refilled
+const bucketPruneInterval = time.Minute
+
+type peerContextKey struct{}
+
+// NewAuthenticator validates cfg and returns an Authenticator
+func NewAuthenticator(cfg AuthConfig) (*Authenticator, error) {
+	if cfg.SelfID == "" {
+		return nil, fmt.Errorf("replication authentication requires a replica ID")
+	}
+	if len(cfg.PeerKeys) == 0 && cfg.ClusterSecret == "" {
+		return nil, fmt.Errorf("replication authentication requires a cluster secret or peer keys")
+	}
+	if len(cfg.PeerKeys) > 0 && cfg.PeerKeys[cfg.SelfID] == "" {
+		return nil, fmt.Errorf("no key configured for this replica (%s)", cfg.SelfID)
+	}
+	if cfg.MaxSkew <= 0 {
+		cfg.MaxSkew = 5 * time.Minute
+	}
+	if cfg.RateBurst <= 0 {
+		cfg.RateBurst = int(cfg.RateLimit) + 1
+	}
+	return &Authenticator{
+		cfg:     cfg,
+		now:     time.Now,
+		nonces:  make(map[string]time.Time),
+		buckets: make(map[string]*bucket),
+	}, nil
+}
+
+// PeerFromContext returns the authenticated peer replica ID of a request
+func PeerFromContext(ctx context.Context) (string, bool) {
+	peer, ok := ctx.Value(peerContextKey{}).(string)
+	return peer, ok
+}
+
+func (a *Authenticator) key(peer string) ([]byte, bool) {
+	if len(a.cfg.PeerKeys) > 0 {
+		k, ok := a.cfg.PeerKeys[peer]
+		return []byte(k), ok && k != ""
+	}
+	return []byte(a.cfg.ClusterSecret), true
+}
+
+func (a *Authenticator) mac(key []byte, parts ...string) string {
+	h := hmac.New(sha256.New, key)
+	for _, p := range parts {
+		h.Write([]byte(p))
+		h.Write([]byte{'\n'})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func bodyHash(body []byte) string {
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:])
+}
+
+// SignRequest adds signature headers to req for the given body
+func (a *Authenticator) SignRequest(req *http.Request, body []byte) {
+	nonce := make([]byte, 16)
+	_, _ = rand.Read(nonce)
+	ts := strconv.FormatInt(a.now().UnixNano(), 10)
+	key, _ := a.key(a.cfg.SelfID)
+
+	req.Header.Set(HeaderPeer, a.cfg.SelfID)
+	req.Header.Set(HeaderTimestamp, ts)
+	req.Header.Set(HeaderNonce, hex.EncodeToString(nonce))
+	req.Header.Set(HeaderSignature, a.mac(key, "request", req.Method, req.URL.RequestURI(),
+		a.cfg.SelfID, ts, req.Header.Get(HeaderNonce), bodyHash(body)))
+}
+
+// VerifyRequest checks the signature, clock skew and nonce of a request and
+// returns the authenticated peer replica ID
+func (a *Authenticator) VerifyRequest(r *http.Request, body []byte) (string, error) {
+	peer := r.Header.Get(HeaderPeer)
+	ts := r.Header.Get(HeaderTimestamp)
+	nonce := r.Header.Get(HeaderNonce)
+	sig := r.Header.Get(HeaderSignature)
+	if peer == "" || ts == "" || nonce == "" || sig == "" {
+		return "", fmt.Errorf("missing signature headers")
+	}
+	key, ok := a.key(peer)
+	if !ok {
+		return "", fmt.Errorf("unknown peer %s", peer)
+	}
+	want := a.mac(key, "request", r.Method, r.URL.RequestURI(), peer, ts, nonce, bodyHash(body))
+	if !hmac.Equal([]byte(sig), []byte(want)) {
+		return "", fmt.Errorf("invalid signature from %s", peer)
+	}
+
+	nanos, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return "", fmt.Errorf("invalid timestamp")
+	}
+	now := a.now()
+	skew := now.Sub(time.Unix(0, nanos))
+	if skew > a.cfg.MaxSkew || skew < -a.cfg.MaxSkew {
+		return "", fmt.Errorf("request from %s outside the allowed clock skew", peer)
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	// Every nonce is kept for the same window, so they expire in the order
+	// they were seen
+	for len(a.nonceOrder) > 0 && now.After(a.nonceOrder[0].expiry) {
+		oldest := a.nonceOrder[0]
+		a.nonceOrder = a.nonceOrder[1:]
+		if a.nonces[oldest.id].Equal(oldest.expiry) {
+			delete(a.nonces, oldest.id)
+		}
+	}
+	id := peer + "/" + nonce
+	if exp, seen := a.nonces[id]; seen && now.Before(exp) {
+		return "", fmt.Errorf("replayed request from %s", peer)
+	}
+	expiry := now.Add(2 * a.cfg.MaxSkew)
+	a.nonces[id] = expiry
+	a.nonceOrder = append(a.nonceOrder, seenID{id: id, expiry: expiry})
+	return peer, nil
+}
+
+// signResponse returns the signature of a response to a request carrying nonce
+func (a *Authenticator) signResponse(status int, nonce, ts string, body []byte) string {
+	key, _ := a.key(a.cfg.SelfID)
+	return a.mac(key, "response", strconv.Itoa(status), a.cfg.SelfID, ts, nonce, bodyHash(body))
+}
+
+// verifyResponse checks that a response was signed by a known peer for the
+// request carrying nonce and returns the peer's replica ID
+func (a *Authenticator) verifyResponse(resp *http.Response, nonce string, body []byte) (string, error) {
+	peer := resp.Header.Get(HeaderPeer)
+	sig := resp.Header.Get(HeaderSignature)
+	if peer == "" || sig == "" || resp.Header.Get(HeaderNonce) != nonce {
+		return "", fmt.Errorf("peer response is not signed (status %d)", resp.StatusCode)
+	}
+	key, ok := a.key(peer)
+	if !ok {
+		return "", fmt.Errorf("unknown peer %s", peer)
+	}
+	want := a.mac(key, "response", strconv.Itoa(resp.StatusCode), peer, resp.Header.Get(HeaderTimestamp), nonce, bodyHash(body))
+	if !hmac.Equal([]byte(sig), []byte(want)) {
+		return "", fmt.Errorf("invalid response signature from %s", peer)
+	}
+	return peer, nil
+}
+
+// allow applies the per-host token bucket
+func (a *Authenticator) allow(host string) bool {
+	if a.cfg.RateLimit <= 0 {
+		return true
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	now := a.now()
+	if now.Sub(a.lastPrune) >= bucketPruneInterval {
+		a.pruneBuckets(now)
+	}
+	b, ok := a.buckets[host]
+	if !ok {
+		b = &bucket{tokens: float64(a.cfg.RateBurst), last: now}
+		a.buckets[host] = b
+	}
+	b.tokens += now.Sub(b.last).Seconds() * a.cfg.RateLimit
+	if b.tokens > float64(a.cfg.RateBurst) {
+		b.tokens = float64(a.cfg.RateBurst)
+	}
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// pruneBuckets drops the buckets that have refilled, which a new bucket
+// matches; callers must hold a.mu
+func (a *Authenticator) pruneBuckets(now time.Time) {
+	for host, b := range a.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*a.cfg.RateLimit >= float64(a.cfg.RateBurst) {
+			delete(a.buckets, host)
+		}
+	}
+	a.lastPrune = now
+}
+
+// responseBuffer captures a handler's response so it can be signed
+type responseBuffer struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (b *responseBuffer) Header() http.Header         { return b.header }
+func (b *responseBuffer) Write(p []byte) (int, error) { return b.body.Write(p) }
+func (b *responseBuffer) WriteHeader(status int)      { b.status = status }
+
+// Middleware rate-limits and authenticates requests, exposes the peer through
+// PeerFromContext and signs the response
+func (a *Authenticator) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			host = r.RemoteAddr
+		}
+		if !a.allow(host) {
+			logging.Warnf("Rate limited replication request %s %s from %s", r.Method, r.URL.Path, host)
+			http.Error(w, "rate limit exceeded", http.StatusTooManyRequests)
+			return
+		}
+
+		body, err := io.ReadAll(io.LimitReader(r.Body, 64<<20))
+		if err != nil {
+			http.Error(w, "failed to read request", http.StatusBadRequest)
+			return
+		}
+		peer, err := a.VerifyRequest(r, body)
+		if err != nil {
+			logging.Warnf("Rejected replication request %s %s from %s: %v", r.Method, r.URL.Path, host, err)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		r = r.WithContext(context.WithValue(r.Context(), peerContextKey{}, peer))
+
+		buf := &responseBuffer{header: w.Header(), status: http.StatusOK}
+		next.ServeHTTP(buf, r)
+
+		ts := strconv.FormatInt(a.now().UnixNano(), 10)
+		nonce := r.Header.Get(HeaderNonce)
+		w.Header().Set(HeaderPeer, a.cfg.SelfID)
+		w.Header().Set(HeaderTimestamp, ts)
+		w.Header().Set(HeaderNonce, nonce)
+		w.Header().Set(HeaderSignature, a.signResponse(buf.status, nonce, ts, buf.body.Bytes()))
+		w.WriteHeader(buf.status)
+		_, _ = w.Write(buf.body.Bytes())
+	})
+}
+
+// Transport wraps base so every request is signed and every response is
+// verified; a nil base uses http.DefaultTransport
+func (a *Authenticator) Transport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &signingTransport{auth: a, base: base}
+}
+
+type signingTransport struct {
+	auth *Authenticator
+	base http.RoundTripper
+}
+
+func (t *signingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		if body, err = io.ReadAll(req.Body); err != nil {
+			return nil, err
+		}
+		req.Body.Close()
+	}
+	signed := req.Clone(req.Context())
+	signed.Body = io.NopCloser(bytes.NewReader(body))
+	signed.ContentLength = int64(len(body))
+	t.auth.SignRequest(signed, body)
+
+	resp, err := t.base.RoundTrip(signed)
+	if err != nil {
+		return nil, err
+	}
+	respBody, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := t.auth.verifyResponse(resp, signed.Header.Get(HeaderNonce), respBody); err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+	return resp, nil
+}
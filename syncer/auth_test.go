@@ -0,0 +1,210 @@
+package syncer
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/luoyjx/crdt-redis/proto"
+	"github.com/luoyjx/crdt-redis/server"
+)
+
+var testPeerKeys = map[string]string{"a": "key-a", "b": "key-b"}
+
+func newTestAuth(t *testing.T, self string) *Authenticator {
+	t.Helper()
+	auth, err := NewAuthenticator(AuthConfig{SelfID: self, PeerKeys: testPeerKeys})
+	if err != nil {
+		t.Fatalf("NewAuthenticator failed: %v", err)
+	}
+	return auth
+}
+
+func newReplica(t *testing.T, id string) *server.Server {
+	t.Helper()
+	dir := t.TempDir()
+	srv, err := server.NewServerWithConfig(server.Config{
+		DataDir:   filepath.Join(dir, "store"),
+		OpLogPath: filepath.Join(dir, "oplog.json"),
+		ReplicaID: id,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	t.Cleanup(func() { srv.Close() })
+	return srv
+}
+
+func signedRequest(auth *Authenticator, body string) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, "/apply", bytes.NewReader([]byte(body)))
+	auth.SignRequest(req, []byte(body))
+	return req
+}
+
+func TestVerifyRequest(t *testing.T) {
+	a := newTestAuth(t, "a")
+	b := newTestAuth(t, "b")
+
+	req := signedRequest(a, `{"operations":[]}`)
+	if peer, err := b.VerifyRequest(req, []byte(`{"operations":[]}`)); err != nil || peer != "a" {
+		t.Fatalf("VerifyRequest = %q, %v; want a, nil", peer, err)
+	}
+	if _, err := b.VerifyRequest(req, []byte(`{"operations":[]}`)); err == nil {
+		t.Error("replayed request should be rejected")
+	}
+
+	req = signedRequest(a, `{"operations":[]}`)
+	if _, err := b.VerifyRequest(req, []byte(`{"operations":[{}]}`)); err == nil {
+		t.Error("tampered body should be rejected")
+	}
+
+	req = signedRequest(a, "")
+	req.Header.Set(HeaderPeer, "b")
+	if _, err := b.VerifyRequest(req, nil); err == nil {
+		t.Error("request claiming another peer's identity should be rejected")
+	}
+
+	c, _ := NewAuthenticator(AuthConfig{SelfID: "c", PeerKeys: map[string]string{"c": "key-c"}})
+	if _, err := b.VerifyRequest(signedRequest(c, ""), nil); err == nil {
+		t.Error("unknown peer should be rejected")
+	}
+
+	a.now = func() time.Time { return time.Now().Add(-time.Hour) }
+	if _, err := b.VerifyRequest(signedRequest(a, ""), nil); err == nil {
+		t.Error("stale request should be rejected")
+	}
+}
+
+func TestNoncesExpireInOrder(t *testing.T) {
+	a := newTestAuth(t, "a")
+	b := newTestAuth(t, "b")
+	now := time.Now()
+	clock := func() time.Time { return now }
+	a.now, b.now = clock, clock
+
+	for i := 0; i < 3; i++ {
+		if _, err := b.VerifyRequest(signedRequest(a, ""), nil); err != nil {
+			t.Fatalf("VerifyRequest failed: %v", err)
+		}
+	}
+	now = now.Add(b.cfg.MaxSkew)
+	if _, err := b.VerifyRequest(signedRequest(a, ""), nil); err != nil {
+		t.Fatalf("VerifyRequest failed: %v", err)
+	}
+	if len(b.nonces) != 4 {
+		t.Errorf("%d nonces kept inside the replay window, want 4", len(b.nonces))
+	}
+
+	// Past the window the first three are dropped when the next arrives
+	now = now.Add(b.cfg.MaxSkew + time.Second)
+	if _, err := b.VerifyRequest(signedRequest(a, ""), nil); err != nil {
+		t.Fatalf("VerifyRequest failed: %v", err)
+	}
+	if len(b.nonces) != 2 || len(b.nonceOrder) != 2 {
+		t.Errorf("%d nonces, %d queued after expiry, want 2", len(b.nonces), len(b.nonceOrder))
+	}
+}
+
+func TestIdleBucketsPruned(t *testing.T) {
+	auth, err := NewAuthenticator(AuthConfig{SelfID: "a", PeerKeys: testPeerKeys, RateLimit: 0.01, RateBurst: 2})
+	if err != nil {
+		t.Fatalf("NewAuthenticator failed: %v", err)
+	}
+	start := time.Now()
+	now := start
+	auth.now = func() time.Time { return now }
+	auth.allow("10.0.0.1")
+	now = start.Add(100 * time.Second)
+	auth.allow("10.0.0.2")
+	auth.allow("10.0.0.2")
+
+	// At the next prune 10.0.0.1 refilled, 10.0.0.2 is still drained
+	now = start.Add(2 * bucketPruneInterval)
+	auth.allow("10.0.0.3")
+	if _, ok := auth.buckets["10.0.0.1"]; ok {
+		t.Error("bucket of an idle host was kept")
+	}
+	if b, ok := auth.buckets["10.0.0.2"]; !ok || b.tokens >= 1 {
+		t.Errorf("bucket of a drained host = %+v", b)
+	}
+}
+
+func TestApplyBatchRejectsForeignOrigin(t *testing.T) {
+	srv := newReplica(t, "b")
+	ctx := context.WithValue(context.Background(), peerContextKey{}, "a")
+	batch := &proto.OperationBatch{Operations: []*proto.Operation{
+		{OperationId: "1", Type: proto.OperationType_SET, Command: "SET", Args: []string{"k1", "v"}, Timestamp: 1, ReplicaId: "a"},
+		{OperationId: "2", Type: proto.OperationType_SET, Command: "SET", Args: []string{"k2", "v"}, Timestamp: 2, ReplicaId: "c"},
+	}}
+	ack := ApplyBatch(ctx, srv, batch)
+	if ack.Applied != 1 || ack.Rejected != 1 || ack.Watermark != 2 {
+		t.Errorf("ack = %+v, want 1 applied, 1 rejected, watermark 2", ack)
+	}
+	if _, ok := srv.Get("k2"); ok {
+		t.Error("operation from another replica should not be applied")
+	}
+}
+
+func TestAuthenticatedReplication(t *testing.T) {
+	srvA, srvB := newReplica(t, "a"), newReplica(t, "b")
+	authA, authB := newTestAuth(t, "a"), newTestAuth(t, "b")
+
+	mux := http.NewServeMux()
+	mux.Handle("/ops", authB.Middleware(HandleOps(srvB)))
+	mux.Handle("/apply", authB.Middleware(HandleApply(srvB)))
+	tsB := httptest.NewServer(mux)
+	defer tsB.Close()
+
+	srvA.Set("from-a", "1", nil)
+	srvB.Set("from-b", "2", nil)
+
+	s := New(Config{Peers: []Peer{{Address: tsB.URL}}, Interval: time.Second, Auth: authA}, srvA)
+	if err := s.pullFromPeer(Peer{Address: tsB.URL}); err != nil {
+		t.Fatalf("pull failed: %v", err)
+	}
+	if err := s.pushToPeer(Peer{Address: tsB.URL}); err != nil {
+		t.Fatalf("push failed: %v", err)
+	}
+	if v, _ := srvA.Get("from-b"); v != "2" {
+		t.Errorf("a: from-b = %q, want 2", v)
+	}
+	if v, _ := srvB.Get("from-a"); v != "1" {
+		t.Errorf("b: from-a = %q, want 1", v)
+	}
+
+	// Unsigned requests are refused, and unsigned responses are not trusted
+	resp, err := http.Get(tsB.URL + "/ops")
+	if err != nil {
+		t.Fatalf("GET failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("unsigned request status = %d, want 401", resp.StatusCode)
+	}
+	plain := httptest.NewServer(HandleOps(srvB))
+	defer plain.Close()
+	if err := s.pullFromPeer(Peer{Address: plain.URL}); err == nil {
+		t.Error("unsigned response should be rejected")
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	auth, err := NewAuthenticator(AuthConfig{SelfID: "a", PeerKeys: testPeerKeys, RateLimit: 1, RateBurst: 2})
+	if err != nil {
+		t.Fatalf("NewAuthenticator failed: %v", err)
+	}
+	h := auth.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	var codes []int
+	for i := 0; i < 3; i++ {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, signedRequest(newTestAuth(t, "b"), ""))
+		codes = append(codes, rec.Code)
+	}
+	if codes[0] != http.StatusOK || codes[1] != http.StatusOK || codes[2] != http.StatusTooManyRequests {
+		t.Errorf("status codes = %v, want [200 200 429]", codes)
+	}
+}
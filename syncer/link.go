@@ -0,0 +1,241 @@
+package syncer
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/luoyjx/crdt-redis/metrics"
+)
+
+// LinkState is the health of the replication link to a peer
+type LinkState string
+
+const (
+	LinkConnected LinkState = "connected"
+	LinkDegraded  LinkState = "degraded" // failing, but fewer than MaxRetries consecutive times
+	LinkDown      LinkState = "down"
+)
+
+// link tracks replication health for one peer
+type link struct {
+	failures    int
+	lastError   string
+	lastSuccess time.Time
+	lastAttempt time.Time
+	nextAttempt time.Time
+}
+
+// LinkStatus is a snapshot of the replication link to a peer
+type LinkStatus struct {
+	Address        string    `json:"address"`
+	State          LinkState `json:"state"`
+	Failures       int       `json:"consecutive_failures"`
+	LastError      string    `json:"last_error,omitempty"`
+	LastSuccess    time.Time `json:"last_success"`
+	NextAttempt    time.Time `json:"next_attempt"`
+	SentWatermark  int64     `json:"sent_watermark"`   // highest local op timestamp acknowledged by the peer
+	PullWatermark  int64     `json:"pull_watermark"`   // highest peer op timestamp applied locally
+	LagOps         int       `json:"lag_ops"`          // local ops not yet acknowledged by the peer
+	LagSeconds     float64   `json:"lag_seconds"`      // age of the oldest unacknowledged local op
+	SecondsSinceOK float64   `json:"seconds_since_ok"` // -1 if the link never succeeded
+}
+
+// due reports whether a peer's backoff has elapsed
+func (s *Syncer) due(address string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	l, ok := s.links[address]
+	return !ok || !s.now().Before(l.nextAttempt)
+}
+
+// recordAttempt updates link health and schedules the next attempt with exponential backoff
+func (s *Syncer) recordAttempt(address string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.membership.Contains(address) {
+		return
+	}
+	l := s.linkFor(address)
+	now := s.now()
+	l.lastAttempt = now
+	if err == nil {
+		if l.failures > 0 {
+			log.Printf("Replication link to %s recovered after %d failures", address, l.failures)
+		}
+		l.failures = 0
+		l.lastError = ""
+		l.lastSuccess = now
+		l.nextAttempt = time.Time{}
+		return
+	}
+
+	l.failures++
+	l.lastError = err.Error()
+	l.nextAttempt = now.Add(s.backoff(l.failures))
+	if l.failures == s.cfg.MaxRetries {
+		log.Printf("Replication link to %s is down after %d failures: %v", address, l.failures, err)
+	}
+}
+
+// backoff returns RetryInterval * 2^(failures-1), capped at MaxBackoff
+func (s *Syncer) backoff(failures int) time.Duration {
+	d := s.cfg.RetryInterval
+	for i := 1; i < failures && d < s.cfg.MaxBackoff; i++ {
+		d *= 2
+	}
+	if d > s.cfg.MaxBackoff {
+		d = s.cfg.MaxBackoff
+	}
+	return d
+}
+
+// linkFor returns the link record for a peer; callers must hold s.mu
+func (s *Syncer) linkFor(address string) *link {
+	l, ok := s.links[address]
+	if !ok {
+		l = &link{}
+		s.links[address] = l
+	}
+	return l
+}
+
+func (s *Syncer) stateOf(l *link) LinkState {
+	switch {
+	case l.failures == 0 && !l.lastSuccess.IsZero():
+		return LinkConnected
+	case l.failures >= s.cfg.MaxRetries:
+		return LinkDown
+	case l.failures > 0:
+		return LinkDegraded
+	default:
+		// Never attempted yet
+		return LinkDegraded
+	}
+}
+
+// LinkStatus returns the replication link status for every peer
+func (s *Syncer) LinkStatus() []LinkStatus {
+	peers := s.membership.List()
+	now := s.now()
+	statuses := make([]LinkStatus, 0, len(peers))
+	for _, p := range peers {
+		// A peer removed since List is skipped; one never attempted yet has
+		// no link record, and reading must not create one
+		s.mu.Lock()
+		var l link
+		if rec, ok := s.links[p.Address]; ok {
+			l = *rec
+		} else if !s.membership.Contains(p.Address) {
+			s.mu.Unlock()
+			continue
+		}
+		sent := s.lastSent[p.Address]
+		pulled := s.lastPull[p.Address]
+		s.mu.Unlock()
+
+		st := LinkStatus{
+			Address:        p.Address,
+			State:          s.stateOf(&l),
+			Failures:       l.failures,
+			LastError:      l.lastError,
+			LastSuccess:    l.lastSuccess,
+			NextAttempt:    l.nextAttempt,
+			SentWatermark:  sent,
+			PullWatermark:  pulled,
+			SecondsSinceOK: -1,
+		}
+		if !l.lastSuccess.IsZero() {
+			st.SecondsSinceOK = now.Sub(l.lastSuccess).Seconds()
+		}
+		if s.srv != nil {
+			count, oldest := s.srv.OpLog().PendingSince(sent)
+			st.LagOps = count
+			if oldest > 0 {
+				st.LagSeconds = now.Sub(time.Unix(0, oldest)).Seconds()
+				if st.LagSeconds < 0 {
+					st.LagSeconds = 0
+				}
+			}
+		}
+		statuses = append(statuses, st)
+	}
+	return statuses
+}
+
+// InfoLines renders link status for the INFO replication section
+func (s *Syncer) InfoLines() []string {
+	statuses := s.LinkStatus()
+	connected := 0
+	for _, st := range statuses {
+		if st.State == LinkConnected {
+			connected++
+		}
+	}
+	lines := []string{
+		fmt.Sprintf("connected_peers:%d", connected),
+		fmt.Sprintf("total_peers:%d", len(statuses)),
+	}
+	for i, st := range statuses {
+		lines = append(lines, fmt.Sprintf("peer%d:addr=%s,state=%s,failures=%d,lag_ops=%d,lag_seconds=%.3f",
+			i, st.Address, st.State, st.Failures, st.LagOps, st.LagSeconds))
+	}
+	return lines
+}
+
+// CRDTInfoLines renders per-peer watermarks for the INFO crdt section;
+// pending_ops counts local operations not yet acknowledged by every peer
+func (s *Syncer) CRDTInfoLines() []string {
+	statuses := s.LinkStatus()
+	pending := 0
+	for _, st := range statuses {
+		if st.LagOps > pending {
+			pending = st.LagOps
+		}
+	}
+	forwarded, dropped, received := s.MessageStats()
+	lines := []string{
+		fmt.Sprintf("pending_ops:%d", pending),
+		fmt.Sprintf("pubsub_forwarded:%d", forwarded),
+		fmt.Sprintf("pubsub_dropped:%d", dropped),
+		fmt.Sprintf("pubsub_received:%d", received),
+	}
+	lines = append(lines, s.retentionInfoLines()...)
+	for i, st := range statuses {
+		lines = append(lines, fmt.Sprintf("peer%d:addr=%s,sent_watermark=%d,pull_watermark=%d,lag_ops=%d,lag_seconds=%.3f",
+			i, st.Address, st.SentWatermark, st.PullWatermark, st.LagOps, st.LagSeconds))
+	}
+	return lines
+}
+
+// CollectMetrics writes per-peer replication lag and link health at scrape time
+func (s *Syncer) CollectMetrics(w *metrics.Writer) {
+	forwarded, dropped, received := s.MessageStats()
+	w.Counter("crdt_pubsub_messages_total", "Pub/sub messages exchanged with peers.", float64(forwarded), "result", "forwarded")
+	w.Counter("crdt_pubsub_messages_total", "Pub/sub messages exchanged with peers.", float64(dropped), "result", "dropped")
+	w.Counter("crdt_pubsub_messages_total", "Pub/sub messages exchanged with peers.", float64(received), "result", "received")
+	for _, st := range s.LinkStatus() {
+		up := 0.0
+		if st.State == LinkConnected {
+			up = 1
+		}
+		w.Gauge("crdt_replication_link_up", "Whether the replication link to a peer is connected.", up, "peer", st.Address)
+		w.Gauge("crdt_replication_lag_operations", "Local operations not yet acknowledged by a peer.", float64(st.LagOps), "peer", st.Address)
+		w.Gauge("crdt_replication_lag_seconds", "Age of the oldest operation not yet acknowledged by a peer.", st.LagSeconds, "peer", st.Address)
+		w.Gauge("crdt_replication_link_failures", "Consecutive failed replication attempts to a peer.", float64(st.Failures), "peer", st.Address)
+	}
+}
+
+// HandleReplication serves the replication status admin API as JSON
+func (s *Syncer) HandleReplication(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(s.LinkStatus())
+}
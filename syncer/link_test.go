@@ -0,0 +1,186 @@
+package syncer
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/luoyjx/crdt-redis/metrics"
+	"github.com/luoyjx/crdt-redis/proto"
+	"github.com/luoyjx/crdt-redis/server"
+)
+
+// fakePeer serves /ops and /apply, recording pushed operations
+type fakePeer struct {
+	mu       sync.Mutex
+	failing  bool
+	requests int
+	pushed   []*proto.Operation
+}
+
+func (f *fakePeer) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ops", func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		f.requests++
+		if f.failing {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		json.NewEncoder(w).Encode(&proto.OperationBatch{})
+	})
+	mux.HandleFunc("/apply", func(w http.ResponseWriter, r *http.Request) {
+		var batch proto.OperationBatch
+		json.NewDecoder(r.Body).Decode(&batch)
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		f.pushed = append(f.pushed, batch.Operations...)
+		json.NewEncoder(w).Encode(ApplyAck{Applied: len(batch.Operations), Watermark: batch.Operations[len(batch.Operations)-1].Timestamp})
+	})
+	return mux
+}
+
+func newLocalServer(t *testing.T) *server.Server {
+	t.Helper()
+	dir := t.TempDir()
+	srv, err := server.NewServerWithConfig(server.Config{
+		DataDir:   filepath.Join(dir, "store"),
+		OpLogPath: filepath.Join(dir, "oplog.json"),
+		ReplicaID: "local",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	t.Cleanup(func() { srv.Close() })
+	return srv
+}
+
+func TestPushAdvancesWatermarkOnAck(t *testing.T) {
+	srv := newLocalServer(t)
+	peer := &fakePeer{}
+	ts := httptest.NewServer(peer.handler())
+	defer ts.Close()
+
+	s := New(Config{Peers: []Peer{{Address: ts.URL}}, Interval: time.Second}, srv)
+
+	srv.Set("a", "1", nil)
+	srv.Set("b", "2", nil)
+	s.replicateOnce()
+	s.replicateOnce()
+
+	peer.mu.Lock()
+	pushed := len(peer.pushed)
+	peer.mu.Unlock()
+	if pushed != 2 {
+		t.Fatalf("peer received %d ops, want 2 (no resend after ack)", pushed)
+	}
+
+	st := s.LinkStatus()[0]
+	if st.State != LinkConnected || st.LagOps != 0 {
+		t.Errorf("status = %+v, want connected with no lag", st)
+	}
+
+	srv.Set("c", "3", nil)
+	if st := s.LinkStatus()[0]; st.LagOps != 1 {
+		t.Errorf("lag_ops = %d, want 1", st.LagOps)
+	}
+
+	reg := metrics.NewRegistry()
+	reg.AddCollector(s.CollectMetrics)
+	want := fmt.Sprintf("crdt_replication_lag_operations{peer=%q} 1", ts.URL)
+	if out := reg.Render(); !strings.Contains(out, want) {
+		t.Errorf("metrics missing %q:\n%s", want, out)
+	}
+}
+
+func TestLinkBackoffAndStates(t *testing.T) {
+	srv := newLocalServer(t)
+	peer := &fakePeer{failing: true}
+	ts := httptest.NewServer(peer.handler())
+	defer ts.Close()
+
+	now := time.Unix(1000, 0)
+	s := New(Config{
+		Peers:         []Peer{{Address: ts.URL}},
+		Interval:      time.Second,
+		MaxRetries:    3,
+		RetryInterval: time.Second,
+	}, srv)
+	s.now = func() time.Time { return now }
+
+	s.replicateOnce()
+	st := s.LinkStatus()[0]
+	if st.State != LinkDegraded || st.Failures != 1 {
+		t.Fatalf("after one failure: %+v", st)
+	}
+	if !st.NextAttempt.Equal(now.Add(time.Second)) {
+		t.Errorf("next attempt = %v, want +1s", st.NextAttempt)
+	}
+
+	// Still backing off: no request is made
+	s.replicateOnce()
+	peer.mu.Lock()
+	if peer.requests != 1 {
+		t.Errorf("requests during backoff = %d, want 1", peer.requests)
+	}
+	peer.mu.Unlock()
+
+	// Backoff doubles: 1s, 2s, 4s
+	now = now.Add(time.Second)
+	s.replicateOnce()
+	if st := s.LinkStatus()[0]; !st.NextAttempt.Equal(now.Add(2 * time.Second)) {
+		t.Errorf("second backoff = %v, want +2s", st.NextAttempt.Sub(now))
+	}
+	now = now.Add(2 * time.Second)
+	s.replicateOnce()
+	st = s.LinkStatus()[0]
+	if st.State != LinkDown || st.Failures != 3 {
+		t.Fatalf("after MaxRetries failures: %+v", st)
+	}
+
+	// Recovery resets the link
+	peer.mu.Lock()
+	peer.failing = false
+	peer.mu.Unlock()
+	now = now.Add(4 * time.Second)
+	s.replicateOnce()
+	if st := s.LinkStatus()[0]; st.State != LinkConnected || st.Failures != 0 {
+		t.Errorf("after recovery: %+v", st)
+	}
+
+	lines := strings.Join(s.InfoLines(), "\n")
+	if !strings.Contains(lines, "connected_peers:1") || !strings.Contains(lines, "state=connected") {
+		t.Errorf("unexpected info lines:\n%s", lines)
+	}
+}
+
+func TestLinkStatusDoesNotCreateLinks(t *testing.T) {
+	srv := newLocalServer(t)
+	peer := &fakePeer{}
+	ts := httptest.NewServer(peer.handler())
+	defer ts.Close()
+	s := New(Config{Peers: []Peer{{Address: ts.URL}}, Interval: time.Second}, srv)
+
+	statuses := s.LinkStatus()
+	if len(statuses) != 1 || statuses[0].State != LinkDegraded {
+		t.Fatalf("status before any attempt = %+v", statuses)
+	}
+	if len(s.links) != 0 {
+		t.Errorf("LinkStatus created %d links", len(s.links))
+	}
+
+	s.replicateOnce()
+	if err := s.RemovePeer(ts.URL); err != nil {
+		t.Fatalf("RemovePeer failed: %v", err)
+	}
+	if statuses := s.LinkStatus(); len(statuses) != 0 || len(s.links) != 0 {
+		t.Errorf("removed peer: status %+v, %d links", statuses, len(s.links))
+	}
+}
@@ -0,0 +1,154 @@
+package syncer
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Membership actions carried by a MembershipChange
+const (
+	MembershipAdd    = "add"
+	MembershipRemove = "remove"
+)
+
+// MembershipChange describes a peer being added to or removed from the cluster
+type MembershipChange struct {
+	Action    string `json:"action"`
+	Address   string `json:"address"`
+	Forwarded bool   `json:"forwarded,omitempty"` // set when relayed by another node, so it is not relayed again
+}
+
+// Membership holds the set of replication peers and persists it in the data dir
+type Membership struct {
+	mu    sync.RWMutex
+	path  string
+	peers map[string]Peer
+}
+
+// LoadMembership loads persisted peers from path and merges in the initial peers.
+// An empty path keeps membership in memory only.
+func LoadMembership(path string, initial []Peer) (*Membership, error) {
+	m := &Membership{
+		path:  path,
+		peers: make(map[string]Peer),
+	}
+
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to read membership file: %v", err)
+		}
+		if len(data) > 0 {
+			var peers []Peer
+			if err := json.Unmarshal(data, &peers); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal membership: %v", err)
+			}
+			for _, p := range peers {
+				m.peers[p.Address] = p
+			}
+		}
+	}
+
+	for _, p := range initial {
+		p.Address = normalizeAddress(p.Address)
+		if p.Address != "" {
+			m.peers[p.Address] = p
+		}
+	}
+
+	if err := m.save(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Add adds a peer, returning false if it was already a member
+func (m *Membership) Add(p Peer) (bool, error) {
+	p.Address = normalizeAddress(p.Address)
+	if p.Address == "" {
+		return false, fmt.Errorf("peer address cannot be empty")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.peers[p.Address]; ok {
+		return false, nil
+	}
+	m.peers[p.Address] = p
+	return true, m.save()
+}
+
+// Remove removes a peer, returning false if it was not a member
+func (m *Membership) Remove(address string) (bool, error) {
+	address = normalizeAddress(address)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.peers[address]; !ok {
+		return false, nil
+	}
+	delete(m.peers, address)
+	return true, m.save()
+}
+
+// Contains reports whether address is a member
+func (m *Membership) Contains(address string) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	_, ok := m.peers[normalizeAddress(address)]
+	return ok
+}
+
+// List returns the current peers sorted by address
+func (m *Membership) List() []Peer {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	peers := make([]Peer, 0, len(m.peers))
+	for _, p := range m.peers {
+		peers = append(peers, p)
+	}
+	sort.Slice(peers, func(i, j int) bool { return peers[i].Address < peers[j].Address })
+	return peers
+}
+
+// save writes the membership to disk; callers must hold m.mu
+func (m *Membership) save() error {
+	if m.path == "" {
+		return nil
+	}
+
+	peers := make([]Peer, 0, len(m.peers))
+	for _, p := range m.peers {
+		peers = append(peers, p)
+	}
+	sort.Slice(peers, func(i, j int) bool { return peers[i].Address < peers[j].Address })
+
+	data, err := json.MarshalIndent(peers, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal membership: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(m.path), 0755); err != nil {
+		return fmt.Errorf("failed to create membership directory: %v", err)
+	}
+
+	// Write to a temp file and rename so a crash never leaves a torn file
+	tmp := m.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write membership file: %v", err)
+	}
+	return os.Rename(tmp, m.path)
+}
+
+// normalizeAddress trims whitespace and trailing slashes from a peer address
+func normalizeAddress(address string) string {
+	return strings.TrimRight(strings.TrimSpace(address), "/")
+}
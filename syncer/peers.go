@@ -0,0 +1,154 @@
+package syncer
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+)
+
+// Peers returns the addresses of the current replication peers
+func (s *Syncer) Peers() []string {
+	peers := s.membership.List()
+	addrs := make([]string, 0, len(peers))
+	for _, p := range peers {
+		addrs = append(addrs, p.Address)
+	}
+	return addrs
+}
+
+// AddPeer adds a peer at runtime and propagates the change to the cluster
+func (s *Syncer) AddPeer(address string) error {
+	return s.ApplyMembershipChange(MembershipChange{Action: MembershipAdd, Address: address})
+}
+
+// RemovePeer removes a peer at runtime, forgets its replication state and
+// propagates the change to the cluster
+func (s *Syncer) RemovePeer(address string) error {
+	return s.ApplyMembershipChange(MembershipChange{Action: MembershipRemove, Address: address})
+}
+
+// AddLocalPeer adds a peer on this node only, without announcing it. Discovery
+// and gossip use it: they run on every node, so each node adds its own view.
+func (s *Syncer) AddLocalPeer(address string) error {
+	return s.ApplyMembershipChange(MembershipChange{Action: MembershipAdd, Address: address, Forwarded: true})
+}
+
+// RemoveLocalPeer removes a peer on this node only, without telling the others
+func (s *Syncer) RemoveLocalPeer(address string) error {
+	return s.ApplyMembershipChange(MembershipChange{Action: MembershipRemove, Address: address, Forwarded: true})
+}
+
+// ApplyMembershipChange applies a membership change locally and, unless it was
+// forwarded by another node, relays it to the remaining peers
+func (s *Syncer) ApplyMembershipChange(change MembershipChange) error {
+	address := normalizeAddress(change.Address)
+	if address == "" {
+		return fmt.Errorf("peer address cannot be empty")
+	}
+	if address == s.cfg.SelfAddress {
+		// A node never replicates with itself; forwarded changes about us are expected
+		if change.Forwarded {
+			return nil
+		}
+		return fmt.Errorf("cannot %s self as peer", change.Action)
+	}
+
+	switch change.Action {
+	case MembershipAdd:
+		added, err := s.membership.Add(Peer{Address: address})
+		if err != nil {
+			return err
+		}
+		if !added || change.Forwarded {
+			return nil
+		}
+		log.Printf("Added replication peer %s", address)
+		s.announceMembership(address)
+	case MembershipRemove:
+		removed, err := s.membership.Remove(address)
+		if err != nil {
+			return err
+		}
+		if !removed {
+			return nil
+		}
+		s.forgetPeer(address)
+		log.Printf("Removed replication peer %s", address)
+		if change.Forwarded {
+			return nil
+		}
+		for _, p := range s.membership.List() {
+			s.sendMembershipChange(p.Address, MembershipChange{Action: MembershipRemove, Address: address, Forwarded: true})
+		}
+	default:
+		return fmt.Errorf("unknown membership action: %s", change.Action)
+	}
+	return nil
+}
+
+// announceMembership tells existing peers about a new peer and tells the new
+// peer about every existing member, including this node
+func (s *Syncer) announceMembership(newPeer string) {
+	for _, p := range s.membership.List() {
+		if p.Address == newPeer {
+			continue
+		}
+		s.sendMembershipChange(p.Address, MembershipChange{Action: MembershipAdd, Address: newPeer, Forwarded: true})
+		s.sendMembershipChange(newPeer, MembershipChange{Action: MembershipAdd, Address: p.Address, Forwarded: true})
+	}
+	if s.cfg.SelfAddress != "" {
+		s.sendMembershipChange(newPeer, MembershipChange{Action: MembershipAdd, Address: s.cfg.SelfAddress, Forwarded: true})
+	}
+}
+
+// sendMembershipChange posts a change to a peer's /peers endpoint (best-effort)
+func (s *Syncer) sendMembershipChange(address string, change MembershipChange) {
+	data, err := json.Marshal(change)
+	if err != nil {
+		return
+	}
+	resp, err := s.httpClient.Post(address+"/peers", "application/json", bytes.NewReader(data))
+	if err != nil {
+		log.Printf("Failed to propagate membership change to %s: %v", address, err)
+		return
+	}
+	resp.Body.Close()
+}
+
+// forgetPeer drops all per-peer replication bookkeeping for a removed peer
+func (s *Syncer) forgetPeer(address string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.lastPull, address)
+	delete(s.lastSent, address)
+	delete(s.protobuf, address)
+	delete(s.links, address)
+	for _, st := range s.states {
+		delete(st.accepted, address)
+	}
+}
+
+// HandlePeers serves the membership admin API: GET lists peers, POST applies a MembershipChange
+func (s *Syncer) HandlePeers(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(s.Peers())
+	case http.MethodPost:
+		var change MembershipChange
+		if err := json.NewDecoder(r.Body).Decode(&change); err != nil {
+			http.Error(w, fmt.Sprintf("invalid membership change: %v", err), http.StatusBadRequest)
+			return
+		}
+		if err := s.ApplyMembershipChange(change); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
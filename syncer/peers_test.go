@@ -0,0 +1,156 @@
+package syncer
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestMembershipPersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "peers.json")
+
+	m, err := LoadMembership(path, []Peer{{Address: "http://a:8083/"}})
+	if err != nil {
+		t.Fatalf("LoadMembership failed: %v", err)
+	}
+	if added, err := m.Add(Peer{Address: "http://b:8083"}); err != nil || !added {
+		t.Fatalf("Add failed: added=%v err=%v", added, err)
+	}
+	if added, _ := m.Add(Peer{Address: "http://b:8083"}); added {
+		t.Error("Adding an existing peer should report false")
+	}
+
+	reloaded, err := LoadMembership(path, nil)
+	if err != nil {
+		t.Fatalf("reload failed: %v", err)
+	}
+	got := []string{}
+	for _, p := range reloaded.List() {
+		got = append(got, p.Address)
+	}
+	want := []string{"http://a:8083", "http://b:8083"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("reloaded peers = %v, want %v", got, want)
+	}
+
+	if removed, err := reloaded.Remove("http://a:8083"); err != nil || !removed {
+		t.Fatalf("Remove failed: removed=%v err=%v", removed, err)
+	}
+	again, _ := LoadMembership(path, nil)
+	if again.Contains("http://a:8083") {
+		t.Error("removed peer should not be persisted")
+	}
+}
+
+// newTestSyncer starts a syncer whose /peers endpoint is served by an httptest server
+func newTestSyncer(t *testing.T) (*Syncer, *httptest.Server) {
+	mux := http.NewServeMux()
+	ts := httptest.NewServer(mux)
+	t.Cleanup(ts.Close)
+
+	s := New(Config{
+		SelfAddress:    ts.URL,
+		MembershipPath: filepath.Join(t.TempDir(), "peers.json"),
+	}, nil)
+	mux.HandleFunc("/peers", s.HandlePeers)
+	return s, ts
+}
+
+func TestMembershipPropagation(t *testing.T) {
+	a, aSrv := newTestSyncer(t)
+	b, bSrv := newTestSyncer(t)
+	c, cSrv := newTestSyncer(t)
+
+	if err := a.AddPeer(bSrv.URL); err != nil {
+		t.Fatalf("AddPeer b failed: %v", err)
+	}
+	if err := a.AddPeer(cSrv.URL); err != nil {
+		t.Fatalf("AddPeer c failed: %v", err)
+	}
+
+	// Every node should now know about the other two
+	for name, tc := range map[string]struct {
+		s    *Syncer
+		want []string
+	}{
+		"a": {a, []string{bSrv.URL, cSrv.URL}},
+		"b": {b, []string{aSrv.URL, cSrv.URL}},
+		"c": {c, []string{aSrv.URL, bSrv.URL}},
+	} {
+		got := tc.s.Peers()
+		if !sameSet(got, tc.want) {
+			t.Errorf("node %s peers = %v, want %v", name, got, tc.want)
+		}
+	}
+
+	// Removing c from b propagates to a and drops c's watermark
+	a.mu.Lock()
+	a.lastPull[cSrv.URL] = 42
+	a.mu.Unlock()
+	if err := b.RemovePeer(cSrv.URL); err != nil {
+		t.Fatalf("RemovePeer failed: %v", err)
+	}
+	if a.membership.Contains(cSrv.URL) || b.membership.Contains(cSrv.URL) {
+		t.Error("c should have been removed from a and b")
+	}
+	a.mu.Lock()
+	_, ok := a.lastPull[cSrv.URL]
+	a.mu.Unlock()
+	if ok {
+		t.Error("watermark for removed peer should be forgotten")
+	}
+
+	if err := a.AddPeer(aSrv.URL); err == nil {
+		t.Error("adding self as peer should fail")
+	}
+}
+
+func TestLocalMembershipChangesAreNotPropagated(t *testing.T) {
+	a, _ := newTestSyncer(t)
+	b, bSrv := newTestSyncer(t)
+	c, cSrv := newTestSyncer(t)
+
+	if err := a.AddPeer(bSrv.URL); err != nil {
+		t.Fatalf("AddPeer b failed: %v", err)
+	}
+	if err := a.AddLocalPeer(cSrv.URL); err != nil {
+		t.Fatalf("AddLocalPeer c failed: %v", err)
+	}
+	if !a.membership.Contains(cSrv.URL) {
+		t.Error("a should have added c")
+	}
+	if b.membership.Contains(cSrv.URL) || len(c.Peers()) != 0 {
+		t.Errorf("local add leaked: b peers %v, c peers %v", b.Peers(), c.Peers())
+	}
+
+	if err := a.RemoveLocalPeer(bSrv.URL); err != nil {
+		t.Fatalf("RemoveLocalPeer b failed: %v", err)
+	}
+	if a.membership.Contains(bSrv.URL) {
+		t.Error("a should have removed b")
+	}
+	if len(b.Peers()) != 1 {
+		t.Errorf("local remove leaked: b peers %v", b.Peers())
+	}
+}
+
+func sameSet(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	seen := make(map[string]int)
+	for _, v := range a {
+		seen[v]++
+	}
+	for _, v := range b {
+		seen[v]--
+	}
+	for _, n := range seen {
+		if n != 0 {
+			return false
+		}
+	}
+	return true
+}
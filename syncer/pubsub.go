@@ -0,0 +1,198 @@
+package syncer
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	messageQueueSize = 1024
+	messageBatchSize = 128
+	// messageSeenTTL bounds how long message IDs are remembered for dedupe
+	messageSeenTTL = 10 * time.Minute
+	// messageSeenMax caps the remembered IDs; past it the oldest are
+	// forgotten early, at the risk of delivering a late duplicate
+	messageSeenMax = 100000
+)
+
+// Message is a pub/sub message forwarded to peers. Messages are not written
+// to the operation log: each is sent once to every peer and lost if a peer is
+// unreachable (at-most-once delivery).
+type Message struct {
+	ID      string `json:"id"`
+	Channel string `json:"channel"`
+	Payload string `json:"payload"`
+}
+
+// messageRelay queues published messages for peers and dedupes received ones
+type messageRelay struct {
+	queue   chan Message
+	deliver func(channel, payload string) int
+
+	mu        sync.Mutex
+	seen      map[string]time.Time // message ID -> expiry
+	seenOrder []seenID             // IDs in the order they expire
+
+	forwarded int64 // updated atomically
+	dropped   int64 // updated atomically
+	received  int64 // updated atomically
+}
+
+// seenID is a remembered message ID and its expiry
+type seenID struct {
+	id     string
+	expiry time.Time
+}
+
+func newMessageRelay() *messageRelay {
+	return &messageRelay{
+		queue: make(chan Message, messageQueueSize),
+		seen:  make(map[string]time.Time),
+	}
+}
+
+// SetMessageHandler registers the function delivering messages received from
+// peers to local subscribers
+func (s *Syncer) SetMessageHandler(fn func(channel, payload string) int) {
+	s.relay.deliver = fn
+}
+
+// Broadcast queues a locally published message for every peer. It never
+// blocks: when the queue is full the message is dropped for remote regions.
+func (s *Syncer) Broadcast(channel, payload string) {
+	id := make([]byte, 16)
+	_, _ = rand.Read(id)
+	msg := Message{ID: hex.EncodeToString(id), Channel: channel, Payload: payload}
+	s.relay.markSeen(msg.ID, s.now())
+	select {
+	case s.relay.queue <- msg:
+	default:
+		atomic.AddInt64(&s.relay.dropped, 1)
+	}
+}
+
+// forwardMessages sends queued messages to peers in batches until stop closes
+func (s *Syncer) forwardMessages(stop <-chan struct{}) {
+	for {
+		var batch []Message
+		select {
+		case msg := <-s.relay.queue:
+			batch = append(batch, msg)
+		case <-stop:
+			return
+		}
+	drain:
+		for len(batch) < messageBatchSize {
+			select {
+			case msg := <-s.relay.queue:
+				batch = append(batch, msg)
+			default:
+				break drain
+			}
+		}
+		s.sendMessages(batch)
+	}
+}
+
+// sendMessages posts a batch to every peer concurrently without retrying
+func (s *Syncer) sendMessages(batch []Message) {
+	data, err := json.Marshal(batch)
+	if err != nil {
+		log.Printf("Failed to marshal pub/sub messages: %v", err)
+		return
+	}
+	var wg sync.WaitGroup
+	for _, p := range s.membership.List() {
+		wg.Add(1)
+		go func(address string) {
+			defer wg.Done()
+			if err := s.postMessages(address, data); err != nil {
+				atomic.AddInt64(&s.relay.dropped, int64(len(batch)))
+				log.Printf("Dropped %d pub/sub messages for %s: %v", len(batch), address, err)
+				return
+			}
+			atomic.AddInt64(&s.relay.forwarded, int64(len(batch)))
+		}(p.Address)
+	}
+	wg.Wait()
+}
+
+func (s *Syncer) postMessages(address string, data []byte) error {
+	req, _ := http.NewRequest(http.MethodPost, address+"/publish", bytes.NewReader(data))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("publish returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// markSeen records a message ID and reports whether it was new
+func (r *messageRelay) markSeen(id string, now time.Time) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if exp, ok := r.seen[id]; ok && now.Before(exp) {
+		return false
+	}
+	// Every ID is kept for the same TTL, so they expire in the order they
+	// were seen
+	for len(r.seenOrder) > 0 && (!now.Before(r.seenOrder[0].expiry) || len(r.seenOrder) >= messageSeenMax) {
+		r.forgetOldest()
+	}
+	expiry := now.Add(messageSeenTTL)
+	r.seen[id] = expiry
+	r.seenOrder = append(r.seenOrder, seenID{id: id, expiry: expiry})
+	return true
+}
+
+// forgetOldest drops the first ID of seenOrder, unless it was seen again
+// since; callers must hold r.mu
+func (r *messageRelay) forgetOldest() {
+	oldest := r.seenOrder[0]
+	r.seenOrder = r.seenOrder[1:]
+	if r.seen[oldest.id].Equal(oldest.expiry) {
+		delete(r.seen, oldest.id)
+	}
+}
+
+// HandlePublish serves /publish for peers forwarding pub/sub messages
+func (s *Syncer) HandlePublish(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var batch []Message
+	if err := json.NewDecoder(r.Body).Decode(&batch); err != nil {
+		http.Error(w, fmt.Sprintf("invalid messages: %v", err), http.StatusBadRequest)
+		return
+	}
+	for _, msg := range batch {
+		if msg.ID == "" || !s.relay.markSeen(msg.ID, s.now()) {
+			continue
+		}
+		atomic.AddInt64(&s.relay.received, 1)
+		if s.relay.deliver != nil {
+			s.relay.deliver(msg.Channel, msg.Payload)
+		}
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// MessageStats returns the number of pub/sub messages forwarded to peers,
+// dropped before reaching a peer, and received from peers
+func (s *Syncer) MessageStats() (forwarded, dropped, received int64) {
+	return atomic.LoadInt64(&s.relay.forwarded), atomic.LoadInt64(&s.relay.dropped), atomic.LoadInt64(&s.relay.received)
+}
@@ -0,0 +1,97 @@
+package syncer
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMessagesForwardedOnceToPeers(t *testing.T) {
+	var mu sync.Mutex
+	var got []string
+	receiver := New(Config{}, nil)
+	receiver.SetMessageHandler(func(channel, payload string) int {
+		mu.Lock()
+		defer mu.Unlock()
+		got = append(got, channel+"="+payload)
+		return 1
+	})
+	ts := httptest.NewServer(http.HandlerFunc(receiver.HandlePublish))
+	defer ts.Close()
+
+	sender := New(Config{Peers: []Peer{{Address: ts.URL}}, Interval: time.Hour}, nil)
+	stop := make(chan struct{})
+	defer close(stop)
+	go sender.forwardMessages(stop)
+
+	sender.Broadcast("news", "hello")
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if forwarded, _, _ := sender.MessageStats(); forwarded == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("message was not delivered to the peer")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// A redelivered message is dropped by ID
+	data, _ := json.Marshal([]Message{{ID: "dup", Channel: "news", Payload: "once"}})
+	for i := 0; i < 2; i++ {
+		resp, err := http.Post(ts.URL, "application/json", bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("POST failed: %v", err)
+		}
+		resp.Body.Close()
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(got) != 2 || got[0] != "news=hello" || got[1] != "news=once" {
+		t.Errorf("delivered = %v, want [news=hello news=once]", got)
+	}
+	if forwarded, _, _ := sender.MessageStats(); forwarded != 1 {
+		t.Errorf("forwarded = %d, want 1", forwarded)
+	}
+	if _, _, received := receiver.MessageStats(); received != 2 {
+		t.Errorf("received = %d, want 2", received)
+	}
+}
+
+func TestMarkSeenExpiresInOrder(t *testing.T) {
+	r := newMessageRelay()
+	now := time.Now()
+	if !r.markSeen("a", now) || r.markSeen("a", now.Add(time.Minute)) {
+		t.Fatal("a was not deduped within the TTL")
+	}
+	r.markSeen("b", now.Add(time.Minute))
+
+	// a expired and is popped when the next ID is seen; b is still kept
+	later := now.Add(messageSeenTTL)
+	if !r.markSeen("c", later) {
+		t.Fatal("c was not new")
+	}
+	if _, ok := r.seen["a"]; ok || len(r.seen) != 2 || len(r.seenOrder) != 2 {
+		t.Errorf("seen = %v, order = %v after a expired", r.seen, r.seenOrder)
+	}
+	if !r.markSeen("a", later) || r.markSeen("b", later) {
+		t.Error("a was not new after expiring, or b was not deduped")
+	}
+
+	// The cap forgets the oldest IDs even inside the TTL
+	for i := 0; i < messageSeenMax+10; i++ {
+		r.markSeen(fmt.Sprintf("m%d", i), later)
+	}
+	if len(r.seen) > messageSeenMax || len(r.seenOrder) > messageSeenMax {
+		t.Errorf("remembering %d IDs, %d queued, cap %d", len(r.seen), len(r.seenOrder), messageSeenMax)
+	}
+	if !r.markSeen("m0", later) {
+		t.Error("oldest ID not forgotten at the cap")
+	}
+}
@@ -0,0 +1,163 @@
+package syncer
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/luoyjx/crdt-redis/logging"
+	"github.com/luoyjx/crdt-redis/server"
+)
+
+// HeaderWatermark carries, on a /snapshot response, the timestamp of the
+// newest operation of the peer's log that the snapshot includes
+const HeaderWatermark = "X-CRDT-Watermark"
+
+// Retention returns the minimum age of dropped operations and the cap on
+// logged operations
+func (s *Syncer) Retention() (time.Duration, int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.cfg.OpLogRetention, s.cfg.OpLogMaxOps
+}
+
+// SetRetention changes when operations are dropped from the log: once every
+// peer acknowledged them and they are older than window, or when the log
+// holds more than maxOps (0 for no cap)
+func (s *Syncer) SetRetention(window time.Duration, maxOps int) error {
+	if window < 0 {
+		return fmt.Errorf("oplog retention cannot be negative")
+	}
+	if maxOps < 0 {
+		return fmt.Errorf("oplog max operations cannot be negative")
+	}
+	s.mu.Lock()
+	s.cfg.OpLogRetention, s.cfg.OpLogMaxOps = window, maxOps
+	s.mu.Unlock()
+	return nil
+}
+
+// ackedWatermark returns the newest operation timestamp every peer has
+// acknowledged; callers must hold s.mu
+func (s *Syncer) ackedWatermark() int64 {
+	acked := int64(math.MaxInt64)
+	for _, p := range s.membership.List() {
+		if s.lastSent[p.Address] < acked {
+			acked = s.lastSent[p.Address]
+		}
+	}
+	return acked
+}
+
+// compactLog truncates the operation log after a replication round. A peer
+// that was down past the size cap finds the log truncated and bootstraps.
+func (s *Syncer) compactLog() {
+	s.mu.Lock()
+	through := s.ackedWatermark()
+	if cutoff := s.now().Add(-s.cfg.OpLogRetention).UnixNano(); cutoff < through {
+		through = cutoff
+	}
+	maxOps := s.cfg.OpLogMaxOps
+	s.mu.Unlock()
+
+	n, err := s.srv.OpLog().Truncate(through, maxOps)
+	if err != nil {
+		log.Printf("Failed to truncate operation log: %v", err)
+		return
+	}
+	if n > 0 {
+		logging.Debugf("Truncated %d operations from the operation log", n)
+	}
+}
+
+// ErrNoPeers is returned by Bootstrap when there is no peer to merge a
+// snapshot from
+var ErrNoPeers = errors.New("no replication peers")
+
+// Bootstrap merges the snapshot of the first peer that serves one. A node
+// restored as the same replica calls it before accepting writes, to take
+// back from its peers the counter contributions and clock entries it made
+// after the backup.
+func (s *Syncer) Bootstrap() error {
+	peers := s.membership.List()
+	if len(peers) == 0 {
+		return ErrNoPeers
+	}
+	var errs []string
+	for _, p := range peers {
+		err := s.bootstrapFromPeer(p)
+		if err == nil {
+			return nil
+		}
+		errs = append(errs, fmt.Sprintf("%s: %v", p.Address, err))
+	}
+	return fmt.Errorf("no peer served a snapshot: %s", strings.Join(errs, "; "))
+}
+
+// bootstrapFromPeer merges a peer's snapshot into the store and resumes
+// pulling from the watermark it covers
+func (s *Syncer) bootstrapFromPeer(p Peer) error {
+	resp, err := s.httpClient.Get(p.Address + "/snapshot")
+	if err != nil {
+		return fmt.Errorf("snapshot request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("snapshot returned status %d", resp.StatusCode)
+	}
+	watermark, err := strconv.ParseInt(resp.Header.Get(HeaderWatermark), 10, 64)
+	if err != nil {
+		return fmt.Errorf("snapshot has no valid %s header", HeaderWatermark)
+	}
+	keys, err := s.srv.MergeSnapshot(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	if s.membership.Contains(p.Address) && watermark > s.lastPull[p.Address] {
+		s.lastPull[p.Address] = watermark
+	}
+	s.bootstraps++
+	s.mu.Unlock()
+	log.Printf("Bootstrapped from %s: merged %d keys, resuming at %d", p.Address, keys, watermark)
+	return nil
+}
+
+// HandleSnapshot serves /snapshot for peers bootstrapping after this
+// replica truncated operations they had not pulled
+func HandleSnapshot(srv *server.Server) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		write, watermark, err := srv.Checkpoint()
+		if err != nil {
+			http.Error(w, fmt.Sprintf("failed to capture snapshot: %v", err), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set(HeaderWatermark, strconv.FormatInt(watermark, 10))
+		if _, err := write(w); err != nil {
+			log.Printf("Failed to send snapshot to %s: %v", r.RemoteAddr, err)
+		}
+	}
+}
+
+// retentionInfoLines renders log retention for the INFO crdt section
+func (s *Syncer) retentionInfoLines() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	acked := s.ackedWatermark()
+	if acked == math.MaxInt64 {
+		acked = -1 // no peers
+	}
+	return []string{
+		fmt.Sprintf("oplog_acked_watermark:%d", acked),
+		fmt.Sprintf("oplog_retention_seconds:%d", int64(s.cfg.OpLogRetention.Seconds())),
+		fmt.Sprintf("oplog_max_operations:%d", s.cfg.OpLogMaxOps),
+		fmt.Sprintf("peer_bootstraps:%d", s.bootstraps),
+	}
+}
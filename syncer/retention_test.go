@@ -0,0 +1,132 @@
+package syncer
+
+import (
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/luoyjx/crdt-redis/server"
+)
+
+func TestBootstrapAfterTruncation(t *testing.T) {
+	srvA, srvB := newReplica(t, "a"), newReplica(t, "b")
+	mux := http.NewServeMux()
+	mux.Handle("/ops", HandleOps(srvB))
+	mux.Handle("/apply", HandleApply(srvB))
+	mux.Handle("/snapshot", HandleSnapshot(srvB))
+	tsB := httptest.NewServer(mux)
+	defer tsB.Close()
+
+	// b dropped its whole log before a ever pulled
+	srvB.Set("k1", "v1", nil)
+	srvB.HSet("h", "f", "v")
+	if n, err := srvB.OpLog().Truncate(math.MaxInt64, 0); err != nil || n != 2 {
+		t.Fatalf("Truncate = %d, %v", n, err)
+	}
+	srvA.Set("local", "1", nil)
+
+	s := New(Config{Peers: []Peer{{Address: tsB.URL}}, Interval: time.Second, OpLogRetention: time.Hour}, srvA)
+	s.replicateOnce()
+	if v, _ := srvA.Get("k1"); v != "v1" {
+		t.Errorf("k1 = %q after bootstrap, want v1", v)
+	}
+	if v, _, _ := srvA.HGet("h", "f"); v != "v" {
+		t.Errorf("h.f = %q after bootstrap, want v", v)
+	}
+	s.mu.Lock()
+	if s.bootstraps != 1 || s.lastPull[tsB.URL] != srvB.OpLog().Truncated() {
+		t.Errorf("bootstraps = %d, lastPull = %d", s.bootstraps, s.lastPull[tsB.URL])
+	}
+	s.mu.Unlock()
+
+	// Later writes are pulled from the log again
+	srvB.Set("k2", "v2", nil)
+	s.replicateOnce()
+	if v, _ := srvA.Get("k2"); v != "v2" {
+		t.Errorf("k2 = %q, want v2", v)
+	}
+	if s.bootstraps != 1 {
+		t.Errorf("bootstraps = %d after catching up, want 1", s.bootstraps)
+	}
+	if v, _ := srvB.Get("local"); v != "1" {
+		t.Errorf("b: local = %q, want 1", v)
+	}
+}
+
+func TestBootstrapAfterRestore(t *testing.T) {
+	dir := t.TempDir()
+	srvA, srvB := newReplica(t, "a"), newReplica(t, "b")
+	mux := http.NewServeMux()
+	mux.Handle("/ops", HandleOps(srvB))
+	mux.Handle("/apply", HandleApply(srvB))
+	mux.Handle("/snapshot", HandleSnapshot(srvB))
+	tsB := httptest.NewServer(mux)
+	defer tsB.Close()
+	cfg := Config{Peers: []Peer{{Address: tsB.URL}}, Interval: time.Second, OpLogRetention: time.Hour}
+
+	// a backs up at 5 and replicates 10 to b before it is restored
+	for i := 0; i < 10; i++ {
+		if i == 5 {
+			if _, err := srvA.Backup(filepath.Join(dir, "a.tar.gz")); err != nil {
+				t.Fatalf("Backup failed: %v", err)
+			}
+		}
+		srvA.Incr("n")
+	}
+	New(cfg, srvA).replicateOnce()
+
+	opts := server.RestoreOptions{DataDir: filepath.Join(dir, "store"), OpLogPath: filepath.Join(dir, "oplog.json")}
+	if _, _, err := server.Restore(filepath.Join(dir, "a.tar.gz"), opts); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+	restored, err := server.NewServerWithConfig(server.Config{DataDir: opts.DataDir, OpLogPath: opts.OpLogPath})
+	if err != nil {
+		t.Fatalf("Failed to start restored server: %v", err)
+	}
+	defer restored.Close()
+
+	s := New(cfg, restored)
+	if err := s.Bootstrap(); err != nil {
+		t.Fatalf("Bootstrap failed: %v", err)
+	}
+	restored.Incr("n")
+	s.replicateOnce()
+	for name, srv := range map[string]*server.Server{"a": restored, "b": srvB} {
+		if v, _ := srv.Get("n"); v != "11" {
+			t.Errorf("%s: n = %q, want 11", name, v)
+		}
+	}
+
+	if err := New(Config{Interval: time.Second}, restored).Bootstrap(); err != ErrNoPeers {
+		t.Errorf("Bootstrap without peers = %v, want ErrNoPeers", err)
+	}
+}
+
+func TestCompactLogKeepsUnacknowledged(t *testing.T) {
+	srvA := newReplica(t, "a")
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+	s := New(Config{Peers: []Peer{{Address: down.URL}}, Interval: time.Second}, srvA)
+
+	srvA.Set("k1", "v1", nil)
+	srvA.Set("k2", "v2", nil)
+	s.compactLog()
+	if n := srvA.OpLog().Len(); n != 2 {
+		t.Errorf("log holds %d operations with a peer behind, want 2", n)
+	}
+
+	// The size cap drops operations no matter what peers acknowledged
+	if err := s.SetRetention(0, 1); err != nil {
+		t.Fatalf("SetRetention failed: %v", err)
+	}
+	s.compactLog()
+	if st := srvA.OpLog().Stats(); st.Operations != 1 || st.TruncatedOps != 1 || st.Truncated == 0 {
+		t.Errorf("stats after the cap = %+v", st)
+	}
+	if err := s.SetRetention(-time.Second, 0); err == nil {
+		t.Error("SetRetention accepted a negative window")
+	}
+}
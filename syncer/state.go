@@ -0,0 +1,64 @@
+package syncer
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+)
+
+// replicatedState is state kept outside the operation log, such as ACL
+// users, that is posted whole to every peer after it changes. Pushes ride
+// the replication rounds, so a peer that misses one gets the state again
+// once its link's backoff elapses.
+type replicatedState struct {
+	path     string
+	snapshot func() (int64, []byte, error)
+	accepted map[string]int64 // peer -> generation it accepted
+}
+
+// ReplicateState posts the state returned by snapshot to path on every peer
+// whenever its generation is ahead of the one the peer last accepted. The
+// peer must apply it idempotently, as the same state can arrive again.
+func (s *Syncer) ReplicateState(path string, snapshot func() (generation int64, data []byte, err error)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.states = append(s.states, &replicatedState{path: path, snapshot: snapshot, accepted: make(map[string]int64)})
+}
+
+// pushStates posts every replicated state the peer has not accepted yet
+func (s *Syncer) pushStates(p Peer) error {
+	s.mu.Lock()
+	states := append([]*replicatedState(nil), s.states...)
+	s.mu.Unlock()
+
+	for _, st := range states {
+		generation, data, err := st.snapshot()
+		if err != nil {
+			return err
+		}
+		s.mu.Lock()
+		accepted := st.accepted[p.Address]
+		s.mu.Unlock()
+		if generation <= accepted {
+			continue
+		}
+
+		req, _ := http.NewRequest(http.MethodPost, p.Address+st.path, bytes.NewReader(data))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := s.httpClient.Do(req)
+		if err != nil {
+			return fmt.Errorf("push of %s failed: %v", st.path, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("push of %s returned status %d", st.path, resp.StatusCode)
+		}
+
+		s.mu.Lock()
+		if s.membership.Contains(p.Address) {
+			st.accepted[p.Address] = generation
+		}
+		s.mu.Unlock()
+	}
+	return nil
+}
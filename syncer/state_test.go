@@ -0,0 +1,67 @@
+package syncer
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestStateReplicatedUntilAccepted(t *testing.T) {
+	srv := newLocalServer(t)
+	var mu sync.Mutex
+	failing := true
+	var received []string
+	mux := http.NewServeMux()
+	mux.Handle("/", (&fakePeer{}).handler())
+	mux.HandleFunc("/state", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		defer mu.Unlock()
+		if failing {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		received = append(received, string(body))
+	})
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	s := New(Config{Peers: []Peer{{Address: ts.URL}}, Interval: time.Second, RetryInterval: time.Millisecond}, srv)
+	generation := int64(1)
+	s.ReplicateState("/state", func() (int64, []byte, error) {
+		return generation, []byte(fmt.Sprintf("state %d", generation)), nil
+	})
+	expect := func(want ...string) {
+		t.Helper()
+		mu.Lock()
+		defer mu.Unlock()
+		if !reflect.DeepEqual(received, want) {
+			t.Fatalf("peer received %q, want %q", received, want)
+		}
+	}
+
+	// A refused push fails the round and is retried after the backoff
+	s.replicateOnce()
+	expect()
+	if st := s.LinkStatus()[0]; st.Failures != 1 {
+		t.Errorf("failures = %d, want 1", st.Failures)
+	}
+	mu.Lock()
+	failing = false
+	mu.Unlock()
+	time.Sleep(5 * time.Millisecond)
+	s.replicateOnce()
+	expect("state 1")
+
+	// Accepted state is only sent again once it changes
+	s.replicateOnce()
+	expect("state 1")
+	generation = 2
+	s.replicateOnce()
+	expect("state 1", "state 2")
+}
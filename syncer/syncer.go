@@ -6,12 +6,18 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"log"
+	"mime"
 	"net/http"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/luoyjx/crdt-redis/logging"
 	"github.com/luoyjx/crdt-redis/proto"
 	"github.com/luoyjx/crdt-redis/server"
+	protobuf "google.golang.org/protobuf/proto"
 )
 
 // Peer represents a remote node
@@ -21,9 +27,17 @@ type Peer struct {
 
 // Config for Syncer
 type Config struct {
-	SelfAddress string
-	Peers       []Peer
-	Interval    time.Duration
+	SelfAddress    string
+	Peers          []Peer
+	Interval       time.Duration
+	MembershipPath string         // file persisting runtime peer changes; empty keeps them in memory
+	MaxRetries     int            // consecutive failures before a link is reported down
+	RetryInterval  time.Duration  // initial backoff after a failure, doubled per consecutive failure
+	MaxBackoff     time.Duration  // cap on the backoff between attempts
+	HTTPClient     *http.Client   // client for peer requests, e.g. configured for mutual TLS; nil uses a plain client
+	Auth           *Authenticator // signs peer requests and verifies responses; nil disables authentication
+	OpLogRetention time.Duration  // minimum age before operations every peer acknowledged are dropped from the log
+	OpLogMaxOps    int            // cap on operations kept in the log, acknowledged or not; 0 for no cap
 }
 
 // Syncer performs periodic pull and apply of operations between peers
@@ -31,32 +45,100 @@ type Syncer struct {
 	cfg        Config
 	srv        *server.Server
 	httpClient *http.Client
+	membership *Membership
 	mu         sync.Mutex
-	lastSent   int64               // watermark for outbound since
+	lastSent   map[string]int64    // per-peer outbound watermark acknowledged by the peer
 	lastPull   map[string]int64    // per-peer last pull timestamp
+	protobuf   map[string]bool     // peers that answered the last pull in protobuf, so pushes to them use it too
+	links      map[string]*link    // per-peer link health
 	seen       map[string]struct{} // op-id dedupe (best-effort)
+	relay      *messageRelay       // pub/sub messages to and from peers
+	states     []*replicatedState  // state outside the operation log, such as ACL users
+	resetTick  chan struct{}       // signals the replication loop that the interval changed
+	bootstraps int64               // snapshot bootstraps from peers
+	now        func() time.Time
 }
 
 func New(cfg Config, srv *server.Server) *Syncer {
+	cfg.SelfAddress = normalizeAddress(cfg.SelfAddress)
+	if cfg.MaxRetries <= 0 {
+		cfg.MaxRetries = 3
+	}
+	if cfg.RetryInterval <= 0 {
+		cfg.RetryInterval = time.Second
+	}
+	if cfg.MaxBackoff <= 0 {
+		cfg.MaxBackoff = time.Minute
+	}
+	membership, err := LoadMembership(cfg.MembershipPath, cfg.Peers)
+	if err != nil {
+		log.Printf("Failed to load peer membership, using configured peers only: %v", err)
+		membership, _ = LoadMembership("", cfg.Peers)
+	}
+	client := cfg.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: 5 * time.Second}
+	}
+	if cfg.Auth != nil {
+		client = &http.Client{Timeout: client.Timeout, Transport: cfg.Auth.Transport(client.Transport)}
+	}
 	return &Syncer{
 		cfg:        cfg,
 		srv:        srv,
-		httpClient: &http.Client{Timeout: 5 * time.Second},
-		lastSent:   0,
+		httpClient: client,
+		membership: membership,
+		lastSent:   make(map[string]int64),
 		lastPull:   make(map[string]int64),
+		protobuf:   make(map[string]bool),
+		links:      make(map[string]*link),
 		seen:       make(map[string]struct{}),
+		relay:      newMessageRelay(),
+		resetTick:  make(chan struct{}, 1),
+		now:        time.Now,
+	}
+}
+
+// HTTPClient returns the client used for peer requests, including TLS and
+// request signing, so other components can reach peers the same way
+func (s *Syncer) HTTPClient() *http.Client {
+	return s.httpClient
+}
+
+// Interval returns the time between replication rounds
+func (s *Syncer) Interval() time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.cfg.Interval
+}
+
+// SetInterval changes the time between replication rounds; a running
+// replication loop switches to the new interval immediately
+func (s *Syncer) SetInterval(d time.Duration) error {
+	if d <= 0 {
+		return fmt.Errorf("sync interval must be positive")
+	}
+	s.mu.Lock()
+	s.cfg.Interval = d
+	s.mu.Unlock()
+	select {
+	case s.resetTick <- struct{}{}:
+	default:
 	}
+	return nil
 }
 
 // Start launches periodic replication in background
 func (s *Syncer) Start(stop <-chan struct{}) {
-	ticker := time.NewTicker(s.cfg.Interval)
+	ticker := time.NewTicker(s.Interval())
+	go s.forwardMessages(stop)
 	go func() {
 		defer ticker.Stop()
 		for {
 			select {
 			case <-ticker.C:
 				s.replicateOnce()
+			case <-s.resetTick:
+				ticker.Reset(s.Interval())
 			case <-stop:
 				return
 			}
@@ -70,35 +152,66 @@ func (s *Syncer) Stop() {
 	// This method is a placeholder for future cleanup logic
 }
 
-// replicateOnce pulls from peers and pushes our ops
+// replicateOnce pulls from and pushes operations and replicated state to
+// every peer that is not backing off
 func (s *Syncer) replicateOnce() {
-	// Pull from peers
-	for _, p := range s.cfg.Peers {
-		s.pullFromPeer(p)
+	for _, p := range s.membership.List() {
+		if !s.due(p.Address) {
+			continue
+		}
+		err := s.pullFromPeer(p)
+		if err == nil {
+			err = s.pushToPeer(p)
+		}
+		if err == nil {
+			err = s.pushStates(p)
+		}
+		s.recordAttempt(p.Address, err)
 	}
-	// Push to peers
-	s.pushToPeers()
+	s.compactLog()
 }
 
-func (s *Syncer) pullFromPeer(p Peer) {
+func (s *Syncer) pullFromPeer(p Peer) error {
+	s.mu.Lock()
 	since := s.lastPull[p.Address]
+	s.mu.Unlock()
 	url := fmt.Sprintf("%s/ops?since=%d", p.Address, since)
-	resp, err := s.httpClient.Get(url)
+	req, _ := http.NewRequest(http.MethodGet, url, nil)
+	req.Header.Set("Accept", contentTypeProtobuf+", "+contentTypeJSON)
+	resp, err := s.httpClient.Do(req)
 	if err != nil {
-		return
+		return fmt.Errorf("pull failed: %v", err)
 	}
 	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusGone {
+		// The peer dropped operations this replica has not pulled yet
+		log.Printf("%s truncated operations not pulled yet, bootstrapping from its snapshot", p.Address)
+		return s.bootstrapFromPeer(p)
+	}
 	if resp.StatusCode != http.StatusOK {
-		return
+		return fmt.Errorf("pull returned status %d", resp.StatusCode)
 	}
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return
+		return fmt.Errorf("pull read failed: %v", err)
 	}
-	var batch proto.OperationBatch
-	if err := json.Unmarshal(body, &batch); err != nil {
-		return
+	contentType := resp.Header.Get("Content-Type")
+	batch, err := decodeBatch(body, contentType)
+	if err != nil {
+		return fmt.Errorf("pull decode failed: %v", err)
+	}
+	// A peer only serves its own operations; with authentication the signer must be their origin
+	origin := ""
+	if s.cfg.Auth != nil {
+		origin = resp.Header.Get(HeaderPeer)
 	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// The peer may have been removed while the request was in flight
+	if !s.membership.Contains(p.Address) {
+		return nil
+	}
+	s.protobuf[p.Address] = isProtobuf(contentType)
 	for _, op := range batch.Operations {
 		if op == nil || op.OperationId == "" {
 			continue
@@ -106,32 +219,187 @@ func (s *Syncer) pullFromPeer(p Peer) {
 		if _, ok := s.seen[op.OperationId]; ok {
 			continue
 		}
-		_ = s.srv.HandleOperation(context.Background(), op)
+		if origin != "" && op.ReplicaId != origin {
+			logging.Warnf("Rejected operation %s from %s: replica %q does not match authenticated peer %q", op.OperationId, p.Address, op.ReplicaId, origin)
+		} else if err := s.srv.HandleOperation(context.Background(), op); err != nil {
+			// A rejected op will never apply; retrying it would wedge the link
+			logging.Warnf("Rejected operation %s from %s: %v", op.OperationId, p.Address, err)
+		}
 		s.seen[op.OperationId] = struct{}{}
 		if op.Timestamp > s.lastPull[p.Address] {
 			s.lastPull[p.Address] = op.Timestamp
 		}
 	}
+	return nil
 }
 
-func (s *Syncer) pushToPeers() {
-	// Export ops since lastSent
-	ops, err := s.srv.OpLog().GetOperations(s.lastSent)
-	if err != nil || len(ops) == 0 {
-		return
+// pushToPeer sends operations the peer has not acknowledged yet and advances
+// its watermark only after a successful response
+func (s *Syncer) pushToPeer(p Peer) error {
+	s.mu.Lock()
+	since := s.lastSent[p.Address]
+	binary := s.protobuf[p.Address]
+	s.mu.Unlock()
+
+	ops, err := s.srv.OpLog().GetOperations(since)
+	if err != nil {
+		return fmt.Errorf("failed to read operation log: %v", err)
 	}
-	batch := proto.OperationBatch{Operations: ops}
-	data, _ := json.Marshal(batch)
-	for _, p := range s.cfg.Peers {
-		url := fmt.Sprintf("%s/apply", p.Address)
-		req, _ := http.NewRequest(http.MethodPost, url, bytes.NewReader(data))
-		req.Header.Set("Content-Type", "application/json")
-		resp, err := s.httpClient.Do(req)
-		if err != nil {
+	if len(ops) == 0 {
+		return nil
+	}
+	var maxTS int64
+	for _, op := range ops {
+		if op.Timestamp > maxTS {
+			maxTS = op.Timestamp
+		}
+	}
+
+	data, contentType, err := encodeBatch(&proto.OperationBatch{Operations: ops}, binary)
+	if err != nil {
+		return fmt.Errorf("failed to marshal batch: %v", err)
+	}
+	url := fmt.Sprintf("%s/apply", p.Address)
+	req, _ := http.NewRequest(http.MethodPost, url, bytes.NewReader(data))
+	req.Header.Set("Content-Type", contentType)
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("push failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("push returned status %d", resp.StatusCode)
+	}
+
+	// An empty body acknowledges the whole batch
+	ack := ApplyAck{Watermark: maxTS}
+	if body, err := io.ReadAll(resp.Body); err == nil && len(bytes.TrimSpace(body)) > 0 {
+		if err := json.Unmarshal(body, &ack); err != nil {
+			return fmt.Errorf("invalid apply acknowledgement: %v", err)
+		}
+	}
+
+	s.mu.Lock()
+	if ack.Watermark > s.lastSent[p.Address] && s.membership.Contains(p.Address) {
+		s.lastSent[p.Address] = ack.Watermark
+	}
+	s.mu.Unlock()
+	if ack.Rejected > 0 {
+		log.Printf("Peer %s rejected %d of %d operations", p.Address, ack.Rejected, len(ops))
+	}
+	return nil
+}
+
+// ApplyAck is the /apply response body acknowledging a pushed batch
+type ApplyAck struct {
+	Applied   int   `json:"applied"`
+	Rejected  int   `json:"rejected"`  // ops that failed to apply and will not be retried
+	Watermark int64 `json:"watermark"` // highest op timestamp processed from the batch
+}
+
+// ApplyBatch applies a pushed batch in order and returns the acknowledgement.
+// When ctx carries an authenticated peer, operations originating from any
+// other replica are rejected.
+func ApplyBatch(ctx context.Context, srv *server.Server, batch *proto.OperationBatch) ApplyAck {
+	var ack ApplyAck
+	peer, authenticated := PeerFromContext(ctx)
+	for _, op := range batch.Operations {
+		if op == nil {
 			continue
 		}
-		resp.Body.Close()
+		if authenticated && op.ReplicaId != peer {
+			logging.Warnf("Rejected pushed operation %s: replica %q does not match authenticated peer %q", op.OperationId, op.ReplicaId, peer)
+			ack.Rejected++
+		} else if err := srv.HandleOperation(ctx, op); err != nil {
+			logging.Warnf("Rejected pushed operation %s: %v", op.OperationId, err)
+			ack.Rejected++
+		} else {
+			ack.Applied++
+		}
+		if op.Timestamp > ack.Watermark {
+			ack.Watermark = op.Timestamp
+		}
+	}
+	return ack
+}
+
+// Operation batches are sent as protobuf to peers that ask for it, and as
+// JSON to peers from before protobuf support. Pullers list protobuf in
+// Accept; a peer that answers in protobuf is pushed protobuf too.
+const (
+	contentTypeJSON     = "application/json"
+	contentTypeProtobuf = "application/x-protobuf"
+)
+
+// encodeBatch marshals a batch as protobuf or JSON and returns its content type
+func encodeBatch(batch *proto.OperationBatch, binary bool) ([]byte, string, error) {
+	if binary {
+		data, err := protobuf.Marshal(batch)
+		return data, contentTypeProtobuf, err
 	}
+	data, err := json.Marshal(batch)
+	return data, contentTypeJSON, err
 }
 
-// no-op
+// decodeBatch unmarshals a batch sent with the given content type
+func decodeBatch(data []byte, contentType string) (*proto.OperationBatch, error) {
+	var batch proto.OperationBatch
+	var err error
+	if isProtobuf(contentType) {
+		err = protobuf.Unmarshal(data, &batch)
+	} else {
+		err = json.Unmarshal(data, &batch)
+	}
+	return &batch, err
+}
+
+func isProtobuf(contentType string) bool {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	return mediaType == contentTypeProtobuf
+}
+
+// HandleOps serves /ops?since=<ts> for peers pulling operations. A peer
+// asking from before the log's truncation point gets 410 Gone and must
+// bootstrap from /snapshot instead.
+func HandleOps(srv *server.Server) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var since int64
+		if sinceStr := r.URL.Query().Get("since"); sinceStr != "" {
+			if v, err := strconv.ParseInt(sinceStr, 10, 64); err == nil {
+				since = v
+			}
+		}
+		ops, err := srv.OpLog().OperationsSince(since)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusGone)
+			return
+		}
+		binary := strings.Contains(r.Header.Get("Accept"), contentTypeProtobuf)
+		data, contentType, err := encodeBatch(&proto.OperationBatch{Operations: ops}, binary)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("failed to marshal batch: %v", err), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", contentType)
+		_, _ = w.Write(data)
+	}
+}
+
+// HandleApply serves /apply for peers pushing operations
+func HandleApply(srv *server.Server) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("failed to read batch: %v", err), http.StatusBadRequest)
+			return
+		}
+		batch, err := decodeBatch(body, r.Header.Get("Content-Type"))
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid batch: %v", err), http.StatusBadRequest)
+			return
+		}
+		ack := ApplyBatch(r.Context(), srv, batch)
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(ack)
+	}
+}
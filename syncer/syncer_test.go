@@ -0,0 +1,94 @@
+package syncer
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/luoyjx/crdt-redis/proto"
+)
+
+func TestReplicationNegotiatesProtobuf(t *testing.T) {
+	srvA, srvB := newReplica(t, "a"), newReplica(t, "b")
+
+	var mu sync.Mutex
+	var applied []string
+	mux := http.NewServeMux()
+	mux.Handle("/ops", HandleOps(srvB))
+	mux.HandleFunc("/apply", func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		applied = append(applied, r.Header.Get("Content-Type"))
+		mu.Unlock()
+		HandleApply(srvB)(w, r)
+	})
+	tsB := httptest.NewServer(mux)
+	defer tsB.Close()
+	old := httptest.NewServer((&fakePeer{}).handler())
+	defer old.Close()
+
+	srvA.Set("from-a", "1", nil)
+	srvB.Set("from-b", "2", nil)
+	s := New(Config{Peers: []Peer{{Address: tsB.URL}, {Address: old.URL}}, Interval: time.Second}, srvA)
+	s.replicateOnce()
+
+	if v, _ := srvA.Get("from-b"); v != "2" {
+		t.Errorf("a: from-b = %q, want 2", v)
+	}
+	if v, _ := srvB.Get("from-a"); v != "1" {
+		t.Errorf("b: from-a = %q, want 1", v)
+	}
+	mu.Lock()
+	if len(applied) != 1 || applied[0] != contentTypeProtobuf {
+		t.Errorf("pushes to b were sent as %v, want protobuf", applied)
+	}
+	mu.Unlock()
+
+	// A peer from before protobuf support answers in JSON, and is pushed JSON
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.protobuf[tsB.URL] || s.protobuf[old.URL] {
+		t.Errorf("protobuf peers = %v", s.protobuf)
+	}
+	if st := s.links[old.URL]; st == nil || st.failures != 0 {
+		t.Errorf("link to the JSON peer = %+v", st)
+	}
+}
+
+// BenchmarkBatchEncoding compares the JSON and protobuf encodings of an
+// operation batch. Each run reports the encoded size.
+func BenchmarkBatchEncoding(b *testing.B) {
+	batch := &proto.OperationBatch{}
+	for i := 0; i < 100; i++ {
+		batch.Operations = append(batch.Operations, &proto.Operation{
+			OperationId: fmt.Sprintf("replica-a-%d", 1700000000000000000+i),
+			Timestamp:   int64(1700000000000000000 + i),
+			ReplicaId:   "replica-a",
+			Command:     "HSET",
+			Args:        []string{fmt.Sprintf("user:%d", i), "name", fmt.Sprintf("name-%d", i)},
+			Type:        proto.OperationType_HSET,
+		})
+	}
+	for _, codec := range []struct {
+		name   string
+		binary bool
+	}{{"json", false}, {"protobuf", true}} {
+		data, contentType, _ := encodeBatch(batch, codec.binary)
+		b.Run(codec.name+"/encode", func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				encodeBatch(batch, codec.binary)
+			}
+			b.ReportMetric(float64(len(data)), "bytes")
+		})
+		b.Run(codec.name+"/decode", func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				decodeBatch(data, contentType)
+			}
+			b.ReportMetric(float64(len(data)), "bytes")
+		})
+	}
+}